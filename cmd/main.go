@@ -1,14 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"gohome/internal"
 
 	"tailscale.com/tsnet"
+	// The SQLite driver behind STORE=sqlite, in pure Go so builds need no cgo.
+	_ "modernc.org/sqlite"
 )
 
 var (
@@ -21,6 +26,7 @@ func main() {
 	// Parse command line flags
 	var showVersion = flag.Bool("version", false, "Show version information")
 	var showHelp = flag.Bool("help", false, "Show help information")
+	var dryRun = flag.Bool("dry-run", false, "Print the resolved configuration and tiles, with why any were left out, and exit")
 	flag.Parse()
 
 	if *showVersion {
@@ -32,6 +38,12 @@ func main() {
 		fmt.Printf("GoHome %s - Kubernetes Personal Homepage\n\n", Version)
 		fmt.Println("Usage:")
 		fmt.Println("  gohome [flags]")
+		fmt.Println("  gohome export-html [-o file]")
+		fmt.Println("  gohome links [-o table|json] [-page slug] [-as login]")
+		fmt.Println()
+		fmt.Println("Commands:")
+		fmt.Println("  export-html       Write the homepage as a self-contained static HTML file and exit")
+		fmt.Println("  links             Print the tiles the homepage would show and exit")
 		fmt.Println()
 		fmt.Println("Flags:")
 		flag.PrintDefaults()
@@ -40,6 +52,7 @@ func main() {
 		fmt.Println("  PORT              Server port (default: 8080)")
 		fmt.Println("  NAMESPACE         Kubernetes namespace (default: default)")
 		fmt.Println("  CONFIG_MAP_NAME   ConfigMap name for bookmarks (default: gohome-config)")
+		fmt.Println("  FIXTURES          YAML file or directory of ingresses and ConfigMaps to serve instead of a cluster's")
 		fmt.Println()
 		fmt.Println("For more information, visit: https://github.com/joeds13/gohome")
 		os.Exit(0)
@@ -74,21 +87,34 @@ func main() {
 	// effectively stateless — Tailscale will append a number each redeploy.
 	tsnetStateDir := os.Getenv("TS_STATE_DIR")
 
-	// Initialize Kubernetes client
-	k8sClient, err := internal.NewK8sClient()
-	if err != nil {
-		log.Printf("Warning: Failed to initialize Kubernetes client: %v", err)
-		log.Println("Running in demo mode without Kubernetes integration")
-		k8sClient = nil
-	}
-
-	// Initialize bookmark manager
+	// Initialize Kubernetes client and bookmark manager. FIXTURES serves
+	// the ingresses and ConfigMaps of manifests on disk instead of a
+	// cluster's, for demos, screenshots and end-to-end tests.
+	var k8sClient *internal.K8sClient
 	var bookmarkManager *internal.BookmarkManager
-	if k8sClient != nil {
-		bookmarkManager = internal.NewBookmarkManager(k8sClient.GetClientset(), namespace, configMapName)
-	} else {
-		// Create a nil bookmark manager for demo mode
+	var err error
+	if fixturesPath := os.Getenv("FIXTURES"); fixturesPath != "" {
+		fixtures, err := internal.LoadFixtures(fixturesPath, namespace)
+		if err != nil {
+			log.Fatalf("Failed to load fixtures: %v", err)
+		}
+		k8sClient = internal.NewFixtureClient(fixtures)
 		bookmarkManager = internal.NewBookmarkManager(nil, namespace, configMapName)
+		bookmarkManager.UseFixtures(fixtures)
+	} else {
+		k8sClient, err = internal.NewK8sClient()
+		if err != nil {
+			log.Printf("Warning: Failed to initialize Kubernetes client: %v", err)
+			log.Println("Running in demo mode until Kubernetes becomes reachable")
+			k8sClient = nil
+		}
+
+		if k8sClient != nil {
+			bookmarkManager = internal.NewBookmarkManager(k8sClient.GetClientset().CoreV1(), namespace, configMapName)
+		} else {
+			// Create a nil bookmark manager for demo mode
+			bookmarkManager = internal.NewBookmarkManager(nil, namespace, configMapName)
+		}
 	}
 
 	// Create the server
@@ -97,6 +123,28 @@ func main() {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 
+	// A dry run and the commands stop before Tailscale is started: they
+	// only read from the cluster and print what the homepage would show.
+	switch flag.Arg(0) {
+	case "":
+	case "export-html":
+		exportHTML(server, flag.Args()[1:])
+		return
+	case "links":
+		listLinks(server, flag.Args()[1:])
+		return
+	default:
+		log.Fatalf("Unknown command %q, see --help", flag.Arg(0))
+	}
+	if *dryRun {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := server.DryRun(ctx, os.Stdout); err != nil {
+			log.Fatalf("Dry run failed: %v", err)
+		}
+		return
+	}
+
 	// Log the auth key in redacted form so it's visible in logs without
 	// exposing the full secret. tsnet reads TS_AUTHKEY automatically when
 	// AuthKey is not set on the struct.
@@ -132,6 +180,22 @@ func main() {
 
 	log.Printf("Starting GoHome %s (built %s)...", Version, BuildTime)
 
+	// NOTIFY_INTERVAL controls how often the cluster is polled for added or
+	// removed ingresses when a notifier is configured.
+	notifyInterval := time.Minute
+	if v := os.Getenv("NOTIFY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid NOTIFY_INTERVAL %q: %v", v, err)
+		}
+		notifyInterval = d
+	}
+	go server.WatchForChanges(context.Background(), notifyInterval)
+	go server.RunBackground(context.Background())
+	// Without a client at startup, keep trying and switch to live data
+	// once the API server can be reached.
+	go server.ConnectInBackground(context.Background())
+
 	errCh := make(chan error, 2)
 
 	// Serve on the local HTTP port
@@ -153,3 +217,44 @@ func main() {
 		log.Fatalf("Fatal server error: %v", err)
 	}
 }
+
+// exportHTML runs gohome export-html: it writes the homepage as it is now
+// to a static HTML file, or to stdout with -o -.
+func exportHTML(server *internal.Server, args []string) {
+	fs := flag.NewFlagSet("export-html", flag.ExitOnError)
+	output := fs.String("o", "gohome.html", "File to write, or - for stdout")
+	_ = fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	var page bytes.Buffer
+	if err := server.ExportHTML(ctx, &page); err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+
+	if *output == "-" {
+		_, _ = os.Stdout.Write(page.Bytes())
+		return
+	}
+	if err := os.WriteFile(*output, page.Bytes(), 0o644); err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	log.Printf("Wrote the homepage to %s", *output)
+}
+
+// listLinks runs gohome links: it prints every app, service and bookmark
+// the homepage would show, or only what one visitor may see with -as.
+func listLinks(server *internal.Server, args []string) {
+	fs := flag.NewFlagSet("links", flag.ExitOnError)
+	format := fs.String("o", "table", "Output format, table or json")
+	page := fs.String("page", "", "Only list the tiles of this page, or of a namespace with ns/<namespace>")
+	as := fs.String("as", "", "Only list the tiles this tailnet login may see, or - for a visitor without one")
+	_ = fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	opts := internal.TileListOptions{Format: *format, Page: *page, As: *as}
+	if err := server.ListTiles(ctx, os.Stdout, opts); err != nil {
+		log.Fatalf("Listing links failed: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// alertInterval is how often active alerts are sent. Alertmanager
+	// expects them to be repeated until they resolve.
+	alertInterval = time.Minute
+	// alertLifetime is the endsAt of a firing alert, relative to when it was
+	// sent: if GoHome stops repeating it, Alertmanager resolves it by itself.
+	alertLifetime = 5 * alertInterval
+)
+
+// Alert is an alert in the Alertmanager v2 API format.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// key identifies an alert by its labels, as Alertmanager does.
+func (a Alert) key() string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(a.Labels)) {
+		fmt.Fprintf(&b, "%s=%q,", name, a.Labels[name])
+	}
+	return b.String()
+}
+
+// AlertForwarder posts alerts for targets that are down and certificates
+// close to expiry to Alertmanager or any webhook that takes the same JSON,
+// so they go through existing alert routing.
+type AlertForwarder struct {
+	urls   []string
+	labels map[string]string // ALERT_LABELS, added to every alert
+	client *http.Client
+
+	mu     sync.Mutex
+	active map[string]Alert // firing alerts by key
+}
+
+// NewAlertForwarderFromEnv returns a forwarder posting to ALERTMANAGER_URL
+// (its /api/v2/alerts) and ALERT_WEBHOOK_URL (as is), or nil when neither is
+// set. Like the NOTIFY_* variables these may carry credentials, so they are
+// best set from a Secret.
+func NewAlertForwarderFromEnv() *AlertForwarder {
+	a := &AlertForwarder{
+		labels: make(map[string]string),
+		client: &http.Client{Timeout: 10 * time.Second},
+		active: make(map[string]Alert),
+	}
+	if u := os.Getenv("ALERTMANAGER_URL"); u != "" {
+		a.urls = append(a.urls, strings.TrimSuffix(u, "/")+"/api/v2/alerts")
+	}
+	if u := os.Getenv("ALERT_WEBHOOK_URL"); u != "" {
+		a.urls = append(a.urls, u)
+	}
+	if len(a.urls) == 0 {
+		return nil
+	}
+	for _, pair := range splitList(os.Getenv("ALERT_LABELS")) {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			log.Printf("Warning: Ignoring invalid ALERT_LABELS entry %q, want name=value", pair)
+			continue
+		}
+		a.labels[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	for _, u := range a.urls {
+		log.Printf("Forwarding alerts to %s", redactURL(u))
+	}
+	return a
+}
+
+// redactURL hides the password in u, for the log.
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return parsed.Redacted()
+}
+
+// Job sends the alerts returned by alerts every alertInterval, together with
+// resolved ones for alerts that stopped firing since the last run.
+func (a *AlertForwarder) Job(alerts func(context.Context) []Alert) Job {
+	if a == nil {
+		return Job{}
+	}
+	return Job{
+		Name:     "alerts",
+		Interval: alertInterval,
+		Run:      func(ctx context.Context) error { return a.forward(ctx, alerts(ctx)) },
+	}
+}
+
+func (a *AlertForwarder) forward(ctx context.Context, firing []Alert) error {
+	now := time.Now()
+	a.mu.Lock()
+	previous := a.active
+	a.active = make(map[string]Alert, len(firing))
+	var batch []Alert
+	for _, alert := range firing {
+		maps.Copy(alert.Labels, a.labels)
+		key := alert.key()
+		alert.StartsAt = now
+		if last, ok := previous[key]; ok {
+			alert.StartsAt = last.StartsAt
+		}
+		alert.EndsAt = now.Add(alertLifetime)
+		a.active[key] = alert
+		batch = append(batch, alert)
+	}
+	for key, alert := range previous {
+		if _, ok := a.active[key]; !ok {
+			alert.EndsAt = now
+			batch = append(batch, alert)
+		}
+	}
+	a.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, u := range a.urls {
+		if err := postJSON(ctx, a.client, u, batch); err != nil {
+			log.Printf("Warning: Could not send alerts to %s: %v", redactURL(u), err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// currentAlerts lists an alert for every tile that is down, and every tile
+// whose certificate is within CERT_WARNING_DAYS of expiry.
+func (s *Server) currentAlerts(ctx context.Context) []Alert {
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: alert forwarder failed to list ingresses: %v", err)
+	}
+	var bookmarks []Bookmark
+	if config, err := s.bookmarkManager.GetConfig(ctx); err == nil {
+		bookmarks = config.Bookmarks
+	}
+	s.applyHealth(apps)
+	s.applyHealth(services)
+	s.applyBookmarkHealth(bookmarks)
+	s.applyCerts(apps, services, bookmarks)
+
+	var alerts []Alert
+	for _, row := range buildStatusRows(apps, services, bookmarks, nil) {
+		labels := map[string]string{"name": row.Name, "kind": row.Kind, "url": row.URL, "cluster": clusterName()}
+		if row.Namespace != "" {
+			labels["namespace"] = row.Namespace
+		}
+		if row.Health.State == HealthDown {
+			alert := Alert{Labels: maps.Clone(labels)}
+			alert.Labels["alertname"], alert.Labels["severity"] = "GoHomeTargetDown", "critical"
+			alert.Annotations = map[string]string{
+				"summary":     row.Name + " is down",
+				"description": fmt.Sprintf("%s (%s) %s", row.Name, row.URL, row.Health.Summary()),
+			}
+			alerts = append(alerts, alert)
+		}
+		if row.Cert.Level == CertWarning || row.Cert.Level == CertCritical {
+			alert := Alert{Labels: maps.Clone(labels)}
+			alert.Labels["alertname"], alert.Labels["severity"] = "GoHomeCertificateExpiring", string(row.Cert.Level)
+			alert.Annotations = map[string]string{
+				"summary":     "Certificate for " + row.Name + " expires " + row.Cert.Expiry.Format("2006-01-02"),
+				"description": fmt.Sprintf("The certificate of %s (%s, from %s) expires in %d days", row.Name, row.URL, row.Cert.Source, row.Cert.Days),
+			}
+			if row.Cert.Days < 0 {
+				alert.Annotations["description"] = fmt.Sprintf("The certificate of %s (%s, from %s) has expired", row.Name, row.URL, row.Cert.Source)
+			}
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+)
+
+// validSeverities lists the accepted announcement severities.
+var validSeverities = map[string]bool{"info": true, "warning": true, "critical": true}
+
+// expiryLayouts are the accepted formats for an announcement's expires
+// option. Times without a zone are in clock-timezone.
+var expiryLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// Announcement is one banner from the announcements ConfigMap key.
+type Announcement struct {
+	ID       string // hash of the line, so editing an announcement shows it again to visitors who dismissed it
+	Text     string
+	Severity string    // "info", "warning" or "critical"
+	Expires  time.Time // zero for no expiry
+}
+
+// parseAnnouncements parses the announcements ConfigMap value, one banner
+// per line as "text|severity=warning|expires=2026-10-18T20:00". A date
+// without a time expires at the end of that day. Blank lines and lines
+// starting with # are ignored.
+func parseAnnouncements(value, timezone string) []Announcement {
+	loc := time.Local
+	if timezone != "" {
+		if l, err := time.LoadLocation(timezone); err == nil {
+			loc = l
+		}
+	}
+
+	var announcements []Announcement
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		sum := sha256.Sum256([]byte(line))
+		a := Announcement{
+			ID:       hex.EncodeToString(sum[:])[:12],
+			Text:     strings.TrimSpace(parts[0]),
+			Severity: "info",
+		}
+		for _, opt := range parts[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			v = strings.TrimSpace(v)
+			switch k {
+			case "":
+			case "severity":
+				if validSeverities[v] {
+					a.Severity = v
+				} else {
+					log.Printf("Warning: announcement %q has invalid severity %q, want info, warning or critical", a.Text, v)
+				}
+			case "expires":
+				if t, ok := parseExpiry(v, loc); ok {
+					a.Expires = t
+				} else {
+					log.Printf("Warning: announcement %q has invalid expires %q, want e.g. 2026-10-18T20:00", a.Text, v)
+				}
+			default:
+				log.Printf("Warning: announcement %q has unknown option %q", a.Text, opt)
+			}
+		}
+		if a.Text == "" {
+			continue
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements
+}
+
+// parseExpiry parses an expires option in one of expiryLayouts.
+func parseExpiry(value string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range expiryLayouts {
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// activeAnnouncements returns the announcements that haven't expired by now
+// and that the visitor hasn't dismissed.
+func activeAnnouncements(announcements []Announcement, dismissed map[string]bool, now time.Time) []Announcement {
+	var active []Announcement
+	for _, a := range announcements {
+		if (!a.Expires.IsZero() && !now.Before(a.Expires)) || dismissed[a.ID] {
+			continue
+		}
+		active = append(active, a)
+	}
+	return active
+}
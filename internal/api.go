@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// requireToken wraps a handler so it only runs when the request carries
+// "Authorization: Bearer <API_TOKEN>". If no token is configured the endpoint
+// is disabled and always returns 403.
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.apiToken == "" {
+			writeJSON(w, http.StatusForbidden, map[string]string{
+				"error": "endpoint disabled: API_TOKEN is not set",
+			})
+			return
+		}
+
+		if !s.validToken(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gohome"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{
+				"error": "missing or invalid bearer token",
+			})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// readOnlyEnabled reports whether READ_ONLY=true, for deployments whose
+// ConfigMap is managed by GitOps and must never be changed from the UI.
+func readOnlyEnabled() bool {
+	return os.Getenv("READ_ONLY") == "true"
+}
+
+// requireWritable wraps every mutating endpoint, outside its auth guard, so
+// that with READ_ONLY=true it is refused even with the API token. That
+// includes POST /preferences. GET /click is left open, since tiles must
+// still open, but recordClick doesn't touch the visitor's preferences, and
+// viewerPreferences doesn't store them.
+func (s *Server) requireWritable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly {
+			writeJSON(w, http.StatusForbidden, map[string]string{
+				"error": "endpoint disabled: READ_ONLY is set",
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// validToken reports whether the request carries the configured API token.
+func (s *Server) validToken(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.apiToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.apiToken)) == 1
+}
+
+// requireEditor wraps a handler that changes page layout, such as saving a
+// custom tile order, or that makes GoHome go back to the cluster, such as
+// the degraded banner's refresh. Unlike requireToken it is meant to be
+// called from the page itself, so besides the API token it accepts any
+// same-origin request from an identified tailnet user (the tailnet is
+// already the access boundary), and anyone while changes only live in
+// memory. Sec-Fetch-Site is only a check against forgery: any client can
+// send it, so it is never enough on its own.
+func (s *Server) requireEditor(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.validToken(r) {
+			next(w, r)
+			return
+		}
+
+		// Browsers send Sec-Fetch-Site on every fetch; refusing anything
+		// that isn't same-origin stops other sites from forging requests
+		// with the visitor's tailnet identity.
+		if site := r.Header.Get("Sec-Fetch-Site"); site != "same-origin" {
+			writeJSON(w, http.StatusForbidden, map[string]string{
+				"error": "cross-origin request refused",
+			})
+			return
+		}
+
+		if s.inMemory() || s.resolveViewer(r.Context(), r) != "" {
+			next(w, r)
+			return
+		}
+
+		writeJSON(w, http.StatusForbidden, map[string]string{
+			"error": "this requires a tailnet identity or the API token",
+		})
+	}
+}
+
+// requireViewer wraps a page that shows who changed what, such as the audit
+// log. It is readable with the API token, by identified tailnet users, and
+// by anyone while changes only live in memory.
+func (s *Server) requireViewer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.validToken(r) || s.inMemory() || s.resolveViewer(r.Context(), r) != "" {
+			next(w, r)
+			return
+		}
+		http.Error(w, "This page requires a tailnet identity or the API token", http.StatusForbidden)
+	}
+}
+
+// inMemory reports whether changes only live in memory, in demo mode or
+// with fixtures, so that there is nothing to protect.
+func (s *Server) inMemory() bool {
+	return s.bookmarkManager.client() == nil
+}
+
+// RefreshResult is the JSON body returned by POST /api/v1/refresh.
+type RefreshResult struct {
+	Apps      int      `json:"apps"`
+	Services  int      `json:"services"`
+	Bookmarks int      `json:"bookmarks"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// handleRefresh forces a fresh listing of ingresses and a reload of the
+// ConfigMap, bypassing the cache, so a CI pipeline that has just deployed an
+// app can make it show up immediately and find out straight away if
+// discovery is broken.
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var result RefreshResult
+
+	s.kube().InvalidateCache()
+	s.bookmarkManager.InvalidateCache()
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.Apps = len(apps)
+		result.Services = len(services)
+	}
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.Bookmarks = len(config.Bookmarks)
+	}
+
+	log.Printf("Refresh requested: %d apps, %d services, %d bookmarks", result.Apps, result.Services, result.Bookmarks)
+
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, result)
+}
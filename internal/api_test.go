@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireEditor(t *testing.T) {
+	cluster, _ := newTestBookmarkManager(t, nil)
+	demo := NewBookmarkManager(nil, "gohome", "gohome-config")
+	tests := []struct {
+		name    string
+		bm      *BookmarkManager
+		remote  string
+		headers map[string]string
+		want    int
+	}{
+		{"token", cluster, "192.0.2.7:40000", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+		{"wrong token", cluster, "192.0.2.7:40000", map[string]string{"Authorization": "Bearer guess", "Sec-Fetch-Site": "same-origin"}, http.StatusForbidden},
+		{"page of a tailnet user", cluster, "127.0.0.1:40000", map[string]string{"Sec-Fetch-Site": "same-origin", "Tailscale-User-Login": "alice@example.com"}, http.StatusOK},
+		{"anonymous page", cluster, "127.0.0.1:40000", map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusForbidden},
+		{"spoofed login", cluster, "192.0.2.7:40000", map[string]string{"Sec-Fetch-Site": "same-origin", "Tailscale-User-Login": "alice@example.com"}, http.StatusForbidden},
+		{"other site", cluster, "127.0.0.1:40000", map[string]string{"Sec-Fetch-Site": "cross-site", "Tailscale-User-Login": "alice@example.com"}, http.StatusForbidden},
+		{"no fetch metadata", cluster, "127.0.0.1:40000", map[string]string{"Tailscale-User-Login": "alice@example.com"}, http.StatusForbidden},
+		{"demo mode", demo, "192.0.2.7:40000", map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{bookmarkManager: tt.bm, apiToken: "secret", trustedProxies: defaultTrustedProxies}
+			handler := s.requireEditor(func(w http.ResponseWriter, r *http.Request) {})
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/refresh", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOnlyPreferences(t *testing.T) {
+	for _, readOnly := range []bool{false, true} {
+		s := &Server{readOnly: readOnly, sessions: &Sessions{key: newSessionKey()}}
+
+		r := httptest.NewRequest(http.MethodPost, "/preferences", strings.NewReader(`{"theme":"dark"}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.requireWritable(s.handlePreferences)(w, r)
+		if want := map[bool]int{false: http.StatusNoContent, true: http.StatusForbidden}[readOnly]; w.Code != want {
+			t.Errorf("READ_ONLY=%v: POST /preferences status %d, want %d", readOnly, w.Code, want)
+		}
+
+		w = httptest.NewRecorder()
+		s.recordClick(w, httptest.NewRequest(http.MethodGet, "/click", nil), &Config{Recent: 6}, "ingress:media/jellyfin")
+		if saved := len(w.Result().Cookies()) > 0; saved == readOnly {
+			t.Errorf("READ_ONLY=%v: recently used tiles saved %v", readOnly, saved)
+		}
+	}
+}
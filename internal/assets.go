@@ -0,0 +1,10 @@
+package internal
+
+import _ "embed"
+
+// faviconICO is served at /favicon.ico. Browsers and crawlers request this
+// path regardless of the <link rel="icon"> in the page, so it's embedded in the
+// binary rather than read from static/ to keep it working in any deployment.
+//
+//go:embed assets/favicon.ico
+var faviconICO []byte
@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// storeNamespaceAudit holds AuditRecords keyed by auditKey.
+	storeNamespaceAudit = "audit"
+	// defaultAuditRetention is how many audit records are kept.
+	defaultAuditRetention = 500
+)
+
+// AuditRecord describes one change made through the API or the page.
+// Before and After are the changed value as JSON, so the record reads the
+// same in the log, the store and on /admin/audit.
+type AuditRecord struct {
+	Time   time.Time       `json:"time"`
+	Actor  string          `json:"actor"`  // tailnet login, "api-token", or "anonymous" in demo mode
+	Action string          `json:"action"` // e.g. "order.save"
+	Target string          `json:"target,omitempty"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditLog records every change as a structured "Audit:" line in the log
+// and keeps the most recent ones for /admin/audit, in the data store with
+// AUDIT_STORE=true so they survive restarts.
+type AuditLog struct {
+	store Store // nil unless AUDIT_STORE=true and a store is configured
+	keep  int
+
+	mu      sync.Mutex
+	records []AuditRecord // oldest first
+}
+
+// NewAuditLogFromEnv creates the audit log, keeping AUDIT_RETENTION
+// records and loading earlier ones from the store with AUDIT_STORE=true.
+func NewAuditLogFromEnv() *AuditLog {
+	a := &AuditLog{keep: defaultAuditRetention}
+	if v := os.Getenv("AUDIT_RETENTION"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			a.keep = n
+		} else {
+			log.Printf("Warning: invalid AUDIT_RETENTION %q, using %d", v, a.keep)
+		}
+	}
+	if os.Getenv("AUDIT_STORE") != "true" {
+		return a
+	}
+	if a.store = sharedStore(); a.store == nil {
+		log.Printf("Warning: AUDIT_STORE=true needs STORE or DATA_DIR; audit records are only kept in memory")
+		return a
+	}
+	records, err := listJSON[AuditRecord](context.Background(), a.store, storeNamespaceAudit)
+	if err != nil {
+		log.Printf("Warning: Could not load audit records: %v", err)
+	}
+	a.records = slices.Collect(maps.Values(records))
+	slices.SortFunc(a.records, func(x, y AuditRecord) int { return x.Time.Compare(y.Time) })
+	a.trim(context.Background())
+	return a
+}
+
+// auditKey is the store key of a record; it sorts by time.
+func auditKey(record AuditRecord) string {
+	return fmt.Sprintf("%020d", record.Time.UnixNano())
+}
+
+// Record logs a change actor made to target. before and after are
+// marshalled to JSON; nil leaves them out.
+func (a *AuditLog) Record(ctx context.Context, actor, action, target string, before, after any) {
+	if a == nil {
+		return
+	}
+	record := AuditRecord{Time: time.Now().UTC(), Actor: actor, Action: action, Target: target}
+	record.Before, _ = auditValue(before)
+	record.After, _ = auditValue(after)
+	if line, err := json.Marshal(record); err == nil {
+		log.Printf("Audit: %s", line)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Keys must be unique, so two changes in the same nanosecond are
+	// nudged apart.
+	if n := len(a.records); n > 0 && !record.Time.After(a.records[n-1].Time) {
+		record.Time = a.records[n-1].Time.Add(time.Nanosecond)
+	}
+	a.records = append(a.records, record)
+	if a.store != nil {
+		if err := setJSON(ctx, a.store, storeNamespaceAudit, auditKey(record), record); err != nil {
+			log.Printf("Warning: Could not store audit record: %v", err)
+		}
+	}
+	a.trim(ctx)
+}
+
+// auditValue marshals v, or returns nil for nil, including nil slices and
+// maps.
+func auditValue(v any) (json.RawMessage, error) {
+	raw, err := json.Marshal(v)
+	if err != nil || string(raw) == "null" {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// trim drops the oldest records beyond the retention, from the store too.
+// The caller holds mu, or is the constructor.
+func (a *AuditLog) trim(ctx context.Context) {
+	extra := len(a.records) - a.keep
+	if extra <= 0 {
+		return
+	}
+	if a.store != nil {
+		for _, record := range a.records[:extra] {
+			if err := a.store.Delete(ctx, storeNamespaceAudit, auditKey(record)); err != nil {
+				log.Printf("Warning: Could not delete old audit record: %v", err)
+				break
+			}
+		}
+	}
+	a.records = slices.Clone(a.records[extra:])
+}
+
+// Records returns the kept records, newest first.
+func (a *AuditLog) Records() []AuditRecord {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	records := slices.Clone(a.records)
+	slices.Reverse(records)
+	return records
+}
+
+// auditActor names who is making request r, for the audit log.
+func (s *Server) auditActor(r *http.Request) string {
+	if s.validToken(r) {
+		return "api-token"
+	}
+	if login := s.resolveViewer(r.Context(), r); login != "" {
+		return login
+	}
+	return "anonymous"
+}
+
+// handleAudit renders the audit log at /admin/audit.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	prefs, _ := s.loadPreferences(r)
+	palette := resolvePalette(prefs, config)
+	data := PageData{
+		Config:     config,
+		DemoMode:   s.kube() == nil,
+		Theme:      resolveTheme(prefs, config),
+		Palette:    palette,
+		ThemeColor: themeColor(config, palette),
+		Locale:     localeFor(r, config),
+		Audit:      s.audit.Records(),
+		Now:        time.Now(),
+	}
+
+	data.Nonce = s.setCSP(w)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.templates[data.Locale.Lang].ExecuteTemplate(w, "audit.html", data); err != nil {
+		log.Printf("Error rendering audit template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handleAuditRecords serves the audit log as JSON, newest first.
+func (s *Server) handleAuditRecords(w http.ResponseWriter, r *http.Request) {
+	records := s.audit.Records()
+	if records == nil {
+		records = []AuditRecord{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
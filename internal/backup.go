@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// backupVersion is the Backup format written by GET /api/v1/backup. Restore
+// refuses other versions.
+const backupVersion = 1
+
+// maxBackupBytes is the largest restore body accepted, the size limit of a
+// ConfigMap.
+const maxBackupBytes = 1 << 20
+
+// errNoConfigMap is returned by backup and restore in demo mode.
+var errNoConfigMap = errors.New("no ConfigMap in demo mode")
+
+// Backup is a portable copy of the ConfigMap: bookmarks, layout, theme and
+// every other setting, without the click counts GoHome keeps there.
+type Backup struct {
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	Source  string            `json:"source"` // namespace/name of the ConfigMap it was taken from
+	Data    map[string]string `json:"data"`
+}
+
+// RestoreResult is the JSON body returned by POST /api/v1/restore.
+type RestoreResult struct {
+	Added   []string `json:"added"`
+	Changed []string `json:"changed"`
+	Removed []string `json:"removed"`
+}
+
+// Backup reads the ConfigMap from the API server, bypassing the cache, or
+// from the fixtures.
+func (bm *BookmarkManager) Backup(ctx context.Context) (Backup, error) {
+	configMap := bm.fixtures.configMap(bm.namespace, bm.configMapName)
+	if bm.client() != nil {
+		var err error
+		configMap, err = bm.client().ConfigMaps(bm.namespace).Get(ctx, bm.configMapName, metav1.GetOptions{})
+		if err != nil {
+			return Backup{}, err
+		}
+	} else if configMap == nil {
+		return Backup{}, errNoConfigMap
+	}
+	// An empty ConfigMap still gets a data object, which restore requires.
+	data := make(map[string]string, len(configMap.Data))
+	maps.Copy(data, configMap.Data)
+	delete(data, clickCountsKey)
+	return Backup{
+		Version: backupVersion,
+		Created: time.Now().UTC(),
+		Source:  bm.ConfigMapRef(),
+		Data:    data,
+	}, nil
+}
+
+// Restore replaces the ConfigMap's data with data, keeping the click
+// counts.
+func (bm *BookmarkManager) Restore(ctx context.Context, data map[string]string) (ConfigChange, error) {
+	if bm.client() == nil {
+		return ConfigChange{}, errNoConfigMap
+	}
+	return bm.updateData(ctx, func(current map[string]string) {
+		for key := range current {
+			if _, ok := data[key]; !ok && key != clickCountsKey {
+				delete(current, key)
+			}
+		}
+		maps.Copy(current, data)
+	})
+}
+
+// validateBackup checks that b can be restored.
+func validateBackup(b Backup) error {
+	if b.Version != backupVersion {
+		return fmt.Errorf("unsupported backup version %d, want %d", b.Version, backupVersion)
+	}
+	if b.Data == nil {
+		return errors.New("backup has no data")
+	}
+	if _, ok := b.Data[clickCountsKey]; ok {
+		return fmt.Errorf("backup may not contain %s", clickCountsKey)
+	}
+	for key := range b.Data {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return fmt.Errorf("invalid key %q: %s", key, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// diffData lists the keys added, changed and removed going from before to
+// after, sorted.
+func diffData(before, after map[string]string) RestoreResult {
+	result := RestoreResult{Added: []string{}, Changed: []string{}, Removed: []string{}}
+	for key, value := range after {
+		if old, ok := before[key]; !ok {
+			result.Added = append(result.Added, key)
+		} else if old != value {
+			result.Changed = append(result.Changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			result.Removed = append(result.Removed, key)
+		}
+	}
+	slices.Sort(result.Added)
+	slices.Sort(result.Changed)
+	slices.Sort(result.Removed)
+	return result
+}
+
+// handleBackup serves GET /api/v1/backup as a download.
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	backup, err := s.bookmarkManager.Backup(ctx)
+	if errors.Is(err, errNoConfigMap) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gohome-backup-%s.json"`, backup.Created.Format("20060102-150405")))
+	writeJSON(w, http.StatusOK, backup)
+}
+
+// handleRestore writes a backup from GET /api/v1/backup back to the
+// ConfigMap, replacing everything in it but the click counts, and reports
+// which keys changed. The replaced keys are kept in the audit log and the
+// data it replaced in the revision history.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	var backup Backup
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBackupBytes)).Decode(&backup); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+	if err := validateBackup(backup); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	change, err := s.bookmarkManager.Restore(ctx, backup.Data)
+	if errors.Is(err, errNoConfigMap) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: Could not restore ConfigMap %s: %v", s.bookmarkManager.ConfigMapRef(), err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	actor := s.auditActor(r)
+	result := s.recordConfigChange(ctx, actor, "config.restore", s.bookmarkManager.ConfigMapRef(), change)
+
+	log.Printf("Restored ConfigMap %s from a backup of %s taken %s: %d added, %d changed, %d removed",
+		s.bookmarkManager.ConfigMapRef(), backup.Source, backup.Created.Format(time.RFC3339),
+		len(result.Added), len(result.Changed), len(result.Removed))
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateBackup(t *testing.T) {
+	tests := []struct {
+		name   string
+		backup Backup
+		err    string // "" for valid
+	}{
+		{"valid", Backup{Version: backupVersion, Data: map[string]string{"title": "Home", "bookmark-wiki.docs_1": "x"}}, ""},
+		{"empty", Backup{Version: backupVersion, Data: map[string]string{}}, ""},
+		{"other version", Backup{Version: 2, Data: map[string]string{}}, "unsupported backup version 2"},
+		{"no data", Backup{Version: backupVersion}, "backup has no data"},
+		{"click counts", Backup{Version: backupVersion, Data: map[string]string{clickCountsKey: "{}"}}, "may not contain"},
+		{"invalid key", Backup{Version: backupVersion, Data: map[string]string{"bookmark wiki": "x"}}, `invalid key "bookmark wiki"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBackup(tt.backup)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("got %v, want valid", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDiffData(t *testing.T) {
+	got := diffData(
+		map[string]string{"title": "Home", "theme": "dark", "layout": "grid", "b": "1", "a": "1"},
+		map[string]string{"title": "Lab", "layout": "grid", "d": "1", "c": "1"},
+	)
+	want := RestoreResult{Added: []string{"c", "d"}, Changed: []string{"title"}, Removed: []string{"a", "b", "theme"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := diffData(nil, nil); got.Added == nil || got.Changed == nil || got.Removed == nil {
+		t.Errorf("got %+v, want empty lists for the JSON body", got)
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	bm, clientset := newTestBookmarkManager(t, map[string]string{
+		"title":         "Home",
+		"bookmark-wiki": "https://wiki.example.com",
+		clickCountsKey:  `{"a":1}`,
+	})
+	ctx := context.Background()
+
+	backup, err := bm.Backup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"title": "Home", "bookmark-wiki": "https://wiki.example.com"}; !maps.Equal(backup.Data, want) {
+		t.Errorf("backup has %v, want %v without the click counts", backup.Data, want)
+	}
+	if backup.Version != backupVersion || backup.Source != "gohome/gohome-config" {
+		t.Errorf("backup %+v", backup)
+	}
+
+	change, err := bm.Restore(ctx, map[string]string{"title": "Lab", "theme": "dark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"title": "Lab", "theme": "dark", clickCountsKey: `{"a":1}`}
+	if got := configMapData(t, clientset); !maps.Equal(got, want) {
+		t.Errorf("ConfigMap has %v, want %v", got, want)
+	}
+	if change.Before["title"] != "Home" || change.After["title"] != "Lab" {
+		t.Errorf("change %+v", change)
+	}
+}
+
+func TestBackupAndRestoreInDemoMode(t *testing.T) {
+	bm := NewBookmarkManager(nil, "gohome", "gohome-config")
+	if _, err := bm.Backup(context.Background()); !errors.Is(err, errNoConfigMap) {
+		t.Errorf("backup: %v, want %v", err, errNoConfigMap)
+	}
+	if _, err := bm.Restore(context.Background(), map[string]string{}); !errors.Is(err, errNoConfigMap) {
+		t.Errorf("restore: %v, want %v", err, errNoConfigMap)
+	}
+}
+
+func TestHandleRestore(t *testing.T) {
+	tests := []struct {
+		name, body string
+		status     int
+		data       map[string]string // the ConfigMap afterwards
+	}{
+		{"invalid JSON", `{"version":`, http.StatusBadRequest, map[string]string{"title": "Home", clickCountsKey: "{}"}},
+		{"other version", `{"version":9,"data":{}}`, http.StatusBadRequest, map[string]string{"title": "Home", clickCountsKey: "{}"}},
+		{"restored", `{"version":1,"data":{"title":"Lab","theme":"dark"}}`, http.StatusOK, map[string]string{"title": "Lab", "theme": "dark", clickCountsKey: "{}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bm, clientset := newTestBookmarkManager(t, map[string]string{"title": "Home", clickCountsKey: "{}"})
+			s := &Server{bookmarkManager: bm, revisions: &Revisions{keep: 10}}
+			w := httptest.NewRecorder()
+			s.handleRestore(w, httptest.NewRequest(http.MethodPost, "/api/v1/restore", strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := configMapData(t, clientset); !maps.Equal(got, tt.data) {
+				t.Errorf("ConfigMap has %v, want %v", got, tt.data)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var result RestoreResult
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if want := (RestoreResult{Added: []string{"theme"}, Changed: []string{"title"}, Removed: []string{}}); !reflect.DeepEqual(result, want) {
+				t.Errorf("result %+v, want %+v", result, want)
+			}
+			// What the restore replaced can be rolled back to.
+			if revisions := s.revisions.List(); len(revisions) != 1 || revisions[0].Action != "config.restore" {
+				t.Errorf("revisions %+v", revisions)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultCacheTTL is how long a listing from the API server is served
+// before it is fetched again; CACHE_TTL overrides it and 0 turns caching off.
+const defaultCacheTTL = 15 * time.Second
+
+// cacheStaleFor is how long past its TTL an entry is still served while a
+// fresh copy is fetched in the background. Older entries make the caller
+// wait for the fetch.
+const cacheStaleFor = 5 * time.Minute
+
+// cacheFetchTimeout bounds a fetch, which outlives the request that started
+// it when it refreshes a stale entry in the background.
+const cacheFetchTimeout = 30 * time.Second
+
+// Cache is a small get-or-fetch cache keyed by string. Concurrent fetches
+// of one key are collapsed into a single call, and an expired entry keeps
+// being served while it is refreshed in the background, so page views
+// rarely wait on the API server. A Cache with a TTL of zero fetches on
+// every call but still falls back to the last good value; a nil Cache just
+// calls fetch.
+//
+// With Redis configured (see sharedRedis) successful fetches are shared
+// with the other replicas, so only one of them asks the API server per TTL.
+type Cache[V any] struct {
+	ttl     time.Duration
+	name    string // labels its metrics and namespaces its keys in Redis
+	redis   *RedisClient
+	group   singleflight.Group
+	mu      sync.Mutex
+	entries map[string]cacheEntry[V]
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	ok      bool      // value has been fetched successfully at least once
+	fetched time.Time // when the last fetch finished; zero once invalidated
+	err     error     // result of the last fetch
+}
+
+// sharedCacheEntry is how a cached value is stored in Redis.
+type sharedCacheEntry[V any] struct {
+	Fetched time.Time `json:"fetched"`
+	Value   V         `json:"value"`
+}
+
+// NewCache creates a cache whose entries are fresh for ttl.
+func NewCache[V any](ttl time.Duration) *Cache[V] {
+	return &Cache[V]{ttl: ttl, entries: make(map[string]cacheEntry[V])}
+}
+
+// NewCacheFromEnv creates a cache with the CACHE_TTL time to live, where
+// "0" turns caching off, shared through Redis under name when REDIS_URL is
+// set. V must survive a JSON round trip.
+func NewCacheFromEnv[V any](name string) *Cache[V] {
+	ttl := time.Duration(0)
+	if os.Getenv("CACHE_TTL") != "0" {
+		ttl = durationFromEnv("CACHE_TTL", defaultCacheTTL)
+	}
+	c := NewCache[V](ttl)
+	c.name = name
+	if ttl > 0 {
+		c.redis = sharedRedis()
+	}
+	return c
+}
+
+// Get returns the value cached under key, calling fetch when there is none
+// or it is too old to serve. The error is that of the most recent fetch, so
+// when a refresh fails the last good value is returned together with the
+// error.
+func (c *Cache[V]) Get(ctx context.Context, key string, fetch func(context.Context) (V, error)) (V, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+
+	c.mu.Lock()
+	entry, found := c.entries[key]
+	c.mu.Unlock()
+
+	if found && !entry.fetched.IsZero() && c.ttl > 0 {
+		age := time.Since(entry.fetched)
+		if age < c.ttl {
+			cacheRequests.WithLabelValues(c.name, "hit").Inc()
+			return entry.value, entry.err
+		}
+		if entry.ok && age < c.ttl+cacheStaleFor {
+			cacheRequests.WithLabelValues(c.name, "stale").Inc()
+			go c.fetch(context.WithoutCancel(ctx), key, fetch, true)
+			return entry.value, entry.err
+		}
+	}
+	cacheRequests.WithLabelValues(c.name, "miss").Inc()
+	// An invalidated entry has to come from the source, not another replica.
+	return c.fetch(ctx, key, fetch, !found || !entry.fetched.IsZero())
+}
+
+// fetch calls fetch for key, or waits for a call already in flight, and
+// stores the result. With shared set a fresh enough value another replica
+// put in Redis is used instead of calling fetch. If ctx is done first the
+// last good value is returned with the cause, and the fetch carries on for
+// the next caller.
+func (c *Cache[V]) fetch(ctx context.Context, key string, fetch func(context.Context) (V, error), shared bool) (V, error) {
+	result := c.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheFetchTimeout)
+		defer cancel()
+
+		redisKey := "cache:" + c.name + ":" + key
+		if shared && c.redis != nil {
+			if value, fetched, ok := c.loadShared(ctx, redisKey); ok {
+				cacheFetches.WithLabelValues(c.name, "shared").Inc()
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				c.entries[key] = cacheEntry[V]{value: value, ok: true, fetched: fetched}
+				return value, nil
+			}
+		}
+
+		value, err := fetch(ctx)
+		if err != nil {
+			cacheFetches.WithLabelValues(c.name, "failure").Inc()
+		} else {
+			cacheFetches.WithLabelValues(c.name, "success").Inc()
+		}
+		if err == nil && c.redis != nil {
+			c.storeShared(ctx, redisKey, value)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		entry := c.entries[key]
+		entry.fetched = time.Now()
+		entry.err = err
+		if err == nil {
+			entry.value, entry.ok = value, true
+		}
+		c.entries[key] = entry
+		return entry.value, err
+	})
+	select {
+	case r := <-result:
+		return r.Val.(V), r.Err
+	case <-ctx.Done():
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.entries[key].value, fmt.Errorf("gave up waiting for %s: %w", c.name, context.Cause(ctx))
+	}
+}
+
+// loadShared returns the value stored in Redis under redisKey if it is
+// younger than the TTL.
+func (c *Cache[V]) loadShared(ctx context.Context, redisKey string) (V, time.Time, bool) {
+	var shared sharedCacheEntry[V]
+	raw, ok, err := c.redis.Get(ctx, redisKey)
+	if err != nil {
+		log.Printf("Warning: Could not read %s from Redis: %v", redisKey, err)
+		return shared.Value, time.Time{}, false
+	}
+	if !ok {
+		return shared.Value, time.Time{}, false
+	}
+	if err := json.Unmarshal([]byte(raw), &shared); err != nil {
+		log.Printf("Warning: Ignoring invalid %s in Redis: %v", redisKey, err)
+		return shared.Value, time.Time{}, false
+	}
+	return shared.Value, shared.Fetched, time.Since(shared.Fetched) < c.ttl
+}
+
+// storeShared puts a freshly fetched value in Redis for the other replicas.
+func (c *Cache[V]) storeShared(ctx context.Context, redisKey string, value V) {
+	raw, err := json.Marshal(sharedCacheEntry[V]{Fetched: time.Now(), Value: value})
+	if err == nil {
+		err = c.redis.Set(ctx, redisKey, string(raw), c.ttl+cacheStaleFor)
+	}
+	if err != nil {
+		log.Printf("Warning: Could not write %s to Redis: %v", redisKey, err)
+	}
+}
+
+// Seed gives key a value to fall back on, such as a snapshot saved before a
+// restart, if it has none. The value isn't fresh: the next Get still
+// fetches, and only returns it if that fails.
+func (c *Cache[V]) Seed(key string, value V) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = cacheEntry[V]{value: value, ok: true}
+	}
+}
+
+// Invalidate makes the next Get for key wait for a fresh fetch. The old
+// value is kept to fall back on if that fetch fails.
+func (c *Cache[V]) Invalidate(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		entry.fetched = time.Time{}
+		c.entries[key] = entry
+	}
+}
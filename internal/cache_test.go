@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedFetch returns a fetch that answers call n with "v<n>", or with the
+// nth error of errs when that isn't nil, and counts its calls.
+func scriptedFetch(errs ...error) (func(context.Context) (string, error), *atomic.Int32) {
+	var calls atomic.Int32
+	return func(context.Context) (string, error) {
+		n := int(calls.Add(1))
+		if n <= len(errs) && errs[n-1] != nil {
+			return "", errs[n-1]
+		}
+		return fmt.Sprintf("v%d", n), nil
+	}, &calls
+}
+
+func TestCacheGet(t *testing.T) {
+	errDown := errors.New("API server down")
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		errs    []error
+		steps   func(c *Cache[string], get func() (string, error))
+		want    string
+		wantErr bool
+		calls   int32
+	}{
+		{
+			name:  "fresh entry served",
+			ttl:   time.Hour,
+			steps: func(c *Cache[string], get func() (string, error)) { get() },
+			want:  "v1", calls: 1,
+		},
+		{
+			name:  "no TTL fetches every time",
+			steps: func(c *Cache[string], get func() (string, error)) { get() },
+			want:  "v2", calls: 2,
+		},
+		{
+			name: "invalidated entry fetched again",
+			ttl:  time.Hour,
+			steps: func(c *Cache[string], get func() (string, error)) {
+				get()
+				c.Invalidate("key")
+			},
+			want: "v2", calls: 2,
+		},
+		{
+			name:  "invalidating an unknown key does nothing",
+			ttl:   time.Hour,
+			steps: func(c *Cache[string], get func() (string, error)) { c.Invalidate("other"); get() },
+			want:  "v1", calls: 1,
+		},
+		{
+			name:  "failure falls back on the last good value",
+			errs:  []error{nil, errDown},
+			steps: func(c *Cache[string], get func() (string, error)) { get() },
+			want:  "v1", wantErr: true, calls: 2,
+		},
+		{
+			name: "failure after invalidation falls back too",
+			ttl:  time.Hour,
+			errs: []error{nil, errDown},
+			steps: func(c *Cache[string], get func() (string, error)) {
+				get()
+				c.Invalidate("key")
+			},
+			want: "v1", wantErr: true, calls: 2,
+		},
+		{
+			name:  "seed only served when the fetch fails",
+			ttl:   time.Hour,
+			errs:  []error{errDown},
+			steps: func(c *Cache[string], get func() (string, error)) { c.Seed("key", "snapshot") },
+			want:  "snapshot", wantErr: true, calls: 1,
+		},
+		{
+			name:  "seed replaced by a fetch",
+			ttl:   time.Hour,
+			steps: func(c *Cache[string], get func() (string, error)) { c.Seed("key", "snapshot") },
+			want:  "v1", calls: 1,
+		},
+		{
+			name: "seed doesn't replace a value",
+			ttl:  time.Hour,
+			steps: func(c *Cache[string], get func() (string, error)) {
+				get()
+				c.Seed("key", "snapshot")
+			},
+			want: "v1", calls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCache[string](tt.ttl)
+			fetch, calls := scriptedFetch(tt.errs...)
+			get := func() (string, error) { return c.Get(context.Background(), "key", fetch) }
+			tt.steps(c, get)
+			got, err := get()
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("got %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+			if n := calls.Load(); n != tt.calls {
+				t.Errorf("%d fetches, want %d", n, tt.calls)
+			}
+		})
+	}
+}
+
+func TestNilCacheFetches(t *testing.T) {
+	var c *Cache[string]
+	fetch, calls := scriptedFetch()
+	c.Seed("key", "snapshot")
+	c.Invalidate("key")
+	for range 2 {
+		c.Get(context.Background(), "key", fetch)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%d fetches, want 2", n)
+	}
+}
+
+func TestCacheCollapsesConcurrentFetches(t *testing.T) {
+	c := NewCache[string](time.Hour)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	fetch := func(context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan string, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := c.Get(context.Background(), "key", fetch)
+			results <- v
+		}()
+	}
+	// Let every caller reach the fetch in flight before it finishes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d fetches, want 1", n)
+	}
+	for v := range results {
+		if v != "value" {
+			t.Errorf("got %q", v)
+		}
+	}
+}
+
+func TestCacheServesStaleWhileRefreshing(t *testing.T) {
+	c := NewCache[string](time.Millisecond)
+	refreshed := make(chan struct{})
+	var calls atomic.Int32
+	fetch := func(context.Context) (string, error) {
+		n := calls.Add(1)
+		if n == 2 {
+			defer close(refreshed)
+		}
+		return fmt.Sprintf("v%d", n), nil
+	}
+	c.Get(context.Background(), "key", fetch)
+	time.Sleep(5 * time.Millisecond)
+
+	if got, err := c.Get(context.Background(), "key", fetch); got != "v1" || err != nil {
+		t.Fatalf("stale get: %q, %v; want v1 without waiting", got, err)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale entry not refreshed in the background")
+	}
+}
+
+func TestCacheGivesUpWaiting(t *testing.T) {
+	c := NewCache[string](0)
+	c.Seed("key", "snapshot")
+	release := make(chan struct{})
+	defer close(release)
+	fetch := func(context.Context) (string, error) {
+		<-release
+		return "value", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err := c.Get(ctx, "key", fetch)
+	if got != "snapshot" || !errors.Is(err, context.Canceled) {
+		t.Errorf("got %q, %v; want the snapshot and the cancellation", got, err)
+	}
+}
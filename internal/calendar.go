@@ -0,0 +1,352 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// calendarKeyPrefix marks ConfigMap keys that define a calendar:
+	//   calendar-<name>: "https://example.com/basic.ics|days=7"
+	calendarKeyPrefix = "calendar-"
+	// defaultCalendarDays is how many days ahead the agenda shows, today included.
+	defaultCalendarDays = 7
+	// defaultCalendarInterval is how often every calendar is refetched.
+	defaultCalendarInterval = 15 * time.Minute
+	// maxCalendarBytes caps the size of a fetched iCalendar document.
+	maxCalendarBytes = 5 << 20
+)
+
+// CalendarConfig is one calendar from the ConfigMap. The URL may instead come
+// from CALENDAR_<NAME>_URL, and basic auth credentials always do (see
+// calendarEnv), so private calendars can be configured from a Secret.
+type CalendarConfig struct {
+	Name     string
+	URL      string
+	Days     int
+	Timezone string // clock-timezone, used for floating times and day boundaries
+}
+
+// CalendarEvent is one occurrence of an event on the agenda.
+type CalendarEvent struct {
+	Summary  string
+	Location string
+	Start    time.Time
+	End      time.Time
+	AllDay   bool
+	Calendar string // name of the calendar it came from
+}
+
+// CalendarDay is the events starting (or continuing) on one day.
+type CalendarDay struct {
+	Label  string // "Today", "Tomorrow" or the date, in the visitor's language
+	Date   time.Time
+	Events []CalendarEvent
+}
+
+// CalendarAgenda is the calendar widget as rendered on the homepage.
+type CalendarAgenda struct {
+	Days   []CalendarDay
+	Errors []string // "<name>: <error>" for calendars whose last fetch failed
+}
+
+// calendarState is what the aggregator remembers about one calendar.
+type calendarState struct {
+	url       string
+	events    []icalEvent
+	err       error
+	fetched   time.Time // last success
+	attempted time.Time // last attempt, successful or not
+}
+
+// CalendarAggregator periodically fetches the configured iCalendar feeds in
+// the background and caches their parsed events. Recurrences are expanded
+// at render time so the agenda always starts from today.
+type CalendarAggregator struct {
+	client    *http.Client
+	interval  time.Duration
+	mu        sync.Mutex
+	calendars map[string]calendarState // keyed by calendar name
+}
+
+// NewCalendarAggregatorFromEnv creates an aggregator that refetches every
+// CALENDAR_INTERVAL.
+func NewCalendarAggregatorFromEnv() *CalendarAggregator {
+	return &CalendarAggregator{
+		client:    &http.Client{Timeout: 15 * time.Second},
+		interval:  durationFromEnv("CALENDAR_INTERVAL", defaultCalendarInterval),
+		calendars: make(map[string]calendarState),
+	}
+}
+
+// calendarEnv returns the CALENDAR_<NAME>_<suffix> variable for a calendar,
+// with the name upper-cased and dashes turned into underscores.
+func calendarEnv(name, suffix string) string {
+	key := "CALENDAR_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + suffix
+	return os.Getenv(key)
+}
+
+// parseCalendarEntry parses a calendar-<name> ConfigMap value: the URL
+// followed by optional |key=value settings. webcal:// links are fetched over
+// HTTPS.
+func parseCalendarEntry(key, value string) (CalendarConfig, bool) {
+	parts := strings.Split(value, "|")
+	cal := CalendarConfig{
+		Name: strings.TrimPrefix(key, calendarKeyPrefix),
+		URL:  strings.TrimSpace(parts[0]),
+		Days: defaultCalendarDays,
+	}
+	if u := calendarEnv(cal.Name, "URL"); u != "" {
+		cal.URL = u
+	}
+	if rest, ok := strings.CutPrefix(cal.URL, "webcal://"); ok {
+		cal.URL = "https://" + rest
+	}
+	if cal.URL == "" {
+		log.Printf("Warning: calendar %s has no URL, skipping", cal.Name)
+		return cal, false
+	}
+	for _, opt := range parts[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch k {
+		case "":
+		case "days":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 31 {
+				cal.Days = n
+			} else {
+				log.Printf("Warning: calendar %s has invalid days %q", cal.Name, v)
+			}
+		default:
+			log.Printf("Warning: calendar %s has unknown option %q", cal.Name, opt)
+		}
+	}
+	return cal, true
+}
+
+// parseCalendars reads every calendar-* key from ConfigMap data, sorted by
+// name.
+func parseCalendars(data map[string]string) []CalendarConfig {
+	var calendars []CalendarConfig
+	for key, value := range data {
+		if !strings.HasPrefix(key, calendarKeyPrefix) {
+			continue
+		}
+		if cal, ok := parseCalendarEntry(key, value); ok {
+			cal.Timezone = data["clock-timezone"]
+			calendars = append(calendars, cal)
+		}
+	}
+	slices.SortFunc(calendars, func(a, b CalendarConfig) int { return strings.Compare(a.Name, b.Name) })
+	return calendars
+}
+
+// location returns the calendar's timezone, falling back to the server's.
+func (c CalendarConfig) location() *time.Location {
+	if c.Timezone != "" {
+		if loc, err := time.LoadLocation(c.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// Name names the calendar widget.
+func (a *CalendarAggregator) Name() string { return "calendars" }
+
+// Interval is a minute at most, so the configuration is re-read every
+// minute even with a longer CALENDAR_INTERVAL.
+func (a *CalendarAggregator) Interval() time.Duration { return min(a.interval, time.Minute) }
+
+// Refresh refetches the configured calendars that are due.
+func (a *CalendarAggregator) Refresh(ctx context.Context, config *Config) error {
+	return a.fetchAll(ctx, config.Calendars)
+}
+
+// Render returns the Agenda of the configured calendars.
+func (a *CalendarAggregator) Render(config *Config, locale *Locale) any {
+	return a.Agenda(config.Calendars, time.Now(), locale)
+}
+
+// fetchAll fetches every calendar that is due, or whose URL changed,
+// concurrently and records the results, returning the errors of those that
+// failed.
+func (a *CalendarAggregator) fetchAll(ctx context.Context, calendars []CalendarConfig) error {
+	var errs []error
+	var wg sync.WaitGroup
+	for _, cal := range calendars {
+		a.mu.Lock()
+		state := a.calendars[cal.Name]
+		due := state.url != cal.URL || time.Since(state.attempted) >= a.interval
+		a.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		wg.Go(func() {
+			fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			events, err := a.fetch(fetchCtx, cal)
+			cancel()
+			if err != nil {
+				log.Printf("Warning: Could not fetch calendar %s: %v", cal.Name, err)
+			}
+
+			a.mu.Lock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", cal.Name, err))
+			}
+			state := a.calendars[cal.Name]
+			if state.url != cal.URL {
+				state = calendarState{url: cal.URL}
+			}
+			state.err = err
+			state.attempted = time.Now()
+			if err == nil {
+				state.events = events
+				state.fetched = time.Now()
+			}
+			a.calendars[cal.Name] = state
+			a.mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name := range a.calendars {
+		if !slices.ContainsFunc(calendars, func(c CalendarConfig) bool { return c.Name == name }) {
+			delete(a.calendars, name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetch downloads and parses one calendar, with basic auth from
+// CALENDAR_<NAME>_USERNAME and CALENDAR_<NAME>_PASSWORD if set.
+func (a *CalendarAggregator) fetch(ctx context.Context, cal CalendarConfig) ([]icalEvent, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cal.URL, nil)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	req.Header.Set("User-Agent", "gohome-calendar")
+	req.Header.Set("Accept", "text/calendar, */*;q=0.8")
+	if user := calendarEnv(cal.Name, "USERNAME"); user != "" {
+		req.SetBasicAuth(user, calendarEnv(cal.Name, "PASSWORD"))
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		// Private calendar URLs often embed a token; keep them out of logs.
+		return nil, redactURLError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return parseICal(io.LimitReader(resp.Body, maxCalendarBytes), cal.location())
+}
+
+// Agenda returns the upcoming events across all calendars, from the start
+// of today for each calendar's number of days, or nil if no calendar has
+// been fetched yet. Days are labelled in locale's language.
+func (a *CalendarAggregator) Agenda(calendars []CalendarConfig, now time.Time, locale *Locale) *CalendarAgenda {
+	if a == nil || len(calendars) == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	loc := calendars[0].location()
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	agenda := &CalendarAgenda{}
+	var events []CalendarEvent
+	fetched := false
+	days := 0
+	for _, cal := range calendars {
+		state, ok := a.calendars[cal.Name]
+		if !ok {
+			continue
+		}
+		fetched = true
+		days = max(days, cal.Days)
+		if state.err != nil {
+			agenda.Errors = append(agenda.Errors, cal.Name+": "+state.err.Error())
+		}
+		end := today.AddDate(0, 0, cal.Days)
+		for _, e := range state.events {
+			duration := e.End.Sub(e.Start)
+			for _, start := range e.occurrences(today, end) {
+				events = append(events, CalendarEvent{
+					Summary:  e.Summary,
+					Location: e.Location,
+					Start:    start.In(loc),
+					End:      start.Add(duration).In(loc),
+					AllDay:   e.AllDay,
+					Calendar: cal.Name,
+				})
+			}
+		}
+	}
+	if !fetched {
+		return nil
+	}
+
+	// All-day events first, then by start time.
+	slices.SortStableFunc(events, func(a, b CalendarEvent) int {
+		if a.AllDay != b.AllDay {
+			if a.AllDay {
+				return -1
+			}
+			return 1
+		}
+		return a.Start.Compare(b.Start)
+	})
+
+	for i := range days {
+		date := today.AddDate(0, 0, i)
+		next := date.AddDate(0, 0, 1)
+		day := CalendarDay{Label: dayLabel(i, date, locale), Date: date}
+		for _, e := range events {
+			// Multi-day events repeat on each day they cover.
+			if e.Start.Before(next) && (e.End.After(date) || !e.Start.Before(date)) {
+				day.Events = append(day.Events, e)
+			}
+		}
+		if len(day.Events) > 0 {
+			agenda.Days = append(agenda.Days, day)
+		}
+	}
+	return agenda
+}
+
+// dayLabel names day number i of the agenda.
+func dayLabel(i int, date time.Time, locale *Locale) string {
+	switch i {
+	case 0:
+		return locale.T("calendar.today")
+	case 1:
+		return locale.T("calendar.tomorrow")
+	}
+	return locale.Date(date)
+}
+
+// Len returns the number of calendars with cached state.
+func (a *CalendarAggregator) Len() int {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.calendars)
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCalendarEntry(t *testing.T) {
+	t.Setenv("CALENDAR_ON_CALL_URL", "https://calendar.example.com/private/token.ics")
+	tests := []struct {
+		key, value string
+		ok         bool
+		url        string
+		days       int
+	}{
+		{"calendar-family", "https://calendar.example.com/family.ics", true, "https://calendar.example.com/family.ics", defaultCalendarDays},
+		{"calendar-family", "webcal://calendar.example.com/family.ics|days=14", true, "https://calendar.example.com/family.ics", 14},
+		{"calendar-family", "https://calendar.example.com/family.ics|days=90", true, "https://calendar.example.com/family.ics", defaultCalendarDays},
+		{"calendar-family", "https://calendar.example.com/family.ics|days=none|colour=red", true, "https://calendar.example.com/family.ics", defaultCalendarDays},
+		{"calendar-on-call", "|days=3", true, "https://calendar.example.com/private/token.ics", 3},
+		{"calendar-family", "|days=3", false, "", 0},
+	}
+	for _, tt := range tests {
+		cal, ok := parseCalendarEntry(tt.key, tt.value)
+		if ok != tt.ok {
+			t.Errorf("%s: %q: ok = %v, want %v", tt.key, tt.value, ok, tt.ok)
+			continue
+		}
+		if ok && (cal.Name != strings.TrimPrefix(tt.key, calendarKeyPrefix) || cal.URL != tt.url || cal.Days != tt.days) {
+			t.Errorf("%s: %q: got %+v, want %s for %d days", tt.key, tt.value, cal, tt.url, tt.days)
+		}
+	}
+}
+
+func TestCalendarAgenda(t *testing.T) {
+	t.Setenv("CALENDAR_WORK_USERNAME", "gohome")
+	t.Setenv("CALENDAR_WORK_PASSWORD", "secret")
+	docs := map[string]string{
+		"/family.ics": "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Dinner\nDTSTART:20261014T190000\nDTEND:20261014T210000\nEND:VEVENT\n" +
+			"BEGIN:VEVENT\nSUMMARY:Holiday\nDTSTART;VALUE=DATE:20261014\nDTEND;VALUE=DATE:20261016\nEND:VEVENT\n" +
+			"BEGIN:VEVENT\nSUMMARY:Next month\nDTSTART:20261114T100000\nEND:VEVENT\nEND:VCALENDAR\n",
+		"/work.ics": "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Standup\nDTSTART:20261001T093000\nDTEND:20261001T094500\nRRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR\nEND:VEVENT\nEND:VCALENDAR\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/work.ics" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "gohome" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(doc))
+	}))
+	defer srv.Close()
+
+	calendars := parseCalendars(map[string]string{
+		"calendar-family": srv.URL + "/family.ics|days=2",
+		"calendar-work":   srv.URL + "/work.ics|days=2",
+		"calendar-broken": srv.URL + "/missing.ics",
+		"clock-timezone":  "UTC",
+	})
+	a := NewCalendarAggregatorFromEnv()
+	if a.Agenda(calendars, time.Now(), locales[defaultLanguage]) != nil {
+		t.Error("agenda before any fetch")
+	}
+	if err := a.fetchAll(context.Background(), calendars); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("fetch error %v, want the broken calendar's", err)
+	}
+
+	now := time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC) // a Wednesday
+	agenda := a.Agenda(calendars, now, locales[defaultLanguage])
+	if agenda == nil {
+		t.Fatal("no agenda")
+	}
+	if len(agenda.Errors) != 1 || !strings.HasPrefix(agenda.Errors[0], "broken: ") {
+		t.Errorf("errors %q", agenda.Errors)
+	}
+
+	var got []string
+	for _, day := range agenda.Days {
+		var summaries []string
+		for _, e := range day.Events {
+			summaries = append(summaries, e.Summary+"@"+e.Calendar)
+		}
+		got = append(got, day.Label+": "+strings.Join(summaries, ", "))
+	}
+	// The agenda runs for the broken calendar's seven days, but the others
+	// only look two days ahead, so the empty days are left out.
+	want := []string{
+		"Today: Holiday@family, Standup@work, Dinner@family",
+		"Tomorrow: Holiday@family, Standup@work",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("agenda\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Calendars taken out of the ConfigMap are forgotten.
+	if err := a.fetchAll(context.Background(), calendars[1:2]); err != nil {
+		t.Error(err)
+	}
+	if a.Len() != 1 {
+		t.Errorf("%d calendars kept, want 1", a.Len())
+	}
+}
@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultCapacityInterval is how often nodes and pods are re-listed.
+	defaultCapacityInterval = 5 * time.Minute
+	// capacityPageSize is how many pods are listed per request, so large
+	// clusters don't come back in one response.
+	capacityPageSize = 500
+	// capacityWarningPercent and capacityCriticalPercent color a bar amber
+	// and red.
+	capacityWarningPercent  = 80
+	capacityCriticalPercent = 90
+)
+
+// ClusterCapacity is what the pods scheduled on schedulable nodes request
+// against what those nodes can allocate.
+type ClusterCapacity struct {
+	Nodes                              int
+	CPURequested, CPUAllocatable       int64 // millicores
+	MemoryRequested, MemoryAllocatable int64 // bytes
+	PodsRequested, PodsAllocatable     int64
+}
+
+// CapacityBar is one resource as rendered on the homepage.
+type CapacityBar struct {
+	Label   string // e.g. "capacity.cpu"
+	Percent int    // requested as a share of allocatable, capped at 100 for the bar's width
+	Level   string // "", "warning" or "critical"
+	Used    string // e.g. "3.2 / 8 cores"
+}
+
+// CapacityPanel is the cluster capacity widget.
+type CapacityPanel struct {
+	Nodes   int
+	Bars    []CapacityBar
+	Err     string // set when the most recent listing failed
+	Fetched time.Time
+}
+
+// CapacityMonitor adds up resource requests against allocatable capacity
+// across the cluster in the background, so the homepage never lists pods.
+type CapacityMonitor struct {
+	k8s      func() *K8sClient
+	interval time.Duration
+	capacity widgetCache[ClusterCapacity] // under capacityKey
+}
+
+// capacityKey is the only key of CapacityMonitor.capacity.
+const capacityKey = "cluster"
+
+// NewCapacityMonitorFromEnv creates a monitor that re-lists nodes and pods
+// every CAPACITY_INTERVAL, or returns nil unless CLUSTER_CAPACITY=true. k8s
+// returns the current client.
+func NewCapacityMonitorFromEnv(k8s func() *K8sClient) *CapacityMonitor {
+	if os.Getenv("CLUSTER_CAPACITY") != "true" {
+		return nil
+	}
+	return &CapacityMonitor{
+		k8s:      k8s,
+		interval: durationFromEnv("CAPACITY_INTERVAL", defaultCapacityInterval),
+	}
+}
+
+// Name names the cluster capacity widget.
+func (m *CapacityMonitor) Name() string { return "capacity" }
+
+// Interval is CAPACITY_INTERVAL.
+func (m *CapacityMonitor) Interval() time.Duration { return m.interval }
+
+// Refresh re-reads the cluster's capacity. In demo mode there is none.
+func (m *CapacityMonitor) Refresh(ctx context.Context, _ *Config) error {
+	k := m.k8s()
+	if k == nil {
+		return nil
+	}
+	return m.capacity.refresh(ctx, time.Minute, []widgetFetch[ClusterCapacity]{{
+		key:   capacityKey,
+		name:  "cluster capacity",
+		every: m.interval,
+		fetch: k.GetClusterCapacity,
+	}})
+}
+
+// Render returns the Panel.
+func (m *CapacityMonitor) Render(*Config, *Locale) any {
+	return m.Panel()
+}
+
+// Panel returns the cached capacity, or nil before the first listing
+// finished.
+func (m *CapacityMonitor) Panel() *CapacityPanel {
+	if m == nil {
+		return nil
+	}
+	state, ok := m.capacity.get(capacityKey)
+	if !ok {
+		return nil
+	}
+
+	c := state.value
+	panel := &CapacityPanel{Nodes: c.Nodes, Err: state.errString(), Fetched: state.fetched}
+	if !state.fetched.IsZero() {
+		panel.Bars = []CapacityBar{
+			capacityBar("capacity.cpu", c.CPURequested, c.CPUAllocatable,
+				formatStat(float64(c.CPURequested)/1000, -1, "")+" / "+formatStat(float64(c.CPUAllocatable)/1000, -1, "cores")),
+			capacityBar("capacity.memory", c.MemoryRequested, c.MemoryAllocatable,
+				formatGiB(c.MemoryRequested)+" / "+formatGiB(c.MemoryAllocatable)+" GiB"),
+			capacityBar("capacity.pods", c.PodsRequested, c.PodsAllocatable,
+				formatStat(float64(c.PodsRequested), 0, "")+" / "+formatStat(float64(c.PodsAllocatable), 0, "")),
+		}
+	}
+	return panel
+}
+
+// Len returns 1 once the capacity has been read, else 0.
+func (m *CapacityMonitor) Len() int {
+	if m == nil {
+		return 0
+	}
+	if state, ok := m.capacity.get(capacityKey); !ok || state.fetched.IsZero() {
+		return 0
+	}
+	return 1
+}
+
+// capacityBar rates requested against allocatable.
+func capacityBar(label string, requested, allocatable int64, used string) CapacityBar {
+	bar := CapacityBar{Label: label, Used: used}
+	if allocatable > 0 {
+		bar.Percent = int(requested * 100 / allocatable)
+	}
+	switch {
+	case bar.Percent >= capacityCriticalPercent:
+		bar.Level = "critical"
+	case bar.Percent >= capacityWarningPercent:
+		bar.Level = "warning"
+	}
+	bar.Percent = min(bar.Percent, 100)
+	return bar
+}
+
+// formatGiB formats a number of bytes in GiB without the unit.
+func formatGiB(bytes int64) string {
+	return formatStat(float64(bytes)/(1<<30), -1, "")
+}
+
+// GetClusterCapacity adds up the allocatable CPU, memory and pods of every
+// schedulable node and the requests of the pods running or pending on
+// them. Cordoned nodes are left out on both sides, as nothing new lands
+// there. It returns an error in demo mode.
+func (k *K8sClient) GetClusterCapacity(ctx context.Context) (ClusterCapacity, error) {
+	var c ClusterCapacity
+	if k == nil || k.clientset == nil {
+		return c, errors.New("kubernetes client not available")
+	}
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return c, err
+	}
+	schedulable := make(map[string]bool, len(nodes.Items))
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		schedulable[node.Name] = true
+		c.Nodes++
+		c.CPUAllocatable += node.Status.Allocatable.Cpu().MilliValue()
+		c.MemoryAllocatable += node.Status.Allocatable.Memory().Value()
+		c.PodsAllocatable += node.Status.Allocatable.Pods().Value()
+	}
+
+	opts := metav1.ListOptions{
+		// Finished pods keep their spec but no longer hold their requests.
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+		Limit:         capacityPageSize,
+	}
+	for {
+		pods, err := k.clientset.CoreV1().Pods("").List(ctx, opts)
+		if err != nil {
+			return c, err
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if !schedulable[pod.Spec.NodeName] {
+				continue
+			}
+			requests := podRequests(pod)
+			c.CPURequested += requests.Cpu().MilliValue()
+			c.MemoryRequested += requests.Memory().Value()
+			c.PodsRequested++
+		}
+		if pods.Continue == "" {
+			return c, nil
+		}
+		opts.Continue = pods.Continue
+	}
+}
+
+// podRequests returns what the scheduler reserves for pod: its containers
+// and sidecars, or the largest init container if that asks for more while
+// running next to the sidecars started before it, plus the pod overhead.
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	sidecars := corev1.ResourceList{}
+	initPeak := corev1.ResourceList{}
+	for _, container := range pod.Spec.InitContainers {
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			addResources(sidecars, container.Resources.Requests)
+			continue
+		}
+		running := sidecars.DeepCopy()
+		addResources(running, container.Resources.Requests)
+		maxResources(initPeak, running)
+	}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	addResources(requests, sidecars)
+	maxResources(requests, initPeak)
+	addResources(requests, pod.Spec.Overhead)
+	return requests
+}
+
+// addResources adds every quantity in add to list.
+func addResources(list, add corev1.ResourceList) {
+	for name, q := range add {
+		sum := list[name]
+		sum.Add(q)
+		list[name] = sum
+	}
+}
+
+// maxResources raises every quantity in list to at least the one in other.
+func maxResources(list, other corev1.ResourceList) {
+	for name, q := range other {
+		if current, ok := list[name]; !ok || q.Cmp(current) > 0 {
+			list[name] = q.DeepCopy()
+		}
+	}
+}
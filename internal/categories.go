@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"log"
+	"strings"
+)
+
+// categoryKeyPrefix marks ConfigMap keys that style a bookmark category:
+//
+//	category-news: "color=#f59e0b|icon=📰"
+//
+// The rest of the key is matched against the category's name slugified, so
+// "category-home-lab" styles the "Home Lab" category.
+const categoryKeyPrefix = "category-"
+
+// CategoryStyle is the colour and icon a bookmark category is shown with.
+type CategoryStyle struct {
+	Color   string // hex colour for the heading and tile accents, or ""
+	Icon    string // icon as configured: an emoji, a pack slug ("si:jellyfin") or a URL
+	IconURL string // Icon resolved to a URL the browser can load; "" for emoji
+}
+
+// parseCategoryStyles reads every category-* key from ConfigMap data, keyed
+// by category ID (see categoryID).
+func parseCategoryStyles(data map[string]string) map[string]CategoryStyle {
+	styles := make(map[string]CategoryStyle)
+	for key, value := range data {
+		name, ok := strings.CutPrefix(key, categoryKeyPrefix)
+		if !ok || name == "" {
+			continue
+		}
+		var style CategoryStyle
+		for _, opt := range strings.Split(value, "|") {
+			k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			v = strings.TrimSpace(v)
+			switch k {
+			case "":
+			case "color":
+				if hexColor.MatchString(v) {
+					style.Color = v
+				} else {
+					log.Printf("Warning: %s has invalid color %q, want a hex colour like #f59e0b", key, v)
+				}
+			case "icon":
+				style.Icon = v
+				if strings.Contains(v, ":") {
+					if style.IconURL = resolveIcon(v); style.IconURL == "" {
+						style.Icon = ""
+					}
+				}
+			default:
+				log.Printf("Warning: %s has unknown option %q", key, opt)
+			}
+		}
+		styles[categoryID(name)] = style
+	}
+	return styles
+}
+
+// applyCategoryStyles copies the configured styles onto categories.
+func applyCategoryStyles(categories []BookmarkCategory, styles map[string]CategoryStyle) {
+	for i := range categories {
+		categories[i].Style = styles[categories[i].ID]
+	}
+}
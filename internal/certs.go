@@ -0,0 +1,276 @@
+package internal
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultCertWarningDays and defaultCertCriticalDays are how close to
+	// expiry a certificate gets a warning and a critical badge. ACME clients
+	// renew with 30 days left, so a warning means renewal has been failing
+	// for a while.
+	defaultCertWarningDays  = 14
+	defaultCertCriticalDays = 3
+	// certCheckInterval is how often certificate expiry metrics are updated
+	// and TLS Secrets are read.
+	certCheckInterval = 5 * time.Minute
+)
+
+// CertLevel is how close a certificate is to expiry.
+type CertLevel string
+
+const (
+	CertOK       CertLevel = "ok"
+	CertWarning  CertLevel = "warning"  // within CERT_WARNING_DAYS
+	CertCritical CertLevel = "critical" // within CERT_CRITICAL_DAYS, or expired
+)
+
+// CertStatus is when a tile's certificate expires. The zero value means no
+// certificate is known.
+type CertStatus struct {
+	Expiry time.Time
+	Days   int // whole days left; negative once expired
+	Level  CertLevel
+	Source string // "tls" for the certificate served to health checks, "secret" for the ingress's TLS Secret
+}
+
+// CertMonitor rates certificate expiry against the CERT_WARNING_DAYS and
+// CERT_CRITICAL_DAYS thresholds and exports it as metrics. Expiry comes from
+// the certificate a target serves to health checks, or else, with
+// CERT_SECRETS=true, from the TLS Secret an ingress references.
+type CertMonitor struct {
+	warning, critical int // days
+	health            *HealthChecker
+	k8s               func() *K8sClient // nil unless CERT_SECRETS=true
+
+	mu      sync.Mutex
+	secrets map[string]time.Time // NotAfter by "namespace/name"
+
+	expiry   *prometheus.GaugeVec
+	expiring *prometheus.GaugeVec
+}
+
+// NewCertMonitorFromEnv creates a monitor and registers its metrics. health
+// may be nil; k8s, which returns the current client, is only used with
+// CERT_SECRETS=true.
+func NewCertMonitorFromEnv(health *HealthChecker, k8s func() *K8sClient) *CertMonitor {
+	m := &CertMonitor{
+		warning:  daysFromEnv("CERT_WARNING_DAYS", defaultCertWarningDays),
+		critical: daysFromEnv("CERT_CRITICAL_DAYS", defaultCertCriticalDays),
+		health:   health,
+		expiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gohome_cert_expiry_timestamp_seconds",
+			Help: "Unix time at which the certificate of each tile URL expires, by source (tls or secret).",
+		}, []string{"url", "source"}),
+		expiring: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gohome_certs_expiring",
+			Help: "Tile certificates within the warning or critical expiry threshold, by level.",
+		}, []string{"level"}),
+	}
+	if os.Getenv("CERT_SECRETS") == "true" {
+		m.k8s = k8s
+	}
+	if m.critical > m.warning {
+		log.Printf("Warning: CERT_CRITICAL_DAYS %d is above CERT_WARNING_DAYS %d", m.critical, m.warning)
+	}
+	prometheus.MustRegister(m.expiry, m.expiring)
+	return m
+}
+
+// daysFromEnv parses a non-negative number of days from the named
+// variable, logging and falling back to def if it is malformed.
+func daysFromEnv(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("Warning: invalid %s %q, using %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// Status returns the expiry of the certificate behind rawURL, preferring
+// the one it served to the last health check over its TLS Secret, named
+// "namespace/name", which may be empty.
+func (m *CertMonitor) Status(rawURL, secret string, now time.Time) CertStatus {
+	if m == nil {
+		return CertStatus{}
+	}
+	if expiry := m.health.Status(rawURL).CertExpiry; !expiry.IsZero() {
+		return m.rate(expiry, "tls", now)
+	}
+	m.mu.Lock()
+	expiry, ok := m.secrets[secret]
+	m.mu.Unlock()
+	if ok {
+		return m.rate(expiry, "secret", now)
+	}
+	return CertStatus{}
+}
+
+// rate compares expiry with the thresholds.
+func (m *CertMonitor) rate(expiry time.Time, source string, now time.Time) CertStatus {
+	status := CertStatus{Expiry: expiry, Source: source, Level: CertOK}
+	status.Days = int(expiry.Sub(now).Hours() / 24)
+	if expiry.Before(now) {
+		status.Days = min(status.Days, -1)
+	}
+	switch {
+	case status.Days < m.critical:
+		status.Level = CertCritical
+	case status.Days < m.warning:
+		status.Level = CertWarning
+	}
+	return status
+}
+
+// CertTile is a tile whose certificate is monitored.
+type CertTile struct {
+	URL    string
+	Secret string // "namespace/name" of the ingress's TLS Secret; empty for bookmarks
+}
+
+// Job reads TLS Secrets and updates the expiry metrics for the tiles
+// returned by tiles every certCheckInterval.
+func (m *CertMonitor) Job(tiles func(context.Context) []CertTile) Job {
+	if m == nil {
+		return Job{}
+	}
+	return Job{
+		Name:     "certs",
+		Interval: certCheckInterval,
+		Run:      func(ctx context.Context) error { return m.check(ctx, tiles(ctx)) },
+	}
+}
+
+func (m *CertMonitor) check(ctx context.Context, tiles []CertTile) error {
+	var err error
+	if m.k8s != nil {
+		var secrets map[string]time.Time
+		if secrets, err = m.k8s().GetTLSSecretExpiries(ctx); err != nil {
+			log.Printf("Warning: Could not read TLS Secrets: %v", err)
+		} else {
+			m.mu.Lock()
+			m.secrets = secrets
+			m.mu.Unlock()
+		}
+	}
+
+	now := time.Now()
+	counts := map[CertLevel]int{CertWarning: 0, CertCritical: 0}
+	seen := make(map[string]bool)
+	m.expiry.Reset()
+	for _, tile := range tiles {
+		if seen[tile.URL] {
+			continue
+		}
+		seen[tile.URL] = true
+		status := m.Status(tile.URL, tile.Secret, now)
+		if status.Expiry.IsZero() {
+			continue
+		}
+		m.expiry.WithLabelValues(tile.URL, status.Source).Set(float64(status.Expiry.Unix()))
+		if status.Level != CertOK {
+			counts[status.Level]++
+		}
+	}
+	for level, n := range counts {
+		m.expiring.WithLabelValues(string(level)).Set(float64(n))
+	}
+	return err
+}
+
+// GetTLSSecretExpiries returns when the certificate in each TLS Secret
+// expires, keyed by "namespace/name". Only NotAfter is kept; the keys
+// aren't. It returns nil in demo mode.
+func (k *K8sClient) GetTLSSecretExpiries(ctx context.Context) (map[string]time.Time, error) {
+	if k == nil || k.clientset == nil {
+		return nil, nil
+	}
+	list, err := k.clientset.CoreV1().Secrets("").List(ctx, metav1.ListOptions{
+		FieldSelector: "type=" + string(corev1.SecretTypeTLS),
+	})
+	if err != nil {
+		return nil, err
+	}
+	expiries := make(map[string]time.Time, len(list.Items))
+	for _, secret := range list.Items {
+		notAfter, err := certNotAfter(secret.Data[corev1.TLSCertKey])
+		if err != nil {
+			log.Printf("Warning: Ignoring TLS Secret %s/%s: %v", secret.Namespace, secret.Name, err)
+			continue
+		}
+		expiries[secret.Namespace+"/"+secret.Name] = notAfter
+	}
+	return expiries, nil
+}
+
+// certNotAfter returns the expiry of the leaf, the first certificate in a
+// PEM chain.
+func certNotAfter(chain []byte) (time.Time, error) {
+	block, _ := pem.Decode(chain)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, errors.New("no PEM certificate in tls.crt")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// certTiles lists every ingress and bookmark URL with the TLS Secret of the
+// ingresses that have one.
+func (s *Server) certTiles(ctx context.Context) []CertTile {
+	var tiles []CertTile
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: certificate monitor failed to list ingresses: %v", err)
+	}
+	for _, info := range append(apps, services...) {
+		tiles = append(tiles, CertTile{URL: info.URL, Secret: info.tlsSecretKey()})
+	}
+	if config, err := s.bookmarkManager.GetConfig(ctx); err == nil {
+		for _, b := range config.Bookmarks {
+			tiles = append(tiles, CertTile{URL: b.URL})
+		}
+	}
+	return tiles
+}
+
+// applyCerts fills in the certificate expiry of each tile.
+func (s *Server) applyCerts(apps, services []IngressInfo, bookmarks []Bookmark) {
+	now := time.Now()
+	for _, list := range [][]IngressInfo{apps, services} {
+		for i := range list {
+			list[i].Cert = s.certs.Status(list[i].URL, list[i].tlsSecretKey(), now)
+		}
+	}
+	for i := range bookmarks {
+		bookmarks[i].Cert = s.certs.Status(bookmarks[i].URL, "", now)
+	}
+}
+
+// tlsSecretKey returns "namespace/name" of the ingress's TLS Secret, or ""
+// if it has none.
+func (info IngressInfo) tlsSecretKey() string {
+	if info.TLSSecret == "" {
+		return ""
+	}
+	return info.Namespace + "/" + info.TLSSecret
+}
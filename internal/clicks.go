@@ -0,0 +1,391 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// clickCountsKey is the ConfigMap key earlier versions persisted the click
+// counters under, as a JSON object keyed by tile ID. It is only read, to
+// carry the counts over into the data store.
+const clickCountsKey = "click-counts"
+
+// clickFlushInterval is how often changed click counters are written back
+// to the data store, so a burst of clicks costs one write.
+const clickFlushInterval = time.Minute
+
+// ClickCount is how often one tile has been opened through /click.
+type ClickCount struct {
+	Count int64     `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// ClickCounter keeps per-tile click counts in memory and periodically
+// persists them to the data store if there is one (see sharedStore). They
+// are never written to the ConfigMap, which is often managed by GitOps:
+// without a data store they last until a restart. Clicks recorded before
+// the stored counts have been loaded are merged into them, so a restart
+// never resets the totals.
+//
+// With Redis configured (see sharedRedis) the totals live there instead, so
+// every replica counts towards and shows the same numbers, and one replica
+// at a time keeps the persisted copy up to date.
+type ClickCounter struct {
+	mu     sync.Mutex
+	counts map[string]ClickCount
+	loaded bool
+	dirty  bool
+	store  Store
+
+	redis   *RedisClient
+	pending map[string]ClickCount // clicks not yet added to the totals in Redis
+	saved   map[string]ClickCount // totals last persisted
+}
+
+// NewClickCounter creates an empty counter; Run loads the stored counts.
+func NewClickCounter() *ClickCounter {
+	return &ClickCounter{
+		counts: make(map[string]ClickCount),
+		store:  sharedStore(),
+		redis:  sharedRedis(),
+
+		pending: make(map[string]ClickCount),
+	}
+}
+
+// Record counts one click on the tile with the given ID.
+func (c *ClickCounter) Record(id string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := c.counts[id]
+	count.Count++
+	count.Last = now
+	c.counts[id] = count
+	c.dirty = true
+
+	if c.redis != nil {
+		p := c.pending[id]
+		p.Count++
+		p.Last = now
+		c.pending[id] = p
+	}
+}
+
+// Counts returns a copy of the current counts.
+func (c *ClickCounter) Counts() map[string]ClickCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.counts)
+}
+
+// Job loads the stored counts, then writes them back every
+// clickFlushInterval while they have changed, and once more on shutdown.
+// Nothing is written until the load has succeeded, so a store that was
+// briefly unreadable isn't overwritten with partial counts.
+func (c *ClickCounter) Job(bm *BookmarkManager) Job {
+	return Job{
+		Name:     "clicks",
+		Interval: clickFlushInterval,
+		Final:    true,
+		Run:      func(ctx context.Context) error { return c.sync(ctx, bm) },
+	}
+}
+
+// sync loads the stored counts if that hasn't happened yet, then saves
+// them if they have changed.
+func (c *ClickCounter) sync(ctx context.Context, bm *BookmarkManager) error {
+	if c.redis != nil {
+		return c.syncShared(ctx, bm)
+	}
+
+	c.mu.Lock()
+	loaded := c.loaded
+	c.mu.Unlock()
+
+	if !loaded {
+		stored, err := c.loadStored(ctx, bm)
+		if err != nil {
+			log.Printf("Warning: Could not load click counts: %v", err)
+			return err
+		}
+		c.mu.Lock()
+		for id, s := range stored {
+			count := c.counts[id]
+			count.Count += s.Count
+			if s.Last.After(count.Last) {
+				count.Last = s.Last
+			}
+			c.counts[id] = count
+		}
+		c.loaded = true
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	counts := maps.Clone(c.counts)
+	c.dirty = false
+	c.mu.Unlock()
+
+	err := c.saveStored(ctx, counts)
+	if err != nil {
+		log.Printf("Warning: Could not save click counts: %v", err)
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// syncShared adds this replica's new clicks to the totals in Redis and
+// copies the totals back. The replica holding the clicks lock also seeds
+// Redis from the persisted counts, unless Redis already has some, and
+// persists the totals when they change, so they survive losing Redis.
+func (c *ClickCounter) syncShared(ctx context.Context, bm *BookmarkManager) error {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]ClickCount)
+	c.mu.Unlock()
+
+	err := c.pushShared(ctx, pending)
+	if err != nil {
+		log.Printf("Warning: Could not add clicks to Redis: %v", err)
+		c.mu.Lock()
+		for id, p := range pending {
+			count := c.pending[id]
+			count.Count += p.Count
+			if p.Last.After(count.Last) {
+				count.Last = p.Last
+			}
+			c.pending[id] = count
+		}
+		c.mu.Unlock()
+		return err
+	}
+
+	var errs []error
+	lead, err := c.redis.Lead(ctx, "clicks", 3*clickFlushInterval)
+	if err != nil {
+		log.Printf("Warning: Could not coordinate click counts through Redis: %v", err)
+		errs = append(errs, err)
+	}
+	c.mu.Lock()
+	loaded := c.loaded
+	c.mu.Unlock()
+	if lead && !loaded {
+		if err := c.seedShared(ctx, bm); err != nil {
+			log.Printf("Warning: Could not seed click counts in Redis: %v", err)
+			errs = append(errs, err)
+		} else {
+			loaded = true
+			c.mu.Lock()
+			c.loaded = true
+			c.mu.Unlock()
+		}
+	}
+
+	totals, err := c.loadShared(ctx)
+	if err != nil {
+		log.Printf("Warning: Could not read click counts from Redis: %v", err)
+		return errors.Join(append(errs, err)...)
+	}
+	c.mu.Lock()
+	counts := maps.Clone(totals)
+	for id, p := range c.pending {
+		count := counts[id]
+		count.Count += p.Count
+		count.Last = p.Last
+		counts[id] = count
+	}
+	c.counts = counts
+	c.mu.Unlock()
+
+	if lead && loaded && !maps.Equal(totals, c.saved) {
+		if err := c.saveStored(ctx, totals); err != nil {
+			log.Printf("Warning: Could not save click counts: %v", err)
+			return errors.Join(append(errs, err)...)
+		}
+		c.saved = totals
+	}
+	return errors.Join(errs...)
+}
+
+// pushShared adds clicks to the totals in Redis. Clicks that were added
+// are removed from pending, so on error it holds what is left.
+func (c *ClickCounter) pushShared(ctx context.Context, pending map[string]ClickCount) error {
+	for id, p := range pending {
+		if _, err := c.redis.Do(ctx, "HINCRBY", redisKeyPrefix+clickCountsKey, id, strconv.FormatInt(p.Count, 10)); err != nil {
+			return err
+		}
+		delete(pending, id)
+		if _, err := c.redis.Do(ctx, "HSET", redisKeyPrefix+clickCountsKey+"-last", id, strconv.FormatInt(p.Last.UnixMilli(), 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedShared copies the persisted counts into Redis if Redis has none yet.
+func (c *ClickCounter) seedShared(ctx context.Context, bm *BookmarkManager) error {
+	exists, err := c.redis.Do(ctx, "EXISTS", redisKeyPrefix+clickCountsKey)
+	if err != nil || exists != int64(0) {
+		return err
+	}
+	stored, err := c.loadStored(ctx, bm)
+	if err != nil {
+		return err
+	}
+	c.saved = stored
+	return c.pushShared(ctx, maps.Clone(stored))
+}
+
+// loadShared reads the totals from Redis.
+func (c *ClickCounter) loadShared(ctx context.Context) (map[string]ClickCount, error) {
+	counts, err := c.redis.HGetAll(ctx, clickCountsKey)
+	if err != nil {
+		return nil, err
+	}
+	last, err := c.redis.HGetAll(ctx, clickCountsKey+"-last")
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]ClickCount, len(counts))
+	for id, value := range counts {
+		n, _ := strconv.ParseInt(value, 10, 64)
+		ms, _ := strconv.ParseInt(last[id], 10, 64)
+		totals[id] = ClickCount{Count: n, Last: time.UnixMilli(ms).UTC()}
+	}
+	return totals, nil
+}
+
+// loadStored reads the persisted counts. The first time the data store is
+// used its click bucket is empty and the counts are carried over from the
+// ConfigMap's click-counts key; without a data store that key is where the
+// counts in memory start from.
+func (c *ClickCounter) loadStored(ctx context.Context, bm *BookmarkManager) (map[string]ClickCount, error) {
+	if c.store == nil {
+		return bm.LoadClickCounts(ctx)
+	}
+	if counts, err := listJSON[ClickCount](ctx, c.store, storeNamespaceClicks); err != nil || len(counts) > 0 {
+		return counts, err
+	}
+	counts, err := bm.LoadClickCounts(ctx)
+	if err != nil || len(counts) == 0 {
+		return counts, err
+	}
+	log.Printf("Moving %d click counts from the ConfigMap to the data store", len(counts))
+	return counts, replaceJSON(ctx, c.store, storeNamespaceClicks, counts)
+}
+
+// saveStored persists counts to the data store; without one they only live
+// in memory.
+func (c *ClickCounter) saveStored(ctx context.Context, counts map[string]ClickCount) error {
+	if c.store == nil {
+		return nil
+	}
+	return replaceJSON(ctx, c.store, storeNamespaceClicks, counts)
+}
+
+// LoadClickCounts reads the persisted click counts from the ConfigMap. In
+// demo mode there is nothing to load.
+func (bm *BookmarkManager) LoadClickCounts(ctx context.Context) (map[string]ClickCount, error) {
+	if bm.client() == nil {
+		return nil, nil
+	}
+	configMap, err := bm.client().ConfigMaps(bm.namespace).Get(ctx, bm.configMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var counts map[string]ClickCount
+	if value := configMap.Data[clickCountsKey]; value != "" {
+		if err := json.Unmarshal([]byte(value), &counts); err != nil {
+			log.Printf("Warning: Ignoring invalid %s: %v", clickCountsKey, err)
+		}
+	}
+	return counts, nil
+}
+
+// mostUsed returns up to limit tiles that have been clicked at least once,
+// most clicked first.
+func mostUsed(counts map[string]ClickCount, limit int, apps, services []IngressInfo, bookmarks []Bookmark) []Favorite {
+	var ids []string
+	for id, count := range counts {
+		if count.Count > 0 {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b].Count, counts[a].Count), strings.Compare(a, b))
+	})
+	tiles := buildFavorites(ids, apps, services, bookmarks)
+	return tiles[:min(len(tiles), limit)]
+}
+
+// TileStats is one tile in the GET /api/v1/stats response.
+type TileStats struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	Clicks      int64      `json:"clicks"`
+	LastClicked *time.Time `json:"last_clicked,omitempty"`
+}
+
+// handleStats serves the click count of every tile currently on the page,
+// most clicked first, so tiles nobody opens stand out at the end with zero.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !config.ClickStats {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "click stats are disabled"})
+		return
+	}
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: /api/v1/stats could not load ingresses: %v", err)
+	}
+	apps, services, config.Bookmarks = s.restrictTiles(r, s.resolveViewer(ctx, r), config, apps, services, config.Bookmarks)
+
+	counts := s.clicks.Counts()
+	stats := make([]TileStats, 0, len(apps)+len(services)+len(config.Bookmarks))
+	add := func(id, name, url string) {
+		count := counts[id]
+		stat := TileStats{ID: id, Name: name, URL: url, Clicks: count.Count}
+		if !count.Last.IsZero() {
+			stat.LastClicked = &count.Last
+		}
+		stats = append(stats, stat)
+	}
+	for _, list := range [][]IngressInfo{apps, services} {
+		for _, info := range list {
+			add(ingressTileID(info), info.Name, info.URL)
+		}
+	}
+	for _, b := range config.Bookmarks {
+		add(bookmarkTileID(b), b.Name, b.URL)
+	}
+	slices.SortStableFunc(stats, func(a, b TileStats) int {
+		return cmp.Compare(b.Clicks, a.Clicks)
+	})
+	writeJSON(w, http.StatusOK, stats)
+}
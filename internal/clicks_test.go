@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClickCounterSync(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name  string
+		store Store
+	}{
+		{"memory only", nil},
+		{"data store", newMemoryStore()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			bm, clientset := newTestBookmarkManager(t, map[string]string{
+				clickCountsKey: `{"grafana":{"count":2,"last":"2026-10-01T00:00:00Z"}}`,
+			})
+			c := &ClickCounter{counts: make(map[string]ClickCount), store: tt.store}
+			c.Record("grafana", now)
+			c.Record("docs", now)
+			if err := c.sync(context.Background(), bm); err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]ClickCount{"grafana": {3, now}, "docs": {1, now}}
+			if got := c.Counts(); len(got) != len(want) || got["grafana"] != want["grafana"] || got["docs"] != want["docs"] {
+				t.Errorf("counts %+v, want %+v", got, want)
+			}
+			for _, action := range clientset.Actions() {
+				if action.GetVerb() != "get" {
+					t.Errorf("sync made a %s request to the API server", action.GetVerb())
+				}
+			}
+			if tt.store == nil {
+				return
+			}
+			stored, err := listJSON[ClickCount](context.Background(), tt.store, storeNamespaceClicks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != len(want) || stored["grafana"] != want["grafana"] || stored["docs"] != want["docs"] {
+				t.Errorf("stored %+v, want %+v", stored, want)
+			}
+		})
+	}
+}
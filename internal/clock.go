@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"log"
+	"strings"
+	"time"
+	_ "time/tzdata" // the distroless image has no zoneinfo; clock-timezone needs it
+)
+
+// ClockConfig configures the optional header clock and greeting, from the
+// ConfigMap keys clock, clock-timezone, clock-format and greeting.
+type ClockConfig struct {
+	Enabled  bool
+	Greeting bool
+	Timezone string // IANA name, e.g. "Europe/London"; empty means the server's local time
+	Hour12   bool   // clock-format: "12h"
+}
+
+// ClockWidget is the server-rendered initial state of the header clock. The
+// browser keeps it ticking using the same timezone and format.
+type ClockWidget struct {
+	Time     string
+	Date     string
+	Greeting string
+	Name     string // who the greeting addresses, so the browser can re-word it
+	Timezone string
+	Hour12   bool
+}
+
+// buildClock renders the clock for now in the configured timezone and the
+// visitor's language, greeting viewer (a Tailscale login such as
+// "alice@example.com") by name if known.
+func buildClock(cfg ClockConfig, viewer string, now time.Time, locale *Locale) *ClockWidget {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			log.Printf("Warning: unknown clock-timezone %q, using server time: %v", cfg.Timezone, err)
+			cfg.Timezone = ""
+		} else {
+			now = now.In(loc)
+		}
+	}
+
+	layout := "15:04"
+	if cfg.Hour12 {
+		layout = "3:04 pm"
+	}
+	clock := &ClockWidget{
+		Time:     now.Format(layout),
+		Date:     locale.Date(now),
+		Timezone: cfg.Timezone,
+		Hour12:   cfg.Hour12,
+	}
+	if cfg.Greeting {
+		clock.Name, _, _ = strings.Cut(viewer, "@")
+		clock.Greeting = greeting(now.Hour(), clock.Name, locale)
+	}
+	return clock
+}
+
+// greeting picks a greeting for the hour, addressed to name when there is one.
+// static/app.js re-words it the same way as the day goes on.
+func greeting(hour int, name string, locale *Locale) string {
+	var key string
+	switch {
+	case hour < 5:
+		key = "greeting.night"
+	case hour < 12:
+		key = "greeting.morning"
+	case hour < 18:
+		key = "greeting.afternoon"
+	default:
+		key = "greeting.evening"
+	}
+	if name == "" {
+		return locale.T(key)
+	}
+	return locale.T("greeting.named", locale.T(key), name)
+}
@@ -2,17 +2,22 @@ package internal
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"maps"
 	"os"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
 )
 
 // Bookmark represents a bookmark entry
@@ -20,43 +25,279 @@ type Bookmark struct {
 	Name     string
 	URL      string
 	Category string
+	Tags     []string
+	Icon     string   // icon as configured, e.g. "si:grafana"
+	IconURL  string   // Icon resolved to a URL the browser can load
+	Target   string   // link target, "_blank" or "_self"; empty until resolved from the new-tab setting
+	Href     string   // link the tile opens through, "/click?id=..." while recent tracking is on or "/launch?target=..." with launch-check; empty to use URL
+	Sample   bool     // one of the built-in examples, not configured by anyone
+	Groups   []string // only viewers in one of these groups see it; empty for everyone
+	GoLinks  []string // /go/<name> names that redirect here, from the go option
+
+	HealthCheck HealthPolicy
+	Health      TargetHealth
+	NoDNS       bool // the host has no DNS record; only checked with DNS_CHECKS=true
+	Cert        CertStatus
 }
 
+// DefaultRobotsTxt disallows all crawlers, since GoHome is a private dashboard
+// that has no business appearing in search results.
+const DefaultRobotsTxt = "User-agent: *\nDisallow: /\n"
+
 // Config holds the application configuration
 type Config struct {
+	Bookmarks  []Bookmark
+	Title      string
+	RobotsTxt  string   // body served at /robots.txt
+	FaviconURL string   // if set, /favicon.ico redirects here instead of the embedded icon
+	Theme      string   // default colour scheme: "auto", "light", "dark" or "contrast"
+	ThemeColor string   // hex colour for the browser toolbar and installed app; empty to follow the palette
+	Palette    string   // default colour palette, one of PaletteNames()
+	Layout     string   // default tile layout: "grid" or "list"
+	GroupBy    string   // default tile grouping, one of Groupings
+	Compact    string   // touch-friendly single-column mode: "auto" (narrow viewports), "always" or "never"
+	NewTab     bool     // tiles and other links open in a new tab unless an item says otherwise
+	Recent     int      // tiles shown in the "Recently used" row, tracked through /click; 0 disables tracking
+	ClickStats bool     // count clicks per tile for /api/v1/stats, persisted in the data store if there is one
+	MostUsed   int      // tiles shown in the "Most used" row, by click count; 0 (default) hides it
+	Launch     bool     // tiles open through /launch, which checks they respond first
+	Collapsed  []string // section ("apps", "services", "bookmarks") or category names that start collapsed
+	Language   string   // UI language overriding Accept-Language, one of Languages(); empty to negotiate
+
+	TileDetails map[string]bool // metadata shown on ingress tiles, a subset of tileDetails
+
+	SearchEngine string            // URL template with {query}; empty means the search box only filters tiles
+	Bangs        map[string]string // "!name" shortcut to URL template, see defaultBangs
+	Commands     map[string]string // quick action name to URL template, see parseCommands
+	GoLinks      map[string]string // /go/<name> redirects from golink-<name> keys, see goLinks
+
+	Announcements []Announcement // banners from the announcements key, see parseAnnouncements
+	Notes         string         // Markdown from the notes.md key, rendered into the Notes panel
+
+	Clock         ClockConfig
+	Weather       WeatherConfig
+	Categories    map[string]CategoryStyle // from category-<name> keys, keyed by category ID
+	Feeds         []FeedConfig             // from feed-<name> keys
+	LinkSources   []LinkSource             // from links-<name> keys
+	MDNSExclude   []string                 // instance or host name patterns left out of "Local network"
+	Pages         []Page                   // from page-<slug> keys, sorted by slug
+	Calendars     []CalendarConfig         // from calendar-<name> keys
+	GitHub        GitHubConfig
+	Prometheus    PrometheusConfig     // from prometheus-url and promql-<name> keys
+	HomeAssistant HomeAssistantConfig  // from homeassistant-url and hass-<name> keys
+	Media         []MediaServiceConfig // from media-<name> keys
+	Probes        []ProbeConfig        // from probe-<name> keys
+	Grafana       GrafanaConfig        // from grafana-url and grafana-panel-<name> keys
+	Visibility    Visibility           // from visibility-<name> and group-<name> keys
+
+	// Order maps a group ID ("apps", "services", "cat-<category>") to the
+	// tile IDs in their custom display order. See orderKeyPrefix.
+	Order map[string][]string
+}
+
+// BookmarkCategory is a group of bookmarks sharing a category, in display order.
+type BookmarkCategory struct {
+	Name      string
+	ID        string // stable identifier used for collapse state, e.g. "cat-news"
+	Style     CategoryStyle
 	Bookmarks []Bookmark
-	Title     string
+}
+
+// groupBookmarks splits bookmarks (already sorted by category) into categories.
+func groupBookmarks(bookmarks []Bookmark) []BookmarkCategory {
+	var categories []BookmarkCategory
+	for _, b := range bookmarks {
+		if n := len(categories); n == 0 || categories[n-1].Name != b.Category {
+			categories = append(categories, BookmarkCategory{Name: b.Category, ID: categoryID(b.Category)})
+		}
+		last := &categories[len(categories)-1]
+		last.Bookmarks = append(last.Bookmarks, b)
+	}
+	return categories
+}
+
+// categoryID returns the collapse-state identifier for a bookmark category.
+func categoryID(category string) string {
+	return "cat-" + slugify(category)
+}
+
+// slugify lower-cases s and replaces runs of anything other than letters and
+// digits with a single "-".
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
 }
 
 // BookmarkManager handles bookmark configuration from ConfigMaps
 type BookmarkManager struct {
-	clientset     *kubernetes.Clientset
+	api           atomic.Pointer[typedcorev1.ConfigMapsGetter] // nil in demo mode, see client
 	namespace     string
 	configMapName string
+
+	loadMu   sync.Mutex
+	lastLoad LoadStatus
+	files    SiteFiles       // from the last GetConfig, see SiteFiles
+	pages    map[string]bool // slugs of the last GetConfig's pages, nil before the first; see MayHavePage
+
+	configMaps *Cache[*corev1.ConfigMap]
+	snapshot   *Snapshot[*corev1.ConfigMap] // nil in demo mode or without SNAPSHOT_FILE or a data store
+
+	// demoOrder stands in for the ConfigMap's order-* keys in demo mode.
+	demoOrder map[string][]string
+
+	// fixtures replace the ConfigMaps when there is no clientset; see
+	// UseFixtures.
+	fixtures *Fixtures
+
+	// local reads the copies of the ConfigMap in other namespaces, for
+	// /ns/<namespace>.
+	local namespaceBookmarks
+
+	// remote holds the links fetched from links-* URLs, see RemoteLinks.
+	remote *RemoteLinks
+
+	// mdns lists the web interfaces found on the LAN; nil unless MDNS=true.
+	mdns *MDNSBrowser
+}
+
+// LoadStatus describes the outcome of the most recent ConfigMap load.
+type LoadStatus struct {
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Bookmarks   int
+	Err         error
 }
 
-// NewBookmarkManager creates a new bookmark manager
-func NewBookmarkManager(clientset *kubernetes.Clientset, namespace, configMapName string) *BookmarkManager {
-	return &BookmarkManager{
-		clientset:     clientset,
+// NewBookmarkManager creates a new bookmark manager reading the ConfigMap
+// through api, usually a clientset's CoreV1(), or in demo mode for nil.
+func NewBookmarkManager(api typedcorev1.ConfigMapsGetter, namespace, configMapName string) *BookmarkManager {
+	bm := &BookmarkManager{
 		namespace:     namespace,
 		configMapName: configMapName,
+		configMaps:    NewCacheFromEnv[*corev1.ConfigMap]("configmap"),
+		remote:        NewRemoteLinksFromEnv(),
+		mdns:          NewMDNSBrowserFromEnv(),
+	}
+	defaults := &Config{RobotsTxt: DefaultRobotsTxt}
+	applyEnvOverrides(defaults)
+	bm.files = defaults.siteFiles()
+	if api != nil {
+		bm.Connect(api)
+	}
+	return bm
+}
+
+// client returns the ConfigMap API, or nil in demo mode.
+func (bm *BookmarkManager) client() typedcorev1.ConfigMapsGetter {
+	if api := bm.api.Load(); api != nil {
+		return *api
 	}
+	return nil
+}
+
+// Connect switches a manager created in demo mode over to reading the
+// ConfigMap through api, starting from its snapshot if there is one. It
+// must be called at most once.
+func (bm *BookmarkManager) Connect(api typedcorev1.ConfigMapsGetter) {
+	bm.snapshot = newSnapshot[*corev1.ConfigMap]("configmap:" + bm.ConfigMapRef())
+	bm.restoreSnapshot()
+	bm.api.Store(&api)
+}
+
+// restoreSnapshot seeds the ConfigMap cache with the copy saved before the
+// last restart, so bookmarks and settings survive an API server outage
+// instead of falling back to the examples.
+func (bm *BookmarkManager) restoreSnapshot() {
+	configMap, saved, ok := bm.snapshot.Load(context.Background())
+	if !ok || configMap == nil {
+		return
+	}
+	bm.configMaps.Seed(bm.configMapName, configMap)
+	bm.loadMu.Lock()
+	bm.lastLoad = LoadStatus{LastSuccess: saved, Bookmarks: len(bm.parseBookmarks(configMap))}
+	bm.loadMu.Unlock()
+	log.Printf("Loaded ConfigMap %s from the snapshot saved at %s", bm.ConfigMapRef(), saved.Format(time.RFC3339))
+}
+
+// getConfigMap returns the ConfigMap, cached (see Cache). If it can't be
+// read the last copy that could is returned along with the error, or nil if
+// there is none.
+func (bm *BookmarkManager) getConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	return bm.configMaps.Get(ctx, bm.configMapName, func(ctx context.Context) (*corev1.ConfigMap, error) {
+		configMap, err := bm.client().ConfigMaps(bm.namespace).Get(ctx, bm.configMapName, metav1.GetOptions{})
+		if err != nil {
+			bm.recordLoad(0, err)
+			return nil, err
+		}
+		bm.recordLoad(len(bm.parseBookmarks(configMap)), nil)
+		// Only what GoHome reads is kept, not the managed fields.
+		bm.snapshot.Save(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: configMap.Name, Namespace: configMap.Namespace}, Data: configMap.Data})
+		return configMap, nil
+	})
+}
+
+// InvalidateCache makes the next read of the ConfigMap go to the API server.
+func (bm *BookmarkManager) InvalidateCache() {
+	bm.configMaps.Invalidate(bm.configMapName)
+}
+
+// ConfigChange is the ConfigMap's data before and after a write, without
+// the click counts, for the revision history.
+type ConfigChange struct {
+	Before map[string]string
+	After  map[string]string
+}
+
+// updateData changes the ConfigMap's data with mutate, retrying on
+// conflicts, and returns the data before and after.
+func (bm *BookmarkManager) updateData(ctx context.Context, mutate func(data map[string]string)) (change ConfigChange, err error) {
+	configMaps := bm.client().ConfigMaps(bm.namespace)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := configMaps.Get(ctx, bm.configMapName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		change.Before = maps.Clone(configMap.Data)
+		mutate(configMap.Data)
+		change.After = maps.Clone(configMap.Data)
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		if err == nil {
+			bm.InvalidateCache()
+		}
+		return err
+	})
+	delete(change.Before, clickCountsKey)
+	delete(change.After, clickCountsKey)
+	return change, err
 }
 
 // LoadBookmarks loads bookmarks from a ConfigMap
 func (bm *BookmarkManager) LoadBookmarks(ctx context.Context) ([]Bookmark, error) {
-	if bm.clientset == nil {
+	if bm.client() == nil {
 		log.Printf("Warning: Kubernetes client not available, using default bookmarks")
 		return bm.getDefaultBookmarks(), nil
 	}
 
-	configMap, err := bm.clientset.CoreV1().ConfigMaps(bm.namespace).Get(ctx, bm.configMapName, metav1.GetOptions{})
+	configMap, err := bm.getConfigMap(ctx)
 	if err != nil {
 		log.Printf("Warning: Could not load bookmarks ConfigMap %s/%s: %v", bm.namespace, bm.configMapName, err)
+	}
+	if configMap == nil {
 		return bm.getDefaultBookmarks(), nil
 	}
-
 	return bm.parseBookmarks(configMap), nil
 }
 
@@ -105,6 +346,31 @@ func (bm *BookmarkManager) parseBookmarkEntry(key, value string) Bookmark {
 		bookmark.Category = strings.TrimSpace(parts[1])
 	}
 
+	// Any further fields are key=value options, e.g. "url|category|tags=a,b"
+	for _, part := range parts[min(len(parts), 2):] {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.TrimSpace(key) {
+		case "tags":
+			bookmark.Tags = splitList(value)
+		case "groups":
+			bookmark.Groups = splitList(value)
+		case "icon":
+			bookmark.Icon = strings.TrimSpace(value)
+			bookmark.IconURL = resolveIcon(bookmark.Icon)
+		case "new-tab":
+			bookmark.Target = parseNewTab(strings.TrimSpace(value), "bookmark "+name)
+		case "go":
+			bookmark.GoLinks = parseGoLinkNames(value, "bookmark "+name)
+		case "":
+		default:
+			if option, ok := strings.CutPrefix(strings.TrimSpace(key), healthOptionPrefix); ok {
+				bookmark.HealthCheck.parseHealthOption(option, value, "bookmark "+name)
+				continue
+			}
+			log.Printf("Warning: Unknown option %q on bookmark %s", key, name)
+		}
+	}
+
 	// Default category if not specified
 	if bookmark.Category == "" {
 		bookmark.Category = "General"
@@ -115,37 +381,285 @@ func (bm *BookmarkManager) parseBookmarkEntry(key, value string) Bookmark {
 
 // GetConfig loads the complete application configuration
 func (bm *BookmarkManager) GetConfig(ctx context.Context) (*Config, error) {
-	bookmarks, err := bm.LoadBookmarks(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load bookmarks: %w", err)
+	config := &Config{
+		Title:       "Go Home",
+		RobotsTxt:   DefaultRobotsTxt,
+		Theme:       "auto",
+		Palette:     DefaultPalette,
+		Layout:      "grid",
+		Compact:     "auto",
+		GroupBy:     "category",
+		TileDetails: map[string]bool{"host": true},
+		NewTab:      true,
+		Recent:      defaultRecentCount,
+		ClickStats:  true,
+		Bangs:       maps.Clone(defaultBangs),
 	}
 
-	// Load title from ConfigMap if available
-	title := "Go Home"
-
-	if bm.clientset != nil {
-		configMap, err := bm.clientset.CoreV1().ConfigMaps(bm.namespace).Get(ctx, bm.configMapName, metav1.GetOptions{})
-		if err == nil {
-			if t, exists := configMap.Data["title"]; exists && t != "" {
-				title = t
-			}
+	if bm.client() != nil {
+		// A ConfigMap that can't be read right now is served from the last
+		// copy that could; only without one do the examples stand in.
+		configMap, err := bm.getConfigMap(ctx)
+		if err != nil {
+			log.Printf("Warning: Could not load bookmarks ConfigMap %s/%s: %v", bm.namespace, bm.configMapName, err)
+		}
+		if configMap != nil {
+			config.Bookmarks = bm.parseBookmarks(configMap)
+			applySettings(config, configMap.Data)
+			config.Bookmarks = append(config.Bookmarks, bm.remote.Bookmarks(config.LinkSources, config.Bookmarks)...)
 		} else {
-			log.Printf("Warning: Could not load ConfigMap for title: %v", err)
+			config.Bookmarks = bm.getDefaultBookmarks()
 		}
 	} else {
-		log.Printf("Info: Using default title (demo mode)")
+		if configMap := bm.fixtures.configMap(bm.namespace, bm.configMapName); configMap != nil {
+			config.Bookmarks = bm.parseBookmarks(configMap)
+			applySettings(config, configMap.Data)
+			config.Bookmarks = append(config.Bookmarks, bm.remote.Bookmarks(config.LinkSources, config.Bookmarks)...)
+			bm.recordLoad(len(config.Bookmarks), nil)
+		} else {
+			log.Printf("Info: Using default title and bookmarks (demo mode)")
+			config.Bookmarks = bm.getDefaultBookmarks()
+		}
+		// Tile orders saved since start take the place of the order-* keys.
+		if config.Order == nil {
+			config.Order = make(map[string][]string)
+		}
+		bm.loadMu.Lock()
+		maps.Copy(config.Order, bm.demoOrder)
+		bm.loadMu.Unlock()
 	}
 
-	// PAGE_TITLE env var takes highest priority, allowing local overrides
-	// (e.g. via mise.toml) without touching the ConfigMap.
-	if t := os.Getenv("PAGE_TITLE"); t != "" {
-		title = t
+	applyEnvOverrides(config)
+	config.Bookmarks = append(config.Bookmarks, bm.mdns.Bookmarks(config.MDNSExclude, config.Bookmarks)...)
+
+	pages := make(map[string]bool, len(config.Pages))
+	for _, page := range config.Pages {
+		pages[page.Slug] = true
 	}
+	bm.loadMu.Lock()
+	bm.files = config.siteFiles()
+	bm.pages = pages
+	bm.loadMu.Unlock()
+	return config, nil
+}
+
+// SiteFiles are the settings of the files browsers and crawlers fetch on
+// their own, which are served without reading the ConfigMap.
+type SiteFiles struct {
+	RobotsTxt  string
+	FaviconURL string
+}
 
-	return &Config{
-		Bookmarks: bookmarks,
-		Title:     title,
-	}, nil
+func (c *Config) siteFiles() SiteFiles {
+	return SiteFiles{RobotsTxt: c.RobotsTxt, FaviconURL: c.FaviconURL}
+}
+
+// SiteFiles returns the robots.txt body and favicon URL of the last
+// configuration GetConfig built, or of the environment before the first, so
+// that /robots.txt and /favicon.ico never make a request to the cluster.
+func (bm *BookmarkManager) SiteFiles() SiteFiles {
+	bm.loadMu.Lock()
+	defer bm.loadMu.Unlock()
+	return bm.files
+}
+
+// MayHavePage reports whether /<slug> may be a configured page: whether the
+// last configuration GetConfig built has it, or true before the first, so
+// that stray paths get a 404 without reading the ConfigMap.
+func (bm *BookmarkManager) MayHavePage(slug string) bool {
+	bm.loadMu.Lock()
+	defer bm.loadMu.Unlock()
+	return bm.pages == nil || bm.pages[slug]
+}
+
+// recordLoad remembers the result of a ConfigMap load for health reporting.
+func (bm *BookmarkManager) recordLoad(bookmarks int, err error) {
+	bm.loadMu.Lock()
+	defer bm.loadMu.Unlock()
+
+	now := time.Now()
+	bm.lastLoad.LastAttempt = now
+	bm.lastLoad.Err = err
+	if err == nil {
+		bm.lastLoad.LastSuccess = now
+		bm.lastLoad.Bookmarks = bookmarks
+	}
+}
+
+// LoadStatus returns the outcome of the most recent ConfigMap load.
+func (bm *BookmarkManager) LoadStatus() LoadStatus {
+	bm.loadMu.Lock()
+	defer bm.loadMu.Unlock()
+	return bm.lastLoad
+}
+
+// ConfigMapRef returns the "namespace/name" of the ConfigMap being read.
+func (bm *BookmarkManager) ConfigMapRef() string {
+	return bm.namespace + "/" + bm.configMapName
+}
+
+// applySettings copies the non-bookmark settings from ConfigMap data into config.
+// Keys that are missing or empty leave the existing default in place.
+func applySettings(config *Config, data map[string]string) {
+	if t := data["title"]; t != "" {
+		config.Title = t
+	}
+	if r := data["robots.txt"]; r != "" {
+		config.RobotsTxt = r
+	}
+	if f := data["favicon"]; f != "" {
+		config.FaviconURL = f
+	}
+	if t := data["theme"]; t != "" {
+		config.Theme = t
+	}
+	if c := data["theme-color"]; c != "" {
+		config.ThemeColor = parseThemeColor(c)
+	}
+	if p := data["palette"]; p != "" {
+		config.Palette = p
+	}
+	if l := data["layout"]; l != "" {
+		config.Layout = l
+	}
+	if c := data["compact"]; c != "" {
+		config.Compact = c
+	}
+	if g := data["group-by"]; g != "" {
+		config.GroupBy = g
+	}
+	if n := data["new-tab"]; n != "" {
+		config.NewTab = n == "true"
+	}
+	if n := data["recent"]; n != "" {
+		config.Recent = parseTileCount("recent", n, defaultRecentCount)
+	}
+	if c := data["click-stats"]; c != "" {
+		config.ClickStats = c == "true"
+	}
+	if l := data["launch-check"]; l != "" {
+		config.Launch = l == "true"
+	}
+	if n := data["most-used"]; n != "" {
+		config.MostUsed = parseTileCount("most-used", n, 0)
+	}
+	if d := data["tile-details"]; d != "" {
+		config.TileDetails = parseTileDetails(d)
+	}
+	if l := data["language"]; l != "" {
+		if _, ok := locales[l]; ok {
+			config.Language = l
+		} else {
+			log.Printf("Warning: unsupported language %q, want one of %s", l, strings.Join(Languages(), ", "))
+		}
+	}
+	if c := data["collapsed"]; c != "" {
+		config.Collapsed = splitList(c)
+	}
+	config.Clock.Enabled = data["clock"] == "true"
+	config.Clock.Greeting = data["greeting"] == "true"
+	config.Clock.Timezone = data["clock-timezone"]
+	config.Clock.Hour12 = data["clock-format"] == "12h"
+	config.Weather = WeatherConfig{
+		City:      data["weather-city"],
+		Latitude:  data["weather-latitude"],
+		Longitude: data["weather-longitude"],
+		Provider:  data["weather-provider"],
+		Units:     data["weather-units"],
+	}
+	if v := data["weather-ttl"]; v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			config.Weather.TTL = d
+		} else {
+			log.Printf("Warning: invalid weather-ttl %q: %v", v, err)
+		}
+	}
+	if e := data["search-engine"]; e != "" {
+		config.SearchEngine = parseSearchEngine(e)
+	}
+	parseBangs(config.Bangs, data)
+	if c := data["commands"]; c != "" {
+		config.Commands = parseCommands(c)
+	}
+	config.Notes = strings.TrimSpace(data["notes.md"])
+	if a := data["announcements"]; a != "" {
+		config.Announcements = parseAnnouncements(a, data["clock-timezone"])
+	}
+	config.Categories = parseCategoryStyles(data)
+	config.Feeds = parseFeeds(data)
+	config.LinkSources = parseLinkSources(data)
+	config.MDNSExclude = splitList(data["mdns-exclude"])
+	config.Pages = parsePages(data)
+	config.Calendars = parseCalendars(data)
+	config.GitHub = parseGitHub(data)
+	config.Prometheus = parsePrometheus(data)
+	config.HomeAssistant = parseHomeAssistant(data)
+	config.Media = parseMediaServices(data)
+	config.Probes = parseProbes(data)
+	config.GoLinks = parseGoLinks(data)
+	config.Grafana = parseGrafana(data)
+	config.Visibility = parseVisibility(data)
+	config.Order = parseOrder(data)
+}
+
+// applyEnvOverrides applies environment variable overrides, which take
+// priority over the ConfigMap so that local runs (e.g. via mise.toml) can
+// change settings without touching the cluster.
+func applyEnvOverrides(config *Config) {
+	if t := os.Getenv("PAGE_TITLE"); t != "" {
+		config.Title = t
+	}
+	if r := os.Getenv("ROBOTS_TXT"); r != "" {
+		config.RobotsTxt = r
+	}
+	if f := os.Getenv("FAVICON_URL"); f != "" {
+		config.FaviconURL = f
+	}
+	if t := os.Getenv("THEME"); t != "" {
+		config.Theme = t
+	}
+	if c := os.Getenv("THEME_COLOR"); c != "" {
+		config.ThemeColor = parseThemeColor(c)
+	}
+	if p := os.Getenv("PALETTE"); p != "" {
+		config.Palette = p
+	}
+	if l := os.Getenv("LAYOUT"); l != "" {
+		config.Layout = l
+	}
+	if c := os.Getenv("COMPACT"); c != "" {
+		config.Compact = c
+	}
+	if g := os.Getenv("GROUP_BY"); g != "" {
+		config.GroupBy = g
+	}
+	if n := os.Getenv("NEW_TAB"); n != "" {
+		config.NewTab = n == "true"
+	}
+	if n := os.Getenv("RECENT"); n != "" {
+		config.Recent = parseTileCount("RECENT", n, defaultRecentCount)
+	}
+	if c := os.Getenv("CLICK_STATS"); c != "" {
+		config.ClickStats = c == "true"
+	}
+	if l := os.Getenv("LAUNCH_CHECK"); l != "" {
+		config.Launch = l == "true"
+	}
+	if n := os.Getenv("MOST_USED"); n != "" {
+		config.MostUsed = parseTileCount("MOST_USED", n, 0)
+	}
+	if d := os.Getenv("TILE_DETAILS"); d != "" {
+		config.TileDetails = parseTileDetails(d)
+	}
+	if c := os.Getenv("COLLAPSED"); c != "" {
+		config.Collapsed = splitList(c)
+	}
+	if e := os.Getenv("MDNS_EXCLUDE"); e != "" {
+		config.MDNSExclude = splitList(e)
+	}
+	if e := os.Getenv("SEARCH_ENGINE"); e != "" {
+		config.SearchEngine = parseSearchEngine(e)
+	}
 }
 
 // getDefaultBookmarks returns a set of example bookmarks when ConfigMap is not available
@@ -155,15 +669,51 @@ func (bm *BookmarkManager) getDefaultBookmarks() []Bookmark {
 			Name:     "Hacker News",
 			URL:      "https://news.ycombinator.com",
 			Category: "News",
+			Tags:     []string{"tech"},
+			Icon:     "si:ycombinator",
+			IconURL:  resolveIcon("si:ycombinator"),
+			Sample:   true,
 		},
 		{
 			Name:     "Bracket City",
 			URL:      "https://www.theatlantic.com/games/bracket-city/",
 			Category: "Games",
+			Sample:   true,
 		},
 	}
 }
 
+// splitList splits a comma-separated list, trimming whitespace and dropping
+// empty entries.
+func splitList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// tileDetails are the values accepted in the tile-details list.
+var tileDetails = map[string]bool{"host": true, "path": true, "namespace": true, "cluster": true}
+
+// parseTileDetails parses the tile-details list, e.g. "host,namespace";
+// "none" shows just the names.
+func parseTileDetails(value string) map[string]bool {
+	details := make(map[string]bool)
+	for _, d := range splitList(strings.ToLower(value)) {
+		switch {
+		case d == "none":
+		case tileDetails[d]:
+			details[d] = true
+		default:
+			log.Printf("Warning: unknown tile-details value %q, want host, path, namespace, cluster or none", d)
+		}
+	}
+	return details
+}
+
 // redactAuthKey returns a redacted but identifiable representation of a
 // Tailscale auth key, showing the first 6 and last 4 characters separated by
 // "...", e.g. "tskey-...abcd". If the key is too short to redact meaningfully
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestBookmarkManager returns a manager for the gohome/gohome-config
+// ConfigMap holding data, and the fake clientset behind it.
+func newTestBookmarkManager(t *testing.T, data map[string]string) (*BookmarkManager, *fake.Clientset) {
+	t.Helper()
+	t.Setenv("CACHE_TTL", "0")
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "gohome", Name: "gohome-config"},
+		Data:       data,
+	})
+	return NewBookmarkManager(clientset.CoreV1(), "gohome", "gohome-config"), clientset
+}
+
+// configMapData returns the data the fake API server holds.
+func configMapData(t *testing.T, clientset *fake.Clientset) map[string]string {
+	t.Helper()
+	configMap, err := clientset.CoreV1().ConfigMaps("gohome").Get(context.Background(), "gohome-config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return configMap.Data
+}
+
+func TestUpdateData(t *testing.T) {
+	bm, clientset := newTestBookmarkManager(t, map[string]string{
+		"title":        "Home",
+		clickCountsKey: `{"a":1}`,
+	})
+	if _, err := bm.GetConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	change, err := bm.updateData(context.Background(), func(data map[string]string) {
+		data["bookmark-wiki"] = "https://wiki.example.com|Docs"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"title": "Home"}; !maps.Equal(change.Before, want) {
+		t.Errorf("before %v, want %v without the click counts", change.Before, want)
+	}
+	if want := map[string]string{"title": "Home", "bookmark-wiki": "https://wiki.example.com|Docs"}; !maps.Equal(change.After, want) {
+		t.Errorf("after %v, want %v", change.After, want)
+	}
+	if got := configMapData(t, clientset)["bookmark-wiki"]; got == "" {
+		t.Error("the ConfigMap wasn't updated")
+	}
+
+	// The write invalidates the cache, so the next read sees it.
+	bookmarks, err := bm.LoadBookmarks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != 1 || bookmarks[0].URL != "https://wiki.example.com" {
+		t.Errorf("bookmarks %+v after the update, want the wiki", bookmarks)
+	}
+}
+
+func TestUpdateDataRetriesConflicts(t *testing.T) {
+	bm, clientset := newTestBookmarkManager(t, map[string]string{"title": "Home"})
+	conflicts := 2
+	clientset.PrependReactor("update", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts == 0 {
+			return false, nil, nil
+		}
+		conflicts--
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "gohome-config", nil)
+	})
+
+	calls := 0
+	_, err := bm.updateData(context.Background(), func(data map[string]string) {
+		calls++
+		data["title"] = "Lab"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("mutate called %d times, want 3 (two conflicts, then success)", calls)
+	}
+	if got := configMapData(t, clientset)["title"]; got != "Lab" {
+		t.Errorf("title %q, want Lab", got)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	bm, clientset := newTestBookmarkManager(t, map[string]string{
+		"title":        "Home",
+		"bookmark-old": "https://old.example.com|Misc",
+		clickCountsKey: `{"a":1}`,
+		"weather-city": "Bristol",
+	})
+
+	change, err := bm.Restore(context.Background(), map[string]string{
+		"title":        "Lab",
+		"weather-city": "Bristol",
+		"bookmark-new": "https://new.example.com|Misc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"title":        "Lab",
+		"weather-city": "Bristol",
+		"bookmark-new": "https://new.example.com|Misc",
+		clickCountsKey: `{"a":1}`,
+	}
+	if got := configMapData(t, clientset); !maps.Equal(got, want) {
+		t.Errorf("data %v, want %v", got, want)
+	}
+	result := diffData(change.Before, change.After)
+	if len(result.Added) != 1 || len(result.Changed) != 1 || len(result.Removed) != 1 {
+		t.Errorf("diff %+v, want one key added, changed and removed", result)
+	}
+}
+
+func TestRestoreInDemoMode(t *testing.T) {
+	bm := NewBookmarkManager(nil, "gohome", "gohome-config")
+	if _, err := bm.Restore(context.Background(), map[string]string{"title": "Lab"}); err != errNoConfigMap {
+		t.Errorf("error %v, want errNoConfigMap", err)
+	}
+}
+
+func TestSiteFilesDontReadConfigMap(t *testing.T) {
+	bm, clientset := newTestBookmarkManager(t, map[string]string{
+		"robots.txt": "User-agent: *\nAllow: /\n",
+		"favicon":    "https://cdn.example.com/favicon.png",
+	})
+	s := &Server{bookmarkManager: bm}
+	serve := func(handler http.HandlerFunc, target string) *http.Response {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Result()
+	}
+
+	// Before the configuration is first loaded, the defaults are served.
+	if body, _ := io.ReadAll(serve(s.handleRobots, "/robots.txt").Body); string(body) != DefaultRobotsTxt {
+		t.Errorf("robots.txt %q before the first load, want the default", body)
+	}
+	if resp := serve(s.handleFavicon, "/favicon.ico"); resp.StatusCode != http.StatusOK {
+		t.Errorf("favicon.ico status %d before the first load, want the embedded icon", resp.StatusCode)
+	}
+
+	if _, err := bm.GetConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	actions := len(clientset.Actions())
+	if body, _ := io.ReadAll(serve(s.handleRobots, "/robots.txt").Body); string(body) != "User-agent: *\nAllow: /\n" {
+		t.Errorf("robots.txt %q, want the ConfigMap's", body)
+	}
+	if resp := serve(s.handleFavicon, "/favicon.ico"); resp.Header.Get("Location") != "https://cdn.example.com/favicon.png" {
+		t.Errorf("favicon.ico redirects to %q, want the ConfigMap's favicon", resp.Header.Get("Location"))
+	}
+	if n := len(clientset.Actions()); n != actions {
+		t.Errorf("serving robots.txt and favicon.ico made %d requests to the API server", n-actions)
+	}
+}
+
+func TestSiteFilesFromEnv(t *testing.T) {
+	t.Setenv("ROBOTS_TXT", "User-agent: *\nDisallow: /private\n")
+	t.Setenv("FAVICON_URL", "https://cdn.example.com/env.png")
+	bm, _ := newTestBookmarkManager(t, map[string]string{"robots.txt": "User-agent: *\nAllow: /\n"})
+	want := SiteFiles{RobotsTxt: "User-agent: *\nDisallow: /private\n", FaviconURL: "https://cdn.example.com/env.png"}
+	if got := bm.SiteFiles(); got != want {
+		t.Errorf("before the first load %+v, want %+v", got, want)
+	}
+	if _, err := bm.GetConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := bm.SiteFiles(); got != want {
+		t.Errorf("after a load %+v, want the environment to override the ConfigMap", got)
+	}
+}
+
+func TestMayHavePage(t *testing.T) {
+	bm, clientset := newTestBookmarkManager(t, map[string]string{"page-media": "title=Media"})
+	if !bm.MayHavePage("wp-login") {
+		t.Error("before the first load, a page was ruled out")
+	}
+	if _, err := bm.GetConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	actions := len(clientset.Actions())
+	for slug, want := range map[string]bool{"media": true, "wp-login": false, "env": false} {
+		if got := bm.MayHavePage(slug); got != want {
+			t.Errorf("MayHavePage(%q) = %v, want %v", slug, got, want)
+		}
+	}
+	if n := len(clientset.Actions()); n != actions {
+		t.Errorf("looking up pages made %d requests to the API server", n-actions)
+	}
+}
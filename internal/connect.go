@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// connectRetryMin is how long ConnectInBackground waits before its
+	// first attempt; each failure doubles the wait up to connectRetryMax.
+	connectRetryMin = 5 * time.Second
+	connectRetryMax = 5 * time.Minute
+)
+
+// kube returns the Kubernetes client, or nil in demo mode.
+func (s *Server) kube() *K8sClient {
+	return s.k8s.Load()
+}
+
+// ConnectInBackground keeps trying to create a Kubernetes client and reach
+// the API server with it, backing off after each failure, and switches the
+// server from demo mode to live data once that works. It returns then, or
+// when ctx is cancelled. It does nothing if the server already has a client.
+func (s *Server) ConnectInBackground(ctx context.Context) {
+	if s.kube() != nil {
+		return
+	}
+	var k *K8sClient
+	delay := connectRetryMin
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, connectRetryMax)
+
+		var err error
+		if k == nil {
+			if k, err = NewK8sClient(); err != nil {
+				log.Printf("Warning: Still in demo mode, retrying in %s: %v", delay, err)
+				continue
+			}
+		}
+		versionCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		version, err := k.ServerVersion(versionCtx)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: Still in demo mode, API server unreachable, retrying in %s: %v", delay, err)
+			continue
+		}
+
+		s.bookmarkManager.Connect(k.GetClientset().CoreV1())
+		s.k8s.Store(k)
+		log.Printf("Connected to Kubernetes %s, leaving demo mode", version)
+		// Check the permissions now rather than at the checker's next run,
+		// so a namespace-scoped listing takes effect right away.
+		_ = s.access.check(ctx)
+		return
+	}
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// consulNamespace is the namespace Consul services are shown in, and
+	// the first part of their tile IDs.
+	consulNamespace = "consul"
+	// consulListConcurrency bounds the per-service catalog requests.
+	consulListConcurrency = 8
+)
+
+// ConsulCatalog lists services from a Consul catalog as tiles, for services
+// that run outside Kubernetes. Listings are cached like ingress listings.
+type ConsulCatalog struct {
+	base     string // e.g. "http://consul.service.consul:8500"
+	token    string
+	tags     []string // a service is listed if it has one of them; empty for every service
+	template *template.Template
+	client   *http.Client
+	services *Cache[[]IngressInfo]
+}
+
+// consulInstance is a catalog entry as returned by /v1/catalog/service.
+type consulInstance struct {
+	Node           string
+	Address        string // the node's
+	Datacenter     string
+	ServiceName    string
+	ServiceAddress string
+	ServicePort    int
+	ServiceTags    []string
+	ServiceMeta    map[string]string
+}
+
+// NewConsulCatalogFromEnv returns a catalog client for CONSUL_HTTP_ADDR with
+// the CONSUL_HTTP_TOKEN ACL token, listing the services tagged with one of
+// CONSUL_TAGS, or nil when no address is set.
+func NewConsulCatalogFromEnv() *ConsulCatalog {
+	addr := os.Getenv("CONSUL_HTTP_ADDR")
+	if addr == "" {
+		return nil
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	c := &ConsulCatalog{
+		base:     strings.TrimSuffix(addr, "/"),
+		token:    os.Getenv("CONSUL_HTTP_TOKEN"),
+		tags:     splitList(os.Getenv("CONSUL_TAGS")),
+		template: urlTemplateFromEnv("CONSUL_URL_TEMPLATE", defaultServiceURLTemplate),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		services: NewCacheFromEnv[[]IngressInfo]("consul"),
+	}
+	log.Printf("Listing services from the Consul catalog at %s", redactURL(c.base))
+	return c
+}
+
+// Discover returns a tile for each listed service, sorted by name. If the
+// catalog can't be read it returns the error together with the tiles from
+// the last listing that worked, if any. A nil catalog has no services.
+func (c *ConsulCatalog) Discover(ctx context.Context) ([]IngressInfo, error) {
+	if c == nil {
+		return nil, nil
+	}
+	services, err := c.services.Get(ctx, "", c.list)
+	return slices.Clone(services), err
+}
+
+// Name is how logs refer to the source.
+func (c *ConsulCatalog) Name() string { return "Consul services" }
+
+// Watch returns at once: the catalog is polled as its cache expires.
+func (c *ConsulCatalog) Watch(context.Context, func()) {}
+
+// list reads the catalog: the service names and tags, then the instances
+// of each service that passes the tag filter.
+func (c *ConsulCatalog) list(ctx context.Context) ([]IngressInfo, error) {
+	var names map[string][]string
+	if err := c.get(ctx, "/v1/catalog/services", &names); err != nil {
+		return nil, err
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		tiles []IngressInfo
+		errs  []error
+	)
+	limit := make(chan struct{}, consulListConcurrency)
+	for name, tags := range names {
+		if name == "consul" || !c.wanted(tags) {
+			continue
+		}
+		wg.Go(func() {
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			var instances []consulInstance
+			err := c.get(ctx, "/v1/catalog/service/"+url.PathEscape(name), &instances)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			if info, ok := c.tile(instances); ok {
+				tiles = append(tiles, info)
+			}
+		})
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		// A partial listing would make tiles come and go.
+		return nil, fmt.Errorf("failed to list %d Consul services, e.g.: %w", len(errs), errs[0])
+	}
+	sort.Slice(tiles, func(i, j int) bool { return tiles[i].Name < tiles[j].Name })
+	return tiles, nil
+}
+
+// wanted reports whether a service with tags passes CONSUL_TAGS.
+func (c *ConsulCatalog) wanted(tags []string) bool {
+	if len(c.tags) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(tags, func(t string) bool { return slices.Contains(c.tags, t) })
+}
+
+// tile builds the tile of a service from its first instance, or reports
+// false when it is hidden or has no usable URL.
+func (c *ConsulCatalog) tile(instances []consulInstance) (IngressInfo, bool) {
+	if len(instances) == 0 {
+		return IngressInfo{}, false
+	}
+	in := instances[0]
+	data := serviceURLData{
+		Name:       in.ServiceName,
+		Address:    cmp.Or(in.ServiceAddress, in.Address),
+		Port:       in.ServicePort,
+		Node:       in.Node,
+		Datacenter: in.Datacenter,
+		Tags:       in.ServiceTags,
+		Meta:       in.ServiceMeta,
+	}
+	return serviceTile("Consul service "+in.ServiceName, consulNamespace, c.template, data)
+}
+
+// get decodes the JSON response to a GET of path into v.
+func (c *ConsulCatalog) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// visibleTiles returns the tiles of every discovery provider, split into
+// apps and services like GetVisibleIngresses and sorted by name. The error
+// is that of the ingress listing, which the degraded banner describes;
+// other providers that fail are logged and contribute their last good
+// listing.
+func (s *Server) visibleTiles(ctx context.Context) (apps, services []IngressInfo, err error) {
+	var tiles []IngressInfo
+	for _, p := range s.providers {
+		found, providerErr := p.Discover(ctx)
+		if _, ingresses := p.(ingressProvider); ingresses {
+			err = providerErr
+		} else if providerErr != nil {
+			log.Printf("Warning: Could not list %s: %v", p.Name(), providerErr)
+		}
+		tiles = append(tiles, found...)
+	}
+	apps, services = splitTiles(tiles)
+	return apps, services, err
+}
+
+// splitTiles splits tiles into apps and services, each sorted by name.
+// Tiles of the same name keep their order.
+func splitTiles(tiles []IngressInfo) (apps, services []IngressInfo) {
+	for _, info := range tiles {
+		if info.IsApp {
+			apps = append(apps, info)
+		} else {
+			services = append(services, info)
+		}
+	}
+	sort.SliceStable(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	sort.SliceStable(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return apps, services
+}
+
+// defaultServiceURLTemplate builds the URL of a service found outside
+// Kubernetes from its instance.
+const defaultServiceURLTemplate = "http://{{.Address}}:{{.Port}}"
+
+// Service meta keys that configure the tile of a service found outside
+// Kubernetes, like the gohome.stringer.sh/* annotations do for an ingress.
+// gohome-health-* keys stand in for the health annotations.
+const (
+	serviceMetaURL          = "gohome-url"
+	serviceMetaName         = "gohome-name"
+	serviceMetaIcon         = "gohome-icon"
+	serviceMetaApp          = "gohome-app"
+	serviceMetaHide         = "gohome-hide"
+	serviceMetaNewTab       = "gohome-new-tab"
+	serviceMetaHealthPrefix = "gohome-health-"
+)
+
+// serviceURLData is what a service URL template is executed with.
+type serviceURLData struct {
+	Name       string
+	Address    string
+	Port       int
+	Node       string
+	Datacenter string
+	Tags       []string
+	Meta       map[string]string
+}
+
+// urlTemplateFuncs are the template functions that service URL templates
+// share with the page templates, e.g. {{index .Meta "host" | default .Address}}.
+var urlTemplateFuncs = template.FuncMap{
+	"default":  fallback,
+	"coalesce": coalesce,
+	"host":     hostname,
+}
+
+// urlTemplateFromEnv parses the service URL template in the named
+// variable, falling back to fallback.
+func urlTemplateFromEnv(name, fallback string) *template.Template {
+	text := os.Getenv(name)
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Option("missingkey=zero").Funcs(urlTemplateFuncs).Parse(text)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %q: %v", name, text, fallback, err)
+		tmpl = template.Must(template.New(name).Parse(fallback))
+	}
+	return tmpl
+}
+
+// serviceTile builds the tile of a service found outside Kubernetes, shown
+// in namespace and grouped by cluster under its datacenter, or reports
+// false when its meta hides it or it has no usable URL. source names it in
+// warnings.
+func serviceTile(source, namespace string, tmpl *template.Template, data serviceURLData) (IngressInfo, bool) {
+	meta := data.Meta
+	if meta[serviceMetaHide] == "true" {
+		return IngressInfo{}, false
+	}
+	rawURL := meta[serviceMetaURL]
+	if rawURL == "" {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			log.Printf("Warning: %s failed for %s: %v", tmpl.Name(), source, err)
+			return IngressInfo{}, false
+		}
+		rawURL = b.String()
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		log.Printf("Warning: Skipping %s with invalid URL %q", source, rawURL)
+		return IngressInfo{}, false
+	}
+
+	health := make(map[string]string)
+	for k, v := range meta {
+		if setting, ok := strings.CutPrefix(k, serviceMetaHealthPrefix); ok {
+			health[HealthAnnotationPrefix+setting] = v
+		}
+	}
+	info := IngressInfo{
+		Name:        cmp.Or(meta[serviceMetaName], data.Name),
+		Namespace:   namespace,
+		Cluster:     data.Datacenter,
+		Host:        u.Hostname(),
+		Path:        u.Path,
+		URL:         rawURL,
+		IsApp:       meta[serviceMetaApp] == "true",
+		Tags:        data.Tags,
+		Icon:        meta[serviceMetaIcon],
+		Labels:      meta,
+		Target:      parseNewTab(meta[serviceMetaNewTab], source),
+		HealthCheck: healthPolicyFromAnnotations(health, source),
+	}
+	info.IconURL = resolveIcon(info.Icon)
+	return info, true
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDNSCheckTTL is how long a lookup result is reused.
+	defaultDNSCheckTTL = 5 * time.Minute
+	// dnsCheckTimeout bounds the lookups made while rendering a page; hosts
+	// that take longer aren't flagged this time.
+	dnsCheckTimeout = 2 * time.Second
+)
+
+// DNSChecker resolves the host of every tile before the page is rendered
+// and flags the ones that don't resolve, usually an ingress whose DNS
+// record was never created. Results are cached for DNS_CHECK_TTL.
+type DNSChecker struct {
+	cache *Cache[bool] // whether each host is missing from DNS
+
+	mu      sync.Mutex
+	failing map[string]bool // hosts last seen not resolving, to log each once
+}
+
+// NewDNSCheckerFromEnv returns a checker when DNS_CHECKS=true, and nil
+// otherwise.
+func NewDNSCheckerFromEnv() *DNSChecker {
+	if os.Getenv("DNS_CHECKS") != "true" {
+		return nil
+	}
+	cache := NewCache[bool](durationFromEnv("DNS_CHECK_TTL", defaultDNSCheckTTL))
+	cache.name = "dns"
+	return &DNSChecker{
+		cache:   cache,
+		failing: make(map[string]bool),
+	}
+}
+
+// Check resolves each of hosts, concurrently and from the cache where it
+// can, and returns the ones that don't resolve. Lookups still running
+// after dnsCheckTimeout finish in the background for the next call.
+func (d *DNSChecker) Check(ctx context.Context, hosts []string) map[string]bool {
+	if d == nil {
+		return nil
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	missing := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		if _, err := netip.ParseAddr(host); err == nil {
+			continue
+		}
+		wg.Go(func() {
+			failing, _ := d.cache.Get(ctx, host, func(ctx context.Context) (bool, error) {
+				return d.lookup(ctx, host), nil
+			})
+			if failing {
+				mu.Lock()
+				missing[host] = true
+				mu.Unlock()
+			}
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	case <-time.After(dnsCheckTimeout):
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return maps.Clone(missing)
+}
+
+// lookup resolves host, reporting it missing only when the name definitely
+// doesn't exist. Timeouts and server failures say more about the resolver
+// than about the record, and would flag every tile at once.
+func (d *DNSChecker) lookup(ctx context.Context, host string) bool {
+	_, err := net.DefaultResolver.LookupHost(ctx, host)
+	var dnsErr *net.DNSError
+	failing := err != nil && errors.As(err, &dnsErr) && dnsErr.IsNotFound
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if failing && !d.failing[host] {
+		log.Printf("Warning: %s doesn't resolve: %v", host, err)
+	}
+	d.failing[host] = failing
+	return failing
+}
+
+// applyDNS flags the ingresses and bookmarks whose host doesn't resolve.
+func (s *Server) applyDNS(ctx context.Context, apps, services []IngressInfo, bookmarks []Bookmark) {
+	if s.dns == nil {
+		return
+	}
+	var hosts []string
+	for _, list := range [][]IngressInfo{apps, services} {
+		for _, info := range list {
+			hosts = append(hosts, info.Host)
+		}
+	}
+	for _, b := range bookmarks {
+		hosts = append(hosts, bookmarkHost(b.URL))
+	}
+
+	missing := s.dns.Check(ctx, hosts)
+	for _, list := range [][]IngressInfo{apps, services} {
+		for i := range list {
+			list[i].NoDNS = missing[list[i].Host]
+		}
+	}
+	for i := range bookmarks {
+		bookmarks[i].NoDNS = missing[bookmarkHost(bookmarks[i].URL)]
+	}
+}
+
+// bookmarkHost returns the host name of a bookmark URL, without a port.
+func bookmarkHost(rawURL string) string {
+	host, _ := targetHostPort(rawURL)
+	return host
+}
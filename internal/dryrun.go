@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// DryRun resolves the configuration and discovers tiles the way the
+// homepage does, then writes what it would show to w: the title, the
+// settings, every tile with who may see it, and the ingresses and
+// bookmarks left out and why. Warnings about the ConfigMap, such as
+// bookmarks that couldn't be parsed, go to the log as usual. It is what
+// gohome --dry-run prints.
+func (s *Server) DryRun(ctx context.Context, w io.Writer) error {
+	// Links files and mDNS are otherwise fetched by RunBackground.
+	s.scheduler.RunOnce(ctx, s.linkJobs()...)
+	s.bookmarkManager.InvalidateCache()
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tiles []IngressInfo
+	var skipped []skippedIngress
+	for _, p := range s.providers {
+		if e, ok := p.(explainer); ok {
+			found, left, err := e.Explain(ctx)
+			if err != nil {
+				return err
+			}
+			tiles, skipped = append(tiles, found...), append(skipped, left...)
+			continue
+		}
+		found, err := p.Discover(ctx)
+		if err != nil {
+			log.Printf("Warning: Could not list %s: %v", p.Name(), err)
+		}
+		tiles = append(tiles, found...)
+	}
+	apps, services := splitTiles(tiles)
+	categories := groupBookmarks(config.Bookmarks)
+	applyTileOrder(config, apps, services, categories)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if s.kube() == nil {
+		fmt.Fprintln(tw, "Demo mode: Kubernetes is unreachable, so these are the sample tiles and bookmarks.")
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintf(tw, "Title:\t%s\n", config.Title)
+	fmt.Fprintf(tw, "ConfigMap:\t%s\n", s.bookmarkManager.ConfigMapRef())
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Settings")
+	for _, setting := range dryRunSettings(config) {
+		fmt.Fprintf(tw, "  %s\t%s\n", setting[0], setting[1])
+	}
+
+	for _, section := range []struct {
+		name  string
+		tiles []IngressInfo
+	}{{"Apps", apps}, {"Services", services}} {
+		fmt.Fprintf(tw, "\n%s (%d)\n", section.name, len(section.tiles))
+		for _, info := range section.tiles {
+			source := info.Namespace + "/" + info.Name
+			if info.Sample {
+				source = "sample"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", info.Name, info.URL, source, describeRestrictions(ingressRestrictions(config.Visibility, info)))
+		}
+	}
+
+	fmt.Fprintf(tw, "\nBookmarks (%d)\n", len(config.Bookmarks))
+	for _, category := range categories {
+		fmt.Fprintf(tw, "  %s\n", category.Name)
+		for _, b := range category.Bookmarks {
+			fmt.Fprintf(tw, "    %s\t%s\t%s\n", b.Name, b.URL, describeRestrictions(bookmarkRestrictions(config.Visibility, b)))
+		}
+	}
+
+	if len(config.Pages) > 0 {
+		fmt.Fprintf(tw, "\nPages (%d)\n", len(config.Pages))
+		for i := range config.Pages {
+			page := &config.Pages[i]
+			pageApps, pageServices, pageBookmarks := page.filter(apps, services, config.Bookmarks)
+			fmt.Fprintf(tw, "  /%s\t%s\t%d apps, %d services, %d bookmarks\n", page.Slug, page.Title, len(pageApps), len(pageServices), len(pageBookmarks))
+		}
+	}
+
+	leftOut := make([][2]string, 0, len(skipped))
+	for _, ingress := range skipped {
+		leftOut = append(leftOut, [2]string{"ingress " + ingress.Namespace + "/" + ingress.Name, ingress.Reason})
+	}
+	leftOut = append(leftOut, s.skippedBookmarks(ctx)...)
+	fmt.Fprintf(tw, "\nLeft out (%d)\n", len(leftOut))
+	for _, item := range leftOut {
+		fmt.Fprintf(tw, "  %s\t%s\n", item[0], item[1])
+	}
+	return tw.Flush()
+}
+
+// skippedBookmarks returns the bookmark keys of the ConfigMap that
+// parseBookmarks drops for having no URL, as key and reason.
+func (s *Server) skippedBookmarks(ctx context.Context) [][2]string {
+	if s.kube() == nil {
+		return nil
+	}
+	backup, err := s.bookmarkManager.Backup(ctx)
+	if err != nil {
+		log.Printf("Warning: Could not read ConfigMap %s: %v", s.bookmarkManager.ConfigMapRef(), err)
+		return nil
+	}
+	var skipped [][2]string
+	for _, key := range slices.Sorted(maps.Keys(backup.Data)) {
+		if strings.HasPrefix(key, "bookmark-") && s.bookmarkManager.parseBookmarkEntry(key, backup.Data[key]).URL == "" {
+			skipped = append(skipped, [2]string{key, "no URL"})
+		}
+	}
+	return skipped
+}
+
+// dryRunSettings lists the resolved settings as name and value, named
+// after their ConfigMap keys.
+func dryRunSettings(config *Config) [][2]string {
+	collapsed := strings.Join(config.Collapsed, ",")
+	details := strings.Join(slices.Sorted(maps.Keys(config.TileDetails)), ",")
+	settings := [][2]string{
+		{"theme", config.Theme},
+		{"palette", config.Palette},
+		{"theme-color", config.ThemeColor},
+		{"layout", config.Layout},
+		{"group-by", config.GroupBy},
+		{"compact", config.Compact},
+		{"language", config.Language},
+		{"new-tab", strconv.FormatBool(config.NewTab)},
+		{"recent", strconv.Itoa(config.Recent)},
+		{"click-stats", strconv.FormatBool(config.ClickStats)},
+		{"most-used", strconv.Itoa(config.MostUsed)},
+		{"launch-check", strconv.FormatBool(config.Launch)},
+		{"collapsed", collapsed},
+		{"tile-details", details},
+		{"search-engine", config.SearchEngine},
+		{"favicon", config.FaviconURL},
+	}
+	for i := range settings {
+		if settings[i][1] == "" {
+			settings[i][1] = "-"
+		}
+	}
+	return settings
+}
+
+// describeRestrictions explains who may see a tile, for DryRun.
+func describeRestrictions(restrictions []restriction) string {
+	if len(restrictions) == 0 {
+		return "everyone"
+	}
+	descriptions := make([]string, len(restrictions))
+	for i, r := range restrictions {
+		descriptions[i] = fmt.Sprintf("groups %s (%s)", strings.Join(r.Groups, ","), r.Source)
+	}
+	return "only " + strings.Join(descriptions, " and ")
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+)
+
+var (
+	// exportStylesheet matches a link to one of our stylesheets, which
+	// ExportHTML replaces by a style element.
+	exportStylesheet = regexp.MustCompile(`<link rel="stylesheet" href="(/[^/"][^"]*)"[^>]*>`)
+	// exportImage matches the attribute of an image or icon served by us,
+	// whose value ExportHTML replaces by a data URL.
+	exportImage = regexp.MustCompile(`(<img [^>]*src|<link rel="(?:icon|apple-touch-icon)"[^>]*href)="(/[^/"][^"]*)"`)
+)
+
+// ExportHTML renders the homepage as one self-contained HTML file: the
+// tiles everyone may see, the widgets and health as they are now, and no
+// scripts, with our stylesheets and images inlined. It can be served from
+// anywhere, or opened from disk when the cluster is down, and is what
+// gohome export-html writes. Only the web font still comes from Google
+// Fonts, falling back to the system's monospace font offline.
+func (s *Server) ExportHTML(ctx context.Context, w io.Writer) error {
+	// Fetch once what the background jobs would, links first since the
+	// other jobs read the bookmarks.
+	s.scheduler.RunOnce(ctx, s.linkJobs()...)
+	s.bookmarkManager.InvalidateCache()
+	s.scheduler.RunOnce(ctx, s.widgetJobs()...)
+
+	rec := httptest.NewRecorder()
+	s.renderHome(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), viewExport, "")
+	if rec.Code != http.StatusOK {
+		return fmt.Errorf("rendering the homepage: %s", strings.TrimSpace(rec.Body.String()))
+	}
+	page := rec.Body.String()
+
+	page = exportStylesheet.ReplaceAllStringFunc(page, func(link string) string {
+		path := html.UnescapeString(exportStylesheet.FindStringSubmatch(link)[1])
+		css, _, err := s.exportAsset(ctx, path)
+		if err != nil {
+			log.Printf("Warning: Could not inline %s: %v", path, err)
+			return link
+		}
+		// Nothing in a stylesheet of ours can close the element early.
+		return "<style>\n" + string(css) + "</style>"
+	})
+	page = exportImage.ReplaceAllStringFunc(page, func(attr string) string {
+		m := exportImage.FindStringSubmatch(attr)
+		path := html.UnescapeString(m[2])
+		body, contentType, err := s.exportAsset(ctx, path)
+		if err != nil {
+			log.Printf("Warning: Could not inline %s: %v", path, err)
+			return attr
+		}
+		return fmt.Sprintf(`%s="data:%s;base64,%s"`, m[1], contentType, base64.StdEncoding.EncodeToString(body))
+	})
+
+	_, err := io.WriteString(w, page)
+	return err
+}
+
+// exportAsset fetches one of our own URLs, such as /static/style.css or an
+// /images URL, for ExportHTML.
+func (s *Server) exportAsset(ctx context.Context, path string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		return nil, "", fmt.Errorf("status %d", rec.Code)
+	}
+	body := rec.Body.Bytes()
+	contentType := rec.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	// "text/css; charset=utf-8" goes in a data URL without the space.
+	return body, strings.ReplaceAll(contentType, " ", ""), nil
+}
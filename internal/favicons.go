@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxFaviconBytes caps the size of a scraped favicon
+	maxFaviconBytes = 256 << 10
+	// maxFaviconPageBytes caps how much of a target's HTML is read looking for <link rel="icon">
+	maxFaviconPageBytes = 256 << 10
+	// faviconRefresh is how long a scraped favicon is kept before re-fetching
+	faviconRefresh = 24 * time.Hour
+	// faviconRetry is how long to wait before retrying a host whose favicon couldn't be found
+	faviconRetry = 6 * time.Hour
+	// faviconQueueInterval is how often hosts queued by page renders are fetched
+	faviconQueueInterval = 2 * time.Second
+)
+
+// linkIconPattern finds <link> tags whose rel contains "icon". Attribute order
+// varies, so the tag is matched first and its attributes picked out after.
+var (
+	linkTagPattern  = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	relIconPattern  = regexp.MustCompile(`(?is)\brel\s*=\s*["']?[^"'>]*\bicon\b`)
+	hrefAttrPattern = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// FaviconScraper fetches favicons for tiles that don't have an icon
+// configured. Hosts are queued as pages render and fetched by a background
+// worker, so a slow or unreachable service never delays the homepage; the
+// icon simply appears on a later load once it has been scraped.
+//
+// With a data store (see sharedStore) where each icon was found is
+// remembered across restarts, so it is fetched again without scraping the
+// page, and hosts without one aren't retried before faviconRetry.
+type FaviconScraper struct {
+	client  *http.Client
+	queue   chan string
+	mu      sync.Mutex
+	icons   map[string]cachedIcon // keyed by the target's base URL (scheme://host)
+	sources map[string]string     // where each icon was last found, by the same key
+	pending map[string]bool
+	store   Store
+}
+
+// faviconMeta is what the data store keeps about one host's favicon.
+type faviconMeta struct {
+	Source  string    `json:"source,omitempty"` // URL of the icon; empty if none was found
+	Fetched time.Time `json:"fetched"`
+}
+
+// NewFaviconScraper creates a scraper with an empty cache, seeded with what
+// the data store remembers.
+func NewFaviconScraper() *FaviconScraper {
+	f := &FaviconScraper{
+		client:  &http.Client{Timeout: 5 * time.Second},
+		queue:   make(chan string, 256),
+		icons:   make(map[string]cachedIcon),
+		sources: make(map[string]string),
+		pending: make(map[string]bool),
+		store:   sharedStore(),
+	}
+	if f.store != nil {
+		metas, err := listJSON[faviconMeta](context.Background(), f.store, storeNamespaceFavicons)
+		if err != nil {
+			log.Printf("Warning: Could not load favicon metadata: %v", err)
+		}
+		for key, meta := range metas {
+			if meta.Source != "" {
+				f.sources[key] = meta.Source
+			} else if time.Since(meta.Fetched) < faviconRetry {
+				f.icons[key] = cachedIcon{fetched: meta.Fetched}
+			}
+		}
+	}
+	return f
+}
+
+// faviconKey returns the scheme://host a target's favicon is scraped from,
+// or "" if rawURL isn't an absolute http(s) URL.
+func faviconKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// IconURL returns the local URL for the target's scraped favicon if one is
+// ready, and queues a fetch if it's missing or stale.
+func (f *FaviconScraper) IconURL(rawURL string) string {
+	if f == nil {
+		return ""
+	}
+	key := faviconKey(rawURL)
+	if key == "" {
+		return ""
+	}
+
+	f.mu.Lock()
+	icon, ok := f.icons[key]
+	stale := !ok ||
+		(icon.body != nil && time.Since(icon.fetched) > faviconRefresh) ||
+		(icon.body == nil && time.Since(icon.fetched) > faviconRetry)
+	if stale && !f.pending[key] {
+		select {
+		case f.queue <- key:
+			f.pending[key] = true
+		default:
+			// Queue full; we'll try again on the next render.
+		}
+	}
+	f.mu.Unlock()
+
+	if !ok || icon.body == nil {
+		return ""
+	}
+	scheme, host, _ := strings.Cut(key, "://")
+	return "/favicons/" + scheme + "/" + url.PathEscape(host)
+}
+
+// Job fetches the favicons queued since its last run, every
+// faviconQueueInterval.
+func (f *FaviconScraper) Job() Job {
+	if f == nil {
+		return Job{}
+	}
+	return Job{Name: "favicons", Interval: faviconQueueInterval, Run: f.drain}
+}
+
+// drain fetches queued favicons until the queue is empty. Hosts without a
+// favicon aren't errors; failing to remember them is.
+func (f *FaviconScraper) drain(ctx context.Context) error {
+	var errs []error
+	for ctx.Err() == nil {
+		var key string
+		select {
+		case key = <-f.queue:
+		default:
+			return errors.Join(errs...)
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		icon, source, err := f.scrape(fetchCtx, key)
+		cancel()
+		if err != nil {
+			log.Printf("Info: No favicon for %s: %v", key, err)
+		}
+		icon.fetched = time.Now()
+
+		f.mu.Lock()
+		f.icons[key] = icon
+		f.sources[key] = source
+		delete(f.pending, key)
+		f.mu.Unlock()
+
+		if f.store != nil {
+			if err := setJSON(ctx, f.store, storeNamespaceFavicons, key, faviconMeta{Source: source, Fetched: icon.fetched}); err != nil {
+				log.Printf("Warning: Could not save favicon metadata for %s: %v", key, err)
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Get returns a scraped favicon by its cache key.
+func (f *FaviconScraper) Get(key string) (cachedIcon, bool) {
+	if f == nil {
+		return cachedIcon{}, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	icon, ok := f.icons[key]
+	return icon, ok && icon.body != nil
+}
+
+// Len returns the number of hosts the scraper knows about, including failures.
+func (f *FaviconScraper) Len() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.icons)
+}
+
+// scrape tries where the icon was found last time, then looks for a
+// <link rel="icon"> on the target's front page and falls back to
+// /favicon.ico. It returns the icon and the URL it came from.
+func (f *FaviconScraper) scrape(ctx context.Context, base string) (cachedIcon, string, error) {
+	f.mu.Lock()
+	known := f.sources[base]
+	f.mu.Unlock()
+	if known != "" {
+		if icon, err := fetchImage(ctx, f.client, known, maxFaviconBytes); err == nil {
+			return icon, known, nil
+		}
+	}
+
+	candidates := []string{}
+	if href := f.findLinkIcon(ctx, base); href != "" {
+		candidates = append(candidates, href)
+	}
+	candidates = append(candidates, base+"/favicon.ico")
+
+	var err error
+	for _, candidate := range candidates {
+		var icon cachedIcon
+		if icon, err = fetchImage(ctx, f.client, candidate, maxFaviconBytes); err == nil {
+			return icon, candidate, nil
+		}
+	}
+	return cachedIcon{}, "", err
+}
+
+// findLinkIcon fetches the target's front page and returns the absolute URL
+// of the first <link rel="icon"> it declares, or "" if there isn't one.
+func (f *FaviconScraper) findLinkIcon(ctx context.Context, base string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/", nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconPageBytes))
+	if err != nil {
+		return ""
+	}
+	for _, tag := range linkTagPattern.FindAll(page, -1) {
+		if !relIconPattern.Match(tag) {
+			continue
+		}
+		m := hrefAttrPattern.FindSubmatch(tag)
+		if m == nil {
+			continue
+		}
+		href := string(m[1]) + string(m[2]) + string(m[3])
+		// Resolve relative to the final URL in case the front page redirected.
+		if ref, err := resp.Request.URL.Parse(strings.TrimSpace(href)); err == nil && (ref.Scheme == "http" || ref.Scheme == "https") {
+			return ref.String()
+		}
+	}
+	return ""
+}
+
+// handleFaviconProxy serves /favicons/{scheme}/{host} for a favicon that has
+// already been scraped. Only hosts the scraper has fetched are served, so this
+// can't be used to make GoHome request arbitrary URLs.
+func (s *Server) handleFaviconProxy(w http.ResponseWriter, r *http.Request) {
+	icon, ok := s.favicons.Get(r.PathValue("scheme") + "://" + r.PathValue("host"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeImage(w, icon)
+}
+
+// applyFavicons fills in IconURL from the favicon scraper for any ingress
+// that doesn't have an icon configured.
+func (s *Server) applyFavicons(items []IngressInfo) {
+	for i := range items {
+		if items[i].IconURL == "" {
+			items[i].IconURL = s.favicons.IconURL(items[i].URL)
+		}
+	}
+}
+
+// applyBookmarkFavicons is applyFavicons for bookmarks.
+func (s *Server) applyBookmarkFavicons(bookmarks []Bookmark) {
+	for i := range bookmarks {
+		if bookmarks[i].IconURL == "" {
+			bookmarks[i].IconURL = s.favicons.IconURL(bookmarks[i].URL)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package internal
+
+import "fmt"
+
+// Favorite is one tile in the visitor's pinned row. Exactly one of Ingress
+// and Bookmark is set.
+type Favorite struct {
+	Ingress  *IngressInfo
+	Bookmark *Bookmark
+}
+
+// URL returns the address the tile links to.
+func (f Favorite) URL() string {
+	if f.Ingress != nil {
+		return f.Ingress.URL
+	}
+	return f.Bookmark.URL
+}
+
+// tileID returns the stable identifier of an ingress or bookmark tile, as
+// used in data-id attributes, custom ordering and pinned favorites.
+func tileID(item any) (string, error) {
+	switch v := item.(type) {
+	case IngressInfo:
+		return ingressTileID(v), nil
+	case *IngressInfo:
+		return ingressTileID(*v), nil
+	case Bookmark:
+		return bookmarkTileID(v), nil
+	case *Bookmark:
+		return bookmarkTileID(*v), nil
+	}
+	return "", fmt.Errorf("tileID: unsupported type %T", item)
+}
+
+// buildFavorites resolves the visitor's pinned tile IDs against what is
+// currently on the page, in pin order. IDs that no longer match anything
+// (a removed ingress, a renamed bookmark) are skipped.
+func buildFavorites(ids []string, apps, services []IngressInfo, bookmarks []Bookmark) []Favorite {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]Favorite)
+	for _, list := range [][]IngressInfo{apps, services} {
+		for i := range list {
+			byID[ingressTileID(list[i])] = Favorite{Ingress: &list[i]}
+		}
+	}
+	for i := range bookmarks {
+		byID[bookmarkTileID(bookmarks[i])] = Favorite{Bookmark: &bookmarks[i]}
+	}
+
+	var favorites []Favorite
+	for _, id := range ids {
+		if f, ok := byID[id]; ok {
+			favorites = append(favorites, f)
+			delete(byID, id) // ignore duplicates in the cookie
+		}
+	}
+	return favorites
+}
+
+// pinnedSet returns the visitor's pinned IDs as a set for template lookups.
+func pinnedSet(favorites []Favorite) map[string]bool {
+	pinned := make(map[string]bool, len(favorites))
+	for _, f := range favorites {
+		if f.Ingress != nil {
+			pinned[ingressTileID(*f.Ingress)] = true
+		} else {
+			pinned[bookmarkTileID(*f.Bookmark)] = true
+		}
+	}
+	return pinned
+}
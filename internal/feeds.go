@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	// feedKeyPrefix marks ConfigMap keys that define a feed:
+	//   feed-<name>: "https://example.com/feed.xml|limit=10"
+	feedKeyPrefix = "feed-"
+	// defaultFeedLimit is how many headlines a feed shows unless limit= is set.
+	defaultFeedLimit = 5
+	// defaultFeedInterval is how often every feed is refetched.
+	defaultFeedInterval = 30 * time.Minute
+	// maxFeedBytes caps the size of a fetched feed document.
+	maxFeedBytes = 2 << 20
+)
+
+// FeedConfig is one feed from the ConfigMap.
+type FeedConfig struct {
+	Name  string
+	URL   string
+	Limit int
+}
+
+// FeedItem is one headline.
+type FeedItem struct {
+	Title     string
+	Link      string
+	Published time.Time // zero if the feed didn't say
+}
+
+// FeedPanel is a feed as rendered on the homepage: its latest headlines and,
+// if the last attempt failed, the error.
+type FeedPanel struct {
+	Name    string
+	URL     string
+	Items   []FeedItem
+	Err     string    // set when the most recent fetch failed
+	Fetched time.Time // time of the last successful fetch
+}
+
+// FeedAggregator periodically fetches the configured RSS and Atom feeds in
+// the background and caches their parsed items. A feed that fails keeps
+// showing its last good items alongside the error.
+type FeedAggregator struct {
+	client   *http.Client
+	interval time.Duration
+	feeds    widgetCache[[]FeedItem] // keyed by feed URL
+}
+
+// NewFeedAggregatorFromEnv creates an aggregator that refetches every
+// FEED_INTERVAL.
+func NewFeedAggregatorFromEnv() *FeedAggregator {
+	return &FeedAggregator{
+		client:   &http.Client{Timeout: 15 * time.Second},
+		interval: durationFromEnv("FEED_INTERVAL", defaultFeedInterval),
+	}
+}
+
+// parseFeedEntry parses a feed-<name> ConfigMap value: the URL followed by
+// optional |key=value settings.
+func parseFeedEntry(key, value string) (FeedConfig, bool) {
+	parts := strings.Split(value, "|")
+	feed := FeedConfig{
+		Name:  strings.TrimPrefix(key, feedKeyPrefix),
+		URL:   strings.TrimSpace(parts[0]),
+		Limit: defaultFeedLimit,
+	}
+	if feed.URL == "" {
+		log.Printf("Warning: feed %s has no URL, skipping", feed.Name)
+		return feed, false
+	}
+	for _, opt := range parts[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch k {
+		case "limit":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				feed.Limit = n
+			} else {
+				log.Printf("Warning: feed %s has invalid limit %q", feed.Name, v)
+			}
+		default:
+			log.Printf("Warning: feed %s has unknown option %q", feed.Name, opt)
+		}
+	}
+	return feed, true
+}
+
+// parseFeeds reads every feed-* key from ConfigMap data, sorted by name.
+func parseFeeds(data map[string]string) []FeedConfig {
+	var feeds []FeedConfig
+	for key, value := range data {
+		if !strings.HasPrefix(key, feedKeyPrefix) {
+			continue
+		}
+		if feed, ok := parseFeedEntry(key, value); ok {
+			feeds = append(feeds, feed)
+		}
+	}
+	slices.SortFunc(feeds, func(a, b FeedConfig) int { return strings.Compare(a.Name, b.Name) })
+	return feeds
+}
+
+// Name names the feeds widget.
+func (a *FeedAggregator) Name() string { return "feeds" }
+
+// Interval is a minute at most, so a newly added feed shows up within a
+// minute even though feeds are only refetched every FEED_INTERVAL.
+func (a *FeedAggregator) Interval() time.Duration { return min(a.interval, time.Minute) }
+
+// Refresh refetches the configured feeds that are due. State for feeds
+// that are no longer configured is dropped.
+func (a *FeedAggregator) Refresh(ctx context.Context, config *Config) error {
+	fetches := make([]widgetFetch[[]FeedItem], 0, len(config.Feeds))
+	for _, feed := range config.Feeds {
+		fetches = append(fetches, widgetFetch[[]FeedItem]{
+			key:   feed.URL,
+			name:  "feed " + feed.Name,
+			every: a.interval,
+			fetch: func(ctx context.Context) ([]FeedItem, error) { return a.fetch(ctx, feed.URL) },
+		})
+	}
+	return a.feeds.refresh(ctx, 30*time.Second, fetches)
+}
+
+// Render returns the Panels of the configured feeds.
+func (a *FeedAggregator) Render(config *Config, _ *Locale) any {
+	return a.Panels(config.Feeds)
+}
+
+// Panels returns the cached headlines for each configured feed, trimmed to
+// its limit. Feeds that haven't been fetched yet are left out.
+func (a *FeedAggregator) Panels(feeds []FeedConfig) []FeedPanel {
+	if a == nil {
+		return nil
+	}
+	var panels []FeedPanel
+	for _, feed := range feeds {
+		state, ok := a.feeds.get(feed.URL)
+		if !ok {
+			continue
+		}
+		panels = append(panels, FeedPanel{
+			Name:    feed.Name,
+			URL:     feed.URL,
+			Items:   state.value[:min(len(state.value), feed.Limit)],
+			Err:     state.errString(),
+			Fetched: state.fetched,
+		})
+	}
+	return panels
+}
+
+// Len returns the number of feeds with cached state.
+func (a *FeedAggregator) Len() int {
+	if a == nil {
+		return 0
+	}
+	return a.feeds.Len()
+}
+
+// fetch downloads and parses one feed.
+func (a *FeedAggregator) fetch(ctx context.Context, rawURL string) ([]FeedItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gohome-feeds")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return parseFeed(io.LimitReader(resp.Body, maxFeedBytes))
+}
+
+// rssItem, atomEntry and feedDocument cover RSS 2.0, RSS 1.0 (RDF) and Atom.
+type rssItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+}
+
+type feedDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`  // RSS 1.0 puts items beside the channel
+	Entries []atomEntry `xml:"entry"` // Atom
+}
+
+// parseFeed decodes an RSS or Atom document into items, newest first as the
+// feed lists them.
+func parseFeed(r io.Reader) ([]FeedItem, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+
+	var doc feedDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing feed: %w", err)
+	}
+
+	var items []FeedItem
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		for _, it := range append(doc.Channel.Items, doc.Items...) {
+			items = append(items, FeedItem{
+				Title:     cleanFeedText(it.Title),
+				Link:      strings.TrimSpace(it.Link),
+				Published: parseFeedTime(firstNonEmpty(it.PubDate, it.Date)),
+			})
+		}
+	case "feed":
+		for _, e := range doc.Entries {
+			items = append(items, FeedItem{
+				Title:     cleanFeedText(e.Title),
+				Link:      atomEntryLink(e.Links),
+				Published: parseFeedTime(firstNonEmpty(e.Published, e.Updated)),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("not an RSS or Atom feed (root element <%s>)", doc.XMLName.Local)
+	}
+	return items, nil
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// atomEntryLink picks an entry's alternate (HTML) link.
+func atomEntryLink(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// cleanFeedText unescapes and collapses whitespace in a title, which some
+// feeds double-encode or wrap across lines.
+func cleanFeedText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// feedTimeLayouts are the date formats seen in the wild, most common first.
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseFeedTime parses a feed date, returning the zero time if it can't.
+func parseFeedTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name, doc string
+		titles    []string
+		links     []string
+		published []time.Time
+	}{
+		{
+			name: "RSS 2.0",
+			doc: `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title>
+<item><title>  Release
+  1.2 &amp;amp; more </title><link> https://example.com/1.2 </link><pubDate>Wed, 14 Oct 2026 09:30:00 +0000</pubDate></item>
+<item><title>Older</title><link>https://example.com/1.1</link><pubDate>not a date</pubDate></item>
+</channel></rss>`,
+			titles:    []string{"Release 1.2 & more", "Older"},
+			links:     []string{"https://example.com/1.2", "https://example.com/1.1"},
+			published: []time.Time{time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC), {}},
+		},
+		{
+			name: "RSS 1.0",
+			doc: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel><title>News</title></channel>
+<item><title>Dated</title><link>https://example.com/a</link><dc:date>2026-10-14T09:30:00Z</dc:date></item>
+</rdf:RDF>`,
+			titles:    []string{"Dated"},
+			links:     []string{"https://example.com/a"},
+			published: []time.Time{time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)},
+		},
+		{
+			name: "Atom",
+			doc: `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+<entry><title>Post</title><link rel="self" href="https://example.com/self"/><link rel="alternate" href="https://example.com/post"/><updated>2026-10-13T00:00:00Z</updated><published>2026-10-12T00:00:00Z</published></entry>
+<entry><title>Updated only</title><link rel="related" href="https://example.com/related"/><updated>2026-10-11</updated></entry>
+</feed>`,
+			titles:    []string{"Post", "Updated only"},
+			links:     []string{"https://example.com/post", "https://example.com/related"},
+			published: []time.Time{time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:      "Latin-1",
+			doc:       "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><item><title>Caf\xe9</title></item></channel></rss>",
+			titles:    []string{"Café"},
+			links:     []string{""},
+			published: []time.Time{{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := parseFeed(strings.NewReader(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != len(tt.titles) {
+				t.Fatalf("got %d items, want %d: %+v", len(items), len(tt.titles), items)
+			}
+			for i, item := range items {
+				if item.Title != tt.titles[i] || item.Link != tt.links[i] || !item.Published.Equal(tt.published[i]) {
+					t.Errorf("item %d: %+v, want %q, %q, %v", i, item, tt.titles[i], tt.links[i], tt.published[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedRejectsOtherDocuments(t *testing.T) {
+	for _, doc := range []string{"<html><body>Not found</body></html>", "", "{}"} {
+		if items, err := parseFeed(strings.NewReader(doc)); err == nil {
+			t.Errorf("%q parsed as %+v", doc, items)
+		}
+	}
+}
+
+func TestParseFeedTime(t *testing.T) {
+	want := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+	for _, s := range []string{
+		"Wed, 14 Oct 2026 09:30:00 +0000",
+		"Wed, 14 Oct 2026 09:30:00 UTC",
+		"2026-10-14T09:30:00Z",
+		"Wed, 14 Oct 2026 11:30:00 +0200",
+		"14 Oct 2026 09:30:00 +0000",
+		" 2026-10-14T09:30:00 ",
+	} {
+		if got := parseFeedTime(s); !got.Equal(want) {
+			t.Errorf("parseFeedTime(%q) = %v, want %v", s, got, want)
+		}
+	}
+	if got := parseFeedTime("yesterday"); !got.IsZero() {
+		t.Errorf("parseFeedTime(yesterday) = %v, want the zero time", got)
+	}
+}
+
+func TestParseFeeds(t *testing.T) {
+	feeds := parseFeeds(map[string]string{
+		"feed-news":    "https://example.com/news.xml|limit=3",
+		"feed-blog":    "https://example.com/blog.xml|limit=none|colour=red",
+		"feed-empty":   "|limit=3",
+		"bookmark-rss": "https://example.com/rss",
+	})
+	if len(feeds) != 2 {
+		t.Fatalf("got %+v, want blog and news", feeds)
+	}
+	if feeds[0] != (FeedConfig{Name: "blog", URL: "https://example.com/blog.xml", Limit: defaultFeedLimit}) {
+		t.Errorf("blog %+v", feeds[0])
+	}
+	if feeds[1] != (FeedConfig{Name: "news", URL: "https://example.com/news.xml", Limit: 3}) {
+		t.Errorf("news %+v", feeds[1])
+	}
+}
+
+func TestFeedAggregatorKeepsItemsOnFailure(t *testing.T) {
+	var failing atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`<rss><channel><item><title>One</title></item><item><title>Two</title></item><item><title>Three</title></item></channel></rss>`))
+	}))
+	defer srv.Close()
+
+	a := &FeedAggregator{client: srv.Client()}
+	config := &Config{Feeds: []FeedConfig{{Name: "news", URL: srv.URL, Limit: 2}}}
+	if panels := a.Panels(config.Feeds); panels != nil {
+		t.Errorf("panels before any fetch: %+v", panels)
+	}
+	if err := a.Refresh(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	failing.Store(true)
+	if err := a.Refresh(context.Background(), config); err == nil {
+		t.Error("failed fetch not reported")
+	}
+	panels := a.Panels(config.Feeds)
+	if len(panels) != 1 {
+		t.Fatalf("got %+v", panels)
+	}
+	p := panels[0]
+	if len(p.Items) != 2 || p.Items[0].Title != "One" || !strings.Contains(p.Err, "502") || p.Fetched.IsZero() {
+		t.Errorf("panel %+v, want the first two items and the error", p)
+	}
+
+	// A feed taken out of the ConfigMap is forgotten.
+	if err := a.Refresh(context.Background(), &Config{}); err != nil {
+		t.Error(err)
+	}
+	if a.Len() != 0 {
+		t.Errorf("%d feeds kept, want none", a.Len())
+	}
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore is a Store in a single JSON file, held in memory: not a
+// database but a snapshot of everything stored, gohome.json in DATA_DIR.
+// Every change rewrites the whole file through a temporary file and a
+// rename, so a crash leaves either the old contents or the new ones.
+//
+// The file belongs to one process; point DATA_DIR at a ReadWriteOnce
+// volume and use the Redis store to share data between replicas.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+	data fileStoreData
+}
+
+// fileStoreData is the file's contents.
+type fileStoreData struct {
+	Version int                                   `json:"version"`
+	Buckets map[string]map[string]json.RawMessage `json:"buckets"` // by namespace
+}
+
+// fileStoreMigrations bring a file up to the current schema, which is
+// len(fileStoreMigrations). Each runs once, in order, on a file whose
+// Version is its index; only ever append to the list.
+var fileStoreMigrations = []func(*fileStoreData) error{
+	// 1: the initial buckets.
+	func(d *fileStoreData) error {
+		for _, namespace := range []string{storeNamespaceClicks, storeNamespaceHealth, storeNamespacePrefs, storeNamespaceFavicons} {
+			if d.Buckets[namespace] == nil {
+				d.Buckets[namespace] = make(map[string]json.RawMessage)
+			}
+		}
+		return nil
+	},
+}
+
+// OpenFileStore opens the store at path, creating it if it doesn't exist,
+// and applies any pending migrations.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path}
+	raw, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, fmt.Errorf("%s is corrupt: %w", path, err)
+		}
+	}
+	if s.data.Buckets == nil {
+		s.data.Buckets = make(map[string]map[string]json.RawMessage)
+	}
+
+	if s.data.Version > len(fileStoreMigrations) {
+		return nil, fmt.Errorf("%s has schema version %d, newer than this GoHome supports (%d)", path, s.data.Version, len(fileStoreMigrations))
+	}
+	if s.data.Version == len(fileStoreMigrations) && raw != nil {
+		return s, nil
+	}
+	from := s.data.Version
+	for s.data.Version < len(fileStoreMigrations) {
+		if err := fileStoreMigrations[s.data.Version](&s.data); err != nil {
+			return nil, fmt.Errorf("migrating %s to schema version %d: %w", path, s.data.Version+1, err)
+		}
+		s.data.Version++
+	}
+	if err := s.write(); err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		log.Printf("Migrated %s from schema version %d to %d", path, from, s.data.Version)
+	}
+	return s, nil
+}
+
+func (s *FileStore) Get(_ context.Context, namespace, key string) (json.RawMessage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.data.Buckets[namespace][key]
+	return value, ok, nil
+}
+
+// Set stores value under key. Writing the value that is already stored
+// doesn't touch the file.
+func (s *FileStore) Set(_ context.Context, namespace, key string, value json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.data.Buckets[namespace][key]; ok && bytes.Equal(old, value) {
+		return nil
+	}
+	if s.data.Buckets[namespace] == nil {
+		s.data.Buckets[namespace] = make(map[string]json.RawMessage)
+	}
+	s.data.Buckets[namespace][key] = value
+	return s.write()
+}
+
+func (s *FileStore) Delete(_ context.Context, namespace, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Buckets[namespace][key]; !ok {
+		return nil
+	}
+	delete(s.data.Buckets[namespace], key)
+	return s.write()
+}
+
+func (s *FileStore) List(_ context.Context, namespace string) (map[string]json.RawMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.data.Buckets[namespace]), nil
+}
+
+func (s *FileStore) Replace(_ context.Context, namespace string, values map[string]json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Buckets[namespace] = maps.Clone(values)
+	return s.write()
+}
+
+// write replaces the file with the current contents. The caller holds mu.
+func (s *FileStore) write() error {
+	raw, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
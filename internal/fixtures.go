@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Fixtures are Kubernetes objects read from manifests on disk instead of an
+// API server, for demos, screenshots and end-to-end tests without a
+// cluster. With FIXTURES set, GoHome lists their ingresses and reads its
+// ConfigMap from them as if they had been discovered.
+type Fixtures struct {
+	Path       string
+	Ingresses  []networkingv1.Ingress
+	ConfigMaps map[string]*corev1.ConfigMap // by namespace/name
+}
+
+// LoadFixtures reads the Ingresses and ConfigMaps in a YAML or JSON file,
+// or in every .yaml, .yml and .json file of a directory. Files may hold
+// several documents and v1 Lists; other kinds, such as the Deployment next
+// to an ingress, are skipped. Objects without a namespace are put in
+// namespace, as kubectl apply would.
+func LoadFixtures(path, namespace string) (*Fixtures, error) {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+
+	f := &Fixtures{Path: path, ConfigMaps: make(map[string]*corev1.ConfigMap)}
+	for _, file := range files {
+		if err := f.load(file, namespace); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	slices.SortFunc(f.Ingresses, func(a, b networkingv1.Ingress) int {
+		return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+	})
+	log.Printf("Loaded %d ingresses and %d ConfigMaps from fixtures %s", len(f.Ingresses), len(f.ConfigMaps), path)
+	return f, nil
+}
+
+// load adds the objects of one file.
+func (f *Fixtures) load(file, namespace string) error {
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(body)))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		if err := f.add(doc, namespace, file); err != nil {
+			return err
+		}
+	}
+}
+
+// add decodes one document and keeps it if it is an Ingress or ConfigMap,
+// or the items of a List.
+func (f *Fixtures) add(doc []byte, namespace, file string) error {
+	obj, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+	if runtime.IsNotRegisteredError(err) || runtime.IsMissingKind(err) {
+		log.Printf("Info: Skipping a document in fixtures %s: %v", file, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch obj := obj.(type) {
+	case *networkingv1.Ingress:
+		if obj.Namespace == "" {
+			obj.Namespace = namespace
+		}
+		f.Ingresses = append(f.Ingresses, *obj)
+	case *corev1.ConfigMap:
+		if obj.Namespace == "" {
+			obj.Namespace = namespace
+		}
+		f.ConfigMaps[obj.Namespace+"/"+obj.Name] = obj
+	case *corev1.List:
+		for _, item := range obj.Items {
+			if err := f.add(item.Raw, namespace, file); err != nil {
+				return err
+			}
+		}
+	default:
+		log.Printf("Info: Skipping %s %s in fixtures %s", gvk.Kind, objectName(obj), file)
+	}
+	return nil
+}
+
+// objectName returns the name of a decoded object, for logging.
+func objectName(obj runtime.Object) string {
+	if named, ok := obj.(interface{ GetName() string }); ok {
+		return named.GetName()
+	}
+	return ""
+}
+
+// ingressList returns the ingresses in namespace, or in all namespaces for
+// "", as the API server would list them.
+func (f *Fixtures) ingressList(namespace string) *networkingv1.IngressList {
+	list := &networkingv1.IngressList{}
+	for _, ingress := range f.Ingresses {
+		if namespace == "" || ingress.Namespace == namespace {
+			list.Items = append(list.Items, *ingress.DeepCopy())
+		}
+	}
+	return list
+}
+
+// configMap returns a copy of the ConfigMap namespace/name, or nil if the
+// fixtures have none or f is nil.
+func (f *Fixtures) configMap(namespace, name string) *corev1.ConfigMap {
+	if f == nil {
+		return nil
+	}
+	configMap, ok := f.ConfigMaps[namespace+"/"+name]
+	if !ok {
+		return nil
+	}
+	return configMap.DeepCopy()
+}
+
+// NewFixtureClient returns a K8sClient that lists the ingresses of
+// fixtures instead of asking an API server. Everything else it would ask
+// the API server, such as certificates and endpoints, is left out as in
+// demo mode.
+func NewFixtureClient(fixtures *Fixtures) *K8sClient {
+	return &K8sClient{
+		fixtures:  fixtures,
+		ingresses: NewCacheFromEnv[ingressList]("ingresses"),
+		replicas:  NewCacheFromEnv[map[string]Replicas]("replicas"),
+	}
+}
+
+// UseFixtures makes a manager created without a clientset read its
+// ConfigMap, and those of namespace pages, from fixtures instead of
+// showing the examples. Changes, such as a saved tile order, only live in
+// memory as in demo mode.
+func (bm *BookmarkManager) UseFixtures(fixtures *Fixtures) {
+	bm.fixtures = fixtures
+}
@@ -0,0 +1,395 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultGitHubInterval is how often repositories and notifications are
+	// refetched. Unauthenticated clients get 60 requests an hour, so this
+	// leaves room for a handful of repos without a token.
+	defaultGitHubInterval = 5 * time.Minute
+	// defaultGitHubLimit is how many items each panel shows.
+	defaultGitHubLimit = 5
+)
+
+// GitHubConfig configures the optional GitHub widget, from the ConfigMap keys
+// github-repos, github-notifications and github-limit. The token is read from
+// GITHUB_TOKEN so it can come from a Secret.
+type GitHubConfig struct {
+	Repos         []string // "owner/name"
+	Notifications bool     // show the token owner's unread notifications
+	Limit         int
+}
+
+// Enabled reports whether there is anything to show.
+func (c GitHubConfig) Enabled() bool {
+	return len(c.Repos) > 0 || c.Notifications
+}
+
+// GitHubItem is one pull request, issue or notification.
+type GitHubItem struct {
+	Title   string
+	URL     string
+	Kind    string // "pr", "issue" or, for notifications, the lower-cased subject type
+	Number  int
+	Repo    string // set on notifications
+	Author  string
+	Draft   bool
+	Updated time.Time
+}
+
+// GitHubPanel is one repository (or the notifications list) as rendered on
+// the homepage.
+type GitHubPanel struct {
+	Name    string
+	URL     string
+	Items   []GitHubItem
+	Err     string
+	Fetched time.Time
+}
+
+// githubState is what the fetcher remembers about one panel.
+type githubState struct {
+	items     []GitHubItem
+	err       error
+	fetched   time.Time
+	attempted time.Time
+}
+
+// notificationsKey is the state key for the notifications panel; it can't
+// collide with a repo, which always contains a slash.
+const notificationsKey = "notifications"
+
+// GitHubFetcher periodically fetches open pull requests and issues for the
+// configured repositories, and optionally the token owner's notifications,
+// caching them like FeedAggregator so renders never wait on the API.
+type GitHubFetcher struct {
+	client   *http.Client
+	apiURL   string
+	webURL   string // where people browse, e.g. https://github.com
+	token    string
+	interval time.Duration
+	mu       sync.Mutex
+	panels   map[string]githubState // keyed by "owner/name" or notificationsKey
+}
+
+// NewGitHubFetcherFromEnv creates a fetcher using GITHUB_TOKEN, refetching
+// every GITHUB_INTERVAL. GITHUB_API_URL points it at GitHub Enterprise.
+func NewGitHubFetcherFromEnv() *GitHubFetcher {
+	apiURL := strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	webURL := strings.TrimSuffix(apiURL, "/api/v3") // GitHub Enterprise Server
+	if apiURL == "https://api.github.com" {
+		webURL = "https://github.com"
+	}
+	return &GitHubFetcher{
+		client:   &http.Client{Timeout: 15 * time.Second},
+		apiURL:   apiURL,
+		webURL:   webURL,
+		token:    os.Getenv("GITHUB_TOKEN"),
+		interval: durationFromEnv("GITHUB_INTERVAL", defaultGitHubInterval),
+		panels:   make(map[string]githubState),
+	}
+}
+
+// parseGitHub reads the github-* ConfigMap keys.
+func parseGitHub(data map[string]string) GitHubConfig {
+	cfg := GitHubConfig{
+		Notifications: data["github-notifications"] == "true",
+		Limit:         defaultGitHubLimit,
+	}
+	for _, repo := range splitList(data["github-repos"]) {
+		if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			log.Printf("Warning: github-repos entry %q is not owner/name, skipping", repo)
+			continue
+		}
+		cfg.Repos = append(cfg.Repos, repo)
+	}
+	if v := data["github-limit"]; v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 50 {
+			cfg.Limit = n
+		} else {
+			log.Printf("Warning: invalid github-limit %q", v)
+		}
+	}
+	return cfg
+}
+
+// keys returns the state keys cfg needs fetched.
+func (c GitHubConfig) keys() []string {
+	keys := slices.Clone(c.Repos)
+	if c.Notifications {
+		keys = append(keys, notificationsKey)
+	}
+	return keys
+}
+
+// Name names the GitHub widget.
+func (f *GitHubFetcher) Name() string { return "github" }
+
+// Interval is a minute at most, so the configuration is re-read every
+// minute even with a longer GITHUB_INTERVAL.
+func (f *GitHubFetcher) Interval() time.Duration { return min(f.interval, time.Minute) }
+
+// Refresh refetches the configured repositories and notifications that
+// are due.
+func (f *GitHubFetcher) Refresh(ctx context.Context, config *Config) error {
+	return f.fetchAll(ctx, config.GitHub)
+}
+
+// Render returns the Panels of the configured repositories.
+func (f *GitHubFetcher) Render(config *Config, _ *Locale) any {
+	return f.Panels(config.GitHub)
+}
+
+// fetchAll fetches every panel that is due and drops state for repos that
+// are no longer configured, returning the errors of those that failed.
+func (f *GitHubFetcher) fetchAll(ctx context.Context, cfg GitHubConfig) error {
+	keys := cfg.keys()
+	var errs []error
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		f.mu.Lock()
+		due := time.Since(f.panels[key].attempted) >= f.interval
+		f.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		wg.Go(func() {
+			fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			var items []GitHubItem
+			var err error
+			if key == notificationsKey {
+				items, err = f.fetchNotifications(fetchCtx, cfg.Limit)
+			} else {
+				items, err = f.fetchRepo(fetchCtx, key)
+			}
+			cancel()
+			if err != nil {
+				log.Printf("Warning: Could not fetch GitHub %s: %v", key, err)
+			}
+
+			f.mu.Lock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+			state := f.panels[key]
+			state.err = err
+			state.attempted = time.Now()
+			if err == nil {
+				state.items = items
+				state.fetched = time.Now()
+			}
+			f.panels[key] = state
+			f.mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key := range f.panels {
+		if !slices.Contains(keys, key) {
+			delete(f.panels, key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Panels returns the cached panels for cfg, notifications first, each trimmed
+// to cfg.Limit. Panels that haven't been fetched yet are left out.
+func (f *GitHubFetcher) Panels(cfg GitHubConfig) []GitHubPanel {
+	if f == nil || !cfg.Enabled() {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var panels []GitHubPanel
+	add := func(key, name, link string) {
+		state, ok := f.panels[key]
+		if !ok {
+			return
+		}
+		panel := GitHubPanel{Name: name, URL: link, Fetched: state.fetched}
+		panel.Items = state.items[:min(len(state.items), cfg.Limit)]
+		if state.err != nil {
+			panel.Err = state.err.Error()
+		}
+		panels = append(panels, panel)
+	}
+	if cfg.Notifications {
+		add(notificationsKey, "Notifications", f.webURL+"/notifications")
+	}
+	for _, repo := range cfg.Repos {
+		add(repo, repo, f.webURL+"/"+repo)
+	}
+	return panels
+}
+
+// Len returns the number of panels with cached state.
+func (f *GitHubFetcher) Len() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.panels)
+}
+
+// getJSON calls the GitHub API and decodes the response into v.
+func (f *GitHubFetcher) getJSON(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", "gohome")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0":
+		return fmt.Errorf("rate limited until %s", rateLimitReset(resp.Header.Get("X-RateLimit-Reset")))
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// rateLimitReset formats an X-RateLimit-Reset epoch for the error message.
+func rateLimitReset(v string) string {
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return "later"
+	}
+	return time.Unix(secs, 0).Format("15:04")
+}
+
+// fetchRepo lists a repository's open issues and pull requests, most
+// recently updated first. The issues endpoint returns both; pull requests
+// carry a pull_request field.
+func (f *GitHubFetcher) fetchRepo(ctx context.Context, repo string) ([]GitHubItem, error) {
+	var issues []struct {
+		Number      int       `json:"number"`
+		Title       string    `json:"title"`
+		HTMLURL     string    `json:"html_url"`
+		UpdatedAt   time.Time `json:"updated_at"`
+		Draft       bool      `json:"draft"`
+		PullRequest *struct{} `json:"pull_request"`
+		User        struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	q := url.Values{"state": {"open"}, "sort": {"updated"}, "per_page": {"50"}}
+	if err := f.getJSON(ctx, "/repos/"+repo+"/issues?"+q.Encode(), &issues); err != nil {
+		return nil, err
+	}
+
+	items := make([]GitHubItem, 0, len(issues))
+	for _, issue := range issues {
+		item := GitHubItem{
+			Title:   issue.Title,
+			URL:     issue.HTMLURL,
+			Kind:    "issue",
+			Number:  issue.Number,
+			Author:  issue.User.Login,
+			Draft:   issue.Draft,
+			Updated: issue.UpdatedAt,
+		}
+		if issue.PullRequest != nil {
+			item.Kind = "pr"
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// fetchNotifications lists the token owner's unread notifications.
+func (f *GitHubFetcher) fetchNotifications(ctx context.Context, limit int) ([]GitHubItem, error) {
+	if f.token == "" {
+		return nil, fmt.Errorf("github-notifications needs GITHUB_TOKEN")
+	}
+	var notifications []struct {
+		UpdatedAt time.Time `json:"updated_at"`
+		Subject   struct {
+			Title string `json:"title"`
+			URL   string `json:"url"`
+			Type  string `json:"type"`
+		} `json:"subject"`
+		Repository struct {
+			FullName string `json:"full_name"`
+			HTMLURL  string `json:"html_url"`
+		} `json:"repository"`
+	}
+	q := url.Values{"per_page": {strconv.Itoa(limit)}}
+	if err := f.getJSON(ctx, "/notifications?"+q.Encode(), &notifications); err != nil {
+		return nil, err
+	}
+
+	items := make([]GitHubItem, 0, len(notifications))
+	for _, n := range notifications {
+		kind := strings.ToLower(n.Subject.Type)
+		if kind == "pullrequest" {
+			kind = "pr"
+		}
+		item := GitHubItem{
+			Title:   n.Subject.Title,
+			URL:     f.htmlURL(n.Subject.URL, n.Repository.HTMLURL),
+			Kind:    kind,
+			Repo:    n.Repository.FullName,
+			Updated: n.UpdatedAt,
+		}
+		if kind == "pr" || kind == "issue" {
+			item.Number, _ = strconv.Atoi(n.Subject.URL[strings.LastIndex(n.Subject.URL, "/")+1:])
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// htmlURL turns a subject API URL such as
+// https://api.github.com/repos/o/r/pulls/1 into the page a person would open,
+// https://github.com/o/r/pull/1, falling back to the repository page for
+// subjects without one (releases, discussions, ...).
+func (f *GitHubFetcher) htmlURL(apiURL, fallback string) string {
+	rest, ok := strings.CutPrefix(apiURL, f.apiURL+"/repos/")
+	if !ok {
+		return fallback
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 4 {
+		return fallback
+	}
+	switch parts[2] {
+	case "pulls":
+		parts[2] = "pull"
+	case "issues":
+	default:
+		return fallback
+	}
+	return f.webURL + "/" + strings.Join(parts, "/")
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// goLinkKeyPrefix marks ConfigMap keys that define a go link:
+	//   golink-<name>: "https://plex.example.com/web"
+	goLinkKeyPrefix = "golink-"
+	// goLinkClickPrefix starts the click counter IDs of go links, which
+	// can't clash with tile IDs since namespaces hold no colons.
+	goLinkClickPrefix = "golink:"
+)
+
+// goLinkNamePattern matches a go link name, as typed after /go/.
+var goLinkNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// GoLink is one /go/<name> redirect in the GET /api/v1/golinks response.
+type GoLink struct {
+	Name     string     `json:"name"`
+	URL      string     `json:"url"`
+	Source   string     `json:"source"` // "config", "bookmark" or "ingress"
+	Hits     int64      `json:"hits"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// parseGoLinks reads every golink-* key from ConfigMap data, keyed by
+// lowercased name.
+func parseGoLinks(data map[string]string) map[string]string {
+	links := make(map[string]string)
+	for key, value := range data {
+		name, ok := strings.CutPrefix(key, goLinkKeyPrefix)
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(name)
+		target := strings.TrimSpace(value)
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			log.Printf("Warning: go link %s has invalid URL %q", name, target)
+			continue
+		}
+		if !goLinkNamePattern.MatchString(name) {
+			log.Printf("Warning: invalid go link name %q, want lowercase letters, digits, '.', '_' and '-'", name)
+			continue
+		}
+		links[name] = target
+	}
+	return links
+}
+
+// parseGoLinkNames parses the go link names of a tile, from the
+// gohome.stringer.sh/go annotation or the bookmark go option, dropping
+// invalid ones.
+func parseGoLinkNames(value, source string) []string {
+	var names []string
+	for _, name := range splitList(value) {
+		name = strings.ToLower(name)
+		if !goLinkNamePattern.MatchString(name) {
+			log.Printf("Warning: invalid go link name %q on %s", name, source)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// goLinks resolves every go link the visitor may use: the golink-* keys
+// first, then bookmarks, then ingresses, so a name taken twice goes to the
+// first. Tiles the visitor can't see are left out.
+func (s *Server) goLinks(ctx context.Context, r *http.Request, config *Config) map[string]GoLink {
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: /go could not load ingresses: %v", err)
+	}
+	apps, services, bookmarks := s.restrictTiles(r, s.resolveViewer(ctx, r), config, apps, services, config.Bookmarks)
+
+	links := make(map[string]GoLink, len(config.GoLinks))
+	add := func(name, target, source string) {
+		if _, taken := links[name]; !taken && target != "" {
+			links[name] = GoLink{Name: name, URL: target, Source: source}
+		}
+	}
+	for name, target := range config.GoLinks {
+		add(name, target, "config")
+	}
+	for _, b := range bookmarks {
+		for _, name := range b.GoLinks {
+			add(name, b.URL, "bookmark")
+		}
+	}
+	for _, info := range append(slices.Clone(apps), services...) {
+		for _, name := range info.GoLinks {
+			add(name, info.URL, "ingress")
+		}
+	}
+	return links
+}
+
+// handleGoLink serves /go/<name>, and /go/<name>/<rest>, redirecting to the
+// link's URL with any rest of the path and the query string appended, so
+// "go/plex" works from the address bar of any browser on the network. Hits
+// are counted with click-stats on. Unknown names are looked up the way the
+// search box's /go?q=<name> does.
+func (s *Server) handleGoLink(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	name, rest, _ := strings.Cut(r.PathValue("link"), "/")
+	name = strings.ToLower(name)
+	link, ok := s.goLinks(ctx, r, config)[name]
+	if !ok {
+		http.Redirect(w, r, "/go?"+url.Values{"q": {name}}.Encode(), http.StatusSeeOther)
+		return
+	}
+	if config.ClickStats {
+		s.clicks.Record(goLinkClickPrefix+name, time.Now())
+	}
+	http.Redirect(w, r, goLinkTarget(link.URL, rest, r.URL.RawQuery), http.StatusFound)
+}
+
+// goLinkTarget appends rest to the path of target and the query rawQuery
+// to its own.
+func goLinkTarget(target, rest, rawQuery string) string {
+	if rest == "" && rawQuery == "" {
+		return target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	if rest != "" {
+		u = u.JoinPath(rest)
+	}
+	if rawQuery != "" {
+		u.RawQuery = strings.TrimPrefix(u.RawQuery+"&"+rawQuery, "&")
+	}
+	return u.String()
+}
+
+// handleGoLinks serves every go link the visitor may use, with its hit
+// count, most used first.
+func (s *Server) handleGoLinks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	counts := s.clicks.Counts()
+	links := make([]GoLink, 0)
+	for name, link := range s.goLinks(ctx, r, config) {
+		if count := counts[goLinkClickPrefix+name]; count.Count > 0 {
+			link.Hits, link.LastUsed = count.Count, &count.Last
+		}
+		links = append(links, link)
+	}
+	slices.SortFunc(links, func(a, b GoLink) int {
+		return cmp.Or(cmp.Compare(b.Hits, a.Hits), strings.Compare(a.Name, b.Name))
+	})
+	writeJSON(w, http.StatusOK, links)
+}
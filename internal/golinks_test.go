@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseGoLinks(t *testing.T) {
+	got := parseGoLinks(map[string]string{
+		"golink-plex":      " https://plex.example.com/web ",
+		"golink-Wiki":      "http://wiki.example.com",
+		"golink-k8s.docs":  "https://kubernetes.io/docs",
+		"golink-js":        "javascript:alert(1)",
+		"golink-relative":  "/status",
+		"golink-_hidden":   "https://example.com",
+		"bookmark-grafana": "https://grafana.example.com",
+	})
+	want := map[string]string{
+		"plex":     "https://plex.example.com/web",
+		"wiki":     "http://wiki.example.com",
+		"k8s.docs": "https://kubernetes.io/docs",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseGoLinkNames(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"plex", []string{"plex"}},
+		{"Plex, media ,tv", []string{"plex", "media", "tv"}},
+		{"plex,with space,-dash,ok_1", []string{"plex", "ok_1"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := parseGoLinkNames(tt.value, "bookmark test"); !slices.Equal(got, tt.want) {
+			t.Errorf("parseGoLinkNames(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestGoLinkTarget(t *testing.T) {
+	tests := []struct {
+		target, rest, query string
+		want                string
+	}{
+		{"https://plex.example.com/web", "", "", "https://plex.example.com/web"},
+		{"https://grafana.example.com", "d/home", "", "https://grafana.example.com/d/home"},
+		{"https://grafana.example.com/", "d/home", "orgId=1", "https://grafana.example.com/d/home?orgId=1"},
+		{"https://search.example.com/?source=go", "", "q=kubernetes", "https://search.example.com/?source=go&q=kubernetes"},
+		{"https://wiki.example.com/a b", "page", "", "https://wiki.example.com/a%20b/page"},
+	}
+	for _, tt := range tests {
+		if got := goLinkTarget(tt.target, tt.rest, tt.query); got != tt.want {
+			t.Errorf("goLinkTarget(%q, %q, %q) = %q, want %q", tt.target, tt.rest, tt.query, got, tt.want)
+		}
+	}
+}
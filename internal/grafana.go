@@ -0,0 +1,368 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// grafanaKeyPrefix marks ConfigMap keys that define a panel:
+	//   grafana-panel-<name>: "<dashboard uid>/<panel id>|from=now-6h|width=600"
+	grafanaKeyPrefix = "grafana-panel-"
+	// defaultGrafanaInterval is how often every panel is re-rendered.
+	defaultGrafanaInterval = 5 * time.Minute
+	// Default size of a rendered panel, in pixels.
+	defaultGrafanaWidth  = 600
+	defaultGrafanaHeight = 300
+	// maxGrafanaBytes caps the size of a rendered image.
+	maxGrafanaBytes = 4 << 20
+)
+
+// GrafanaPanelConfig is one panel from the ConfigMap.
+type GrafanaPanelConfig struct {
+	Name      string // from the key; also the image path, /grafana/<name>
+	Label     string // title shown above the image
+	Dashboard string // dashboard UID
+	PanelID   int
+	From, To  string // Grafana time range, e.g. "now-6h" and "now"
+	Width     int
+	Height    int
+	Theme     string // "light" or "dark"; empty for Grafana's default
+	Org       int    // organisation ID; zero for the token's own
+}
+
+// GrafanaConfig configures the optional Grafana widget, from the grafana-url
+// and grafana-panel-<name> ConfigMap keys. The service account token comes
+// from GRAFANA_TOKEN so it can live in a Secret.
+type GrafanaConfig struct {
+	URL      string
+	Timezone string               // clock-timezone, so graphs match the header clock
+	Panels   []GrafanaPanelConfig // sorted by name
+}
+
+// renderURL is the render API URL of p, which returns it as a PNG.
+func (c GrafanaConfig) renderURL(p GrafanaPanelConfig) string {
+	params := url.Values{
+		"panelId": {strconv.Itoa(p.PanelID)},
+		"from":    {p.From},
+		"to":      {p.To},
+		"width":   {strconv.Itoa(p.Width)},
+		"height":  {strconv.Itoa(p.Height)},
+	}
+	if p.Theme != "" {
+		params.Set("theme", p.Theme)
+	}
+	if p.Org != 0 {
+		params.Set("orgId", strconv.Itoa(p.Org))
+	}
+	if c.Timezone != "" {
+		params.Set("tz", c.Timezone)
+	}
+	// Grafana ignores the slug after the UID, so any placeholder will do.
+	return c.URL + "/render/d-solo/" + url.PathEscape(p.Dashboard) + "/_?" + params.Encode()
+}
+
+// viewURL opens p on its own in Grafana.
+func (c GrafanaConfig) viewURL(p GrafanaPanelConfig) string {
+	params := url.Values{"viewPanel": {strconv.Itoa(p.PanelID)}, "from": {p.From}, "to": {p.To}}
+	if p.Org != 0 {
+		params.Set("orgId", strconv.Itoa(p.Org))
+	}
+	return c.URL + "/d/" + url.PathEscape(p.Dashboard) + "/_?" + params.Encode()
+}
+
+// GrafanaPanel is a rendered panel as shown on the homepage.
+type GrafanaPanel struct {
+	Name    string
+	Label   string
+	Image   string // our URL for the cached image; empty until a render succeeds
+	URL     string // the panel in Grafana
+	Width   int
+	Height  int
+	Err     string // set when the most recent render failed
+	Fetched time.Time
+}
+
+// grafanaImage is what the fetcher remembers about one panel.
+type grafanaImage struct {
+	src       string // render URL the image came from; a config change makes it due
+	body      []byte
+	fetched   time.Time
+	attempted time.Time
+	err       error
+}
+
+// GrafanaRenderer renders the configured Grafana panels in the background
+// and keeps the images, so the homepage shows a few graphs without iframing
+// Grafana or handing its token to the browser.
+type GrafanaRenderer struct {
+	client   *http.Client
+	token    string
+	interval time.Duration
+
+	mu     sync.Mutex
+	images map[string]grafanaImage // keyed by panel name
+}
+
+// NewGrafanaRendererFromEnv returns a renderer using GRAFANA_TOKEN that
+// re-renders every GRAFANA_INTERVAL.
+func NewGrafanaRendererFromEnv() *GrafanaRenderer {
+	return &GrafanaRenderer{
+		// Rendering starts a headless browser, which takes a while.
+		client:   &http.Client{Timeout: time.Minute},
+		token:    os.Getenv("GRAFANA_TOKEN"),
+		interval: durationFromEnv("GRAFANA_INTERVAL", defaultGrafanaInterval),
+		images:   make(map[string]grafanaImage),
+	}
+}
+
+// parseGrafana reads grafana-url and every grafana-panel-* key from
+// ConfigMap data.
+func parseGrafana(data map[string]string) GrafanaConfig {
+	cfg := GrafanaConfig{
+		URL:      strings.TrimSuffix(strings.TrimSpace(data["grafana-url"]), "/"),
+		Timezone: strings.TrimSpace(data["clock-timezone"]),
+	}
+	for key, value := range data {
+		if !strings.HasPrefix(key, grafanaKeyPrefix) {
+			continue
+		}
+		if panel, ok := parseGrafanaPanel(key, value); ok {
+			cfg.Panels = append(cfg.Panels, panel)
+		}
+	}
+	if len(cfg.Panels) > 0 && cfg.URL == "" {
+		log.Printf("Warning: grafana-panel-* keys are set but grafana-url isn't")
+	}
+	slices.SortFunc(cfg.Panels, func(a, b GrafanaPanelConfig) int { return strings.Compare(a.Name, b.Name) })
+	return cfg
+}
+
+// parseGrafanaPanel parses a grafana-panel-<name> ConfigMap value: the
+// dashboard UID and panel ID, as in the panel's share link, followed by
+// optional |key=value settings.
+func parseGrafanaPanel(key, value string) (GrafanaPanelConfig, bool) {
+	parts := strings.Split(value, "|")
+	panel := GrafanaPanelConfig{
+		Name:   slugify(strings.TrimPrefix(key, grafanaKeyPrefix)),
+		Label:  strings.TrimPrefix(key, grafanaKeyPrefix),
+		From:   "now-6h",
+		To:     "now",
+		Width:  defaultGrafanaWidth,
+		Height: defaultGrafanaHeight,
+	}
+	uid, id, _ := strings.Cut(strings.TrimSpace(parts[0]), "/")
+	n, err := strconv.Atoi(id)
+	if uid == "" || err != nil || n < 0 || panel.Name == "" {
+		log.Printf("Warning: grafana panel %s needs <dashboard uid>/<panel id>, got %q, skipping", panel.Label, parts[0])
+		return panel, false
+	}
+	panel.Dashboard, panel.PanelID = uid, n
+
+	for _, opt := range parts[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		v = strings.TrimSpace(v)
+		switch k {
+		case "from":
+			panel.From = v
+		case "to":
+			panel.To = v
+		case "label":
+			panel.Label = v
+		case "theme":
+			if v == "light" || v == "dark" {
+				panel.Theme = v
+			} else {
+				log.Printf("Warning: grafana panel %s has invalid theme %q, want light or dark", panel.Label, v)
+			}
+		case "width", "height", "org":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || (k != "org" && n > 4000) {
+				log.Printf("Warning: grafana panel %s has invalid %s %q", panel.Label, k, v)
+				continue
+			}
+			switch k {
+			case "width":
+				panel.Width = n
+			case "height":
+				panel.Height = n
+			default:
+				panel.Org = n
+			}
+		default:
+			log.Printf("Warning: grafana panel %s has unknown option %q", panel.Label, opt)
+		}
+	}
+	return panel, true
+}
+
+// Name names the Grafana widget.
+func (g *GrafanaRenderer) Name() string { return "grafana" }
+
+// Interval is a minute at most, so the configuration is re-read every
+// minute even with a longer GRAFANA_INTERVAL.
+func (g *GrafanaRenderer) Interval() time.Duration { return min(g.interval, time.Minute) }
+
+// Refresh re-renders the configured panels that are due.
+func (g *GrafanaRenderer) Refresh(ctx context.Context, config *Config) error {
+	return g.renderAll(ctx, config.Grafana)
+}
+
+// Render returns the Panels of the configured panels.
+func (g *GrafanaRenderer) Render(config *Config, _ *Locale) any {
+	return g.Panels(config.Grafana)
+}
+
+// renderAll renders every panel that is due, one at a time to go easy on
+// the image renderer, and drops images of panels that are no longer
+// configured.
+func (g *GrafanaRenderer) renderAll(ctx context.Context, cfg GrafanaConfig) error {
+	if cfg.URL == "" {
+		cfg.Panels = nil
+	}
+	var errs []error
+	names := make([]string, 0, len(cfg.Panels))
+	for _, panel := range cfg.Panels {
+		names = append(names, panel.Name)
+		src := cfg.renderURL(panel)
+		g.mu.Lock()
+		image := g.images[panel.Name]
+		g.mu.Unlock()
+		if image.src == src && time.Since(image.attempted) < g.interval {
+			continue
+		}
+
+		body, err := g.render(ctx, src)
+		if err != nil {
+			log.Printf("Warning: Could not render Grafana panel %s: %v", panel.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", panel.Name, err))
+		}
+		image.err, image.attempted = err, time.Now()
+		if err == nil {
+			image.body, image.fetched = body, time.Now()
+		} else if image.src != src {
+			// Don't keep showing the old panel under a new configuration.
+			image.body, image.fetched = nil, time.Time{}
+		}
+		image.src = src
+		g.mu.Lock()
+		g.images[panel.Name] = image
+		g.mu.Unlock()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for name := range g.images {
+		if !slices.Contains(names, name) {
+			delete(g.images, name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// render fetches a PNG from the render API.
+func (g *GrafanaRenderer) render(ctx context.Context, src string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gohome-grafana")
+	req.Header.Set("Accept", "image/png")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	// Without the image renderer plugin Grafana answers with an HTML page.
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/png") {
+		return nil, fmt.Errorf("got %s instead of a PNG; is the image renderer installed?", cmp.Or(ct, "no content type"))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGrafanaBytes))
+	if err != nil {
+		return nil, err
+	}
+	if ct, err := checkImage(body, "image/png"); err != nil {
+		return nil, err
+	} else if ct != "image/png" {
+		return nil, fmt.Errorf("got %s instead of a PNG", ct)
+	}
+	return body, nil
+}
+
+// Panels returns the panels in cfg that have been rendered or tried.
+func (g *GrafanaRenderer) Panels(cfg GrafanaConfig) []GrafanaPanel {
+	if g == nil || cfg.URL == "" {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var panels []GrafanaPanel
+	for _, p := range cfg.Panels {
+		image, ok := g.images[p.Name]
+		if !ok {
+			continue
+		}
+		panel := GrafanaPanel{
+			Name:    p.Name,
+			Label:   p.Label,
+			URL:     cfg.viewURL(p),
+			Width:   p.Width,
+			Height:  p.Height,
+			Fetched: image.fetched,
+		}
+		if image.body != nil {
+			// The timestamp busts the browser cache when a new render lands.
+			panel.Image = fmt.Sprintf("/grafana/%s?v=%d", p.Name, image.fetched.Unix())
+		}
+		if image.err != nil {
+			panel.Err = image.err.Error()
+		}
+		panels = append(panels, panel)
+	}
+	return panels
+}
+
+// Len returns the number of panels with cached state.
+func (g *GrafanaRenderer) Len() int {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.images)
+}
+
+// handleGrafanaImage serves the last rendered image of a panel.
+func (s *Server) handleGrafanaImage(w http.ResponseWriter, r *http.Request) {
+	s.grafana.mu.Lock()
+	image := s.grafana.images[r.PathValue("name")]
+	s.grafana.mu.Unlock()
+	if image.body == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	// Graphs can show private data, so don't let shared caches keep them.
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(s.grafana.interval.Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, _ = w.Write(image.body)
+}
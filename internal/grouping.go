@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"cmp"
+	"os"
+	"slices"
+)
+
+// validGroupings lists the accepted values for the group-by setting.
+// "category" is the default apps/services/bookmarks sections with bookmark
+// categories; the others regroup the same tiles into TileGroups.
+var validGroupings = map[string]bool{"category": true, "none": true, "namespace": true, "cluster": true}
+
+// Groupings are the group-by values in the order the header selector offers them.
+var Groupings = []string{"category", "none", "namespace", "cluster"}
+
+// TileGroup is one section of tiles when grouping by something other than
+// category. Tiles reuses Favorite: exactly one of Ingress and Bookmark is set.
+type TileGroup struct {
+	Name  string
+	ID    string // collapse-state identifier, e.g. "ns-media"
+	Tiles []Favorite
+}
+
+// defaultClusterName labels ingresses when CLUSTER_NAME is unset.
+const defaultClusterName = "local"
+
+// clusterName is the name ingresses from this cluster are grouped under.
+func clusterName() string {
+	if name := os.Getenv("CLUSTER_NAME"); name != "" {
+		return name
+	}
+	return defaultClusterName
+}
+
+// resolveGrouping picks the visitor's grouping if set, otherwise the
+// configured default, falling back to "category".
+func resolveGrouping(prefs Preferences, config *Config) string {
+	if prefs.GroupBy != "" {
+		return prefs.GroupBy
+	}
+	if validGroupings[config.GroupBy] {
+		return config.GroupBy
+	}
+	return "category"
+}
+
+// groupTiles regroups the visible tiles for groupBy, keeping their display
+// order within each group. It returns nil for "category", which the template
+// renders from Apps, Services and BookmarkCategories as usual. Bookmarks
+// have no namespace or cluster, so those groupings list them last under
+// "Bookmarks".
+func groupTiles(groupBy string, apps, services []IngressInfo, categories []BookmarkCategory, locale *Locale) []TileGroup {
+	var bookmarks []Favorite
+	for _, c := range categories {
+		for i := range c.Bookmarks {
+			bookmarks = append(bookmarks, Favorite{Bookmark: &c.Bookmarks[i]})
+		}
+	}
+
+	var key func(IngressInfo) (name, id string)
+	switch groupBy {
+	case "none":
+		all := TileGroup{Name: locale.T("group.all"), ID: "all"}
+		for _, list := range [][]IngressInfo{apps, services} {
+			for i := range list {
+				all.Tiles = append(all.Tiles, Favorite{Ingress: &list[i]})
+			}
+		}
+		all.Tiles = append(all.Tiles, bookmarks...)
+		if len(all.Tiles) == 0 {
+			return nil
+		}
+		return []TileGroup{all}
+	case "namespace":
+		key = func(in IngressInfo) (string, string) { return in.Namespace, "ns-" + slugify(in.Namespace) }
+	case "cluster":
+		key = func(in IngressInfo) (string, string) { return in.Cluster, "cluster-" + slugify(in.Cluster) }
+	default:
+		return nil
+	}
+
+	var groups []TileGroup
+	index := make(map[string]int)
+	for _, list := range [][]IngressInfo{apps, services} {
+		for i := range list {
+			name, id := key(list[i])
+			n, ok := index[id]
+			if !ok {
+				n = len(groups)
+				index[id] = n
+				groups = append(groups, TileGroup{Name: name, ID: id})
+			}
+			groups[n].Tiles = append(groups[n].Tiles, Favorite{Ingress: &list[i]})
+		}
+	}
+	slices.SortStableFunc(groups, func(a, b TileGroup) int { return cmp.Compare(a.Name, b.Name) })
+
+	if len(bookmarks) > 0 {
+		groups = append(groups, TileGroup{Name: locale.T("section.bookmarks"), ID: "bookmarks", Tiles: bookmarks})
+	}
+	return groups
+}
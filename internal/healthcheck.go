@@ -0,0 +1,701 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHealthCheckInterval is how often every target is probed.
+	defaultHealthCheckInterval = time.Minute
+	// defaultHealthCheckTimeout bounds a single probe.
+	defaultHealthCheckTimeout = 5 * time.Second
+	// defaultHealthCheckSlow is the latency above which an up target is
+	// highlighted as slow.
+	defaultHealthCheckSlow = time.Second
+	// defaultHealthHistory is how many probe results are kept per target for
+	// uptime and sparklines: an hour at the default interval.
+	defaultHealthHistory = 60
+	// healthCheckConcurrency caps how many probes run at once.
+	healthCheckConcurrency = 8
+)
+
+// HealthState is the result of probing a tile's URL.
+type HealthState string
+
+const (
+	HealthUnknown HealthState = "unknown" // not probed yet
+	HealthUp      HealthState = "up"
+	HealthDown    HealthState = "down"
+)
+
+// TargetHealth is the most recent probe result for one URL. The zero value
+// (empty State) means health checks are disabled.
+type TargetHealth struct {
+	State       HealthState
+	StatusCode  int
+	Err         string
+	LastChecked time.Time
+	Latency     time.Duration // time to the response headers, ping reply or TCP connection; zero when down
+	Slow        bool          // up, but Latency exceeded HEALTH_CHECK_SLOW
+	CertExpiry  time.Time     // NotAfter of the certificate served over HTTPS; zero for plain HTTP
+	Check       string        // HealthCheckPing, HealthCheckTCP or HealthCheckUptimeKuma; empty for HTTP
+
+	History []HealthSample // recent results, oldest first, including this one
+	Uptime  float64        // percentage of History that was up
+}
+
+// HealthSample is one past probe result.
+type HealthSample struct {
+	Time    time.Time
+	Up      bool
+	Latency time.Duration
+}
+
+// UptimeText formats Uptime for display, e.g. "99.2%".
+func (h TargetHealth) UptimeText() string {
+	if h.Uptime == 0 || h.Uptime == 100 {
+		return fmt.Sprintf("%.0f%%", h.Uptime)
+	}
+	return fmt.Sprintf("%.1f%%", h.Uptime)
+}
+
+// LatencyText formats Latency for display, e.g. "85ms" or "1.2s".
+func (h TargetHealth) LatencyText() string {
+	if h.Latency < time.Second {
+		return fmt.Sprintf("%dms", h.Latency.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", h.Latency.Seconds())
+}
+
+// Summary describes the result in a few words, for tooltips.
+func (h TargetHealth) Summary() string {
+	switch h.State {
+	case HealthUp:
+		slow := ""
+		if h.Slow {
+			slow = ", slow"
+		}
+		if h.Check != "" {
+			return fmt.Sprintf("up (%s) in %s%s, checked %s", h.Check, h.LatencyText(), slow, h.LastChecked.Format("15:04:05"))
+		}
+		return fmt.Sprintf("up (HTTP %d) in %s%s, checked %s", h.StatusCode, h.LatencyText(), slow, h.LastChecked.Format("15:04:05"))
+	case HealthDown:
+		if h.StatusCode != 0 {
+			return fmt.Sprintf("down (HTTP %d), checked %s", h.StatusCode, h.LastChecked.Format("15:04:05"))
+		}
+		return fmt.Sprintf("down (%s), checked %s", h.Err, h.LastChecked.Format("15:04:05"))
+	}
+	if h.Err != "" {
+		return h.Err
+	}
+	return "not checked yet"
+}
+
+// HealthChecker periodically probes every ingress and bookmark URL in the
+// background and remembers whether each responded.
+type HealthChecker struct {
+	client   *http.Client
+	insecure *http.Client // for targets with health-tls-verify=false
+	timeout  time.Duration
+	interval time.Duration
+	slow     time.Duration
+	keep     int // samples of history kept per target
+	mu       sync.Mutex
+	results  map[string]TargetHealth   // keyed by URL
+	history  map[string][]HealthSample // keyed by URL, oldest first
+
+	// redis, if set, shares results between replicas so only one of them
+	// probes every target.
+	redis *RedisClient
+	// store, if set, keeps the history across restarts.
+	store Store
+	// kuma, if set, supplies the results of targets Uptime Kuma monitors.
+	kuma *UptimeKuma
+	// notifier, if set, is told when a target goes down or comes back up.
+	notifier *Dispatcher
+}
+
+// healthSnapshot is the leading replica's results as shared through Redis.
+type healthSnapshot struct {
+	Results map[string]TargetHealth   `json:"results"`
+	History map[string][]HealthSample `json:"history"`
+}
+
+// NewHealthCheckerFromEnv builds a checker from HEALTH_CHECK_INTERVAL,
+// HEALTH_CHECK_TIMEOUT, HEALTH_CHECK_SLOW and HEALTH_HISTORY, or returns nil
+// when HEALTH_CHECKS=false.
+func NewHealthCheckerFromEnv() *HealthChecker {
+	if os.Getenv("HEALTH_CHECKS") == "false" {
+		return nil
+	}
+
+	interval := durationFromEnv("HEALTH_CHECK_INTERVAL", defaultHealthCheckInterval)
+	timeout := durationFromEnv("HEALTH_CHECK_TIMEOUT", defaultHealthCheckTimeout)
+	slow := durationFromEnv("HEALTH_CHECK_SLOW", defaultHealthCheckSlow)
+
+	keep := defaultHealthHistory
+	if v := os.Getenv("HEALTH_HISTORY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			keep = n
+		} else {
+			log.Printf("Warning: invalid HEALTH_HISTORY %q, using %d", v, keep)
+		}
+	}
+
+	h := &HealthChecker{
+		client:   newHealthClient(false),
+		insecure: newHealthClient(true),
+		timeout:  timeout,
+		interval: interval,
+		slow:     slow,
+		keep:     keep,
+		results:  make(map[string]TargetHealth),
+		history:  make(map[string][]HealthSample),
+		redis:    sharedRedis(),
+		store:    sharedStore(),
+		kuma:     NewUptimeKumaFromEnv(),
+	}
+	if h.store != nil {
+		history, err := listJSON[[]HealthSample](context.Background(), h.store, storeNamespaceHealth)
+		if err != nil {
+			log.Printf("Warning: Could not load health history: %v", err)
+		}
+		for u, samples := range history {
+			h.history[u] = samples[max(0, len(samples)-keep):]
+		}
+	}
+	return h
+}
+
+// newHealthClient creates the client probes are sent with. Timeouts come
+// from each probe's context, as targets can override them.
+func newHealthClient(insecure bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{
+		Transport: transport,
+		// A redirect (typically to a login page) already proves the
+		// service is answering; don't chase it to another host.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// durationFromEnv parses a positive duration from the named variable, logging
+// and falling back to def if it is malformed.
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s %q, using %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// Status returns the latest result for a URL, with its recent history.
+func (h *HealthChecker) Status(rawURL string) TargetHealth {
+	if h == nil {
+		return TargetHealth{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result, ok := h.results[rawURL]
+	if !ok {
+		return TargetHealth{State: HealthUnknown}
+	}
+	result.History = slices.Clone(h.history[rawURL])
+	result.Uptime = uptime(result.History)
+	return result
+}
+
+// Statuses returns the latest result for every checked URL.
+func (h *HealthChecker) Statuses() map[string]TargetHealth {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	urls := slices.Collect(maps.Keys(h.results))
+	h.mu.Unlock()
+
+	statuses := make(map[string]TargetHealth, len(urls))
+	for _, u := range urls {
+		statuses[u] = h.Status(u)
+	}
+	return statuses
+}
+
+// uptime returns the percentage of samples that were up.
+func uptime(samples []HealthSample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	up := 0
+	for _, s := range samples {
+		if s.Up {
+			up++
+		}
+	}
+	return 100 * float64(up) / float64(len(samples))
+}
+
+// Len returns the number of URLs with a recorded result.
+func (h *HealthChecker) Len() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.results)
+}
+
+// Job probes the targets returned by targets every interval. Results for
+// URLs that disappear from the list are dropped.
+func (h *HealthChecker) Job(targets func(context.Context) []HealthTarget) Job {
+	if h == nil {
+		return Job{}
+	}
+	return Job{
+		Name:     "health",
+		Interval: h.interval,
+		Run:      func(ctx context.Context) error { return h.tick(ctx, targets) },
+	}
+}
+
+// tick runs one round of checks. With Redis only the replica holding the
+// health lock probes, and publishes its results for the others to copy. If
+// Redis can't be reached every replica probes for itself. Targets being
+// down isn't an error; failing to save or share the results is.
+func (h *HealthChecker) tick(ctx context.Context, targets func(context.Context) []HealthTarget) error {
+	if h.redis == nil {
+		h.checkAll(ctx, targets(ctx))
+		return h.saveHistory(ctx)
+	}
+	lead, err := h.redis.Lead(ctx, "health", 3*h.interval)
+	if err != nil {
+		log.Printf("Warning: Could not coordinate health checks through Redis: %v", err)
+		h.checkAll(ctx, targets(ctx))
+		return errors.Join(err, h.saveHistory(ctx))
+	}
+	if lead {
+		h.checkAll(ctx, targets(ctx))
+		return errors.Join(h.saveHistory(ctx), h.publish(ctx))
+	}
+	return h.copyShared(ctx)
+}
+
+// saveHistory writes the history to the data store, if there is one.
+func (h *HealthChecker) saveHistory(ctx context.Context) error {
+	if h.store == nil {
+		return nil
+	}
+	h.mu.Lock()
+	history := maps.Clone(h.history)
+	h.mu.Unlock()
+	err := replaceJSON(ctx, h.store, storeNamespaceHealth, history)
+	if err != nil {
+		log.Printf("Warning: Could not save health history: %v", err)
+	}
+	return err
+}
+
+// publish stores the current results in Redis.
+func (h *HealthChecker) publish(ctx context.Context) error {
+	h.mu.Lock()
+	raw, err := json.Marshal(healthSnapshot{Results: h.results, History: h.history})
+	h.mu.Unlock()
+	if err == nil {
+		err = h.redis.Set(ctx, "health", string(raw), 3*h.interval)
+	}
+	if err != nil {
+		log.Printf("Warning: Could not share health results through Redis: %v", err)
+	}
+	return err
+}
+
+// copyShared replaces the results with those the leading replica published.
+func (h *HealthChecker) copyShared(ctx context.Context) error {
+	raw, ok, err := h.redis.Get(ctx, "health")
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("Warning: Could not read shared health results from Redis: %v", err)
+		}
+		return err
+	}
+	var snapshot healthSnapshot
+	if err := json.Unmarshal([]byte(raw), &snapshot); err != nil {
+		log.Printf("Warning: Ignoring invalid shared health results: %v", err)
+		return err
+	}
+	if snapshot.Results == nil || snapshot.History == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.results, h.history = snapshot.Results, snapshot.History
+	return nil
+}
+
+// checkAll probes targets concurrently, replaces the stored results and
+// appends to each target's history. Targets with a longer interval of
+// their own keep their last result until it is due, and targets Uptime
+// Kuma monitors take its result without being probed. Targets that went
+// from up to down or back are then announced through the notifier.
+func (h *HealthChecker) checkAll(ctx context.Context, targets []HealthTarget) {
+	results := make(map[string]TargetHealth, len(targets))
+	probed := make(map[string]bool, len(targets))
+	var resultsMu sync.Mutex
+	sem := make(chan struct{}, healthCheckConcurrency)
+
+	h.mu.Lock()
+	previous := h.results
+	h.mu.Unlock()
+	monitors := h.kumaMonitors(ctx)
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		// Probes started earlier in the loop write results concurrently.
+		if result, ok := monitors[kumaURLKey(target.URL)]; ok {
+			resultsMu.Lock()
+			results[target.URL] = result
+			resultsMu.Unlock()
+			probed[target.URL] = true
+			continue
+		}
+		if last, ok := previous[target.URL]; ok && time.Since(last.LastChecked) < target.Policy.Interval {
+			resultsMu.Lock()
+			results[target.URL] = last
+			resultsMu.Unlock()
+			continue
+		}
+		probed[target.URL] = true
+		wg.Go(func() {
+			healthChecksQueued.Inc()
+			sem <- struct{}{}
+			healthChecksQueued.Dec()
+			healthChecksInFlight.Inc()
+			defer func() {
+				healthChecksInFlight.Dec()
+				<-sem
+			}()
+
+			result := h.probe(ctx, target)
+			resultsMu.Lock()
+			results[target.URL] = result
+			resultsMu.Unlock()
+		})
+	}
+	wg.Wait()
+	events := healthEvents(targets, previous, results)
+
+	h.mu.Lock()
+	h.results = results
+	for u, result := range results {
+		if !probed[u] {
+			continue
+		}
+		samples := append(h.history[u], HealthSample{
+			Time:    result.LastChecked,
+			Up:      result.State == HealthUp,
+			Latency: result.Latency,
+		})
+		if len(samples) > h.keep {
+			samples = slices.Clone(samples[len(samples)-h.keep:])
+		}
+		h.history[u] = samples
+	}
+	for u := range h.history {
+		if _, ok := results[u]; !ok {
+			delete(h.history, u)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, event := range events {
+		h.notifier.Dispatch(ctx, event)
+	}
+}
+
+// healthEvents returns an EventHealthChanged for each target that was up
+// and is now down, or the other way round, sorted by title. Targets seen
+// for the first time or whose state is unknown aren't announced, so a
+// restart or a monitor under maintenance stays quiet.
+func healthEvents(targets []HealthTarget, previous, current map[string]TargetHealth) []Event {
+	var events []Event
+	for _, target := range targets {
+		before, after := previous[target.URL], current[target.URL]
+		if !isUpOrDown(before.State) || !isUpOrDown(after.State) || before.State == after.State {
+			continue
+		}
+		name := cmp.Or(target.Name, hostOf(target.URL))
+		title := "Down: " + name
+		if after.State == HealthUp {
+			title = "Back up: " + name
+		}
+		events = append(events, Event{
+			Kind:    EventHealthChanged,
+			Title:   title,
+			Message: fmt.Sprintf("%s is %s", name, after.Summary()),
+			URL:     target.URL,
+			Time:    after.LastChecked,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Title < events[j].Title
+	})
+	return events
+}
+
+// isUpOrDown reports whether state is a definite result.
+func isUpOrDown(state HealthState) bool {
+	return state == HealthUp || state == HealthDown
+}
+
+// probe sends a HEAD request, falling back to GET for servers that don't
+// support HEAD. Unless the target's policy says otherwise, any response
+// below 500 counts as up: a 401 or 404 still means something is listening.
+// Targets with health-check=ping or tcp are pinged or connected to instead.
+func (h *HealthChecker) probe(ctx context.Context, target HealthTarget) TargetHealth {
+	result := TargetHealth{State: HealthDown}
+	policy := target.Policy
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(policy.Timeout, h.timeout))
+	defer cancel()
+	if policy.Check == HealthCheckPing || policy.Check == HealthCheckTCP {
+		result.Check = policy.Check
+		var latency time.Duration
+		var err error
+		if policy.Check == HealthCheckPing {
+			latency, err = ping(ctx, target.URL)
+		} else {
+			latency, err = dialTCP(ctx, target.URL, policy.Port)
+		}
+		result.LastChecked = time.Now()
+		if err != nil {
+			result.Err = probeError(err)
+			return result
+		}
+		result.State = HealthUp
+		result.Latency = latency
+		result.Slow = latency > h.slow
+		return result
+	}
+	client := h.client
+	if policy.Insecure {
+		client = h.insecure
+	}
+	rawURL := policy.probeURL(target.URL)
+
+	start := time.Now()
+	resp, err := h.do(ctx, client, cmp.Or(policy.Method, http.MethodHead), rawURL)
+	if err == nil && policy.Method == "" && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		start = time.Now()
+		resp, err = h.do(ctx, client, http.MethodGet, rawURL)
+	}
+	result.LastChecked = time.Now()
+	if err != nil {
+		result.Err = probeError(err)
+		return result
+	}
+	resp.Body.Close()
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		result.CertExpiry = resp.TLS.PeerCertificates[0].NotAfter
+	}
+
+	result.StatusCode = resp.StatusCode
+	if policy.up(resp.StatusCode) {
+		result.State = HealthUp
+		result.Latency = result.LastChecked.Sub(start)
+		result.Slow = result.Latency > h.slow
+	}
+	return result
+}
+
+func (h *HealthChecker) do(ctx context.Context, client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gohome-healthcheck")
+	return client.Do(req)
+}
+
+// dialTCP connects to the host and port of rawURL, or port if set, and
+// returns how long the connection took to open.
+func dialTCP(ctx context.Context, rawURL, port string) (time.Duration, error) {
+	host, urlPort := targetHostPort(rawURL)
+	port = cmp.Or(port, urlPort)
+	if port == "" {
+		return 0, errors.New("no port to connect to")
+	}
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+	conn.Close()
+	return latency, nil
+}
+
+// probeError shortens a client error to something that fits in a tooltip.
+func probeError(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "DNS lookup failed"
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Err.Error()
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// healthTargets lists every URL currently shown on the homepage, with its
+// health check policy. When an ingress and a bookmark share a URL the
+// ingress's policy is used.
+func (s *Server) healthTargets(ctx context.Context) []HealthTarget {
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	var targets []HealthTarget
+	add := func(name, u string, policy HealthPolicy) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			targets = append(targets, HealthTarget{URL: u, Name: name, Policy: policy})
+		}
+	}
+
+	apps, services, err := s.visibleTiles(listCtx)
+	if err != nil {
+		log.Printf("Warning: health checker failed to list ingresses: %v", err)
+	}
+	for _, info := range append(apps, services...) {
+		add(info.Name, info.URL, info.HealthCheck)
+	}
+
+	if config, err := s.bookmarkManager.GetConfig(listCtx); err == nil {
+		for _, b := range config.Bookmarks {
+			add(b.Name, b.URL, b.HealthCheck)
+		}
+	}
+	return targets
+}
+
+// applyHealth fills in the latest probe result for each ingress.
+func (s *Server) applyHealth(items []IngressInfo) {
+	for i := range items {
+		items[i].Health = s.health.Status(items[i].URL)
+	}
+}
+
+// applyBookmarkHealth fills in the latest probe result for each bookmark.
+func (s *Server) applyBookmarkHealth(bookmarks []Bookmark) {
+	for i := range bookmarks {
+		bookmarks[i].Health = s.health.Status(bookmarks[i].URL)
+	}
+}
+
+// HealthStatus is one target in the GET /api/v1/health response.
+type HealthStatus struct {
+	URL           string          `json:"url"`
+	State         HealthState     `json:"state"`
+	Check         string          `json:"check,omitempty"`
+	StatusCode    int             `json:"status_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	LatencyMs     int64           `json:"latency_ms"`
+	Slow          bool            `json:"slow"`
+	LastChecked   time.Time       `json:"last_checked"`
+	CertExpiry    *time.Time      `json:"cert_expiry,omitempty"`
+	UptimePercent float64         `json:"uptime_percent"`
+	History       []HealthHistory `json:"history"`
+}
+
+// HealthHistory is one past probe in a HealthStatus.
+type HealthHistory struct {
+	Time      time.Time `json:"time"`
+	Up        bool      `json:"up"`
+	LatencyMs int64     `json:"latency_ms"`
+}
+
+// handleHealthStatus serves the latest probe result and recent history for
+// every target, or just one when ?url= is given.
+func (s *Server) handleHealthStatus(w http.ResponseWriter, r *http.Request) {
+	if s.health == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "health checks are disabled"})
+		return
+	}
+
+	statuses := s.health.Statuses()
+	if u := r.URL.Query().Get("url"); u != "" {
+		status, ok := statuses[u]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "url is not being checked"})
+			return
+		}
+		statuses = map[string]TargetHealth{u: status}
+	}
+
+	results := make([]HealthStatus, 0, len(statuses))
+	for u, h := range statuses {
+		status := HealthStatus{
+			URL:           u,
+			State:         h.State,
+			Check:         h.Check,
+			StatusCode:    h.StatusCode,
+			Error:         h.Err,
+			LatencyMs:     h.Latency.Milliseconds(),
+			Slow:          h.Slow,
+			LastChecked:   h.LastChecked,
+			UptimePercent: h.Uptime,
+			History:       make([]HealthHistory, 0, len(h.History)),
+		}
+		if !h.CertExpiry.IsZero() {
+			status.CertExpiry = &h.CertExpiry
+		}
+		for _, sample := range h.History {
+			status.History = append(status.History, HealthHistory{
+				Time:      sample.Time,
+				Up:        sample.Up,
+				LatencyMs: sample.Latency.Milliseconds(),
+			})
+		}
+		results = append(results, status)
+	}
+	slices.SortFunc(results, func(a, b HealthStatus) int { return strings.Compare(a.URL, b.URL) })
+
+	writeJSON(w, http.StatusOK, results)
+}
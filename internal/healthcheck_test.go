@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestHealthChecker returns a checker with the defaults of
+// NewHealthCheckerFromEnv and no Redis, store or Uptime Kuma.
+func newTestHealthChecker() *HealthChecker {
+	return &HealthChecker{
+		client:   newHealthClient(false),
+		insecure: newHealthClient(true),
+		timeout:  defaultHealthCheckTimeout,
+		interval: defaultHealthCheckInterval,
+		slow:     defaultHealthCheckSlow,
+		keep:     defaultHealthHistory,
+		results:  make(map[string]TargetHealth),
+		history:  make(map[string][]HealthSample),
+	}
+}
+
+// TestCheckAllMixedTargets spreads a few probed targets among many that
+// Uptime Kuma monitors or that aren't due yet, so that the results of the
+// latter are filled in while probes finish. Run it with -race.
+func TestCheckAllMixedTargets(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/down") {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer backend.Close()
+
+	const n, probeEvery = 20000, 1000
+	var metrics strings.Builder
+	metrics.WriteString("# TYPE monitor_status gauge\n")
+	for i := range n {
+		fmt.Fprintf(&metrics, "monitor_status{monitor_name=\"kuma-%d\",monitor_url=\"https://kuma-%d.example.com/\"} 1\n", i, i)
+	}
+	kuma := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, metrics.String())
+	}))
+	defer kuma.Close()
+
+	h := newTestHealthChecker()
+	h.kuma = &UptimeKuma{metricsURL: kuma.URL + "/metrics", client: kuma.Client()}
+	carried := time.Now().Add(-time.Minute)
+	want := make(map[string]HealthState)
+	var targets []HealthTarget
+	for i := range n {
+		if i%probeEvery == 0 {
+			up, down := fmt.Sprintf("%s/up-%d", backend.URL, i), fmt.Sprintf("%s/down-%d", backend.URL, i)
+			targets = append(targets, HealthTarget{URL: up}, HealthTarget{URL: down})
+			want[up], want[down] = HealthUp, HealthDown
+		}
+		kumaURL, notDue := fmt.Sprintf("https://kuma-%d.example.com", i), fmt.Sprintf("https://not-due-%d.example.com", i)
+		h.results[notDue] = TargetHealth{State: HealthUp, LastChecked: carried}
+		targets = append(targets, HealthTarget{URL: kumaURL}, HealthTarget{URL: notDue, Policy: HealthPolicy{Interval: time.Hour}})
+		want[kumaURL], want[notDue] = HealthUp, HealthUp
+	}
+
+	h.checkAll(context.Background(), targets)
+
+	if len(h.results) != len(targets) {
+		t.Fatalf("got %d results, want %d", len(h.results), len(targets))
+	}
+	for url, state := range want {
+		result := h.results[url]
+		if result.State != state {
+			t.Errorf("%s: state %v, want %v", url, result.State, state)
+		}
+		switch {
+		case strings.Contains(url, "not-due"):
+			if !result.LastChecked.Equal(carried) {
+				t.Errorf("%s was probed although not due", url)
+			}
+			if len(h.history[url]) != 0 {
+				t.Errorf("%s: a result carried over was added to the history", url)
+			}
+		case strings.Contains(url, "kuma"):
+			if result.Check != HealthCheckUptimeKuma {
+				t.Errorf("%s: check %q, want %q", url, result.Check, HealthCheckUptimeKuma)
+			}
+		default:
+			if len(h.history[url]) != 1 {
+				t.Errorf("%s: %d history samples, want 1", url, len(h.history[url]))
+			}
+		}
+	}
+}
+
+// TestCheckAllNotifiesHealthChanges flips a target between up and down and
+// checks that only the flips are announced.
+func TestCheckAllNotifiesHealthChanges(t *testing.T) {
+	var down atomic.Bool
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer backend.Close()
+
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decoding event: %v", err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	h := newTestHealthChecker()
+	h.notifier = &Dispatcher{notifiers: []Notifier{webhookNotifier{url: webhook.URL}}, client: webhook.Client()}
+	targets := []HealthTarget{{URL: backend.URL, Name: "Grafana"}}
+	var titles []string
+	for _, isDown := range []bool{false, true, true, false, false} {
+		down.Store(isDown)
+		h.checkAll(context.Background(), targets)
+		mu.Lock()
+		for _, event := range events {
+			if event.Kind != EventHealthChanged || event.URL != backend.URL {
+				t.Errorf("unexpected event %+v", event)
+			}
+			titles = append(titles, event.Title)
+		}
+		events = nil
+		mu.Unlock()
+	}
+
+	if want := []string{"Down: Grafana", "Back up: Grafana"}; !slices.Equal(titles, want) {
+		t.Errorf("got events %q, want %q", titles, want)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	var methods []string
+	var mu sync.Mutex
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/get-only":
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/login":
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		annotations map[string]string
+		want        HealthState
+		requests    []string
+	}{
+		{name: "ok", path: "/", want: HealthUp, requests: []string{"HEAD /"}},
+		{name: "404 still answers", path: "/missing", want: HealthUp},
+		{name: "500", path: "/broken", want: HealthDown},
+		{name: "HEAD not allowed", path: "/get-only", want: HealthUp, requests: []string{"HEAD /get-only", "GET /get-only"}},
+		{name: "method", path: "/", annotations: map[string]string{HealthAnnotationPrefix + "method": "GET"}, want: HealthUp, requests: []string{"GET /"}},
+		{name: "path", path: "/", annotations: map[string]string{HealthAnnotationPrefix + "path": "/broken"}, want: HealthDown, requests: []string{"HEAD /broken"}},
+		{name: "expected status", path: "/login", annotations: map[string]string{HealthAnnotationPrefix + "status": "200-299"}, want: HealthDown},
+	}
+	h := newTestHealthChecker()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			methods = nil
+			mu.Unlock()
+			policy := healthPolicyFromAnnotations(tt.annotations, "test")
+			result := h.probe(context.Background(), HealthTarget{URL: backend.URL + tt.path, Policy: policy})
+			if result.State != tt.want {
+				t.Errorf("state %v (%s), want %v", result.State, result.Summary(), tt.want)
+			}
+			if result.LastChecked.IsZero() {
+				t.Error("LastChecked not set")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.requests != nil && !slices.Equal(methods, tt.requests) {
+				t.Errorf("requests %q, want %q", methods, tt.requests)
+			}
+		})
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+	defer backend.Close()
+
+	h := newTestHealthChecker()
+	tcp := HealthPolicy{Check: HealthCheckTCP}
+	if result := h.probe(context.Background(), HealthTarget{URL: backend.URL, Policy: tcp}); result.State != HealthUp || result.Check != HealthCheckTCP {
+		t.Errorf("open port: %+v, want up over TCP", result)
+	}
+	if result := h.probe(context.Background(), HealthTarget{URL: closedURL, Policy: tcp}); result.State != HealthDown || result.Err == "" {
+		t.Errorf("closed port: %+v, want down with an error", result)
+	}
+}
+
+func TestCheckAllKeepsHistory(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	defer backend.Close()
+
+	h := newTestHealthChecker()
+	h.keep = 3
+	targets := []HealthTarget{{URL: backend.URL}}
+	for range 5 {
+		h.checkAll(context.Background(), targets)
+	}
+	status := h.Status(backend.URL)
+	if len(status.History) != 3 || status.Uptime != 100 {
+		t.Errorf("history of %d samples at %.0f%% uptime, want 3 at 100%%", len(status.History), status.Uptime)
+	}
+
+	// A target that is no longer listed is forgotten.
+	h.checkAll(context.Background(), nil)
+	if status := h.Status(backend.URL); status.State != HealthUnknown || len(status.History) != 0 {
+		t.Errorf("removed target still has %+v", status)
+	}
+}
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// healthOptionPrefix starts the bookmark options that override how a
+// bookmark is health checked, e.g. "health-path=/healthz"; ingresses use
+// HealthAnnotationPrefix annotations with the same names.
+const healthOptionPrefix = "health-"
+
+// Kinds of health check.
+const (
+	HealthCheckHTTP = "http" // an HTTP request, the default
+	HealthCheckPing = "ping" // an ICMP echo request to the URL's host
+	HealthCheckTCP  = "tcp"  // a TCP connection to the URL's host and port
+)
+
+// validHealthChecks are the kinds of check a target may pick.
+var validHealthChecks = []string{HealthCheckHTTP, HealthCheckPing, HealthCheckTCP}
+
+// HealthPolicy overrides how one target is probed. The zero value probes
+// the tile's own URL with HEAD, falling back to GET, and counts any status
+// below 500 as up.
+type HealthPolicy struct {
+	Check    string        // one of validHealthChecks; empty for HTTP
+	Port     string        // port a TCP check connects to; empty for the URL's
+	Method   string        // request method; empty for HEAD with a GET fallback
+	Path     string        // path, and optionally query, probed instead of the URL's own
+	Expect   [][2]int      // inclusive status code ranges that count as up; empty for anything below 500
+	Timeout  time.Duration // zero for HEALTH_CHECK_TIMEOUT
+	Interval time.Duration // zero for HEALTH_CHECK_INTERVAL; shorter ones are rounded up to it
+	Insecure bool          // don't verify the TLS certificate, for self-signed ones
+}
+
+// HealthTarget is a URL to probe and how.
+type HealthTarget struct {
+	URL    string
+	Name   string // of the tile or bookmark, for notifications
+	Policy HealthPolicy
+}
+
+// parseHealthOption applies one health-<name> setting to p, where name is
+// check, port, method, path, status, timeout, interval or tls-verify. item names the
+// ingress or bookmark for the warning logged when the value is invalid.
+func (p *HealthPolicy) parseHealthOption(name, value, item string) {
+	value = strings.TrimSpace(value)
+	var err error
+	switch name {
+	case "check":
+		if check := strings.ToLower(value); slices.Contains(validHealthChecks, check) {
+			p.Check = check
+		} else {
+			err = errors.New("want http, ping or tcp")
+		}
+	case "port":
+		if _, err = net.LookupPort("tcp", value); err == nil {
+			p.Port = value
+		}
+	case "method":
+		if method := strings.ToUpper(value); validHealthMethods[method] {
+			p.Method = method
+		} else {
+			err = errors.New("want GET, HEAD or POST")
+		}
+	case "path":
+		if !strings.HasPrefix(value, "/") {
+			err = errors.New("want a path starting with /")
+		} else {
+			p.Path = value
+		}
+	case "status":
+		var expect [][2]int
+		if expect, err = parseStatusRanges(value); err == nil {
+			p.Expect = expect
+		}
+	case "timeout", "interval":
+		var d time.Duration
+		if d, err = time.ParseDuration(value); err == nil && d <= 0 {
+			err = errors.New("must be positive")
+		}
+		if err != nil {
+			break
+		}
+		if name == "timeout" {
+			p.Timeout = d
+		} else {
+			p.Interval = d
+		}
+	case "tls-verify":
+		var verify bool
+		if verify, err = strconv.ParseBool(value); err == nil {
+			p.Insecure = !verify
+		}
+	default:
+		err = errors.New("unknown setting")
+	}
+	if err != nil {
+		log.Printf("Warning: %s has invalid health-%s %q: %v", item, name, value, err)
+	}
+}
+
+// validHealthMethods are the methods a probe may use. Anything else could
+// change state on the target.
+var validHealthMethods = map[string]bool{http.MethodGet: true, http.MethodHead: true, http.MethodPost: true}
+
+// parseStatusRanges parses a comma-separated list of status codes and
+// ranges, e.g. "200-299,401".
+func parseStatusRanges(value string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range splitList(value) {
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isRange {
+			hi = lo
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		to, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || from < 100 || to > 599 || from > to {
+			return nil, errors.New("want status codes or ranges like 200-299,401")
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
+
+// healthPolicyFromAnnotations reads the gohome.stringer.sh/health-*
+// annotations of an ingress.
+func healthPolicyFromAnnotations(annotations map[string]string, item string) HealthPolicy {
+	var p HealthPolicy
+	for key, value := range annotations {
+		if name, ok := strings.CutPrefix(key, HealthAnnotationPrefix); ok {
+			p.parseHealthOption(name, value, item)
+		}
+	}
+	return p
+}
+
+// up reports whether a response with status code counts as up.
+func (p HealthPolicy) up(code int) bool {
+	if len(p.Expect) == 0 {
+		return code < 500
+	}
+	for _, r := range p.Expect {
+		if code >= r[0] && code <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// probeURL returns the URL to request for target rawURL.
+func (p HealthPolicy) probeURL(rawURL string) string {
+	if p.Path == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	ref, err := url.Parse(p.Path)
+	if err != nil {
+		return rawURL
+	}
+	u.Path, u.RawPath, u.RawQuery = ref.Path, ref.RawPath, ref.RawQuery
+	return u.String()
+}
+
+// targetHostPort returns the host of rawURL and its port: the explicit one,
+// or else the one its scheme is registered for, e.g. 22 for ssh://. A bare
+// "host" or "host:port" works too.
+func targetHostPort(rawURL string) (host, port string) {
+	if !strings.Contains(rawURL, "://") {
+		if h, p, err := net.SplitHostPort(rawURL); err == nil {
+			return h, p
+		}
+		return rawURL, ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL, ""
+	}
+	port = u.Port()
+	if port == "" && u.Scheme != "" {
+		if n, err := net.LookupPort("tcp", u.Scheme); err == nil {
+			port = strconv.Itoa(n)
+		}
+	}
+	return u.Hostname(), port
+}
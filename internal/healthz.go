@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// HealthDetails is the JSON body served at /healthz/details.
+type HealthDetails struct {
+	Status        string           `json:"status"`
+	Version       string           `json:"version"`
+	StartedAt     time.Time        `json:"started_at"`
+	UptimeSeconds int64            `json:"uptime_seconds"`
+	Kubernetes    KubernetesHealth `json:"kubernetes"`
+	Ingresses     IngressHealth    `json:"ingresses"`
+	ConfigMap     ConfigMapHealth  `json:"configmap"`
+	Cache         CacheHealth      `json:"cache"`
+}
+
+// KubernetesHealth reports API server connectivity.
+type KubernetesHealth struct {
+	DemoMode      bool   `json:"demo_mode"`
+	Connected     bool   `json:"connected"`
+	ServerVersion string `json:"server_version,omitempty"`
+	Error         string `json:"error,omitempty"`
+	// MissingPermissions are those AccessChecker found missing, e.g.
+	// "list ingresses.networking.k8s.io in all namespaces".
+	MissingPermissions []string `json:"missing_permissions,omitempty"`
+}
+
+// IngressHealth reports the state of the most recent ingress listing.
+type IngressHealth struct {
+	LastSync   *time.Time `json:"last_sync,omitempty"`
+	AgeSeconds *int64     `json:"age_seconds,omitempty"`
+	Count      int        `json:"count"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ConfigMapHealth reports the state of the most recent ConfigMap load.
+type ConfigMapHealth struct {
+	Name       string     `json:"name"`
+	LastLoad   *time.Time `json:"last_load,omitempty"`
+	AgeSeconds *int64     `json:"age_seconds,omitempty"`
+	Bookmarks  int        `json:"bookmarks"`
+	ReadOnly   bool       `json:"read_only"` // READ_ONLY=true: GoHome never writes to it
+	Error      string     `json:"error,omitempty"`
+}
+
+// CacheHealth reports the size of in-memory state held by the server.
+type CacheHealth struct {
+	UniqueVisitors int `json:"unique_visitors"`
+	Images         int `json:"images"`
+	Favicons       int `json:"favicons"`
+	HealthTargets  int `json:"health_targets"`
+	Feeds          int `json:"feeds"`
+	Calendars      int `json:"calendars"`
+	GitHub         int `json:"github"`
+	PromQL         int `json:"promql"`
+	HomeAssistant  int `json:"homeassistant"`
+	Media          int `json:"media"`
+	Probes         int `json:"probes"`
+	Capacity       int `json:"capacity"`
+	Grafana        int `json:"grafana"`
+}
+
+// handleHealthDetails reports per-component status as JSON. Unlike /health,
+// which only says the process is alive, this makes a live call to the API
+// server and returns 503 when any component is degraded, so it's suitable
+// for external monitors.
+func (s *Server) handleHealthDetails(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	details := s.healthDetails(ctx)
+
+	status := http.StatusOK
+	if details.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, details)
+}
+
+// healthDetails gathers the current state of every component.
+func (s *Server) healthDetails(ctx context.Context) HealthDetails {
+	now := time.Now()
+	details := HealthDetails{
+		Status:        "ok",
+		Version:       s.version,
+		StartedAt:     s.startTime,
+		UptimeSeconds: int64(now.Sub(s.startTime).Seconds()),
+	}
+
+	// Kubernetes connectivity
+	details.Kubernetes.DemoMode = s.kube() == nil
+	if s.kube() != nil {
+		version, err := s.kube().ServerVersion(ctx)
+		if err != nil {
+			details.Kubernetes.Error = err.Error()
+			details.Status = "degraded"
+		} else {
+			details.Kubernetes.Connected = true
+			details.Kubernetes.ServerVersion = version
+		}
+	}
+	for _, p := range s.access.Problems() {
+		details.Kubernetes.MissingPermissions = append(details.Kubernetes.MissingPermissions, p.Permission)
+	}
+
+	// Ingress listing
+	ingressSync := s.kube().SyncStatus()
+	details.Ingresses.Count = ingressSync.Count
+	if !ingressSync.LastSuccess.IsZero() {
+		details.Ingresses.LastSync = &ingressSync.LastSuccess
+		details.Ingresses.AgeSeconds = ageSeconds(now, ingressSync.LastSuccess)
+	}
+	if ingressSync.Err != nil {
+		details.Ingresses.Error = ingressSync.Err.Error()
+		details.Status = "degraded"
+	}
+
+	// ConfigMap
+	load := s.bookmarkManager.LoadStatus()
+	details.ConfigMap.Name = s.bookmarkManager.ConfigMapRef()
+	details.ConfigMap.Bookmarks = load.Bookmarks
+	details.ConfigMap.ReadOnly = s.readOnly
+	if !load.LastSuccess.IsZero() {
+		details.ConfigMap.LastLoad = &load.LastSuccess
+		details.ConfigMap.AgeSeconds = ageSeconds(now, load.LastSuccess)
+	}
+	if load.Err != nil {
+		// A missing ConfigMap isn't fatal (defaults are used), so it's
+		// reported but doesn't mark the whole service as degraded.
+		details.ConfigMap.Error = load.Err.Error()
+	}
+
+	// In-memory state
+	s.seenVisitorsMu.Lock()
+	details.Cache.UniqueVisitors = len(s.seenVisitors)
+	s.seenVisitorsMu.Unlock()
+	details.Cache.Images = s.images.Len()
+	details.Cache.Favicons = s.favicons.Len()
+	details.Cache.HealthTargets = s.health.Len()
+	details.Cache.Feeds = s.feeds.Len()
+	details.Cache.Calendars = s.calendars.Len()
+	details.Cache.GitHub = s.github.Len()
+	details.Cache.PromQL = s.promql.Len()
+	details.Cache.HomeAssistant = s.hass.Len()
+	details.Cache.Media = s.media.Len()
+	details.Cache.Probes = s.probes.Len()
+	details.Cache.Capacity = s.capacity.Len()
+	details.Cache.Grafana = s.grafana.Len()
+
+	return details
+}
+
+// ageSeconds returns the whole number of seconds between t and now.
+func ageSeconds(now, t time.Time) *int64 {
+	age := int64(now.Sub(t).Seconds())
+	return &age
+}
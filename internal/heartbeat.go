@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHeartbeatInterval is how often the heartbeat URL is pinged
+	// while GoHome is healthy.
+	defaultHeartbeatInterval = 5 * time.Minute
+	// heartbeatCheckInterval is how often readiness is checked, so a
+	// degradation is reported within a minute rather than at the next
+	// scheduled ping.
+	heartbeatCheckInterval = time.Minute
+)
+
+// Heartbeat pings a dead man's switch such as healthchecks.io, so an
+// outside service notices when the homepage itself stops working: either
+// the pings stop, or GoHome reports itself degraded.
+type Heartbeat struct {
+	url      string
+	failURL  string
+	interval time.Duration
+	client   *http.Client
+
+	mu       sync.Mutex
+	lastPing time.Time
+	lastOK   bool
+}
+
+// NewHeartbeatFromEnv returns a heartbeat pinging HEARTBEAT_URL every
+// HEARTBEAT_INTERVAL, or nil when it isn't set. Failures go to
+// HEARTBEAT_FAIL_URL, which defaults to HEARTBEAT_URL with /fail appended
+// as healthchecks.io expects. The URL is a credential of sorts, so it is
+// best set from a Secret.
+func NewHeartbeatFromEnv() *Heartbeat {
+	u := os.Getenv("HEARTBEAT_URL")
+	if u == "" {
+		return nil
+	}
+	b := &Heartbeat{
+		url:      u,
+		failURL:  os.Getenv("HEARTBEAT_FAIL_URL"),
+		interval: durationFromEnv("HEARTBEAT_INTERVAL", defaultHeartbeatInterval),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	if b.failURL == "" {
+		b.failURL = strings.TrimSuffix(u, "/") + "/fail"
+	}
+	log.Printf("Sending heartbeats to %s every %s", redactURL(u), b.interval)
+	return b
+}
+
+// Job checks readiness with details every heartbeatCheckInterval, pinging
+// when the last ping is HEARTBEAT_INTERVAL old or readiness has changed
+// since.
+func (b *Heartbeat) Job(details func(context.Context) HealthDetails) Job {
+	if b == nil {
+		return Job{}
+	}
+	return Job{
+		Name:     "heartbeat",
+		Interval: min(b.interval, heartbeatCheckInterval),
+		Run: func(ctx context.Context) error {
+			checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			return b.beat(ctx, details(checkCtx))
+		},
+	}
+}
+
+// beat sends the details to the heartbeat URL, or its fail URL when
+// degraded, if a ping is due. The body shows up in the ping's log.
+func (b *Heartbeat) beat(ctx context.Context, details HealthDetails) error {
+	ok := details.Status == "ok"
+	b.mu.Lock()
+	due := ok != b.lastOK || time.Since(b.lastPing) >= b.interval
+	b.mu.Unlock()
+	if !due {
+		return nil
+	}
+
+	u := b.url
+	if !ok {
+		u = b.failURL
+		log.Printf("Warning: GoHome is %s, reporting it to the heartbeat URL", details.Status)
+	}
+	if err := postJSON(ctx, b.client, u, details); err != nil {
+		log.Printf("Warning: Could not send heartbeat to %s: %v", redactURL(u), err)
+		return err
+	}
+	b.mu.Lock()
+	b.lastPing, b.lastOK = time.Now(), ok
+	b.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,40 @@
+package internal
+
+// hideTiles removes the tiles the visitor has hidden from the page. When
+// showHidden is set nothing is removed, so the visitor can find and restore
+// them. It returns the filtered slices and how many tiles are hidden.
+func hideTiles(hidden map[string]bool, showHidden bool, apps, services []IngressInfo, bookmarks []Bookmark) ([]IngressInfo, []IngressInfo, []Bookmark, int) {
+	if len(hidden) == 0 {
+		return apps, services, bookmarks, 0
+	}
+
+	count := 0
+	keepIngress := func(list []IngressInfo) []IngressInfo {
+		var kept []IngressInfo
+		for _, info := range list {
+			if hidden[ingressTileID(info)] {
+				count++
+				if !showHidden {
+					continue
+				}
+			}
+			kept = append(kept, info)
+		}
+		return kept
+	}
+	apps = keepIngress(apps)
+	services = keepIngress(services)
+
+	var keptBookmarks []Bookmark
+	for _, b := range bookmarks {
+		if hidden[bookmarkTileID(b)] {
+			count++
+			if !showHidden {
+				continue
+			}
+		}
+		keptBookmarks = append(keptBookmarks, b)
+	}
+
+	return apps, services, keptBookmarks, count
+}
@@ -0,0 +1,335 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// hassKeyPrefix marks ConfigMap keys that define an entity:
+	//   hass-<name>: "sensor.living_room_temperature|label=Living room|decimals=1"
+	hassKeyPrefix = "hass-"
+	// defaultHomeAssistantInterval is how often every entity is re-read.
+	defaultHomeAssistantInterval = time.Minute
+	// maxHomeAssistantBytes caps the size of a state response.
+	maxHomeAssistantBytes = 1 << 20
+)
+
+// HassEntityConfig is one entity from the ConfigMap.
+type HassEntityConfig struct {
+	Name      string
+	EntityID  string // e.g. "alarm_control_panel.home" or "person.alice"
+	Attribute string // shown instead of the state when set
+	Unit      string // overrides the entity's unit_of_measurement
+	Decimals  int    // digits after the point for numeric values; -1 picks by magnitude
+}
+
+// key identifies the cached state of c.
+func (c HassEntityConfig) key() string {
+	return c.EntityID + "\x00" + c.Attribute
+}
+
+// HomeAssistantConfig configures the optional Home Assistant widget, from
+// the homeassistant-url and hass-<name> ConfigMap keys. The long-lived
+// access token comes from HOMEASSISTANT_TOKEN so it can live in a Secret.
+type HomeAssistantConfig struct {
+	URL      string
+	Entities []HassEntityConfig // sorted by name
+}
+
+// HassStat is an entity state as rendered on the homepage.
+type HassStat struct {
+	Name    string
+	Value   string // formatted with its unit; empty until a read succeeds
+	Err     string // set when the most recent read failed
+	Fetched time.Time
+}
+
+// hassState is an entity as returned by /api/states/<entity_id>.
+type hassState struct {
+	EntityID   string         `json:"entity_id"`
+	State      string         `json:"state"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// hassCached is what the fetcher remembers about one entity.
+type hassCached struct {
+	state     hassState
+	ok        bool
+	err       error
+	fetched   time.Time
+	attempted time.Time
+}
+
+// HomeAssistantFetcher reads the configured entity states in the background
+// and caches them, so the homepage never waits on Home Assistant.
+type HomeAssistantFetcher struct {
+	client   *http.Client
+	token    string
+	interval time.Duration
+	mu       sync.Mutex
+	states   map[string]hassCached // keyed by HassEntityConfig.key
+}
+
+// NewHomeAssistantFetcherFromEnv creates a fetcher that re-reads entities
+// every HOMEASSISTANT_INTERVAL.
+func NewHomeAssistantFetcherFromEnv() *HomeAssistantFetcher {
+	return &HomeAssistantFetcher{
+		client:   &http.Client{Timeout: 15 * time.Second},
+		token:    os.Getenv("HOMEASSISTANT_TOKEN"),
+		interval: durationFromEnv("HOMEASSISTANT_INTERVAL", defaultHomeAssistantInterval),
+		states:   make(map[string]hassCached),
+	}
+}
+
+// parseHomeAssistant reads homeassistant-url and every hass-* key from
+// ConfigMap data.
+func parseHomeAssistant(data map[string]string) HomeAssistantConfig {
+	cfg := HomeAssistantConfig{URL: strings.TrimSuffix(strings.TrimSpace(data["homeassistant-url"]), "/")}
+	for key, value := range data {
+		if !strings.HasPrefix(key, hassKeyPrefix) {
+			continue
+		}
+		if entity, ok := parseHassEntity(key, value); ok {
+			cfg.Entities = append(cfg.Entities, entity)
+		}
+	}
+	if len(cfg.Entities) > 0 && cfg.URL == "" {
+		log.Printf("Warning: hass-* keys are set but homeassistant-url isn't")
+	}
+	slices.SortFunc(cfg.Entities, func(a, b HassEntityConfig) int { return strings.Compare(a.Name, b.Name) })
+	return cfg
+}
+
+// parseHassEntity parses a hass-<name> ConfigMap value: the entity ID
+// followed by optional |key=value settings.
+func parseHassEntity(key, value string) (HassEntityConfig, bool) {
+	parts := strings.Split(value, "|")
+	entity := HassEntityConfig{
+		Name:     strings.TrimPrefix(key, hassKeyPrefix),
+		EntityID: strings.TrimSpace(parts[0]),
+		Decimals: -1,
+	}
+	if domain, object, ok := strings.Cut(entity.EntityID, "."); !ok || domain == "" || object == "" {
+		log.Printf("Warning: hass %s has invalid entity ID %q, want domain.object_id", entity.Name, entity.EntityID)
+		return entity, false
+	}
+	for _, opt := range parts[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		v = strings.TrimSpace(v)
+		switch k {
+		case "label":
+			entity.Name = v
+		case "attribute":
+			entity.Attribute = v
+		case "unit":
+			entity.Unit = v
+		case "decimals":
+			if d, err := strconv.Atoi(v); err == nil && d >= 0 && d <= 6 {
+				entity.Decimals = d
+			} else {
+				log.Printf("Warning: hass %s has invalid decimals %q", entity.Name, v)
+			}
+		default:
+			log.Printf("Warning: hass %s has unknown option %q", entity.Name, opt)
+		}
+	}
+	return entity, true
+}
+
+// Name names the Home Assistant widget.
+func (f *HomeAssistantFetcher) Name() string { return "homeassistant" }
+
+// Interval is a minute at most, so the configuration is re-read every
+// minute even with a longer HOMEASSISTANT_INTERVAL.
+func (f *HomeAssistantFetcher) Interval() time.Duration { return min(f.interval, time.Minute) }
+
+// Refresh re-reads the configured entities that are due.
+func (f *HomeAssistantFetcher) Refresh(ctx context.Context, config *Config) error {
+	return f.fetchAll(ctx, config.HomeAssistant)
+}
+
+// Render returns the Stats of the configured entities.
+func (f *HomeAssistantFetcher) Render(config *Config, _ *Locale) any {
+	return f.Stats(config.HomeAssistant)
+}
+
+// fetchAll reads every entity that is due and drops state for entities that
+// are no longer configured, returning the errors of those that failed.
+func (f *HomeAssistantFetcher) fetchAll(ctx context.Context, cfg HomeAssistantConfig) error {
+	if cfg.URL == "" {
+		cfg.Entities = nil
+	}
+	keys := make([]string, 0, len(cfg.Entities))
+	var errs []error
+	var wg sync.WaitGroup
+	for _, entity := range cfg.Entities {
+		key := entity.key()
+		keys = append(keys, key)
+		f.mu.Lock()
+		due := time.Since(f.states[key].attempted) >= f.interval
+		f.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		wg.Go(func() {
+			fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			state, err := f.fetch(fetchCtx, cfg.URL, entity.EntityID)
+			cancel()
+			if err != nil {
+				log.Printf("Warning: Could not read Home Assistant entity %s: %v", entity.EntityID, err)
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entity.EntityID, err))
+			}
+			cached := f.states[key]
+			cached.err = err
+			cached.attempted = time.Now()
+			if err == nil {
+				cached.state, cached.ok = state, true
+				cached.fetched = time.Now()
+			}
+			f.states[key] = cached
+		})
+	}
+	wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key := range f.states {
+		if !slices.Contains(keys, key) {
+			delete(f.states, key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetch reads the state of one entity.
+func (f *HomeAssistantFetcher) fetch(ctx context.Context, baseURL, entityID string) (hassState, error) {
+	var state hassState
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/states/"+url.PathEscape(entityID), nil)
+	if err != nil {
+		return state, err
+	}
+	req.Header.Set("User-Agent", "gohome-homeassistant")
+	req.Header.Set("Accept", "application/json")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return state, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return state, errors.New("no such entity")
+	default:
+		return state, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxHomeAssistantBytes)).Decode(&state)
+	return state, err
+}
+
+// Stats returns the cached states for cfg. Entities that haven't been read
+// yet are left out.
+func (f *HomeAssistantFetcher) Stats(cfg HomeAssistantConfig) []HassStat {
+	if f == nil || cfg.URL == "" {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var stats []HassStat
+	for _, entity := range cfg.Entities {
+		cached, ok := f.states[entity.key()]
+		if !ok {
+			continue
+		}
+		stat := HassStat{Name: entity.Name, Fetched: cached.fetched}
+		if cached.ok {
+			stat.Value = formatHassValue(cached.state, entity)
+		}
+		if cached.err != nil {
+			stat.Err = cached.err.Error()
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// Len returns the number of entities with cached state.
+func (f *HomeAssistantFetcher) Len() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.states)
+}
+
+// formatHassValue formats the state, or the configured attribute, of an
+// entity. Numbers get decimals and a unit like Prometheus stats; other
+// states such as "armed_away" or "not_home" are made readable.
+func formatHassValue(state hassState, entity HassEntityConfig) string {
+	var raw string
+	if entity.Attribute != "" {
+		switch v := state.Attributes[entity.Attribute].(type) {
+		case nil:
+			return ""
+		case string:
+			raw = v
+		case []any:
+			// e.g. the entity_id list of a group of people at home, shown
+			// without their domain.
+			var items []string
+			for _, item := range v {
+				text := fmt.Sprint(item)
+				if _, object, ok := strings.Cut(text, "."); ok && !strings.Contains(text, " ") {
+					text = object
+				}
+				items = append(items, text)
+			}
+			return strings.Join(items, ", ")
+		default:
+			raw = fmt.Sprint(v)
+		}
+	} else {
+		raw = state.State
+	}
+	if raw == "unavailable" || raw == "unknown" {
+		return ""
+	}
+	if v, err := strconv.ParseFloat(raw, 64); err == nil {
+		unit := entity.Unit
+		if unit == "" && entity.Attribute == "" {
+			unit, _ = state.Attributes["unit_of_measurement"].(string)
+		}
+		return formatStat(v, entity.Decimals, unit)
+	}
+	text := strings.ReplaceAll(raw, "_", " ")
+	if text != "" {
+		text = strings.ToUpper(text[:1]) + text[1:]
+	}
+	if entity.Unit != "" {
+		text += " " + entity.Unit
+	}
+	return text
+}
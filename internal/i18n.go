@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// Message catalogs live in locales/<lang>.json as flat "key": "message"
+// objects, where messages are fmt format strings. en.json is the reference:
+// every key must exist there, and other languages fall back to it for keys
+// they don't translate yet. Adding a language is just adding a file.
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// defaultLanguage is used when nothing better matches and for missing keys.
+const defaultLanguage = "en"
+
+// Locale is the UI text for one language.
+type Locale struct {
+	Lang     string // BCP 47 tag, e.g. "de"
+	messages map[string]string
+	fallback *Locale // English, nil for English itself
+}
+
+// scriptMessages are the keys static/app.js needs, passed to the page as JSON.
+var scriptMessages = []string{
+	"controls.layout", "controls.theme", "controls.theme_title", "degraded.failed", "degraded.retrying",
+	"greeting.afternoon", "greeting.evening", "greeting.morning", "greeting.named", "greeting.night",
+	"layout.grid", "layout.list", "offline.banner", "theme.auto", "theme.contrast", "theme.dark", "theme.light",
+}
+
+var (
+	locales       map[string]*Locale
+	localeTags    []language.Tag // supported languages, defaultLanguage first
+	localeMatcher language.Matcher
+)
+
+func init() {
+	var err error
+	locales, err = loadLocales()
+	if err != nil {
+		panic(err)
+	}
+	localeTags = []language.Tag{language.Make(defaultLanguage)}
+	for _, lang := range Languages() {
+		if lang != defaultLanguage {
+			localeTags = append(localeTags, language.Make(lang))
+		}
+	}
+	localeMatcher = language.NewMatcher(localeTags)
+}
+
+// loadLocales parses the embedded catalogs.
+func loadLocales() (map[string]*Locale, error) {
+	files, err := localeFS.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[string]*Locale, len(files))
+	for _, f := range files {
+		data, err := localeFS.ReadFile("locales/" + f.Name())
+		if err != nil {
+			return nil, err
+		}
+		lang := strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
+		l := &Locale{Lang: lang}
+		if err := json.Unmarshal(data, &l.messages); err != nil {
+			return nil, fmt.Errorf("locales/%s: %w", f.Name(), err)
+		}
+		loaded[lang] = l
+	}
+	en, ok := loaded[defaultLanguage]
+	if !ok {
+		return nil, fmt.Errorf("locales/%s.json is missing", defaultLanguage)
+	}
+	for _, l := range loaded {
+		if l != en {
+			l.fallback = en
+		}
+	}
+	return loaded, nil
+}
+
+// Languages returns the supported language tags, sorted.
+func Languages() []string {
+	langs := make([]string, 0, len(locales))
+	for lang := range locales {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// T returns the message for key formatted with args, falling back to
+// English and then to the key itself.
+func (l *Locale) T(key string, args ...any) string {
+	msg, ok := l.messages[key]
+	if !ok && l.fallback != nil {
+		msg, ok = l.fallback.messages[key]
+	}
+	if !ok {
+		log.Printf("Warning: no %s message for %q", l.Lang, key)
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Date formats t as a long date without the year, e.g. "Monday 14 October".
+func (l *Locale) Date(t time.Time) string {
+	weekdays := strings.Split(l.T("date.weekdays"), ",")
+	months := strings.Split(l.T("date.months"), ",")
+	if len(weekdays) != 7 || len(months) != 12 {
+		return t.Format("Monday 2 January")
+	}
+	return l.T("date.long", weekdays[t.Weekday()], t.Day(), months[t.Month()-1])
+}
+
+// Ago describes how long ago t was in its largest unit, e.g. "3d ago", or
+// how long until it for a time to come. Under a minute is "just now" and
+// the zero time gives "".
+func (l *Locale) Ago(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := time.Since(t)
+	switch {
+	case d.Abs() < time.Minute:
+		return l.T("time.now")
+	case d < 0:
+		return l.T("time.in", l.durationUnits(d, 1))
+	}
+	return l.T("time.ago", l.durationUnits(d, 1))
+}
+
+// Duration formats d in its two largest units, e.g. "3d 4h" or "45s".
+func (l *Locale) Duration(d time.Duration) string {
+	return l.durationUnits(d, 2)
+}
+
+// durationUnits formats d, rounded down to a second, in up to n units
+// starting from its largest.
+func (l *Locale) durationUnits(d time.Duration, n int) string {
+	d = d.Abs()
+	var parts []string
+	for _, unit := range []struct {
+		key  string
+		size time.Duration
+	}{{"time.days", 24 * time.Hour}, {"time.hours", time.Hour}, {"time.minutes", time.Minute}, {"time.seconds", time.Second}} {
+		count := d / unit.size
+		d -= count * unit.size
+		if count > 0 {
+			parts = append(parts, l.T(unit.key, int(count)))
+		}
+		if len(parts) == n || (len(parts) > 0 && count == 0) {
+			break
+		}
+	}
+	if len(parts) == 0 {
+		return l.T("time.seconds", 0)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ScriptMessages returns the messages static/app.js uses.
+func (l *Locale) ScriptMessages() map[string]string {
+	m := make(map[string]string, len(scriptMessages))
+	for _, key := range scriptMessages {
+		m[key] = l.T(key)
+	}
+	return m
+}
+
+// localeFor picks the language for a request: the ConfigMap's language key
+// if set, otherwise the best match for the browser's Accept-Language.
+func localeFor(r *http.Request, config *Config) *Locale {
+	if l, ok := locales[config.Language]; ok {
+		return l
+	}
+	tags, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	_, i, confidence := localeMatcher.Match(tags...)
+	if confidence == language.No {
+		return locales[defaultLanguage]
+	}
+	return locales[localeTags[i].String()]
+}
+
+// localizeTemplates returns a copy of base per language with the "t",
+// "date", "ago" and "duration" template functions bound to that language's
+// messages.
+func localizeTemplates(base *template.Template) (map[string]*template.Template, error) {
+	localized := make(map[string]*template.Template, len(locales))
+	for lang, l := range locales {
+		tmpl, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		localized[lang] = tmpl.Funcs(template.FuncMap{"t": l.T, "date": l.Date, "ago": l.Ago, "duration": l.Duration})
+	}
+	return localized, nil
+}
@@ -0,0 +1,302 @@
+package internal
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icalEvent is a VEVENT as parsed from an iCalendar document. Only the parts
+// the calendar widget needs are kept.
+type icalEvent struct {
+	Summary  string
+	Location string
+	Start    time.Time
+	End      time.Time
+	AllDay   bool
+	RRule    map[string]string // parsed RRULE parts, nil if not recurring
+	ExDates  map[time.Time]bool
+}
+
+// maxRecurrencePeriods bounds RRULE expansion so a daily event from decades
+// ago, or a malformed rule, can't spin forever.
+const maxRecurrencePeriods = 50000
+
+// parseICal reads the VEVENTs from an iCalendar document. Floating times and
+// all-day dates are interpreted in loc.
+func parseICal(r io.Reader, loc *time.Location) ([]icalEvent, error) {
+	lines, err := unfoldICal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []icalEvent
+	var current *icalEvent
+	depth := 0 // nesting inside the VEVENT, e.g. VALARM
+	for _, line := range lines {
+		name, params, value := parseICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &icalEvent{}
+			depth = 0
+		case current == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+		case name == "END" && value == "VEVENT":
+			if !current.Start.IsZero() {
+				if current.End.IsZero() || !current.End.After(current.Start) {
+					if current.AllDay {
+						current.End = current.Start.AddDate(0, 0, 1)
+					} else {
+						current.End = current.Start
+					}
+				}
+				events = append(events, *current)
+			}
+			current = nil
+		case name == "END":
+			depth--
+		case depth > 0:
+			continue
+		case name == "SUMMARY":
+			current.Summary = unescapeICalText(value)
+		case name == "LOCATION":
+			current.Location = unescapeICalText(value)
+		case name == "DTSTART":
+			current.Start, current.AllDay, err = parseICalTime(value, params, loc)
+			if err != nil {
+				return nil, err
+			}
+		case name == "DTEND":
+			current.End, _, err = parseICalTime(value, params, loc)
+			if err != nil {
+				return nil, err
+			}
+		case name == "RRULE":
+			current.RRule = make(map[string]string)
+			for part := range strings.SplitSeq(value, ";") {
+				if k, v, ok := strings.Cut(part, "="); ok {
+					current.RRule[strings.ToUpper(k)] = strings.ToUpper(v)
+				}
+			}
+		case name == "EXDATE":
+			if current.ExDates == nil {
+				current.ExDates = make(map[time.Time]bool)
+			}
+			for v := range strings.SplitSeq(value, ",") {
+				if t, _, err := parseICalTime(v, params, loc); err == nil {
+					current.ExDates[t.UTC()] = true
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+// unfoldICal splits a document into logical lines, joining continuation
+// lines (those starting with a space or tab) onto the previous one.
+func unfoldICal(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseICalLine splits "NAME;PARAM=x;PARAM2=y:value".
+func parseICalLine(line string) (name string, params map[string]string, value string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	name = strings.ToUpper(parts[0])
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return name, params, value
+}
+
+// unescapeICalText undoes TEXT value escaping.
+func unescapeICalText(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// parseICalTime parses a DATE or DATE-TIME value. UTC ("Z") times are
+// absolute, TZID selects a zone, and anything else is taken as loc.
+func parseICalTime(value string, params map[string]string, loc *time.Location) (t time.Time, allDay bool, err error) {
+	value = strings.TrimSpace(value)
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	zone := loc
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			zone = l
+		}
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, zone)
+	return t, false, err
+}
+
+// occurrences returns the start times of e that overlap [from, to).
+func (e icalEvent) occurrences(from, to time.Time) []time.Time {
+	duration := e.End.Sub(e.Start)
+	overlaps := func(start time.Time) bool {
+		return start.Before(to) && (start.Add(duration).After(from) || !start.Before(from))
+	}
+
+	if e.RRule == nil {
+		if overlaps(e.Start) {
+			return []time.Time{e.Start}
+		}
+		return nil
+	}
+
+	rule := e.RRule
+	interval := 1
+	if n, err := strconv.Atoi(rule["INTERVAL"]); err == nil && n > 0 {
+		interval = n
+	}
+	count := -1
+	if n, err := strconv.Atoi(rule["COUNT"]); err == nil && n > 0 {
+		count = n
+	}
+	var until time.Time
+	if v := rule["UNTIL"]; v != "" {
+		until, _, _ = parseICalTime(v, nil, e.Start.Location())
+		if len(v) == 8 {
+			until = until.AddDate(0, 0, 1) // a DATE UNTIL includes that whole day
+		}
+	}
+
+	var result []time.Time
+	seen := 0
+	for k := 0; k < maxRecurrencePeriods; k++ {
+		candidates := e.period(rule["FREQ"], rule["BYDAY"], k*interval)
+		if candidates == nil {
+			return result // unsupported FREQ
+		}
+		for _, start := range candidates {
+			if start.Before(e.Start) {
+				continue
+			}
+			if (!until.IsZero() && !start.Before(until)) || !start.Before(to) {
+				return result
+			}
+			seen++
+			if count >= 0 && seen > count {
+				return result
+			}
+			if !e.ExDates[start.UTC()] && overlaps(start) {
+				result = append(result, start)
+			}
+		}
+	}
+	return result
+}
+
+// weekdays maps RRULE BYDAY codes to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// period returns the candidate starts in the n'th period after e.Start, in
+// order. It returns nil for an unsupported frequency and an empty slice for
+// a period with no valid dates (e.g. the 31st in a short month).
+func (e icalEvent) period(freq, byday string, n int) []time.Time {
+	s := e.Start
+	switch freq {
+	case "DAILY":
+		return []time.Time{s.AddDate(0, 0, n)}
+
+	case "WEEKLY":
+		base := s.AddDate(0, 0, 7*n)
+		if byday == "" {
+			return []time.Time{base}
+		}
+		// Expand BYDAY within the week (Monday first) containing base.
+		monday := base.AddDate(0, 0, -((int(base.Weekday()) + 6) % 7))
+		starts := []time.Time{}
+		for code := range strings.SplitSeq(byday, ",") {
+			if wd, ok := weekdays[code]; ok {
+				starts = append(starts, monday.AddDate(0, 0, (int(wd)+6)%7))
+			}
+		}
+		slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+		return starts
+
+	case "MONTHLY":
+		first := time.Date(s.Year(), s.Month()+time.Month(n), 1, s.Hour(), s.Minute(), s.Second(), 0, s.Location())
+		if byday == "" {
+			t := first.AddDate(0, 0, s.Day()-1)
+			if t.Month() != first.Month() {
+				return []time.Time{}
+			}
+			return []time.Time{t}
+		}
+		starts := []time.Time{}
+		for code := range strings.SplitSeq(byday, ",") {
+			if t, ok := nthWeekday(first, code); ok {
+				starts = append(starts, t)
+			}
+		}
+		slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+		return starts
+
+	case "YEARLY":
+		t := time.Date(s.Year()+n, s.Month(), s.Day(), s.Hour(), s.Minute(), s.Second(), 0, s.Location())
+		if t.Month() != s.Month() {
+			return []time.Time{} // 29 February in a non-leap year
+		}
+		return []time.Time{t}
+	}
+	return nil
+}
+
+// nthWeekday resolves a monthly BYDAY code such as "2TU" (second Tuesday) or
+// "-1FR" (last Friday) in the month starting at first.
+func nthWeekday(first time.Time, code string) (time.Time, bool) {
+	if len(code) < 2 {
+		return time.Time{}, false
+	}
+	wd, ok := weekdays[code[len(code)-2:]]
+	if !ok {
+		return time.Time{}, false
+	}
+	nth := 1
+	if prefix := code[:len(code)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 {
+			return time.Time{}, false
+		}
+		nth = n
+	}
+
+	if nth > 0 {
+		t := first.AddDate(0, 0, (int(wd)-int(first.Weekday())+7)%7+7*(nth-1))
+		return t, t.Month() == first.Month()
+	}
+	last := first.AddDate(0, 1, -1)
+	t := last.AddDate(0, 0, -((int(last.Weekday())-int(wd)+7)%7)+7*(nth+1))
+	return t, t.Month() == first.Month()
+}
@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseICal(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no timezone database:", err)
+	}
+	doc := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"SUMMARY:Team sync\\, weekly",
+		"LOCATION:Room 1\\; upstairs",
+		"DTSTART;TZID=Europe/Berlin:20261014T090000",
+		"DTEND;TZID=Europe/Berlin:20261014T093000",
+		"RRULE:FREQ=WEEKLY;byday=we",
+		"EXDATE;TZID=Europe/Berlin:20261021T090000,20261028T090000",
+		"BEGIN:VALARM",
+		"SUMMARY:Reminder",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:A long",
+		"  folded title",
+		"DTSTART;VALUE=DATE:20261015",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Deploy",
+		"DTSTART:20261014T120000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:No start",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := parseICal(strings.NewReader(doc), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(events), events)
+	}
+
+	sync := events[0]
+	if sync.Summary != "Team sync, weekly" || sync.Location != "Room 1; upstairs" {
+		t.Errorf("text not unescaped, or the alarm's read: %q at %q", sync.Summary, sync.Location)
+	}
+	if want := time.Date(2026, 10, 14, 9, 0, 0, 0, berlin); !sync.Start.Equal(want) || sync.End.Sub(sync.Start) != 30*time.Minute {
+		t.Errorf("start %v end %v, want 30 minutes from %v", sync.Start, sync.End, want)
+	}
+	if sync.RRule["FREQ"] != "WEEKLY" || sync.RRule["BYDAY"] != "WE" {
+		t.Errorf("rule %v", sync.RRule)
+	}
+	if len(sync.ExDates) != 2 || !sync.ExDates[time.Date(2026, 10, 21, 7, 0, 0, 0, time.UTC)] {
+		t.Errorf("exdates %v", sync.ExDates)
+	}
+
+	allDay := events[1]
+	if allDay.Summary != "A long folded title" || !allDay.AllDay || allDay.End.Sub(allDay.Start) != 24*time.Hour {
+		t.Errorf("all-day event %+v", allDay)
+	}
+
+	deploy := events[2]
+	if !deploy.Start.Equal(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)) || !deploy.End.Equal(deploy.Start) {
+		t.Errorf("event without an end %+v", deploy)
+	}
+}
+
+func TestParseICalTime(t *testing.T) {
+	tests := []struct {
+		value  string
+		params map[string]string
+		want   time.Time
+		allDay bool
+	}{
+		{"20261014", nil, time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), true},
+		{"20261014", map[string]string{"VALUE": "DATE"}, time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), true},
+		{"20261014T093000Z", nil, time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC), false},
+		{"20261014T093000", nil, time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC), false},
+		{"20261014T093000", map[string]string{"TZID": "America/New_York"}, time.Date(2026, 10, 14, 13, 30, 0, 0, time.UTC), false},
+		{"20261014T093000", map[string]string{"TZID": "Not/A_Zone"}, time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		got, allDay, err := parseICalTime(tt.value, tt.params, time.UTC)
+		if err != nil {
+			t.Errorf("%s %v: %v", tt.value, tt.params, err)
+			continue
+		}
+		if !got.Equal(tt.want) || allDay != tt.allDay {
+			t.Errorf("%s %v: got %v, all day %v; want %v, %v", tt.value, tt.params, got, allDay, tt.want, tt.allDay)
+		}
+	}
+	if _, _, err := parseICalTime("tomorrow", nil, time.UTC); err == nil {
+		t.Error("invalid time parsed")
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	day := func(month time.Month, d, hour int) time.Time {
+		return time.Date(2026, month, d, hour, 0, 0, 0, time.UTC)
+	}
+	event := func(start time.Time, rule string) icalEvent {
+		e := icalEvent{Start: start, End: start.Add(time.Hour)}
+		if rule != "" {
+			e.RRule = make(map[string]string)
+			for part := range strings.SplitSeq(rule, ";") {
+				k, v, _ := strings.Cut(part, "=")
+				e.RRule[k] = v
+			}
+		}
+		return e
+	}
+	withExDate := func(e icalEvent, t time.Time) icalEvent {
+		e.ExDates = map[time.Time]bool{t: true}
+		return e
+	}
+	multiDay := event(day(10, 12, 9), "")
+	multiDay.End = day(10, 15, 9)
+
+	tests := []struct {
+		name     string
+		event    icalEvent
+		from, to time.Time
+		want     []time.Time
+	}{
+		{"single, inside", event(day(10, 14, 9), ""), day(10, 14, 0), day(10, 15, 0), []time.Time{day(10, 14, 9)}},
+		{"single, outside", event(day(10, 20, 9), ""), day(10, 14, 0), day(10, 15, 0), nil},
+		{"started before the window", multiDay, day(10, 14, 0), day(10, 15, 0), []time.Time{day(10, 12, 9)}},
+		{"daily", event(day(10, 1, 9), "FREQ=DAILY"), day(10, 14, 0), day(10, 17, 0), []time.Time{day(10, 14, 9), day(10, 15, 9), day(10, 16, 9)}},
+		{"daily, count", event(day(10, 13, 9), "FREQ=DAILY;COUNT=2"), day(10, 13, 0), day(10, 20, 0), []time.Time{day(10, 13, 9), day(10, 14, 9)}},
+		{"daily, until a date", event(day(10, 13, 9), "FREQ=DAILY;UNTIL=20261014"), day(10, 13, 0), day(10, 20, 0), []time.Time{day(10, 13, 9), day(10, 14, 9)}},
+		{"every other day", event(day(10, 1, 9), "FREQ=DAILY;INTERVAL=2"), day(10, 14, 0), day(10, 18, 0), []time.Time{day(10, 15, 9), day(10, 17, 9)}},
+		{"exdate", withExDate(event(day(10, 1, 9), "FREQ=DAILY"), day(10, 15, 9)), day(10, 14, 0), day(10, 17, 0), []time.Time{day(10, 14, 9), day(10, 16, 9)}},
+		{"weekly, by day", event(day(10, 12, 9), "FREQ=WEEKLY;BYDAY=MO,FR"), day(10, 12, 0), day(10, 24, 0), []time.Time{day(10, 12, 9), day(10, 16, 9), day(10, 19, 9), day(10, 23, 9)}},
+		{"monthly 31st skips short months", event(day(8, 31, 9), "FREQ=MONTHLY"), day(9, 1, 0), day(11, 1, 0), []time.Time{day(10, 31, 9)}},
+		{"monthly last Friday", event(day(1, 30, 9), "FREQ=MONTHLY;BYDAY=-1FR"), day(10, 1, 0), day(11, 1, 0), []time.Time{day(10, 30, 9)}},
+		{"monthly second Tuesday", event(day(1, 13, 9), "FREQ=MONTHLY;BYDAY=2TU"), day(10, 1, 0), day(11, 1, 0), []time.Time{day(10, 13, 9)}},
+		{"yearly leap day", event(time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC), "FREQ=YEARLY"), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC), []time.Time{time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC)}},
+		{"unsupported frequency", event(day(10, 1, 9), "FREQ=HOURLY"), day(10, 14, 0), day(10, 15, 0), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.event.occurrences(tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("got %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestNthWeekday(t *testing.T) {
+	october := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC) // a Thursday
+	tests := []struct {
+		code string
+		day  int // 0 for none
+	}{
+		{"TH", 1},
+		{"1MO", 5},
+		{"2TU", 13},
+		{"4SA", 24},
+		{"5SA", 31},
+		{"5SU", 0},
+		{"-1FR", 30},
+		{"-2SA", 24},
+		{"0MO", 0},
+		{"XX", 0},
+		{"M", 0},
+	}
+	for _, tt := range tests {
+		got, ok := nthWeekday(october, tt.code)
+		if tt.day == 0 {
+			if ok {
+				t.Errorf("%s: got %v, want none", tt.code, got)
+			}
+			continue
+		}
+		if !ok || got.Day() != tt.day || got.Month() != time.October || got.Hour() != 9 {
+			t.Errorf("%s: got %v, %v; want 9:00 on 2026-10-%02d", tt.code, got, ok, tt.day)
+		}
+	}
+}
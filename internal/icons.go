@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// iconPacks maps an icon slug prefix to the CDN URL template for that pack.
+// Both the short and long prefix are accepted, e.g. "si:grafana" and
+// "simple-icons:grafana".
+var iconPacks = map[string]string{
+	"si":              "https://cdn.simpleicons.org/%s",
+	"simple-icons":    "https://cdn.simpleicons.org/%s",
+	"di":              "https://cdn.jsdelivr.net/gh/homarr-labs/dashboard-icons/png/%s.png",
+	"dashboard-icons": "https://cdn.jsdelivr.net/gh/homarr-labs/dashboard-icons/png/%s.png",
+}
+
+// iconSlugPattern restricts slugs to the characters the packs actually use,
+// so a slug can never be abused to reach a different path on the CDN.
+var iconSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+const (
+	// maxIconBytes caps the size of a fetched icon
+	maxIconBytes = 512 << 10
+	// iconCacheTTL is how long a fetched icon is remembered
+	iconCacheTTL = 24 * time.Hour
+	// iconFailureTTL is how long a failed fetch is remembered before retrying
+	iconFailureTTL = 5 * time.Minute
+)
+
+// resolveIcon turns an icon setting into a URL the browser can load. Pack
+// slugs ("si:grafana") are served through /icons/ and absolute http(s) URLs
+// through /images, so the browser never talks to a third-party host
+// directly. URLs on hosts the image proxy won't fetch from, and anything
+// else, resolve to "" (no icon).
+func resolveIcon(icon string) string {
+	icon = strings.TrimSpace(icon)
+	if icon == "" {
+		return ""
+	}
+	if strings.HasPrefix(icon, "https://") || strings.HasPrefix(icon, "http://") {
+		u, err := url.Parse(icon)
+		if err != nil || u.Host == "" {
+			log.Printf("Warning: Ignoring invalid icon URL %q", icon)
+			return ""
+		}
+		return sharedImageProxy().iconURL(u)
+	}
+
+	pack, slug, ok := strings.Cut(icon, ":")
+	slug = strings.ToLower(slug)
+	if _, known := iconPacks[pack]; !ok || !known || !iconSlugPattern.MatchString(slug) {
+		log.Printf("Warning: Ignoring unrecognised icon %q", icon)
+		return ""
+	}
+	return "/icons/" + pack + "/" + slug
+}
+
+// cachedIcon is a fetched icon, or a remembered failure when body is nil.
+type cachedIcon struct {
+	body        []byte
+	contentType string
+	fetched     time.Time
+}
+
+// fetchImage downloads an image, enforcing a size cap and an image content
+// type, both declared and sniffed.
+func fetchImage(ctx context.Context, client *http.Client, url string, maxBytes int) (cachedIcon, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return cachedIcon{}, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		return cachedIcon{}, fmt.Errorf("fetching %s: unexpected content type %q", url, contentType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return cachedIcon{}, err
+	}
+	if len(body) > maxBytes {
+		return cachedIcon{}, fmt.Errorf("fetching %s: image larger than %d bytes", url, maxBytes)
+	}
+	if contentType, err = checkImage(body, contentType); err != nil {
+		return cachedIcon{}, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	return cachedIcon{body: body, contentType: contentType}, nil
+}
+
+// checkImage makes sure body is an image, whatever its declared type says,
+// and returns the type to serve it as: the sniffed one when the content
+// sniffer recognises it. SVG and AVIF, which it doesn't, keep their
+// declared type unless the body looks like an HTML page.
+func checkImage(body []byte, declared string) (string, error) {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	switch {
+	case strings.HasPrefix(sniffed, "image/"):
+		return sniffed, nil
+	case sniffed == "text/html":
+		return "", errors.New("got an HTML page instead of an image")
+	case declared == "image/svg+xml" || declared == "image/avif":
+		return declared, nil
+	}
+	return "", fmt.Errorf("content sniffed as %q is not an image", sniffed)
+}
+
+// handleIcon serves /icons/{pack}/{slug} through the image proxy.
+func (s *Server) handleIcon(w http.ResponseWriter, r *http.Request) {
+	pack, slug := r.PathValue("pack"), r.PathValue("slug")
+	if _, ok := iconPacks[pack]; !ok || !iconSlugPattern.MatchString(slug) {
+		http.NotFound(w, r)
+		return
+	}
+
+	icon, err := s.images.Get(r.Context(), fmt.Sprintf(iconPacks[pack], slug), maxIconBytes)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	writeImage(w, icon)
+}
+
+// writeImage serves a cached image with headers that make it safe to serve
+// third-party content from our origin.
+func writeImage(w http.ResponseWriter, icon cachedIcon) {
+	w.Header().Set("Content-Type", icon.contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	// SVGs can carry scripts; this stops them running if opened directly.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, _ = w.Write(icon.body)
+}
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxProxyImageBytes caps the size of an image fetched through /images.
+	maxProxyImageBytes = 1 << 20
+	// maxProxyImages caps how many images and failures are kept in memory;
+	// the oldest is dropped to make room.
+	maxProxyImages = 512
+)
+
+// proxiedImageURL is our URL for a third-party image.
+func proxiedImageURL(rawURL string) string {
+	return "/images?" + url.Values{"url": {rawURL}}.Encode()
+}
+
+// ImageProxy fetches third-party images server-side and keeps them in
+// memory, so the browser only ever loads images from our origin. Only
+// allowlisted hosts are fetched from.
+type ImageProxy struct {
+	client *http.Client
+	hosts  []string // path.Match patterns, e.g. "*.example.com"
+
+	mu      sync.Mutex
+	images  map[string]cachedIcon // keyed by URL
+	refused map[string]bool       // hosts of icon URLs already warned about
+}
+
+// sharedImageProxy is the image proxy of this process, shared by the
+// server and resolveIcon so that icons are only pointed at it when it will
+// fetch them.
+var sharedImageProxy = sync.OnceValue(NewImageProxyFromEnv)
+
+// NewImageProxyFromEnv creates an image proxy that fetches from the icon
+// pack CDNs and the hosts listed in IMAGE_PROXY_HOSTS. Icon URLs on other
+// hosts are refused: they can be set by anyone who can annotate an ingress,
+// so they don't widen the allowlist themselves.
+func NewImageProxyFromEnv() *ImageProxy {
+	p := &ImageProxy{images: make(map[string]cachedIcon), refused: make(map[string]bool)}
+	for _, template := range iconPacks {
+		if u, err := url.Parse(fmt.Sprintf(template, "icon")); err == nil {
+			p.hosts = append(p.hosts, u.Host)
+		}
+	}
+	for _, host := range splitList(os.Getenv("IMAGE_PROXY_HOSTS")) {
+		if _, err := path.Match(host, ""); err != nil {
+			log.Printf("Warning: IMAGE_PROXY_HOSTS has invalid pattern %q", host)
+			continue
+		}
+		p.hosts = append(p.hosts, strings.ToLower(host))
+	}
+	p.client = &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			if !p.allows(req.URL) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return p
+}
+
+// allows reports whether u may be fetched: an http(s) URL on an allowed
+// host.
+func (p *ImageProxy) allows(u *url.URL) bool {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	for _, pattern := range p.hosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, strings.ToLower(u.Hostname())); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// iconURL is our URL for the icon at u, or "" when its host isn't allowed,
+// so the tile falls back to its favicon or no icon rather than an image
+// that never loads. Each refused host is only logged once.
+func (p *ImageProxy) iconURL(u *url.URL) string {
+	if p.allows(u) {
+		return proxiedImageURL(u.String())
+	}
+	host := strings.ToLower(u.Host)
+	p.mu.Lock()
+	warned := p.refused[host]
+	p.refused[host] = true
+	p.mu.Unlock()
+	if !warned {
+		log.Printf("Warning: Ignoring icon URLs on %s: add it to IMAGE_PROXY_HOSTS to show them", host)
+	}
+	return ""
+}
+
+// Get returns the image at rawURL, fetching it if it isn't cached or the
+// cached copy has expired. Failures are remembered for iconFailureTTL.
+func (p *ImageProxy) Get(ctx context.Context, rawURL string, maxBytes int) (cachedIcon, error) {
+	p.mu.Lock()
+	image, ok := p.images[rawURL]
+	p.mu.Unlock()
+	if ok && image.body != nil && time.Since(image.fetched) < iconCacheTTL {
+		return image, nil
+	}
+	if ok && image.body == nil && time.Since(image.fetched) < iconFailureTTL {
+		return image, fmt.Errorf("image %s unavailable (cached failure)", rawURL)
+	}
+
+	image, err := fetchImage(ctx, p.client, rawURL, maxBytes)
+	image.fetched = time.Now()
+	if err != nil {
+		image.body = nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, cached := p.images[rawURL]; !cached && len(p.images) >= maxProxyImages {
+		p.evictOldest()
+	}
+	p.images[rawURL] = image
+	return image, err
+}
+
+// evictOldest drops the image fetched longest ago. p.mu must be held.
+func (p *ImageProxy) evictOldest() {
+	var oldest string
+	var when time.Time
+	for key, image := range p.images {
+		if oldest == "" || image.fetched.Before(when) {
+			oldest, when = key, image.fetched
+		}
+	}
+	delete(p.images, oldest)
+}
+
+// Len returns the number of cached entries, including remembered failures.
+func (p *ImageProxy) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.images)
+}
+
+// handleImage serves /images?url=<image URL> through the proxy, for images
+// on allowed hosts only.
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	rawURL := r.URL.Query().Get("url")
+	u, err := url.Parse(rawURL)
+	if err != nil || !s.images.allows(u) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	image, err := s.images.Get(r.Context(), u.String(), maxProxyImageBytes)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	writeImage(w, image)
+}
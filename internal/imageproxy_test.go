@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestImageProxyAllows(t *testing.T) {
+	t.Setenv("IMAGE_PROXY_HOSTS", "*.example.com,cdn.example.net:8443")
+	p := NewImageProxyFromEnv()
+
+	// An icon URL doesn't allow its own host: it is dropped instead.
+	if got := resolveIcon("http://169.254.169.254/latest/meta-data"); got != "" {
+		t.Fatalf("icon URL on a host that isn't allowed resolved to %q", got)
+	}
+	for rawURL, want := range map[string]bool{
+		"https://cdn.simpleicons.org/grafana":        true,
+		"https://icons.example.com/grafana.png":      true,
+		"https://ICONS.example.com/grafana.png":      true,
+		"https://cdn.example.net:8443/logo.png":      true,
+		"https://cdn.example.net/logo.png":           false,
+		"http://169.254.169.254/latest/meta-data":    false,
+		"https://user@icons.example.com/grafana.png": false,
+		"ftp://icons.example.com/grafana.png":        false,
+	} {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.allows(u); got != want {
+			t.Errorf("allows(%s) = %v, want %v", rawURL, got, want)
+		}
+	}
+}
+
+func TestImageProxyIconURL(t *testing.T) {
+	t.Setenv("IMAGE_PROXY_HOSTS", "*.example.com")
+	p := NewImageProxyFromEnv()
+	tests := []struct {
+		rawURL, want string
+	}{
+		{"https://icons.example.com/grafana.png", "/images?url=https%3A%2F%2Ficons.example.com%2Fgrafana.png"},
+		{"https://cdn.jsdelivr.net/gh/homarr-labs/dashboard-icons/png/grafana.png", "/images?url=https%3A%2F%2Fcdn.jsdelivr.net%2Fgh%2Fhomarr-labs%2Fdashboard-icons%2Fpng%2Fgrafana.png"},
+		{"https://other.example.net/logo.png", ""},
+		{"https://OTHER.example.net/favicon.png", ""},
+		{"http://169.254.169.254/latest/meta-data", ""},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.iconURL(u); got != tt.want {
+			t.Errorf("iconURL(%s) = %q, want %q", tt.rawURL, got, tt.want)
+		}
+	}
+	if !p.refused["other.example.net"] || !p.refused["169.254.169.254"] || len(p.refused) != 2 {
+		t.Errorf("refused hosts %v, want other.example.net and 169.254.169.254", p.refused)
+	}
+}
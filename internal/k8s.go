@@ -2,15 +2,22 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -23,22 +30,89 @@ const (
 	NameAnnotation = "gohome.stringer.sh/name"
 	// AppAnnotation is the annotation key to mark an ingress as a top-level app
 	AppAnnotation = "gohome.stringer.sh/app"
+	// TagsAnnotation is the annotation key for a comma-separated list of search tags
+	TagsAnnotation = "gohome.stringer.sh/tags"
+	// IconAnnotation is the annotation key for a tile icon, e.g. "si:grafana" or an image URL
+	IconAnnotation = "gohome.stringer.sh/icon"
+	// NewTabAnnotation is the annotation key overriding the new-tab setting for one ingress, "true" or "false"
+	NewTabAnnotation = "gohome.stringer.sh/new-tab"
+	// GroupsAnnotation is the annotation key for a comma-separated list of groups that alone may see an ingress
+	GroupsAnnotation = "gohome.stringer.sh/groups"
+	// GoLinkAnnotation is the annotation key for a comma-separated list of go link names that redirect to an ingress, e.g. "plex,tv"
+	GoLinkAnnotation = "gohome.stringer.sh/go"
+	// HealthAnnotationPrefix starts the annotation keys overriding the health check of one ingress, see HealthPolicy
+	HealthAnnotationPrefix = "gohome.stringer.sh/health-"
 )
 
 // IngressInfo represents a simplified ingress for display
 type IngressInfo struct {
 	Name            string
+	Namespace       string
+	Cluster         string // see clusterName
 	Host            string
 	Path            string
 	URL             string
+	Service         string // backend Service of the first path, in Namespace; empty for demo ingresses
+	TLSSecret       string // Secret holding Host's certificate, in Namespace; empty without one
 	Tailscale       bool
 	TailscaleFunnel bool
 	IsApp           bool
+	Tags            []string
+	Groups          []string // only viewers in one of these groups see it; empty for everyone
+	GoLinks         []string // /go/<name> names that redirect here, from GoLinkAnnotation
+	Icon            string   // icon as configured, e.g. "si:grafana"
+	IconURL         string   // Icon resolved to a URL the browser can load
+	Target          string   // link target, "_blank" or "_self"; empty until resolved from the new-tab setting
+	Href            string   // link the tile opens through, "/click?id=..." while recent tracking is on or "/launch?target=..." with launch-check; empty to use URL
+	Sample          bool     // a demo-mode example, not a real ingress
+	Labels          map[string]string
+	HealthCheck     HealthPolicy
+	Health          TargetHealth
+	NoDNS           bool // Host has no DNS record; only checked with DNS_CHECKS=true
+	Cert            CertStatus
+	Traffic         TrafficStatus // ingress-nginx request rate; only with ingress-traffic
 }
 
 // K8sClient wraps the Kubernetes client
 type K8sClient struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+	fixtures  *Fixtures // lists ingresses instead of clientset; see NewFixtureClient
+
+	syncMu   sync.Mutex
+	lastSync SyncStatus
+
+	ingresses *Cache[ingressList]
+	replicas  *Cache[map[string]Replicas]
+	snapshot  *Snapshot[ingressList] // nil unless SNAPSHOT_FILE or a data store is set
+
+	// ingressNamespace narrows the listing to one namespace when
+	// AccessChecker finds ingresses can't be listed cluster-wide.
+	scopeMu          sync.Mutex
+	ingressNamespace string
+}
+
+// ingressList is a classified ingress listing as cached by K8sClient. The
+// fields are exported so it can be shared through Redis.
+type ingressList struct {
+	Apps, Services []IngressInfo
+}
+
+// SyncStatus describes the outcome of the most recent ingress listing.
+type SyncStatus struct {
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Count       int
+	Err         error
+	// Restored is set while LastSuccess and Count are those of the
+	// snapshot loaded at startup, before any listing has succeeded.
+	Restored bool
+}
+
+// Degraded describes a page rendered while the API server is unreachable.
+type Degraded struct {
+	Since    time.Time // last successful listing; zero if there never was one
+	Restored bool      // the tiles come from a snapshot saved before a restart
+	Err      string
 }
 
 // NewK8sClient creates a new Kubernetes client, trying in-cluster config first, then kubeconfig
@@ -61,14 +135,43 @@ func NewK8sClient() (*K8sClient, error) {
 		log.Println("Using in-cluster config for Kubernetes client")
 	}
 
+	config.Wrap(instrumentKubernetes)
+	if tracingEnabled() {
+		config.Wrap(traceKubernetes)
+	}
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
+	return NewK8sClientFor(clientset), nil
+}
 
-	return &K8sClient{
+// NewK8sClientFor creates a Kubernetes client that talks to the API server
+// through clientset, such as a fake clientset in tests.
+func NewK8sClientFor(clientset kubernetes.Interface) *K8sClient {
+	k := &K8sClient{
 		clientset: clientset,
-	}, nil
+		ingresses: NewCacheFromEnv[ingressList]("ingresses"),
+		replicas:  NewCacheFromEnv[map[string]Replicas]("replicas"),
+		snapshot:  newSnapshot[ingressList]("ingresses"),
+	}
+	k.restoreSnapshot()
+	return k
+}
+
+// restoreSnapshot seeds the ingress cache with the listing saved before the
+// last restart, so the page has tiles to show if the API server can't be
+// reached now.
+func (k *K8sClient) restoreSnapshot() {
+	list, saved, ok := k.snapshot.Load(context.Background())
+	if !ok {
+		return
+	}
+	k.ingresses.Seed("", list)
+	k.syncMu.Lock()
+	k.lastSync = SyncStatus{LastSuccess: saved, Count: len(list.Apps) + len(list.Services), Restored: true}
+	k.syncMu.Unlock()
+	log.Printf("Loaded %d ingresses from the snapshot saved at %s", len(list.Apps)+len(list.Services), saved.Format(time.RFC3339))
 }
 
 // loadKubeConfig loads the kubeconfig from default locations
@@ -99,53 +202,236 @@ func loadKubeConfig() (*rest.Config, error) {
 }
 
 // GetClientset returns the underlying Kubernetes clientset
-func (k *K8sClient) GetClientset() *kubernetes.Clientset {
+func (k *K8sClient) GetClientset() kubernetes.Interface {
 	return k.clientset
 }
 
 // GetVisibleIngresses returns all ingresses that should be displayed on the homepage,
 // split into apps (annotated with gohome.stringer.sh/app: "true") and regular services.
+// Listings are cached (see Cache); if the listing fails it returns the error together
+// with the ingresses from the last successful listing, if any, so the page can keep
+// showing them.
 func (k *K8sClient) GetVisibleIngresses(ctx context.Context) (apps []IngressInfo, services []IngressInfo, err error) {
-	if k == nil || k.clientset == nil {
+	if k == nil || (k.clientset == nil && k.fixtures == nil) {
 		log.Printf("Info: Kubernetes client not available, returning demo ingresses")
 		demoApps, demoServices := k.getDemoIngresses()
+		for _, list := range [][]IngressInfo{demoApps, demoServices} {
+			for i := range list {
+				list[i].Cluster = clusterName()
+				list[i].Sample = true
+			}
+		}
 		return demoApps, demoServices, nil
 	}
 
-	ingresses, err := k.clientset.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
+	list, err := k.ingresses.Get(ctx, "", k.listIngresses)
+	// Callers fill in favicons, health and links, so each gets its own copy.
+	return slices.Clone(list.Apps), slices.Clone(list.Services), err
+}
+
+// InvalidateCache makes the next listings go to the API server.
+func (k *K8sClient) InvalidateCache() {
+	if k == nil {
+		return
+	}
+	k.ingresses.Invalidate("")
+	k.replicas.Invalidate("")
+}
+
+// setIngressNamespace lists ingresses in namespace only from now on, or in
+// all namespaces for "". A change takes effect at the next listing.
+func (k *K8sClient) setIngressNamespace(namespace string) {
+	k.scopeMu.Lock()
+	changed := k.ingressNamespace != namespace
+	k.ingressNamespace = namespace
+	k.scopeMu.Unlock()
+	if changed {
+		k.InvalidateCache()
+	}
+}
+
+// listIngresses lists and classifies the ingresses across all namespaces.
+func (k *K8sClient) listIngresses(ctx context.Context) (ingressList, error) {
+	var list ingressList
+	k.scopeMu.Lock()
+	namespace := k.ingressNamespace
+	k.scopeMu.Unlock()
+	ingresses, err := k.listIngressObjects(ctx, namespace)
+	k.recordSync(ingresses, err)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to list ingresses: %w", err)
+		return list, fmt.Errorf("failed to list ingresses: %w", err)
 	}
 
 	for _, ingress := range ingresses.Items {
-		// Skip ingresses with hide annotation
-		if shouldHide := ingress.Annotations[HideAnnotation]; shouldHide == "true" {
+		info, skipped := k.classifyIngress(&ingress)
+		if skipped == skipHidden {
 			log.Printf("Hiding ingress %s/%s due to annotation", ingress.Namespace, ingress.Name)
-			continue
 		}
-
-		// Extract ingress information
-		info := k.extractIngressInfo(&ingress)
-		if info.URL == "" {
+		if skipped != "" {
 			continue
 		}
 
 		if info.IsApp {
-			apps = append(apps, info)
+			list.Apps = append(list.Apps, info)
 		} else {
-			services = append(services, info)
+			list.Services = append(list.Services, info)
 		}
 	}
 
 	// Sort both slices alphabetically by name
-	sort.Slice(apps, func(i, j int) bool {
-		return apps[i].Name < apps[j].Name
+	sort.Slice(list.Apps, func(i, j int) bool {
+		return list.Apps[i].Name < list.Apps[j].Name
 	})
-	sort.Slice(services, func(i, j int) bool {
-		return services[i].Name < services[j].Name
+	sort.Slice(list.Services, func(i, j int) bool {
+		return list.Services[i].Name < list.Services[j].Name
 	})
 
-	return apps, services, nil
+	k.snapshot.Save(ctx, list)
+	return list, nil
+}
+
+// listIngressObjects lists the ingresses in namespace, or in all
+// namespaces for "", from the API server or the fixtures.
+func (k *K8sClient) listIngressObjects(ctx context.Context, namespace string) (*networkingv1.IngressList, error) {
+	if k.fixtures != nil {
+		return k.fixtures.ingressList(namespace), nil
+	}
+	return k.clientset.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+}
+
+// watchIngresses watches the ingresses the listing would see from their
+// current version, invalidating the cache and calling changed for every
+// change, until the API server ends the watch or ctx is done.
+func (k *K8sClient) watchIngresses(ctx context.Context, changed func()) error {
+	k.scopeMu.Lock()
+	namespace := k.ingressNamespace
+	k.scopeMu.Unlock()
+	ingresses := k.clientset.NetworkingV1().Ingresses(namespace)
+	// Watching from the version of a list skips the ADDED event a watch
+	// from scratch sends for every existing ingress.
+	current, err := ingresses.List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return err
+	}
+	w, err := ingresses.Watch(ctx, metav1.ListOptions{ResourceVersion: current.ResourceVersion})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+	for event := range w.ResultChan() {
+		if event.Type == watch.Error {
+			return apierrors.FromObject(event.Object)
+		}
+		k.InvalidateCache()
+		changed()
+	}
+	return ctx.Err()
+}
+
+// Reasons classifyIngress leaves an ingress off the homepage.
+const (
+	skipHidden          = "annotation " + HideAnnotation + "=true"
+	skipNoHost          = "no host in spec.rules"
+	skipNoTailscaleHost = "the Tailscale operator has not published a hostname in status.loadBalancer yet"
+)
+
+// classifyIngress converts an ingress for display, or returns why it isn't
+// shown.
+func (k *K8sClient) classifyIngress(ingress *networkingv1.Ingress) (IngressInfo, string) {
+	if ingress.Annotations[HideAnnotation] == "true" {
+		return IngressInfo{}, skipHidden
+	}
+	info := k.extractIngressInfo(ingress)
+	if info.URL == "" {
+		if info.Tailscale {
+			return info, skipNoTailscaleHost
+		}
+		return info, skipNoHost
+	}
+	return info, ""
+}
+
+// skippedIngress is an ingress that isn't shown, and why.
+type skippedIngress struct {
+	Namespace, Name string
+	Reason          string
+}
+
+// explainIngresses lists the ingresses like the homepage does, bypassing
+// the cache, and also returns those it leaves out.
+func (k *K8sClient) explainIngresses(ctx context.Context) (list ingressList, skipped []skippedIngress, err error) {
+	ingresses, err := k.listIngressObjects(ctx, "")
+	if err != nil {
+		return list, nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+	for _, ingress := range ingresses.Items {
+		info, reason := k.classifyIngress(&ingress)
+		switch {
+		case reason != "":
+			skipped = append(skipped, skippedIngress{Namespace: ingress.Namespace, Name: ingress.Name, Reason: reason})
+		case info.IsApp:
+			list.Apps = append(list.Apps, info)
+		default:
+			list.Services = append(list.Services, info)
+		}
+	}
+	sort.Slice(list.Apps, func(i, j int) bool { return list.Apps[i].Name < list.Apps[j].Name })
+	sort.Slice(list.Services, func(i, j int) bool { return list.Services[i].Name < list.Services[j].Name })
+	return list, skipped, nil
+}
+
+// recordSync remembers the result of an ingress listing for health reporting.
+func (k *K8sClient) recordSync(ingresses *networkingv1.IngressList, err error) {
+	k.syncMu.Lock()
+	defer k.syncMu.Unlock()
+
+	now := time.Now()
+	k.lastSync.LastAttempt = now
+	k.lastSync.Err = err
+	if err == nil {
+		k.lastSync.LastSuccess = now
+		k.lastSync.Count = len(ingresses.Items)
+		k.lastSync.Restored = false
+	}
+}
+
+// SyncStatus returns the outcome of the most recent ingress listing.
+func (k *K8sClient) SyncStatus() SyncStatus {
+	if k == nil {
+		return SyncStatus{}
+	}
+	k.syncMu.Lock()
+	defer k.syncMu.Unlock()
+	return k.lastSync
+}
+
+// ServerVersion asks the API server for its version, which doubles as a cheap
+// connectivity check.
+func (k *K8sClient) ServerVersion(ctx context.Context) (string, error) {
+	if k != nil && k.fixtures != nil {
+		return "fixtures " + k.fixtures.Path, nil
+	}
+	if k == nil || k.clientset == nil {
+		return "", fmt.Errorf("kubernetes client not available")
+	}
+	restClient := k.clientset.Discovery().RESTClient()
+	if restClient == nil {
+		// Fake clientsets have no REST client, but answer this themselves.
+		info, err := k.clientset.Discovery().ServerVersion()
+		if err != nil {
+			return "", err
+		}
+		return info.GitVersion, nil
+	}
+	body, err := restClient.Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return "", err
+	}
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", fmt.Errorf("failed to decode server version: %w", err)
+	}
+	return info.GitVersion, nil
 }
 
 // isTailscaleIngress returns true when the ingress is managed by the Tailscale operator.
@@ -174,18 +460,34 @@ func (k *K8sClient) extractIngressInfo(ingress *networkingv1.Ingress) IngressInf
 
 	info := IngressInfo{
 		Name:            name,
+		Namespace:       ingress.Namespace,
+		Cluster:         clusterName(),
 		Tailscale:       isTailscaleIngress(ingress),
 		TailscaleFunnel: isTailscaleIngress(ingress) && ingress.Annotations["tailscale.com/funnel"] == "true",
 		IsApp:           ingress.Annotations[AppAnnotation] == "true",
+		Tags:            splitList(ingress.Annotations[TagsAnnotation]),
+		Groups:          splitList(ingress.Annotations[GroupsAnnotation]),
+		GoLinks:         parseGoLinkNames(ingress.Annotations[GoLinkAnnotation], "ingress "+ingress.Namespace+"/"+ingress.Name),
+		Icon:            ingress.Annotations[IconAnnotation],
+		Labels:          ingress.Labels,
+		Target:          parseNewTab(ingress.Annotations[NewTabAnnotation], "ingress "+ingress.Namespace+"/"+ingress.Name),
+		HealthCheck:     healthPolicyFromAnnotations(ingress.Annotations, "ingress "+ingress.Namespace+"/"+ingress.Name),
 	}
+	info.IconURL = resolveIcon(info.Icon)
 
 	// Extract the first path from spec rules if available
 	if len(ingress.Spec.Rules) > 0 {
 		rule := ingress.Spec.Rules[0]
 		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
 			info.Path = rule.HTTP.Paths[0].Path
+			if backend := rule.HTTP.Paths[0].Backend.Service; backend != nil {
+				info.Service = backend.Name
+			}
 		}
 	}
+	if info.Service == "" && ingress.Spec.DefaultBackend != nil && ingress.Spec.DefaultBackend.Service != nil {
+		info.Service = ingress.Spec.DefaultBackend.Service.Name
+	}
 
 	if info.Tailscale {
 		// Tailscale ingresses use a wildcard host in spec.rules; the real hostname is
@@ -212,6 +514,7 @@ func (k *K8sClient) extractIngressInfo(ingress *networkingv1.Ingress) IngressInf
 			for _, host := range tls.Hosts {
 				if host == info.Host {
 					protocol = "https"
+					info.TLSSecret = tls.SecretName
 					break
 				}
 			}
@@ -229,59 +532,91 @@ func (k *K8sClient) extractIngressInfo(ingress *networkingv1.Ingress) IngressInf
 func (k *K8sClient) getDemoIngresses() ([]IngressInfo, []IngressInfo) {
 	apps := []IngressInfo{
 		{
-			Name:  "freshrss",
-			Host:  "rss.example.com",
-			Path:  "/",
-			URL:   "https://rss.example.com/",
-			IsApp: true,
+			Name:      "freshrss",
+			Namespace: "freshrss",
+			Host:      "rss.example.com",
+			Path:      "/",
+			URL:       "https://rss.example.com/",
+			IsApp:     true,
+			Tags:      []string{"news", "reader"},
+			Icon:      "di:freshrss",
+			IconURL:   resolveIcon("di:freshrss"),
 		},
 		{
-			Name:  "home-assistant",
-			Host:  "hass.example.com",
-			Path:  "/",
-			URL:   "https://hass.example.com/",
-			IsApp: true,
+			Name:      "home-assistant",
+			Namespace: "home-automation",
+			Host:      "hass.example.com",
+			Path:      "/",
+			URL:       "https://hass.example.com/",
+			IsApp:     true,
+			Tags:      []string{"smart-home"},
+			Icon:      "di:home-assistant",
+			IconURL:   resolveIcon("di:home-assistant"),
 		},
 	}
 	services := []IngressInfo{
 		{
-			Name: "grafana",
-			Host: "grafana.example.com",
-			Path: "/",
-			URL:  "https://grafana.example.com/",
+			Name:      "grafana",
+			Namespace: "monitoring",
+			Host:      "grafana.example.com",
+			Path:      "/",
+			URL:       "https://grafana.example.com/",
+			Tags:      []string{"metrics", "dashboards"},
+			Icon:      "si:grafana",
+			IconURL:   resolveIcon("si:grafana"),
 		},
 		{
-			Name: "jellyfin",
-			Host: "media.example.com",
-			Path: "/",
-			URL:  "https://media.example.com/",
+			Name:      "jellyfin",
+			Namespace: "media",
+			Host:      "media.example.com",
+			Path:      "/",
+			URL:       "https://media.example.com/",
+			Tags:      []string{"video"},
+			Icon:      "di:jellyfin",
+			IconURL:   resolveIcon("di:jellyfin"),
 		},
 		{
-			Name: "nextcloud",
-			Host: "cloud.example.com",
-			Path: "/",
-			URL:  "https://cloud.example.com/",
+			Name:      "nextcloud",
+			Namespace: "nextcloud",
+			Host:      "cloud.example.com",
+			Path:      "/",
+			URL:       "https://cloud.example.com/",
+			Tags:      []string{"files"},
+			Icon:      "si:nextcloud",
+			IconURL:   resolveIcon("si:nextcloud"),
 		},
 		{
 			Name:      "open-webui",
+			Namespace: "ai",
 			Host:      "ai.example-tailnet.ts.net",
 			Path:      "/",
 			URL:       "https://ai.example-tailnet.ts.net/",
 			Tailscale: true,
+			Tags:      []string{"llm"},
+			Icon:      "di:open-webui",
+			IconURL:   resolveIcon("di:open-webui"),
 		},
 		{
 			Name:            "open-webui-funnel",
+			Namespace:       "ai",
 			Host:            "ai.snowy-galaxy.ts.net",
 			Path:            "/",
 			URL:             "https://ai.snowy-galaxy.ts.net/",
 			Tailscale:       true,
 			TailscaleFunnel: true,
+			Tags:            []string{"llm"},
+			Icon:            "di:open-webui",
+			IconURL:         resolveIcon("di:open-webui"),
 		},
 		{
-			Name: "portainer",
-			Host: "portainer.example.com",
-			Path: "/",
-			URL:  "https://portainer.example.com/",
+			Name:      "portainer",
+			Namespace: "portainer",
+			Host:      "portainer.example.com",
+			Path:      "/",
+			URL:       "https://portainer.example.com/",
+			Tags:      []string{"admin"},
+			Icon:      "di:portainer",
+			IconURL:   resolveIcon("di:portainer"),
 		},
 	}
 	return apps, services
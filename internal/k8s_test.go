@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testIngress returns an ingress for host, with TLS when secret is set.
+func testIngress(namespace, name, host, secret string, annotations map[string]string) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations},
+	}
+	if host != "" {
+		ingress.Spec.Rules = []networkingv1.IngressRule{{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+				Paths: []networkingv1.HTTPIngressPath{{
+					Path:    "/",
+					Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: name}},
+				}},
+			}},
+		}}
+	}
+	if secret != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{host}, SecretName: secret}}
+	}
+	return ingress
+}
+
+// testTailscaleIngress returns a Tailscale ingress, published as hostname
+// once the operator has given it one.
+func testTailscaleIngress(namespace, name, hostname string) *networkingv1.Ingress {
+	className := "tailscale"
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &className,
+			DefaultBackend:   &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: name}},
+		},
+	}
+	if hostname != "" {
+		ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{Hostname: hostname}}
+	}
+	return ingress
+}
+
+func TestClassifyIngress(t *testing.T) {
+	tests := []struct {
+		name    string
+		ingress *networkingv1.Ingress
+		skipped string
+		url     string
+		isApp   bool
+	}{
+		{
+			name:    "https",
+			ingress: testIngress("media", "jellyfin", "media.example.com", "jellyfin-tls", nil),
+			url:     "https://media.example.com/",
+		},
+		{
+			name:    "http without a matching TLS host",
+			ingress: testIngress("media", "sonarr", "sonarr.example.com", "", nil),
+			url:     "http://sonarr.example.com/",
+		},
+		{
+			name:    "app",
+			ingress: testIngress("home", "hass", "hass.example.com", "hass-tls", map[string]string{AppAnnotation: "true"}),
+			url:     "https://hass.example.com/",
+			isApp:   true,
+		},
+		{
+			name:    "hidden",
+			ingress: testIngress("gohome", "gohome", "home.example.com", "", map[string]string{HideAnnotation: "true"}),
+			skipped: skipHidden,
+		},
+		{
+			name:    "no host",
+			ingress: testIngress("default", "catch-all", "", "", nil),
+			skipped: skipNoHost,
+		},
+		{
+			name:    "Tailscale",
+			ingress: testTailscaleIngress("ai", "open-webui", "ai.example-tailnet.ts.net"),
+			url:     "https://ai.example-tailnet.ts.net",
+		},
+		{
+			name:    "Tailscale without a hostname yet",
+			ingress: testTailscaleIngress("ai", "ollama", ""),
+			skipped: skipNoTailscaleHost,
+		},
+	}
+	k := NewK8sClientFor(fake.NewClientset())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, skipped := k.classifyIngress(tt.ingress)
+			if skipped != tt.skipped {
+				t.Fatalf("skipped %q, want %q", skipped, tt.skipped)
+			}
+			if skipped != "" {
+				return
+			}
+			if info.URL != tt.url {
+				t.Errorf("URL %q, want %q", info.URL, tt.url)
+			}
+			if info.IsApp != tt.isApp {
+				t.Errorf("IsApp %v, want %v", info.IsApp, tt.isApp)
+			}
+		})
+	}
+}
+
+func TestClassifyIngressNames(t *testing.T) {
+	k := NewK8sClientFor(fake.NewClientset())
+	for _, tt := range []struct {
+		ingress *networkingv1.Ingress
+		want    string
+	}{
+		{testIngress("media", "jellyfin-ingress", "media.example.com", "", nil), "jellyfin"},
+		{testIngress("media", "jellyfin", "media.example.com", "", map[string]string{NameAnnotation: "Movies"}), "Movies"},
+	} {
+		if info, _ := k.classifyIngress(tt.ingress); info.Name != tt.want {
+			t.Errorf("%s: name %q, want %q", tt.ingress.Name, info.Name, tt.want)
+		}
+	}
+}
+
+func TestGetVisibleIngresses(t *testing.T) {
+	t.Setenv("CACHE_TTL", "0")
+	clientset := fake.NewClientset(
+		testIngress("monitoring", "grafana", "grafana.example.com", "grafana-tls", nil),
+		testIngress("media", "jellyfin", "media.example.com", "jellyfin-tls", nil),
+		testIngress("home", "hass", "hass.example.com", "hass-tls", map[string]string{AppAnnotation: "true"}),
+		testIngress("news", "freshrss", "rss.example.com", "rss-tls", map[string]string{AppAnnotation: "true"}),
+		testIngress("gohome", "gohome", "home.example.com", "", map[string]string{HideAnnotation: "true"}),
+		testIngress("default", "catch-all", "", "", nil),
+	)
+	k := NewK8sClientFor(clientset)
+
+	apps, services, err := k.GetVisibleIngresses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tileNames(apps), []string{"freshrss", "hass"}; !slices.Equal(got, want) {
+		t.Errorf("apps %q, want %q", got, want)
+	}
+	if got, want := tileNames(services), []string{"grafana", "jellyfin"}; !slices.Equal(got, want) {
+		t.Errorf("services %q, want %q", got, want)
+	}
+	if status := k.SyncStatus(); status.Count != 6 || status.LastSuccess.IsZero() {
+		t.Errorf("sync status %+v, want 6 ingresses listed", status)
+	}
+
+	// When the API server fails, the last listing is served with the error.
+	clientset.PrependReactor("list", "ingresses", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	apps, services, err = k.GetVisibleIngresses(context.Background())
+	if err == nil {
+		t.Fatal("no error when listing fails")
+	}
+	if len(apps) != 2 || len(services) != 2 {
+		t.Errorf("got %d apps and %d services after a failure, want the last listing", len(apps), len(services))
+	}
+}
+
+func TestGetVisibleIngressesNamespaceScope(t *testing.T) {
+	t.Setenv("CACHE_TTL", "0")
+	k := NewK8sClientFor(fake.NewClientset(
+		testIngress("media", "jellyfin", "media.example.com", "", nil),
+		testIngress("monitoring", "grafana", "grafana.example.com", "", nil),
+	))
+	k.setIngressNamespace("media")
+	_, services, err := k.GetVisibleIngresses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tileNames(services); !slices.Equal(got, []string{"jellyfin"}) {
+		t.Errorf("services %q, want only the media namespace", got)
+	}
+}
+
+// tileNames returns the names of tiles, in order.
+func tileNames(tiles []IngressInfo) []string {
+	var names []string
+	for _, tile := range tiles {
+		names = append(names, tile.Name)
+	}
+	return names
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultKioskRefresh is how often a kiosk page reloads its tiles.
+	defaultKioskRefresh = time.Minute
+	// minKioskRefresh stops ?refresh= from hammering the cluster.
+	minKioskRefresh = 5 * time.Second
+)
+
+// HealthSummary counts tiles by health-check state for the kiosk status line,
+// and by certificate expiry for the status page.
+type HealthSummary struct {
+	Up, Down, Slow, Unknown int
+
+	CertWarning, CertCritical int
+}
+
+// add counts one tile's health.
+func (h *HealthSummary) add(health TargetHealth) {
+	switch {
+	case health.State == HealthDown:
+		h.Down++
+	case health.State == HealthUp && health.Slow:
+		h.Slow++
+	case health.State == HealthUp:
+		h.Up++
+	case health.State == HealthUnknown:
+		h.Unknown++
+	}
+}
+
+// addCert counts one tile's certificate if it is close to expiry.
+func (h *HealthSummary) addCert(cert CertStatus) {
+	switch cert.Level {
+	case CertWarning:
+		h.CertWarning++
+	case CertCritical:
+		h.CertCritical++
+	}
+}
+
+// summarizeHealth counts every tile on the page by health state and
+// certificate expiry.
+func summarizeHealth(apps, services []IngressInfo, bookmarks []Bookmark) HealthSummary {
+	var summary HealthSummary
+	for _, info := range apps {
+		summary.add(info.Health)
+		summary.addCert(info.Cert)
+	}
+	for _, info := range services {
+		summary.add(info.Health)
+		summary.addCert(info.Cert)
+	}
+	for _, b := range bookmarks {
+		summary.add(b.Health)
+		summary.addCert(b.Cert)
+	}
+	return summary
+}
+
+// kioskRefresh returns how often the kiosk page should refresh: ?refresh=
+// if given and valid, otherwise KIOSK_REFRESH, otherwise one minute.
+func kioskRefresh(r *http.Request) time.Duration {
+	refresh := durationFromEnv("KIOSK_REFRESH", defaultKioskRefresh)
+	if v := r.URL.Query().Get("refresh"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			refresh = d
+		}
+	}
+	return max(refresh, minKioskRefresh)
+}
+
+// requestedView is the view of the homepage an ordinary request asks for:
+// the kiosk with ?kiosk=1.
+func requestedView(r *http.Request) homeView {
+	if r.URL.Query().Get("kiosk") == "1" {
+		return viewKiosk
+	}
+	return viewNormal
+}
+
+// handleKiosk renders the homepage as a full-screen, self-refreshing
+// dashboard for wall-mounted displays. /?kiosk=1 is equivalent.
+func (s *Server) handleKiosk(w http.ResponseWriter, r *http.Request) {
+	s.renderHome(w, r, viewKiosk, "")
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// defaultLaunchTimeout is how long /launch waits for a tile to respond
+// before showing the interstitial.
+const defaultLaunchTimeout = 3 * time.Second
+
+// LaunchPage is the interstitial /launch shows when a tile doesn't respond.
+type LaunchPage struct {
+	Name     string
+	URL      string
+	Retry    string    // the /launch link to try again, which doesn't count as another click
+	Summary  string    // why the check failed, e.g. "down (HTTP 502), checked 14:03:10"
+	LastSeen time.Time // when a health check last found it up; zero if never
+}
+
+// LastSeenText formats LastSeen as a time of day, with the date unless it
+// was today.
+func (p *LaunchPage) LastSeenText() string {
+	if p.LastSeen.Format(time.DateOnly) == time.Now().Format(time.DateOnly) {
+		return p.LastSeen.Format("15:04")
+	}
+	return p.LastSeen.Format("2006-01-02 15:04")
+}
+
+// Launcher checks a tile right before it is opened with launch-check on.
+// It reuses the health checker's clients, or its own when health checks
+// are off.
+type Launcher struct {
+	checker *HealthChecker
+	timeout time.Duration
+}
+
+// NewLauncherFromEnv creates a launcher that waits LAUNCH_TIMEOUT for a
+// tile. health may be nil.
+func NewLauncherFromEnv(health *HealthChecker) *Launcher {
+	checker := health
+	if checker == nil {
+		checker = &HealthChecker{
+			client:   newHealthClient(false),
+			insecure: newHealthClient(true),
+			timeout:  defaultLaunchTimeout,
+			slow:     defaultLaunchTimeout,
+		}
+	}
+	return &Launcher{checker: checker, timeout: durationFromEnv("LAUNCH_TIMEOUT", defaultLaunchTimeout)}
+}
+
+// check probes rawURL the way health checks do, within the launch timeout.
+func (l *Launcher) check(ctx context.Context, rawURL string, policy HealthPolicy) TargetHealth {
+	policy.Timeout = min(cmp.Or(policy.Timeout, l.timeout), l.timeout)
+	return l.checker.probe(ctx, HealthTarget{URL: rawURL, Policy: policy})
+}
+
+// launchURL is the /launch link a tile uses while launch-check is on.
+func launchURL(target string) string {
+	return "/launch?" + url.Values{"target": {target}}.Encode()
+}
+
+// applyLaunchLinks points ingress tiles at /launch.
+func applyLaunchLinks(items []IngressInfo) {
+	for i := range items {
+		items[i].Href = launchURL(items[i].URL)
+	}
+}
+
+// applyBookmarkLaunchLinks is applyLaunchLinks for bookmarks.
+func applyBookmarkLaunchLinks(bookmarks []Bookmark) {
+	for i := range bookmarks {
+		bookmarks[i].Href = launchURL(bookmarks[i].URL)
+	}
+}
+
+// handleLaunch serves /launch?target=<tile URL>: it checks that the tile
+// responds and redirects to it, or else renders an interstitial saying
+// when it was last seen up, with a retry. Clicks are counted as on /click.
+// Like /click, only the URLs of current tiles are redirected to.
+func (s *Server) handleLaunch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: /launch could not load ingresses: %v", err)
+	}
+	apps, services, bookmarks := s.restrictTiles(r, s.resolveViewer(ctx, r), config, apps, services, config.Bookmarks)
+
+	target := r.URL.Query().Get("target")
+	var id, name string
+	var policy HealthPolicy
+	ingresses := append(slices.Clone(apps), services...)
+	if i := slices.IndexFunc(ingresses, func(info IngressInfo) bool { return info.URL == target }); i >= 0 {
+		id, name, policy = ingressTileID(ingresses[i]), ingresses[i].Name, ingresses[i].HealthCheck
+	} else if i := slices.IndexFunc(bookmarks, func(b Bookmark) bool { return b.URL == target }); i >= 0 {
+		id, name, policy = bookmarkTileID(bookmarks[i]), bookmarks[i].Name, bookmarks[i].HealthCheck
+	} else {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if trackClicks(config) && r.URL.Query().Get("retry") == "" {
+		s.recordClick(w, r, config, id)
+	}
+
+	health := s.launcher.check(ctx, target, policy)
+	if health.State == HealthUp {
+		http.Redirect(w, r, target, http.StatusFound)
+		return
+	}
+
+	page := &LaunchPage{Name: name, URL: target, Retry: launchURL(target) + "&retry=1", Summary: health.Summary()}
+	for _, sample := range slices.Backward(s.health.Status(target).History) {
+		if sample.Up {
+			page.LastSeen = sample.Time
+			break
+		}
+	}
+	prefs, _ := s.loadPreferences(r)
+	palette := resolvePalette(prefs, config)
+	data := PageData{
+		Config:     config,
+		DemoMode:   s.kube() == nil,
+		Theme:      resolveTheme(prefs, config),
+		Palette:    palette,
+		ThemeColor: themeColor(config, palette),
+		Locale:     localeFor(r, config),
+		Launch:     page,
+	}
+
+	data.Nonce = s.setCSP(w)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := s.templates[data.Locale.Lang].ExecuteTemplate(w, "launch.html", data); err != nil {
+		log.Printf("Error rendering launch template: %v", err)
+	}
+}
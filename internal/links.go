@@ -0,0 +1,52 @@
+package internal
+
+import "log"
+
+// Link targets for tiles. Tiles open in a new tab by default (the new-tab
+// setting); the gohome.stringer.sh/new-tab annotation or a bookmark's
+// new-tab option overrides that per item.
+const (
+	targetNewTab = "_blank"
+	targetSelf   = "_self"
+)
+
+// parseNewTab converts a per-item "true"/"false" new-tab value to a link
+// target, or "" (use the global setting) when it's unset or invalid.
+func parseNewTab(value, item string) string {
+	switch value {
+	case "":
+		return ""
+	case "true":
+		return targetNewTab
+	case "false":
+		return targetSelf
+	}
+	log.Printf("Warning: %s has invalid new-tab %q, want \"true\" or \"false\"", item, value)
+	return ""
+}
+
+// defaultTarget returns the link target for items without their own setting.
+func defaultTarget(newTab bool) string {
+	if newTab {
+		return targetNewTab
+	}
+	return targetSelf
+}
+
+// applyLinkTargets fills in Target for ingresses that don't set their own.
+func applyLinkTargets(items []IngressInfo, newTab bool) {
+	for i := range items {
+		if items[i].Target == "" {
+			items[i].Target = defaultTarget(newTab)
+		}
+	}
+}
+
+// applyBookmarkLinkTargets is applyLinkTargets for bookmarks.
+func applyBookmarkLinkTargets(bookmarks []Bookmark, newTab bool) {
+	for i := range bookmarks {
+		if bookmarks[i].Target == "" {
+			bookmarks[i].Target = defaultTarget(newTab)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// defaultThemeColor is --bg-primary from static/style.css, used when neither
+// theme-color nor the palette provides one.
+const defaultThemeColor = "#0a0a0b"
+
+// hexColor matches the #rgb, #rgba, #rrggbb and #rrggbbaa forms accepted for
+// theme-color.
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// parseThemeColor validates a theme-color value, returning "" (follow the
+// palette) for anything that isn't a hex colour.
+func parseThemeColor(value string) string {
+	if !hexColor.MatchString(value) {
+		log.Printf("Warning: theme-color %q is not a hex colour like #0a0a0b, ignoring", value)
+		return ""
+	}
+	return value
+}
+
+// themeColor returns the colour browsers and installed apps use for their
+// toolbar and splash screen: theme-color if configured, otherwise the dark
+// background of palette.
+func themeColor(config *Config, palette string) string {
+	if config.ThemeColor != "" {
+		return config.ThemeColor
+	}
+	if bg := palettes[palette].Dark["bg-primary"]; bg != "" {
+		return bg
+	}
+	return defaultThemeColor
+}
+
+// webManifest is the subset of the Web App Manifest that makes the homepage
+// installable to a phone's home screen.
+type webManifest struct {
+	Name            string         `json:"name"`
+	ShortName       string         `json:"short_name"`
+	StartURL        string         `json:"start_url"`
+	Scope           string         `json:"scope"`
+	Display         string         `json:"display"`
+	BackgroundColor string         `json:"background_color"`
+	ThemeColor      string         `json:"theme_color"`
+	Icons           []manifestIcon `json:"icons"`
+}
+
+type manifestIcon struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes"`
+	Type    string `json:"type"`
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// manifestIcons are the app icons in static/, rendered from favicon.svg.
+var manifestIcons = []manifestIcon{
+	{Src: "/static/favicon.svg", Sizes: "any", Type: "image/svg+xml"},
+	{Src: "/static/icon-192.png", Sizes: "192x192", Type: "image/png"},
+	{Src: "/static/icon-512.png", Sizes: "512x512", Type: "image/png"},
+	{Src: "/static/icon-maskable-512.png", Sizes: "512x512", Type: "image/png", Purpose: "maskable"},
+}
+
+// handleManifest serves /manifest.webmanifest, named after the configured
+// title and coloured like the configured palette.
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	config, err := s.bookmarkManager.GetConfig(r.Context())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	color := themeColor(config, resolvePalette(Preferences{}, config))
+	manifest := webManifest{
+		Name:            config.Title,
+		ShortName:       config.Title,
+		StartURL:        "/",
+		Scope:           "/",
+		Display:         "standalone",
+		BackgroundColor: color,
+		ThemeColor:      color,
+		Icons:           manifestIcons,
+	}
+
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		log.Printf("Error encoding manifest: %v", err)
+	}
+}
@@ -0,0 +1,443 @@
+package internal
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// markdownSchemes are the URL schemes a Markdown link may use. Anything
+// else, such as javascript:, leaves the link text without a link.
+var markdownSchemes = map[string]bool{"http": true, "https": true, "mailto": true, "tel": true}
+
+// renderMarkdown converts Markdown from the ConfigMap to HTML for the
+// page. It knows the parts that notes and runbooks need: headings,
+// paragraphs, bullet, numbered and task lists, block quotes, fenced code,
+// rules, and inline emphasis, strikethrough, code and links, with bare
+// http(s) URLs linked too. Line breaks are kept. Everything else is text:
+// raw HTML is escaped rather than passed through and links only keep
+// markdownSchemes and relative URLs, so whoever edits the ConfigMap can't
+// put markup or scripts on the page.
+func renderMarkdown(src string) template.HTML {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\t", "    ")
+	var b strings.Builder
+	writeMarkdownBlocks(&b, strings.Split(src, "\n"))
+	return template.HTML(b.String())
+}
+
+// markdownItem is a list item: its first line and the indented lines
+// under it, which may hold a nested list.
+type markdownItem struct {
+	text string
+	rest []string
+}
+
+// writeMarkdownBlocks writes the blocks in lines to b.
+func writeMarkdownBlocks(b *strings.Builder, lines []string) {
+	var (
+		para    []string
+		items   []markdownItem
+		listTag string // "ul" or "ol" while items are being collected
+		indent  int    // where the text of the current item starts
+	)
+	flushPara := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + markdownInline(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+	flushList := func() {
+		if listTag == "" {
+			return
+		}
+		b.WriteString("<" + listTag + ">\n")
+		for _, item := range items {
+			writeMarkdownItem(b, item)
+		}
+		b.WriteString("</" + listTag + ">\n")
+		items, listTag = nil, ""
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+
+		// Lines indented under a list item belong to it, blank ones too
+		// as long as more indented lines follow.
+		if listTag != "" && spaces >= indent && indent > 0 && (trimmed != "" || continuesItem(lines[i+1:], indent)) {
+			last := &items[len(items)-1]
+			last.rest = append(last.rest, line[min(indent, len(line)):])
+			continue
+		}
+
+		if tag, text, width, ok := markdownListItem(line); ok {
+			flushPara()
+			if tag != listTag {
+				flushList()
+				listTag = tag
+			}
+			items = append(items, markdownItem{text: text})
+			indent = width
+			continue
+		}
+		if trimmed != "" && listTag != "" && !markdownBlockStart(trimmed) {
+			// A lazy continuation of the item's text.
+			last := &items[len(items)-1]
+			if len(last.rest) == 0 {
+				last.text += "\n" + trimmed
+				continue
+			}
+		}
+
+		switch {
+		case trimmed == "":
+			flushPara()
+			if !nextItemIs(lines[i+1:], listTag) {
+				flushList()
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flushPara()
+			flushList()
+			fence := trimmed[:3]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+		case markdownRule(trimmed):
+			flushPara()
+			flushList()
+			b.WriteString("<hr>\n")
+		case markdownHeading(trimmed) > 0:
+			flushPara()
+			flushList()
+			level := markdownHeading(trimmed)
+			text := strings.TrimRight(strings.TrimSpace(trimmed[level:]), "#")
+			tag := "h" + strconv.Itoa(level)
+			b.WriteString("<" + tag + ">" + markdownInline(strings.TrimSpace(text)) + "</" + tag + ">\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flushPara()
+			flushList()
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				text := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(text, " "))
+			}
+			i--
+			b.WriteString("<blockquote>\n")
+			writeMarkdownBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		default:
+			flushList()
+			para = append(para, trimmed)
+		}
+	}
+	flushPara()
+	flushList()
+}
+
+// continuesItem reports whether the next non-blank line of lines is
+// indented by at least indent, so a blank line before it stays in the
+// list item.
+func continuesItem(lines []string, indent int) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		return len(line)-len(strings.TrimLeft(line, " ")) >= indent
+	}
+	return false
+}
+
+// nextItemIs reports whether the next non-blank line of lines is an item
+// of a tag list, so a blank line between items doesn't split the list.
+func nextItemIs(lines []string, tag string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		next, _, _, ok := markdownListItem(line)
+		return ok && next == tag
+	}
+	return false
+}
+
+// writeMarkdownItem writes a list item, with a checkbox for a task item
+// ("[ ]" or "[x]").
+func writeMarkdownItem(b *strings.Builder, item markdownItem) {
+	text := item.text
+	if len(text) >= 3 && text[0] == '[' && text[2] == ']' && strings.ContainsRune(" xX", rune(text[1])) && (len(text) == 3 || text[3] == ' ') {
+		checked := ""
+		if text[1] != ' ' {
+			checked = " checked"
+		}
+		b.WriteString(`<li class="task"><input type="checkbox" disabled` + checked + `> `)
+		text = strings.TrimSpace(text[3:])
+	} else {
+		b.WriteString("<li>")
+	}
+	b.WriteString(markdownInline(text))
+	if len(item.rest) > 0 {
+		b.WriteString("\n")
+		writeMarkdownBlocks(b, item.rest)
+	}
+	b.WriteString("</li>\n")
+}
+
+// markdownListItem parses a list item line, returning "ul" or "ol", the
+// item's text and the column that text starts at.
+func markdownListItem(line string) (tag, text string, width int, ok bool) {
+	spaces := len(line) - len(strings.TrimLeft(line, " "))
+	rest := line[spaces:]
+	if spaces > 3 || rest == "" {
+		return "", "", 0, false
+	}
+	marker := 0
+	switch {
+	case rest[0] == '-' || rest[0] == '*' || rest[0] == '+':
+		tag, marker = "ul", 1
+	default:
+		for marker < len(rest) && marker < 9 && rest[marker] >= '0' && rest[marker] <= '9' {
+			marker++
+		}
+		if marker == 0 || marker >= len(rest) || (rest[marker] != '.' && rest[marker] != ')') {
+			return "", "", 0, false
+		}
+		tag, marker = "ol", marker+1
+	}
+	after := rest[marker:]
+	if after != "" && after[0] != ' ' {
+		return "", "", 0, false
+	}
+	text = strings.TrimLeft(after, " ")
+	if tag == "ul" && markdownRule(strings.TrimSpace(rest)) {
+		return "", "", 0, false
+	}
+	return tag, strings.TrimSpace(text), spaces + marker + 1, true
+}
+
+// markdownBlockStart reports whether a trimmed line starts a block of its
+// own rather than continuing a paragraph or list item.
+func markdownBlockStart(trimmed string) bool {
+	if _, _, _, ok := markdownListItem(trimmed); ok {
+		return true
+	}
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") ||
+		strings.HasPrefix(trimmed, ">") || markdownRule(trimmed) || markdownHeading(trimmed) > 0
+}
+
+// markdownHeading returns the level of an ATX heading such as "## Wifi",
+// or 0 if trimmed isn't one.
+func markdownHeading(trimmed string) int {
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(trimmed) && trimmed[level] != ' ') {
+		return 0
+	}
+	return level
+}
+
+// markdownRule reports whether a trimmed line is a thematic break: three
+// or more of the same -, * or _, optionally spaced.
+func markdownRule(trimmed string) bool {
+	compact := strings.ReplaceAll(trimmed, " ", "")
+	if len(compact) < 3 || !strings.ContainsRune("-*_", rune(compact[0])) {
+		return false
+	}
+	return strings.Count(compact, compact[:1]) == len(compact)
+}
+
+// markdownInline converts the inline Markdown of a paragraph, heading or
+// list item, escaping all text.
+func markdownInline(text string) string {
+	return markdownSpan(text, true)
+}
+
+// markdownSpan is markdownInline, without links in the text of a link.
+func markdownSpan(text string, links bool) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.ContainsRune("\\`*_{}[]()#+-.!~>|", rune(text[i+1])):
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '\n':
+			b.WriteString("<br>\n")
+			i++
+			continue
+		case c == '`':
+			run := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			fence := text[i : i+run]
+			if end := strings.Index(text[i+run:], fence); end >= 0 {
+				code := strings.TrimSpace(text[i+run : i+run+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += run + end + run
+				continue
+			}
+			b.WriteString(fence)
+			i += run
+			continue
+		case c == '[' && links:
+			if label, target, n, ok := markdownLink(text[i:]); ok {
+				if href, ok := markdownHref(target); ok {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `">` + markdownSpan(label, false) + "</a>")
+				} else {
+					b.WriteString(markdownSpan(label, false))
+				}
+				i += n
+				continue
+			}
+		case c == '<' && links:
+			if end := strings.IndexByte(text[i:], '>'); end > 0 {
+				if href, ok := markdownHref(text[i+1 : i+end]); ok && strings.Contains(href, ":") {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(href) + "</a>")
+					i += end + 1
+					continue
+				}
+			}
+		case c == 'h' && links && (strings.HasPrefix(text[i:], "https://") || strings.HasPrefix(text[i:], "http://")) && wordStart(text, i):
+			end := i + strings.IndexFunc(text[i:]+" ", func(r rune) bool { return unicode.IsSpace(r) || r == '<' })
+			link := strings.TrimRight(text[i:end], ".,;:!?)'\"")
+			if href, ok := markdownHref(link); ok {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(link) + "</a>")
+				i += len(link)
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if tag, inner, n, ok := markdownEmphasis(text, i); ok {
+				b.WriteString("<" + tag + ">" + markdownSpan(inner, links) + "</" + tag + ">")
+				i += n
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		b.WriteString(html.EscapeString(text[i : i+size]))
+		i += size
+	}
+	return b.String()
+}
+
+// markdownLink parses "[label](target)" at the start of text, returning
+// how many bytes it takes.
+func markdownLink(text string) (label, target string, n int, ok bool) {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(text) || text[i+1] != '(' {
+				return "", "", 0, false
+			}
+			end := closingParen(text[i+2:])
+			if end < 0 {
+				return "", "", 0, false
+			}
+			target, _, _ = strings.Cut(strings.TrimSpace(text[i+2:i+2+end]), " ")
+			return text[1:i], target, i + 2 + end + 1, true
+		}
+	}
+	return "", "", 0, false
+}
+
+// closingParen returns the index of the ")" that closes a link target,
+// skipping balanced pairs like those of Wikipedia URLs, or -1.
+func closingParen(text string) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// markdownHref returns target if it is safe to link to: a URL with one of
+// markdownSchemes, or a relative one.
+func markdownHref(target string) (string, bool) {
+	target = strings.TrimSpace(target)
+	if target == "" || strings.ContainsFunc(target, unicode.IsControl) {
+		return "", false
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !markdownSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return target, true
+}
+
+// markdownEmphasis parses emphasis opening at text[i]: "**strong**" or
+// "__strong__", "*em*" or "_em_", and "~~strikethrough~~". Underscores
+// only count at word boundaries, so snake_case stays as it is.
+func markdownEmphasis(text string, i int) (tag, inner string, n int, ok bool) {
+	c := text[i]
+	var delim string
+	double := i+1 < len(text) && text[i+1] == c
+	switch {
+	case c == '~' && double:
+		tag, delim = "del", "~~"
+	case c == '~':
+		return "", "", 0, false
+	case double:
+		tag, delim = "strong", text[i:i+2]
+	default:
+		tag, delim = "em", text[i:i+1]
+	}
+	if c == '_' && !wordStart(text, i) {
+		return "", "", 0, false
+	}
+	start := i + len(delim)
+	if start >= len(text) || text[start] == ' ' || text[start] == '\n' {
+		return "", "", 0, false
+	}
+	for j := start + 1; j+len(delim) <= len(text); j++ {
+		// A single * or _ must not be part of a nested or closing **.
+		if len(delim) == 1 && text[j] == c && j+1 < len(text) && text[j+1] == c {
+			for j+1 < len(text) && text[j+1] == c {
+				j++
+			}
+			continue
+		}
+		if text[j:j+len(delim)] != delim || text[j-1] == ' ' {
+			continue
+		}
+		end := j + len(delim)
+		if c == '_' && end < len(text) && isWordByte(text[end]) {
+			continue
+		}
+		return tag, text[start:j], end - i, true
+	}
+	return "", "", 0, false
+}
+
+// wordStart reports whether text[i] starts a word.
+func wordStart(text string, i int) bool {
+	return i == 0 || !isWordByte(text[i-1])
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownLinks(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"https", "[Wiki](https://wiki.example.com)", `<p><a href="https://wiki.example.com">Wiki</a></p>`},
+		{"mailto", "[Mail](mailto:admin@example.com)", `<p><a href="mailto:admin@example.com">Mail</a></p>`},
+		{"tel", "[Call](tel:+15555550100)", `<p><a href="tel:+15555550100">Call</a></p>`},
+		{"relative", "[Status](/status)", `<p><a href="/status">Status</a></p>`},
+		{"balanced parens", "[Go](https://en.wikipedia.org/wiki/Go_(programming_language))", `<p><a href="https://en.wikipedia.org/wiki/Go_(programming_language)">Go</a></p>`},
+		{"title dropped", `[Wiki](https://wiki.example.com "The wiki")`, `<p><a href="https://wiki.example.com">Wiki</a></p>`},
+		{"autolink", "<https://example.com/a?b=1&c=2>", `<p><a href="https://example.com/a?b=1&amp;c=2">https://example.com/a?b=1&amp;c=2</a></p>`},
+		{"bare URL", "See https://example.com/docs.", `<p>See <a href="https://example.com/docs">https://example.com/docs</a>.</p>`},
+		{"emphasis in label", "[**Wiki**](https://wiki.example.com)", `<p><a href="https://wiki.example.com"><strong>Wiki</strong></a></p>`},
+		{"no link in label", "[see https://a.example.com](https://b.example.com)", `<p><a href="https://b.example.com">see https://a.example.com</a></p>`},
+
+		{"javascript", "[x](javascript:alert(1))", `<p>x</p>`},
+		{"mixed case javascript", "[x](JaVaScRiPt:alert(1))", `<p>x</p>`},
+		{"javascript after spaces", "[x](  javascript:alert(1))", `<p>x</p>`},
+		{"javascript autolink", "<javascript:alert(1)>", `<p>&lt;javascript:alert(1)&gt;</p>`},
+		{"data", "[x](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)", `<p>x</p>`},
+		{"mixed case data", "[x](DaTa:text/html,<script>alert(1)</script>)", `<p>x</p>`},
+		{"vbscript", "[x](vbscript:msgbox)", `<p>x</p>`},
+		{"control character", "[x](java\x01script:alert(1))", `<p>x</p>`},
+		// An entity in a target is not decoded, so it can't spell a scheme:
+		// the browser reads it back as the relative URL it was written as.
+		{"entity-encoded colon", "[x](javascript&#58;alert(1))", `<p><a href="javascript&amp;#58;alert(1)">x</a></p>`},
+		{"entity-encoded letter", "[x](&#106;avascript:alert(1))", `<p><a href="&amp;#106;avascript:alert(1)">x</a></p>`},
+		{"quote in target", `[x](https://example.com/"onmouseover="alert(1))`, `<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1)">x</a></p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.TrimSpace(string(renderMarkdown(tt.src))); got != tt.want {
+				t.Errorf("renderMarkdown(%q)\n got %s\nwant %s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownEscapesHTML(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"script", "<script>alert(1)</script>", `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`},
+		{"attribute", `<img src=x onerror="alert(1)">`, `<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>`},
+		{"entity", "AT&amp;T &lt;3", `<p>AT&amp;amp;T &amp;lt;3</p>`},
+		{"heading", "# <b>Hi</b>", `<h1>&lt;b&gt;Hi&lt;/b&gt;</h1>`},
+		{"code span", "`<b>` & co", `<p><code>&lt;b&gt;</code> &amp; co</p>`},
+		{"fenced code", "```\n<script>\n```", "<pre><code>&lt;script&gt;</code></pre>"},
+		{"link label", "[<i>x</i>](https://example.com)", `<p><a href="https://example.com">&lt;i&gt;x&lt;/i&gt;</a></p>`},
+		{"escaped markup", `\*not em\*`, `<p>*not em*</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.TrimSpace(string(renderMarkdown(tt.src))); got != tt.want {
+				t.Errorf("renderMarkdown(%q)\n got %s\nwant %s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownEmphasis(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"*em* and _em_", `<p><em>em</em> and <em>em</em></p>`},
+		{"**strong** and __strong__", `<p><strong>strong</strong> and <strong>strong</strong></p>`},
+		{"~~gone~~", `<p><del>gone</del></p>`},
+		{"**bold *and em* inside**", `<p><strong>bold <em>and em</em> inside</strong></p>`},
+		{"*em **and bold** inside*", `<p><em>em <strong>and bold</strong> inside</em></p>`},
+		{"~~**struck bold**~~", `<p><del><strong>struck bold</strong></del></p>`},
+		{"**`code` in bold**", `<p><strong><code>code</code> in bold</strong></p>`},
+		{"snake_case_name", `<p>snake_case_name</p>`},
+		{"2 * 3 * 4", `<p>2 * 3 * 4</p>`},
+		{"**unclosed", `<p>**unclosed</p>`},
+		{"~single~", `<p>~single~</p>`},
+	}
+	for _, tt := range tests {
+		if got := strings.TrimSpace(string(renderMarkdown(tt.src))); got != tt.want {
+			t.Errorf("renderMarkdown(%q)\n got %s\nwant %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownBlocks(t *testing.T) {
+	src := "## Wi-Fi\n\n- [x] Renew the domain\n- [ ] Replace disks\n  1. Order\n  2. Swap\n\n> Quoted\n\n---\nline one\nline two"
+	want := `<h2>Wi-Fi</h2>
+<ul>
+<li class="task"><input type="checkbox" disabled checked> Renew the domain</li>
+<li class="task"><input type="checkbox" disabled> Replace disks
+<ol>
+<li>Order</li>
+<li>Swap</li>
+</ol>
+</li>
+</ul>
+<blockquote>
+<p>Quoted</p>
+</blockquote>
+<hr>
+<p>line one<br>
+line two</p>
+`
+	if got := string(renderMarkdown(src)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// markdownTag matches the start of every tag renderMarkdown writes.
+var markdownTag = regexp.MustCompile(`^</?(p|br|h[1-6]|ul|ol|li|blockquote|pre|code|hr|strong|em|del)>|^<li class="task">|^<input type="checkbox" disabled( checked)?>|^<a href="([^"<>]*)">|^</a>`)
+
+// FuzzRenderMarkdown checks that whatever the ConfigMap holds, the HTML
+// only has the tags renderMarkdown writes and links with safe schemes.
+func FuzzRenderMarkdown(f *testing.F) {
+	for _, seed := range []string{
+		"# Title\n\n- [x] done\n- item\n  1. nested\n\n> quote\n\n```\ncode\n```\n---",
+		"**bold *em*** ~~del~~ `code` [link](https://example.com) <https://example.com> https://example.com",
+		"[x](javascript:alert(1)) [x](JaVaScRiPt:alert(1)) [x](data:text/html,x) [x](javascript&#58;x)",
+		"<script>alert(1)</script> <img src=x onerror=alert(1)>",
+		"[a](<javascript:x>) [[nested](https://a)](https://b) [x](https://a.example.com/\"onclick=\"x)",
+		"\\*escaped\\* snake_case_word 2*3*4 ~~~\nfence\n~~~",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		out := string(renderMarkdown(src))
+		for i := strings.IndexByte(out, '<'); i >= 0; i = strings.IndexByte(out, '<') {
+			out = out[i:]
+			m := markdownTag.FindStringSubmatch(out)
+			if m == nil {
+				t.Fatalf("renderMarkdown(%q) wrote markup of its own: %.40q", src, out)
+			}
+			if strings.HasPrefix(m[0], "<a ") {
+				href := html.UnescapeString(m[3])
+				if u, err := url.Parse(href); err != nil || (u.Scheme != "" && !markdownSchemes[strings.ToLower(u.Scheme)]) {
+					t.Fatalf("renderMarkdown(%q) links to %q", src, href)
+				}
+			}
+			out = out[len(m[0]):]
+		}
+	})
+}
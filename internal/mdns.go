@@ -0,0 +1,335 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// MDNSCategory is the bookmark category LAN services are listed under.
+	MDNSCategory = "Local network"
+	// defaultMDNSInterval is how often the LAN is browsed.
+	defaultMDNSInterval = 5 * time.Minute
+	// mdnsWindow is how long each round of a browse waits for answers.
+	mdnsWindow = 2 * time.Second
+	// mdnsRounds bounds a browse: one for the services, then follow-up
+	// queries for the records the answers left out.
+	mdnsRounds = 3
+	// mdnsForgetAfter is how many intervals a service that stopped
+	// answering is still listed for, so devices that doze aren't dropped
+	// straight away.
+	mdnsForgetAfter = 3
+)
+
+// mdnsGroup is the IPv4 mDNS multicast group.
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// MDNSService is a web interface a device on the LAN advertises with
+// DNS-SD, e.g. a NAS or an ESPHome device.
+type MDNSService struct {
+	Instance string // e.g. "Synology DS920+"
+	Host     string // e.g. "nas.local"
+	Port     int
+	Path     string // from the TXT record's path=, "/" if it has none
+	HTTPS    bool   // advertised as _https._tcp
+}
+
+// URL returns the address of the service's web interface. It uses the
+// .local host name, which the visitor's device resolves on the same LAN.
+func (svc MDNSService) URL() string {
+	scheme, port := "http", 80
+	if svc.HTTPS {
+		scheme, port = "https", 443
+	}
+	host := strings.TrimSuffix(svc.Host, ".")
+	if svc.Port != port {
+		host = net.JoinHostPort(host, strconv.Itoa(svc.Port))
+	}
+	if !strings.HasPrefix(svc.Path, "/") {
+		return scheme + "://" + host + "/" + svc.Path
+	}
+	return scheme + "://" + host + svc.Path
+}
+
+// mdnsEntry is a service as last seen by a browse.
+type mdnsEntry struct {
+	service MDNSService
+	seen    time.Time
+}
+
+// MDNSBrowser periodically browses the LAN with multicast DNS for the
+// MDNS_SERVICES service types and lists what answers as bookmarks in the
+// "Local network" category. It needs a network where multicast reaches
+// GoHome, such as hostNetwork on the node.
+type MDNSBrowser struct {
+	interval time.Duration
+	types    []string // e.g. "_http._tcp"
+
+	mu       sync.Mutex
+	services map[string]mdnsEntry // by instance name
+}
+
+// NewMDNSBrowserFromEnv creates a browser running every MDNS_INTERVAL when
+// MDNS=true, or returns nil.
+func NewMDNSBrowserFromEnv() *MDNSBrowser {
+	if os.Getenv("MDNS") != "true" {
+		return nil
+	}
+	b := &MDNSBrowser{
+		interval: durationFromEnv("MDNS_INTERVAL", defaultMDNSInterval),
+		types:    []string{"_http._tcp"},
+		services: make(map[string]mdnsEntry),
+	}
+	if v := splitList(os.Getenv("MDNS_SERVICES")); len(v) > 0 {
+		b.types = v
+	}
+	log.Printf("Browsing the local network for %s services", strings.Join(b.types, ", "))
+	return b
+}
+
+// Job browses the LAN right away and then every interval.
+func (b *MDNSBrowser) Job() Job {
+	if b == nil {
+		return Job{}
+	}
+	return Job{Name: "mdns", Interval: b.interval, Run: b.refresh}
+}
+
+// refresh browses the LAN, remembers what answered and forgets services
+// that haven't for mdnsForgetAfter intervals.
+func (b *MDNSBrowser) refresh(ctx context.Context) error {
+	found, err := b.browse(ctx)
+	if err != nil {
+		log.Printf("Warning: Could not browse the local network: %v", err)
+		return err
+	}
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, svc := range found {
+		b.services[svc.Instance] = mdnsEntry{service: svc, seen: now}
+	}
+	for name, entry := range b.services {
+		if now.Sub(entry.seen) > mdnsForgetAfter*b.interval {
+			delete(b.services, name)
+		}
+	}
+	return nil
+}
+
+// Bookmarks returns a bookmark for each service on the LAN, sorted by
+// name, leaving out those whose instance or host name matches one of the
+// exclude patterns (see path.Match, without regard to case) and those
+// named like one of local.
+func (b *MDNSBrowser) Bookmarks(exclude []string, local []Bookmark) []Bookmark {
+	if b == nil {
+		return nil
+	}
+	excluded := func(name string) bool {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		return slices.ContainsFunc(exclude, func(pattern string) bool {
+			ok, _ := path.Match(strings.ToLower(pattern), name)
+			return ok
+		})
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var bookmarks []Bookmark
+	for _, entry := range b.services {
+		svc := entry.service
+		if excluded(svc.Instance) || excluded(svc.Host) {
+			continue
+		}
+		if slices.ContainsFunc(local, func(l Bookmark) bool { return strings.EqualFold(l.Name, svc.Instance) }) {
+			continue
+		}
+		bookmarks = append(bookmarks, Bookmark{
+			Name:     svc.Instance,
+			URL:      svc.URL(),
+			Category: MDNSCategory,
+			Tags:     []string{"mdns", strings.TrimSuffix(svc.Host, ".local.")},
+		})
+	}
+	slices.SortFunc(bookmarks, func(x, y Bookmark) int { return strings.Compare(x.Name, y.Name) })
+	return bookmarks
+}
+
+// mdnsAnswers collects the records of a browse by name.
+type mdnsAnswers struct {
+	instances map[string]string // instance name to its service type
+	srv       map[string]dnsmessage.SRVResource
+	txt       map[string][]string
+}
+
+// browse asks for every service type and then, for up to mdnsRounds, for
+// the SRV and TXT records the answers so far left out. Queries come
+// from an ephemeral port, so responders answer by unicast (RFC 6762
+// section 6.7) and GoHome doesn't need port 5353.
+func (b *MDNSBrowser) browse(ctx context.Context) ([]MDNSService, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	answers := mdnsAnswers{
+		instances: make(map[string]string),
+		srv:       make(map[string]dnsmessage.SRVResource),
+		txt:       make(map[string][]string),
+	}
+	for round := range mdnsRounds {
+		var questions []dnsmessage.Question
+		if round == 0 {
+			for _, t := range b.types {
+				questions = append(questions, mdnsQuestion(t+".local.", dnsmessage.TypePTR))
+			}
+		} else {
+			questions = answers.missing()
+		}
+		if len(questions) == 0 {
+			break
+		}
+		if err := mdnsQuery(conn, questions); err != nil {
+			return nil, err
+		}
+		if err := answers.collect(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+	return answers.services(), nil
+}
+
+// mdnsQuestion asks for a record by name without the unicast-response bit,
+// which legacy unicast queries don't need.
+func mdnsQuestion(name string, t dnsmessage.Type) dnsmessage.Question {
+	return dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: t, Class: dnsmessage.ClassINET}
+}
+
+// mdnsQuery sends one query with questions to the multicast group.
+func mdnsQuery(conn *net.UDPConn, questions []dnsmessage.Question) error {
+	msg := dnsmessage.Message{Questions: questions}
+	packet, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = conn.WriteToUDP(packet, mdnsGroup)
+	return err
+}
+
+// collect reads answers for mdnsWindow, or until ctx is done.
+func (a *mdnsAnswers) collect(ctx context.Context, conn *net.UDPConn) error {
+	deadline := time.Now().Add(mdnsWindow)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return ctx.Err()
+			}
+			return err
+		}
+		var msg dnsmessage.Message
+		if msg.Unpack(buf[:n]) != nil || !msg.Response {
+			continue
+		}
+		for _, r := range slices.Concat(msg.Answers, msg.Authorities, msg.Additionals) {
+			a.add(r)
+		}
+	}
+}
+
+// add records one resource record.
+func (a *mdnsAnswers) add(r dnsmessage.Resource) {
+	name := r.Header.Name.String()
+	switch body := r.Body.(type) {
+	case *dnsmessage.PTRResource:
+		if t, ok := strings.CutSuffix(name, ".local."); ok {
+			a.instances[body.PTR.String()] = t
+		}
+	case *dnsmessage.SRVResource:
+		a.srv[name] = *body
+	case *dnsmessage.TXTResource:
+		a.txt[name] = body.TXT
+	}
+}
+
+// missing returns questions for the records still needed to build a URL
+// for every instance found: its SRV and TXT records.
+func (a *mdnsAnswers) missing() []dnsmessage.Question {
+	var questions []dnsmessage.Question
+	for instance := range a.instances {
+		if _, ok := a.srv[instance]; !ok {
+			questions = append(questions, mdnsQuestion(instance, dnsmessage.TypeSRV))
+		}
+		if _, ok := a.txt[instance]; !ok {
+			questions = append(questions, mdnsQuestion(instance, dnsmessage.TypeTXT))
+		}
+	}
+	return questions
+}
+
+// services returns the instances that have an SRV record.
+func (a *mdnsAnswers) services() []MDNSService {
+	var services []MDNSService
+	for instance, t := range a.instances {
+		srv, ok := a.srv[instance]
+		if !ok {
+			continue
+		}
+		svc := MDNSService{
+			Instance: mdnsInstanceName(strings.TrimSuffix(instance, "."+t+".local.")),
+			Host:     srv.Target.String(),
+			Port:     int(srv.Port),
+			Path:     "/",
+			HTTPS:    t == "_https._tcp",
+		}
+		for _, kv := range a.txt[instance] {
+			if p, ok := strings.CutPrefix(kv, "path="); ok && p != "" {
+				svc.Path = p
+			}
+		}
+		services = append(services, svc)
+	}
+	return services
+}
+
+// mdnsInstanceName undoes the escaping of an instance label, where spaces
+// and other bytes come back as \DDD and dots as "\.".
+func mdnsInstanceName(label string) string {
+	var b strings.Builder
+	for i := 0; i < len(label); i++ {
+		if label[i] != '\\' || i+1 >= len(label) {
+			b.WriteByte(label[i])
+			continue
+		}
+		if i+3 < len(label) {
+			if n, err := strconv.Atoi(label[i+1 : i+4]); err == nil && n < 256 {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(label[i+1])
+		i++
+	}
+	return b.String()
+}
@@ -0,0 +1,370 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mediaKeyPrefix marks ConfigMap keys that define a media service:
+	//   media-<name>: "http://sonarr.media:8989|type=sonarr|label=TV"
+	mediaKeyPrefix = "media-"
+	// defaultMediaInterval is how often every media service is polled.
+	defaultMediaInterval = time.Minute
+	// maxMediaBytes caps the size of an API response.
+	maxMediaBytes = 4 << 20
+)
+
+// Media service types, as given with type=.
+const (
+	mediaSonarr      = "sonarr"
+	mediaRadarr      = "radarr"
+	mediaLidarr      = "lidarr"
+	mediaQBittorrent = "qbittorrent"
+)
+
+// arrAPIVersions are the API paths of the *arr applications.
+var arrAPIVersions = map[string]string{
+	mediaSonarr: "/api/v3",
+	mediaRadarr: "/api/v3",
+	mediaLidarr: "/api/v1",
+}
+
+// MediaServiceConfig is one media service from the ConfigMap. Its API key,
+// or qBittorrent username and password, come from MEDIA_<NAME>_API_KEY,
+// MEDIA_<NAME>_USERNAME and MEDIA_<NAME>_PASSWORD.
+type MediaServiceConfig struct {
+	Name  string
+	Label string
+	URL   string
+	Type  string // one of sonarr, radarr, lidarr, qbittorrent
+}
+
+// key identifies the cached counts of c.
+func (c MediaServiceConfig) key() string {
+	return c.Type + "\x00" + c.URL
+}
+
+// MediaCount is one number shown for a media service. Label is a message
+// ID so the page can translate it.
+type MediaCount struct {
+	Label string // e.g. "media.queue"
+	Value string
+}
+
+// MediaPanel is a media service as rendered on the homepage.
+type MediaPanel struct {
+	Name    string
+	URL     string
+	Counts  []MediaCount // empty until a poll succeeds
+	Err     string       // set when the most recent poll failed
+	Fetched time.Time
+}
+
+// mediaState is what the fetcher remembers about one media service.
+type mediaState struct {
+	counts    []MediaCount
+	err       error
+	fetched   time.Time
+	attempted time.Time
+}
+
+// MediaFetcher polls the queue and download counts of the configured
+// Sonarr, Radarr, Lidarr and qBittorrent instances in the background and
+// caches them, so the homepage never waits on them.
+type MediaFetcher struct {
+	client   *http.Client
+	interval time.Duration
+	mu       sync.Mutex
+	services map[string]mediaState // keyed by MediaServiceConfig.key
+}
+
+// NewMediaFetcherFromEnv creates a fetcher that polls every MEDIA_INTERVAL.
+func NewMediaFetcherFromEnv() *MediaFetcher {
+	return &MediaFetcher{
+		client:   &http.Client{Timeout: 15 * time.Second},
+		interval: durationFromEnv("MEDIA_INTERVAL", defaultMediaInterval),
+		services: make(map[string]mediaState),
+	}
+}
+
+// mediaEnv returns the MEDIA_<NAME>_<suffix> variable for a media service,
+// with the name upper-cased and dashes turned into underscores.
+func mediaEnv(name, suffix string) string {
+	key := "MEDIA_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + suffix
+	return os.Getenv(key)
+}
+
+// parseMediaServices reads every media-* key from ConfigMap data, sorted by
+// name.
+func parseMediaServices(data map[string]string) []MediaServiceConfig {
+	var services []MediaServiceConfig
+	for key, value := range data {
+		if !strings.HasPrefix(key, mediaKeyPrefix) {
+			continue
+		}
+		if service, ok := parseMediaEntry(key, value); ok {
+			services = append(services, service)
+		}
+	}
+	slices.SortFunc(services, func(a, b MediaServiceConfig) int { return strings.Compare(a.Name, b.Name) })
+	return services
+}
+
+// parseMediaEntry parses a media-<name> ConfigMap value: the URL followed
+// by |key=value settings, of which type is required.
+func parseMediaEntry(key, value string) (MediaServiceConfig, bool) {
+	parts := strings.Split(value, "|")
+	service := MediaServiceConfig{
+		Name: strings.TrimPrefix(key, mediaKeyPrefix),
+		URL:  strings.TrimSuffix(strings.TrimSpace(parts[0]), "/"),
+	}
+	service.Label = service.Name
+	if u, err := url.Parse(service.URL); err != nil || u.Host == "" {
+		log.Printf("Warning: media %s has invalid URL %q, skipping", service.Name, service.URL)
+		return service, false
+	}
+	for _, opt := range parts[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		v = strings.TrimSpace(v)
+		switch k {
+		case "type":
+			service.Type = strings.ToLower(v)
+		case "label":
+			service.Label = v
+		default:
+			log.Printf("Warning: media %s has unknown option %q", service.Name, opt)
+		}
+	}
+	if _, arr := arrAPIVersions[service.Type]; !arr && service.Type != mediaQBittorrent {
+		log.Printf("Warning: media %s has unsupported type %q, want sonarr, radarr, lidarr or qbittorrent", service.Name, service.Type)
+		return service, false
+	}
+	return service, true
+}
+
+// Name names the media widget.
+func (f *MediaFetcher) Name() string { return "media" }
+
+// Interval is a minute at most, so the configuration is re-read every
+// minute even with a longer MEDIA_INTERVAL.
+func (f *MediaFetcher) Interval() time.Duration { return min(f.interval, time.Minute) }
+
+// Refresh polls the configured services that are due.
+func (f *MediaFetcher) Refresh(ctx context.Context, config *Config) error {
+	return f.fetchAll(ctx, config.Media)
+}
+
+// Render returns the Panels of the configured services.
+func (f *MediaFetcher) Render(config *Config, _ *Locale) any {
+	return f.Panels(config.Media)
+}
+
+// fetchAll polls every service that is due and drops state for services
+// that are no longer configured, returning the errors of those that failed.
+func (f *MediaFetcher) fetchAll(ctx context.Context, services []MediaServiceConfig) error {
+	keys := make([]string, 0, len(services))
+	var errs []error
+	var wg sync.WaitGroup
+	for _, service := range services {
+		key := service.key()
+		keys = append(keys, key)
+		f.mu.Lock()
+		due := time.Since(f.services[key].attempted) >= f.interval
+		f.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		wg.Go(func() {
+			fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			var counts []MediaCount
+			var err error
+			if service.Type == mediaQBittorrent {
+				counts, err = f.qbittorrent(fetchCtx, service)
+			} else {
+				counts, err = f.arr(fetchCtx, service)
+			}
+			cancel()
+			if err != nil {
+				log.Printf("Warning: Could not poll media service %s: %v", service.Name, err)
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", service.Name, err))
+			}
+			state := f.services[key]
+			state.err = err
+			state.attempted = time.Now()
+			if err == nil {
+				state.counts = counts
+				state.fetched = time.Now()
+			}
+			f.services[key] = state
+		})
+	}
+	wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key := range f.services {
+		if !slices.Contains(keys, key) {
+			delete(f.services, key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// arr reads the download queue size and the number of health issues of a
+// Sonarr, Radarr or Lidarr instance.
+func (f *MediaFetcher) arr(ctx context.Context, service MediaServiceConfig) ([]MediaCount, error) {
+	base := service.URL + arrAPIVersions[service.Type]
+	header := make(http.Header)
+	if key := mediaEnv(service.Name, "API_KEY"); key != "" {
+		header.Set("X-Api-Key", key)
+	}
+
+	var queue struct {
+		TotalCount int `json:"totalCount"`
+	}
+	if err := f.getJSON(ctx, base+"/queue/status", header, &queue); err != nil {
+		return nil, err
+	}
+	var health []json.RawMessage
+	if err := f.getJSON(ctx, base+"/health", header, &health); err != nil {
+		return nil, err
+	}
+	return []MediaCount{
+		{Label: "media.queue", Value: strconv.Itoa(queue.TotalCount)},
+		{Label: "media.health", Value: strconv.Itoa(len(health))},
+	}, nil
+}
+
+// qbittorrent reads the number of downloading torrents and the transfer
+// rates of a qBittorrent instance, logging in first when it has a
+// username.
+func (f *MediaFetcher) qbittorrent(ctx context.Context, service MediaServiceConfig) ([]MediaCount, error) {
+	// qBittorrent rejects API requests whose Referer doesn't match its
+	// address, as a CSRF protection.
+	header := http.Header{"Referer": {service.URL}}
+	if user := mediaEnv(service.Name, "USERNAME"); user != "" {
+		form := url.Values{"username": {user}, "password": {mediaEnv(service.Name, "PASSWORD")}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, service.URL+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header = header.Clone()
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+			return nil, fmt.Errorf("login failed: %s", resp.Status)
+		}
+		for _, c := range resp.Cookies() {
+			if c.Name == "SID" {
+				header.Set("Cookie", c.Name+"="+c.Value)
+			}
+		}
+	}
+
+	var downloading []json.RawMessage
+	if err := f.getJSON(ctx, service.URL+"/api/v2/torrents/info?filter=downloading", header, &downloading); err != nil {
+		return nil, err
+	}
+	var transfer struct {
+		Download int64 `json:"dl_info_speed"`
+		Upload   int64 `json:"up_info_speed"`
+	}
+	if err := f.getJSON(ctx, service.URL+"/api/v2/transfer/info", header, &transfer); err != nil {
+		return nil, err
+	}
+	return []MediaCount{
+		{Label: "media.downloading", Value: strconv.Itoa(len(downloading))},
+		{Label: "media.download_rate", Value: formatRate(transfer.Download)},
+		{Label: "media.upload_rate", Value: formatRate(transfer.Upload)},
+	}, nil
+}
+
+// getJSON decodes the JSON response to a GET of rawURL into v.
+func (f *MediaFetcher) getJSON(ctx context.Context, rawURL string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+	req.Header.Set("User-Agent", "gohome-media")
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxMediaBytes)).Decode(v)
+}
+
+// formatRate formats a transfer rate in bytes per second.
+func formatRate(bytesPerSecond int64) string {
+	v := float64(bytesPerSecond)
+	for _, unit := range []string{"B/s", "kB/s", "MB/s"} {
+		if v < 1000 {
+			return formatStat(v, -1, unit)
+		}
+		v /= 1000
+	}
+	return formatStat(v, -1, "GB/s")
+}
+
+// Panels returns the cached counts for services. Services that haven't
+// been polled yet are left out.
+func (f *MediaFetcher) Panels(services []MediaServiceConfig) []MediaPanel {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var panels []MediaPanel
+	for _, service := range services {
+		state, ok := f.services[service.key()]
+		if !ok {
+			continue
+		}
+		panel := MediaPanel{Name: service.Label, URL: service.URL, Counts: state.counts, Fetched: state.fetched}
+		if state.err != nil {
+			panel.Err = state.err.Error()
+		}
+		panels = append(panels, panel)
+	}
+	return panels
+}
+
+// Len returns the number of media services with cached state.
+func (f *MediaFetcher) Len() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.services)
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Internal metrics shared by every instance of the components they
+// measure, so they are registered once for the process rather than by a
+// constructor.
+var (
+	kubernetesRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gohome_kubernetes_requests_total",
+		Help: "Requests to the Kubernetes API server by verb, resource and status code (\"error\" when no response arrived).",
+	}, []string{"verb", "resource", "code"})
+	kubernetesRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gohome_kubernetes_request_duration_seconds",
+		Help:    "Kubernetes API request duration in seconds by verb and resource.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"verb", "resource"})
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gohome_cache_requests_total",
+		Help: "Cache lookups by cache and result: hit (fresh), stale (served while refreshing) or miss (waited for a fetch).",
+	}, []string{"cache", "result"})
+	cacheFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gohome_cache_fetches_total",
+		Help: "Cache refreshes by cache and result: success, failure, or shared (taken from another replica through Redis).",
+	}, []string{"cache", "result"})
+	healthChecksQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gohome_health_checks_queued",
+		Help: "Health probes waiting for one of the concurrent probe slots.",
+	})
+	healthChecksInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gohome_health_checks_in_flight",
+		Help: "Health probes currently running.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(kubernetesRequests, kubernetesRequestDuration, cacheRequests, cacheFetches, healthChecksQueued, healthChecksInFlight)
+}
+
+// kubernetesMetrics counts and times every request the Kubernetes client
+// sends through rt.
+type kubernetesMetrics struct {
+	rt http.RoundTripper
+}
+
+// instrumentKubernetes wraps the Kubernetes client's transport; see
+// rest.Config.Wrap.
+func instrumentKubernetes(rt http.RoundTripper) http.RoundTripper {
+	return kubernetesMetrics{rt: rt}
+}
+
+func (m kubernetesMetrics) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := m.rt.RoundTrip(req)
+	verb, resource := req.Method, kubernetesResource(req.URL.Path)
+	kubernetesRequestDuration.WithLabelValues(verb, resource).Observe(time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	kubernetesRequests.WithLabelValues(verb, resource, code).Inc()
+	return resp, err
+}
+
+// kubernetesResource returns the resource an API path refers to, e.g.
+// "ingresses" for /apis/networking.k8s.io/v1/ingresses and "configmaps" for
+// /api/v1/namespaces/default/configmaps/gohome, keeping the label's
+// cardinality down to the handful of resources GoHome reads.
+func kubernetesResource(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	case parts[0] == "":
+		return "discovery"
+	default:
+		return parts[0]
+	}
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		return "discovery"
+	}
+	return parts[0]
+}
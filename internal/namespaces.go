@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// namespacePagePrefix starts the slug renderHome is given for
+// /ns/<namespace>.
+const namespacePagePrefix = "ns/"
+
+// namespaceName matches a Kubernetes namespace name (an RFC 1123 label).
+var namespaceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// namespaceBookmarks reads the bookmarks in each namespace's own copy of the
+// ConfigMap, one cached BookmarkManager per namespace.
+type namespaceBookmarks struct {
+	mu       sync.Mutex
+	managers map[string]*BookmarkManager
+}
+
+// NamespaceBookmarks returns the bookmark-* entries of the ConfigMap with the
+// same name as the main one in namespace, or none if there is no such
+// ConfigMap. In demo mode there are none.
+func (bm *BookmarkManager) NamespaceBookmarks(ctx context.Context, namespace string) ([]Bookmark, error) {
+	if bm.client() == nil {
+		if configMap := bm.fixtures.configMap(namespace, bm.configMapName); configMap != nil {
+			return bm.parseBookmarks(configMap), nil
+		}
+		return nil, nil
+	}
+	bm.local.mu.Lock()
+	local := bm.local.managers[namespace]
+	if local == nil {
+		if bm.local.managers == nil {
+			bm.local.managers = make(map[string]*BookmarkManager)
+		}
+		local = NewBookmarkManager(bm.client(), namespace, bm.configMapName)
+		bm.local.managers[namespace] = local
+	}
+	bm.local.mu.Unlock()
+
+	configMap, err := local.getConfigMap(ctx)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if configMap == nil {
+		return nil, err
+	}
+	return local.parseBookmarks(configMap), err
+}
+
+// handleNamespace renders /ns/<namespace>: a homepage with only that
+// namespace's ingresses and its own bookmarks.
+func (s *Server) handleNamespace(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	if !namespaceName.MatchString(namespace) {
+		s.renderNotFound(w, r)
+		return
+	}
+	s.renderHome(w, r, requestedView(r), namespacePagePrefix+namespace)
+}
+
+// pageFor returns the page slug names: a configured page, a namespace's
+// for "ns/<namespace>", or nil if there is none or slug is empty.
+func (s *Server) pageFor(ctx context.Context, config *Config, slug string) *Page {
+	if namespace, ok := strings.CutPrefix(slug, namespacePagePrefix); ok {
+		return s.namespacePage(ctx, config, namespace)
+	}
+	if slug == "" {
+		return nil
+	}
+	return findPage(config.Pages, slug)
+}
+
+// namespacePage returns the page for /ns/<namespace> and replaces
+// config.Bookmarks with the namespace's own, or returns nil when no visible
+// ingress is in the namespace. Requiring one keeps unknown namespaces from
+// each getting a cached ConfigMap lookup.
+func (s *Server) namespacePage(ctx context.Context, config *Config, namespace string) *Page {
+	apps, services, err := s.kube().GetVisibleIngresses(ctx)
+	if err != nil {
+		log.Printf("Warning: /ns/%s could not load ingresses: %v", namespace, err)
+	}
+	inNamespace := func(info IngressInfo) bool { return info.Namespace == namespace }
+	if !slices.ContainsFunc(apps, inNamespace) && !slices.ContainsFunc(services, inNamespace) {
+		return nil
+	}
+
+	bookmarks, err := s.bookmarkManager.NamespaceBookmarks(ctx, namespace)
+	if err != nil {
+		log.Printf("Warning: Could not load bookmarks ConfigMap %s/%s: %v", namespace, s.bookmarkManager.configMapName, err)
+	}
+	config.Bookmarks = bookmarks
+	return &Page{
+		Slug:       namespacePagePrefix + namespace,
+		Title:      namespace,
+		Namespaces: []string{namespace},
+		Namespace:  namespace,
+	}
+}
@@ -0,0 +1,362 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// probeKeyPrefix marks ConfigMap keys that define a network probe:
+	//   probe-<name>: "1.1.1.1|check=ping|label=Internet|download=https://speed.example.com/25MB.bin"
+	probeKeyPrefix = "probe-"
+	// probeTargetKubernetes probes the Kubernetes API server instead of an
+	// address.
+	probeTargetKubernetes = "kubernetes"
+	// defaultProbeInterval is how often every target's latency is measured.
+	defaultProbeInterval = time.Minute
+	// defaultDownloadInterval is how often throughput is measured, which
+	// costs real bandwidth.
+	defaultDownloadInterval = time.Hour
+	// probeHistory is how many latency samples the trend line is drawn from.
+	probeHistory = 30
+	// maxDownloadBytes caps a throughput download.
+	maxDownloadBytes = 100 << 20
+)
+
+// ProbeConfig is one probe-<name> entry from the ConfigMap.
+type ProbeConfig struct {
+	Name     string
+	Target   string // URL, host[:port], or "kubernetes" for the API server
+	Check    string // one of validHealthChecks; http for URLs, tcp for host:port, ping for a bare host
+	Download string // URL downloaded to measure throughput; empty for none
+}
+
+// key identifies the cached results of c.
+func (c ProbeConfig) key() string {
+	return c.Target + "\x00" + c.Check + "\x00" + c.Download
+}
+
+// ProbeStat is a probe as rendered on the homepage.
+type ProbeStat struct {
+	Name       string
+	Latency    string // e.g. "14 ms"; empty until a probe succeeds
+	Trend      string // SVG polyline points of the recent latencies, see trendPoints
+	Throughput string // e.g. "94.2 Mbit/s"; empty without a download or until one succeeds
+	Err        string // set when the most recent probe or download failed
+	Fetched    time.Time
+}
+
+// probeState is what NetProbes remembers about one probe.
+type probeState struct {
+	latencies  []float64 // in milliseconds, oldest first
+	throughput float64   // in Mbit/s
+	err        error
+	fetched    time.Time
+	attempted  time.Time
+	downloaded time.Time // last download attempt
+	downErr    error
+}
+
+// NetProbes measures latency to the configured targets, such as the
+// internet and the cluster, and optionally throughput, in the background
+// and keeps a short history of each for the homepage.
+type NetProbes struct {
+	client           *http.Client
+	k8s              func() *K8sClient
+	interval         time.Duration
+	downloadInterval time.Duration
+	mu               sync.Mutex
+	probes           map[string]probeState // keyed by ProbeConfig.key
+}
+
+// NewNetProbesFromEnv creates a prober that measures latency every
+// PROBE_INTERVAL and throughput every PROBE_DOWNLOAD_INTERVAL. k8s returns
+// the client "kubernetes" targets are probed through.
+func NewNetProbesFromEnv(k8s func() *K8sClient) *NetProbes {
+	return &NetProbes{
+		// A fresh connection per probe, so the latency includes the
+		// handshakes like a browser's first request would.
+		client:           &http.Client{Transport: &http.Transport{DisableKeepAlives: true, Proxy: http.ProxyFromEnvironment}},
+		k8s:              k8s,
+		interval:         durationFromEnv("PROBE_INTERVAL", defaultProbeInterval),
+		downloadInterval: durationFromEnv("PROBE_DOWNLOAD_INTERVAL", defaultDownloadInterval),
+		probes:           make(map[string]probeState),
+	}
+}
+
+// parseProbes reads every probe-* key from ConfigMap data, sorted by name.
+func parseProbes(data map[string]string) []ProbeConfig {
+	var probes []ProbeConfig
+	for key, value := range data {
+		if !strings.HasPrefix(key, probeKeyPrefix) {
+			continue
+		}
+		if probe, ok := parseProbeEntry(key, value); ok {
+			probes = append(probes, probe)
+		}
+	}
+	slices.SortFunc(probes, func(a, b ProbeConfig) int { return strings.Compare(a.Name, b.Name) })
+	return probes
+}
+
+// parseProbeEntry parses a probe-<name> ConfigMap value: the target
+// followed by optional |key=value settings.
+func parseProbeEntry(key, value string) (ProbeConfig, bool) {
+	parts := strings.Split(value, "|")
+	probe := ProbeConfig{
+		Name:   strings.TrimPrefix(key, probeKeyPrefix),
+		Target: strings.TrimSpace(parts[0]),
+	}
+	if probe.Target == "" {
+		log.Printf("Warning: probe %s has no target, skipping", probe.Name)
+		return probe, false
+	}
+	for _, opt := range parts[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		v = strings.TrimSpace(v)
+		switch k {
+		case "label":
+			probe.Name = v
+		case "check":
+			if !slices.Contains(validHealthChecks, v) {
+				log.Printf("Warning: probe %s has invalid check %q, want one of %s", probe.Name, v, strings.Join(validHealthChecks, ", "))
+				continue
+			}
+			probe.Check = v
+		case "download":
+			if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				log.Printf("Warning: probe %s has invalid download URL %q", probe.Name, v)
+				continue
+			}
+			probe.Download = v
+		default:
+			log.Printf("Warning: probe %s has unknown option %q", probe.Name, opt)
+		}
+	}
+	if probe.Target != probeTargetKubernetes && probe.Check == "" {
+		_, port := targetHostPort(probe.Target)
+		switch {
+		case strings.Contains(probe.Target, "://"):
+			probe.Check = HealthCheckHTTP
+		case port != "":
+			probe.Check = HealthCheckTCP
+		default:
+			probe.Check = HealthCheckPing
+		}
+	}
+	if probe.Check == HealthCheckHTTP && !strings.Contains(probe.Target, "://") {
+		probe.Target = "https://" + probe.Target
+	}
+	return probe, true
+}
+
+// Name names the network probes widget.
+func (p *NetProbes) Name() string { return "probes" }
+
+// Interval is a minute at most, so the configuration is re-read every
+// minute even with a longer PROBE_INTERVAL.
+func (p *NetProbes) Interval() time.Duration { return min(p.interval, time.Minute) }
+
+// Refresh probes the configured targets that are due.
+func (p *NetProbes) Refresh(ctx context.Context, config *Config) error {
+	return p.probeAll(ctx, config.Probes)
+}
+
+// Render returns the Stats of the configured probes.
+func (p *NetProbes) Render(config *Config, _ *Locale) any {
+	return p.Stats(config.Probes)
+}
+
+// probeAll measures every probe that is due, and downloads for those whose
+// throughput is due, dropping state for probes that are no longer
+// configured. It returns the errors of those that failed.
+func (p *NetProbes) probeAll(ctx context.Context, probes []ProbeConfig) error {
+	keys := make([]string, 0, len(probes))
+	var errs []error
+	var wg sync.WaitGroup
+	for _, probe := range probes {
+		key := probe.key()
+		keys = append(keys, key)
+		p.mu.Lock()
+		state := p.probes[key]
+		due := time.Since(state.attempted) >= p.interval
+		downloadDue := probe.Download != "" && time.Since(state.downloaded) >= p.downloadInterval
+		p.mu.Unlock()
+		if !due && !downloadDue {
+			continue
+		}
+
+		wg.Go(func() {
+			var latency time.Duration
+			var err, downErr error
+			var throughput float64
+			if due {
+				probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				latency, err = p.latency(probeCtx, probe)
+				cancel()
+				if err != nil {
+					log.Printf("Warning: Could not probe %s: %v", probe.Name, err)
+				}
+			}
+			if downloadDue {
+				downCtx, cancel := context.WithTimeout(ctx, time.Minute)
+				throughput, downErr = p.download(downCtx, probe.Download)
+				cancel()
+				if downErr != nil {
+					log.Printf("Warning: Could not measure throughput for %s: %v", probe.Name, downErr)
+				}
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			state := p.probes[key]
+			if due {
+				state.attempted = time.Now()
+				state.err = err
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", probe.Name, err))
+				} else {
+					state.latencies = append(state.latencies, float64(latency)/float64(time.Millisecond))
+					if n := len(state.latencies); n > probeHistory {
+						state.latencies = slices.Clone(state.latencies[n-probeHistory:])
+					}
+					state.fetched = time.Now()
+				}
+			}
+			if downloadDue {
+				state.downloaded = time.Now()
+				state.downErr = downErr
+				if downErr != nil {
+					errs = append(errs, fmt.Errorf("%s download: %w", probe.Name, downErr))
+				} else {
+					state.throughput = throughput
+				}
+			}
+			p.probes[key] = state
+		})
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key := range p.probes {
+		if !slices.Contains(keys, key) {
+			delete(p.probes, key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// latency measures one round trip to the probe's target: an HTTP request
+// until the response headers, a TCP connection, an ICMP echo, or a request
+// for the Kubernetes API server's version.
+func (p *NetProbes) latency(ctx context.Context, probe ProbeConfig) (time.Duration, error) {
+	switch {
+	case probe.Target == probeTargetKubernetes:
+		k := p.k8s()
+		if k == nil {
+			return 0, errors.New("not connected to Kubernetes")
+		}
+		start := time.Now()
+		if _, err := k.ServerVersion(ctx); err != nil {
+			return 0, err
+		}
+		return time.Since(start), nil
+	case probe.Check == HealthCheckPing:
+		return ping(ctx, probe.Target)
+	case probe.Check == HealthCheckTCP:
+		return dialTCP(ctx, probe.Target, "")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, probe.Target, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "gohome-probe")
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+	resp.Body.Close()
+	return latency, nil
+}
+
+// download fetches rawURL, up to maxDownloadBytes, and returns the
+// throughput in Mbit/s.
+func (p *NetProbes) download(ctx context.Context, rawURL string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "gohome-probe")
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxDownloadBytes))
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	if n == 0 || elapsed <= 0 {
+		return 0, errors.New("empty download")
+	}
+	return float64(n) * 8 / elapsed.Seconds() / 1e6, nil
+}
+
+// Stats returns the cached results for probes. Probes that haven't run yet
+// are left out.
+func (p *NetProbes) Stats(probes []ProbeConfig) []ProbeStat {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var stats []ProbeStat
+	for _, probe := range probes {
+		state, ok := p.probes[probe.key()]
+		if !ok {
+			continue
+		}
+		stat := ProbeStat{Name: probe.Name, Fetched: state.fetched}
+		if n := len(state.latencies); n > 0 {
+			stat.Latency = formatStat(state.latencies[n-1], -1, "ms")
+			stat.Trend = trendPoints(state.latencies)
+		}
+		if state.throughput > 0 {
+			stat.Throughput = formatStat(state.throughput, -1, "Mbit/s")
+		}
+		if err := cmp.Or(state.err, state.downErr); err != nil {
+			stat.Err = err.Error()
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// Len returns the number of probes with cached state.
+func (p *NetProbes) Len() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.probes)
+}
@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// nomadNamespacePrefix starts the namespace Nomad services are shown in,
+// followed by their Nomad namespace, e.g. "nomad-default".
+const nomadNamespacePrefix = "nomad-"
+
+// NomadServices lists the services registered with Nomad's own service
+// discovery as tiles, for homelabs running Nomad alongside or instead of
+// Kubernetes. Each service's tile is configured by the meta of the job
+// that registers it. Listings are cached like ingress listings.
+type NomadServices struct {
+	base      string // e.g. "http://nomad.service.consul:4646"
+	token     string
+	namespace string            // Nomad namespace to list, "*" for all
+	tags      []string          // a service is listed if it has one of them; empty for every service
+	meta      map[string]string // a service is listed if its job has all of these meta values
+	template  *template.Template
+	client    *http.Client
+	services  *Cache[[]IngressInfo]
+}
+
+// nomadServiceList is an entry of /v1/services.
+type nomadServiceList struct {
+	Namespace string
+	Services  []struct {
+		ServiceName string
+		Tags        []string
+	}
+}
+
+// nomadRegistration is an entry of /v1/service/<name>: one allocation's
+// registration of the service.
+type nomadRegistration struct {
+	ServiceName string
+	Namespace   string
+	NodeID      string
+	Datacenter  string
+	JobID       string
+	AllocID     string
+	Tags        []string
+	Address     string
+	Port        int
+}
+
+// NewNomadServicesFromEnv returns a client for NOMAD_ADDR with the
+// NOMAD_TOKEN ACL token, listing the services in NOMAD_NAMESPACE (default
+// all) tagged with one of NOMAD_TAGS whose job meta matches NOMAD_META, or
+// nil when no address is set.
+func NewNomadServicesFromEnv() *NomadServices {
+	addr := os.Getenv("NOMAD_ADDR")
+	if addr == "" {
+		return nil
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	n := &NomadServices{
+		base:      strings.TrimSuffix(addr, "/"),
+		token:     os.Getenv("NOMAD_TOKEN"),
+		namespace: os.Getenv("NOMAD_NAMESPACE"),
+		tags:      splitList(os.Getenv("NOMAD_TAGS")),
+		meta:      make(map[string]string),
+		template:  urlTemplateFromEnv("NOMAD_URL_TEMPLATE", defaultServiceURLTemplate),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		services:  NewCacheFromEnv[[]IngressInfo]("nomad"),
+	}
+	if n.namespace == "" {
+		n.namespace = "*"
+	}
+	for _, pair := range splitList(os.Getenv("NOMAD_META")) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			log.Printf("Warning: Ignoring invalid NOMAD_META entry %q, want key=value", pair)
+			continue
+		}
+		n.meta[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	log.Printf("Listing services from Nomad at %s", redactURL(n.base))
+	return n
+}
+
+// Discover returns a tile for each listed service, sorted by name. If
+// Nomad can't be read it returns the error together with the tiles from
+// the last listing that worked, if any. A nil client has no services.
+func (n *NomadServices) Discover(ctx context.Context) ([]IngressInfo, error) {
+	if n == nil {
+		return nil, nil
+	}
+	services, err := n.services.Get(ctx, "", n.list)
+	return slices.Clone(services), err
+}
+
+// Name is how logs refer to the source.
+func (n *NomadServices) Name() string { return "Nomad services" }
+
+// Watch returns at once: the Nomad is polled as its cache expires.
+func (n *NomadServices) Watch(context.Context, func()) {}
+
+// list reads the service names, then the registrations of each service
+// that passes the tag filter and the meta of the jobs registering them.
+func (n *NomadServices) list(ctx context.Context) ([]IngressInfo, error) {
+	var lists []nomadServiceList
+	if err := n.get(ctx, "/v1/services", n.namespace, &lists); err != nil {
+		return nil, err
+	}
+
+	jobMeta := make(map[string]map[string]string) // by namespace/job
+	var tiles []IngressInfo
+	for _, list := range lists {
+		for _, service := range list.Services {
+			if !n.wanted(service.Tags) {
+				continue
+			}
+			var registrations []nomadRegistration
+			if err := n.get(ctx, "/v1/service/"+url.PathEscape(service.ServiceName), list.Namespace, &registrations); err != nil {
+				return nil, err
+			}
+			if len(registrations) == 0 {
+				continue
+			}
+			reg := registrations[0]
+			key := reg.Namespace + "/" + reg.JobID
+			meta, ok := jobMeta[key]
+			if !ok {
+				var job struct{ Meta map[string]string }
+				if err := n.get(ctx, "/v1/job/"+url.PathEscape(reg.JobID), reg.Namespace, &job); err != nil {
+					return nil, err
+				}
+				meta = job.Meta
+				jobMeta[key] = meta
+			}
+			if !n.metaMatches(meta) {
+				continue
+			}
+			data := serviceURLData{
+				Name:       reg.ServiceName,
+				Address:    reg.Address,
+				Port:       reg.Port,
+				Node:       reg.NodeID,
+				Datacenter: reg.Datacenter,
+				Tags:       reg.Tags,
+				Meta:       maps.Clone(meta),
+			}
+			source := fmt.Sprintf("Nomad service %s/%s", reg.Namespace, reg.ServiceName)
+			if info, ok := serviceTile(source, nomadNamespacePrefix+reg.Namespace, n.template, data); ok {
+				tiles = append(tiles, info)
+			}
+		}
+	}
+	sort.Slice(tiles, func(i, j int) bool { return tiles[i].Name < tiles[j].Name })
+	return tiles, nil
+}
+
+// wanted reports whether a service with tags passes NOMAD_TAGS.
+func (n *NomadServices) wanted(tags []string) bool {
+	if len(n.tags) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(tags, func(t string) bool { return slices.Contains(n.tags, t) })
+}
+
+// metaMatches reports whether job meta has every NOMAD_META value.
+func (n *NomadServices) metaMatches(meta map[string]string) bool {
+	for k, v := range n.meta {
+		if meta[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// get decodes the JSON response to a GET of path in namespace into v.
+func (n *NomadServices) get(ctx context.Context, path, namespace string, v any) error {
+	u := n.base + path + "?namespace=" + url.QueryEscape(namespace)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if n.token != "" {
+		req.Header.Set("X-Nomad-Token", n.token)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nomad %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
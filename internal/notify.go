@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind identifies what changed.
+type EventKind string
+
+const (
+	// EventIngressAdded fires when a new visible ingress is discovered
+	EventIngressAdded EventKind = "ingress_added"
+	// EventIngressRemoved fires when a previously visible ingress disappears
+	EventIngressRemoved EventKind = "ingress_removed"
+	// EventHealthChanged fires when a health check flips between up and down
+	EventHealthChanged EventKind = "health_changed"
+)
+
+// Event is a single change notification.
+type Event struct {
+	Kind    EventKind `json:"kind"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	URL     string    `json:"url,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Notifier delivers events to an external service.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, client *http.Client, event Event) error
+}
+
+// Dispatcher fans events out to every configured notifier.
+type Dispatcher struct {
+	notifiers []Notifier
+	client    *http.Client
+}
+
+// NewDispatcherFromEnv builds a dispatcher from the NOTIFY_* environment
+// variables. These hold webhook URLs and tokens, so in Kubernetes they are
+// expected to be populated from a Secret rather than the ConfigMap.
+func NewDispatcherFromEnv() *Dispatcher {
+	d := &Dispatcher{client: &http.Client{Timeout: 10 * time.Second}}
+
+	if u := os.Getenv("NOTIFY_SLACK_URL"); u != "" {
+		d.notifiers = append(d.notifiers, slackNotifier{url: u})
+	}
+	if u := os.Getenv("NOTIFY_DISCORD_URL"); u != "" {
+		d.notifiers = append(d.notifiers, discordNotifier{url: u})
+	}
+	if u := os.Getenv("NOTIFY_NTFY_URL"); u != "" {
+		d.notifiers = append(d.notifiers, ntfyNotifier{url: u, token: os.Getenv("NOTIFY_NTFY_TOKEN")})
+	}
+	if u := os.Getenv("NOTIFY_WEBHOOK_URL"); u != "" {
+		d.notifiers = append(d.notifiers, webhookNotifier{url: u})
+	}
+
+	for _, n := range d.notifiers {
+		log.Printf("Notifications enabled via %s", n.Name())
+	}
+	return d
+}
+
+// Enabled reports whether any notifier is configured.
+func (d *Dispatcher) Enabled() bool {
+	return d != nil && len(d.notifiers) > 0
+}
+
+// Dispatch sends the event to every notifier concurrently. Failures are logged
+// rather than returned: a broken Slack webhook shouldn't stop Discord from
+// getting the message.
+func (d *Dispatcher) Dispatch(ctx context.Context, event Event) {
+	if !d.Enabled() {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	var wg sync.WaitGroup
+	for _, n := range d.notifiers {
+		wg.Go(func() {
+			if err := n.Notify(ctx, d.client, event); err != nil {
+				log.Printf("Warning: %s notification failed: %v", n.Name(), err)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// postJSON POSTs body as JSON and treats any non-2xx response as an error.
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doNotify(client, req)
+}
+
+// doNotify executes req and treats any non-2xx response as an error.
+func doNotify(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// eventText renders an event as a single line of plain text.
+func eventText(event Event) string {
+	text := event.Title + ": " + event.Message
+	if event.URL != "" {
+		text += " (" + event.URL + ")"
+	}
+	return text
+}
+
+// slackNotifier posts to a Slack incoming webhook.
+type slackNotifier struct{ url string }
+
+func (n slackNotifier) Name() string { return "slack" }
+
+func (n slackNotifier) Notify(ctx context.Context, client *http.Client, event Event) error {
+	return postJSON(ctx, client, n.url, map[string]string{"text": eventText(event)})
+}
+
+// discordNotifier posts to a Discord channel webhook.
+type discordNotifier struct{ url string }
+
+func (n discordNotifier) Name() string { return "discord" }
+
+func (n discordNotifier) Notify(ctx context.Context, client *http.Client, event Event) error {
+	return postJSON(ctx, client, n.url, map[string]string{"content": eventText(event)})
+}
+
+// ntfyNotifier publishes to an ntfy topic URL, e.g. https://ntfy.sh/my-topic.
+type ntfyNotifier struct {
+	url   string
+	token string
+}
+
+func (n ntfyNotifier) Name() string { return "ntfy" }
+
+func (n ntfyNotifier) Notify(ctx context.Context, client *http.Client, event Event) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, strings.NewReader(event.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", event.Title)
+	req.Header.Set("Tags", string(event.Kind))
+	if event.URL != "" {
+		req.Header.Set("Click", event.URL)
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	return doNotify(client, req)
+}
+
+// webhookNotifier POSTs the raw Event as JSON to an arbitrary URL.
+type webhookNotifier struct{ url string }
+
+func (n webhookNotifier) Name() string { return "webhook" }
+
+func (n webhookNotifier) Notify(ctx context.Context, client *http.Client, event Event) error {
+	return postJSON(ctx, client, n.url, event)
+}
+
+// ingressKey identifies an ingress across listings.
+func ingressKey(info IngressInfo) string {
+	return info.Namespace + "/" + info.Name
+}
+
+// diffIngresses returns the events needed to go from the previous snapshot to
+// the current one, sorted so notifications arrive in a stable order.
+func diffIngresses(previous, current map[string]IngressInfo) []Event {
+	var events []Event
+	for key, info := range current {
+		if _, ok := previous[key]; !ok {
+			events = append(events, Event{
+				Kind:    EventIngressAdded,
+				Title:   "New service: " + info.Name,
+				Message: fmt.Sprintf("%s appeared in namespace %s", info.Name, info.Namespace),
+				URL:     info.URL,
+			})
+		}
+	}
+	for key, info := range previous {
+		if _, ok := current[key]; !ok {
+			events = append(events, Event{
+				Kind:    EventIngressRemoved,
+				Title:   "Service removed: " + info.Name,
+				Message: fmt.Sprintf("%s is no longer present in namespace %s", info.Name, info.Namespace),
+				URL:     info.URL,
+			})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Title < events[j].Title
+	})
+	return events
+}
+
+// WatchForChanges polls the cluster every interval, and as soon as a
+// provider's Watch reports a change, and dispatches a notification for each
+// ingress that appears or disappears. The first listing
+// only establishes a baseline so a restart doesn't announce every service.
+// It returns when ctx is cancelled, or immediately if no notifier is
+// configured. In demo mode it waits for ConnectInBackground.
+func (s *Server) WatchForChanges(ctx context.Context, interval time.Duration) {
+	if !s.notifier.Enabled() {
+		return
+	}
+
+	var previous map[string]IngressInfo
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// In demo mode there is nothing worth announcing yet.
+		if s.kube() != nil {
+			listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			apps, services, err := s.visibleTiles(listCtx)
+			cancel()
+
+			if err != nil {
+				log.Printf("Warning: change watcher failed to list ingresses: %v", err)
+			} else {
+				current := make(map[string]IngressInfo, len(apps)+len(services))
+				for _, info := range append(apps, services...) {
+					current[ingressKey(info)] = info
+				}
+				if previous != nil {
+					for _, event := range diffIngresses(previous, current) {
+						s.notifier.Dispatch(ctx, event)
+					}
+				}
+				previous = current
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.tilesChanged:
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiffIngresses(t *testing.T) {
+	grafana := IngressInfo{Name: "grafana", Namespace: "monitoring", URL: "https://grafana.example.com/"}
+	jellyfin := IngressInfo{Name: "jellyfin", Namespace: "media", URL: "https://media.example.com/"}
+	previous := map[string]IngressInfo{ingressKey(grafana): grafana}
+	current := map[string]IngressInfo{ingressKey(jellyfin): jellyfin}
+
+	events := diffIngresses(previous, current)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(events), events)
+	}
+	if e := events[0]; e.Kind != EventIngressAdded || e.Title != "New service: jellyfin" || e.URL != jellyfin.URL {
+		t.Errorf("first event %+v, want jellyfin added", e)
+	}
+	if e := events[1]; e.Kind != EventIngressRemoved || e.Title != "Service removed: grafana" {
+		t.Errorf("second event %+v, want grafana removed", e)
+	}
+	if events := diffIngresses(current, current); len(events) != 0 {
+		t.Errorf("got %+v for an unchanged listing", events)
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	event := Event{Kind: EventHealthChanged, Title: "Down: Grafana", Message: "Grafana is down", URL: "https://grafana.example.com/"}
+	for _, tt := range []struct {
+		notifier func(url string) Notifier
+		check    func(t *testing.T, r *http.Request, body []byte)
+	}{
+		{func(url string) Notifier { return slackNotifier{url: url} }, func(t *testing.T, r *http.Request, body []byte) {
+			checkJSON(t, body, "text", "Down: Grafana: Grafana is down (https://grafana.example.com/)")
+		}},
+		{func(url string) Notifier { return discordNotifier{url: url} }, func(t *testing.T, r *http.Request, body []byte) {
+			checkJSON(t, body, "content", "Down: Grafana: Grafana is down (https://grafana.example.com/)")
+		}},
+		{func(url string) Notifier { return ntfyNotifier{url: url, token: "tk_123"} }, func(t *testing.T, r *http.Request, body []byte) {
+			for header, want := range map[string]string{
+				"Title":         event.Title,
+				"Tags":          string(event.Kind),
+				"Click":         event.URL,
+				"Authorization": "Bearer tk_123",
+			} {
+				if got := r.Header.Get(header); got != want {
+					t.Errorf("%s header %q, want %q", header, got, want)
+				}
+			}
+			if string(body) != event.Message {
+				t.Errorf("body %q, want the message", body)
+			}
+		}},
+		{func(url string) Notifier { return webhookNotifier{url: url} }, func(t *testing.T, r *http.Request, body []byte) {
+			checkJSON(t, body, "kind", string(EventHealthChanged))
+		}},
+	} {
+		t.Run(tt.notifier("").Name(), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				tt.check(t, r, body)
+			}))
+			defer server.Close()
+			if err := tt.notifier(server.URL).Notify(context.Background(), server.Client(), event); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNotifierReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer server.Close()
+	if err := (webhookNotifier{url: server.URL}).Notify(context.Background(), server.Client(), Event{}); err == nil {
+		t.Error("no error for a 410 response")
+	}
+}
+
+// checkJSON checks that body is a JSON object with key set to want.
+func checkJSON(t *testing.T, body []byte, key, want string) {
+	t.Helper()
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("body %q: %v", body, err)
+	}
+	if payload[key] != want {
+		t.Errorf("%s %q, want %q", key, payload[key], want)
+	}
+}
+
+// TestWatchForChanges adds and removes an ingress in a fake cluster and
+// checks that each is announced once the baseline is taken.
+func TestWatchForChanges(t *testing.T) {
+	t.Setenv("CACHE_TTL", "0")
+	events := make(chan Event, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decoding event: %v", err)
+		}
+		events <- event
+	}))
+	defer webhook.Close()
+
+	clientset := fake.NewClientset(testIngress("media", "jellyfin", "media.example.com", "", nil))
+	listed := make(chan struct{}, 10)
+	clientset.PrependReactor("list", "ingresses", func(k8stesting.Action) (bool, runtime.Object, error) {
+		listed <- struct{}{}
+		return false, nil, nil
+	})
+	s := &Server{
+		notifier:     &Dispatcher{notifiers: []Notifier{webhookNotifier{url: webhook.URL}}, client: webhook.Client()},
+		tilesChanged: make(chan struct{}, 1),
+	}
+	s.k8s.Store(NewK8sClientFor(clientset))
+	s.providers = []Provider{ingressProvider{s: s}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchForChanges(ctx, time.Hour)
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-listed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+	expect := func(kind EventKind, title string) {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Kind != kind || event.Title != title {
+				t.Errorf("got %s %q, want %s %q", event.Kind, event.Title, kind, title)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", title)
+		}
+	}
+	wait("the baseline")
+
+	ingresses := clientset.NetworkingV1().Ingresses("monitoring")
+	if _, err := ingresses.Create(ctx, testIngress("monitoring", "grafana", "grafana.example.com", "", nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	s.tilesChanged <- struct{}{}
+	expect(EventIngressAdded, "New service: grafana")
+
+	if err := ingresses.Delete(ctx, "grafana", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	s.tilesChanged <- struct{}{}
+	expect(EventIngressRemoved, "Service removed: grafana")
+
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %+v", event)
+	default:
+	}
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Onboarding is the setup guide shown instead of an empty homepage when
+// GoHome is connected to a cluster but has nothing to show. Everything in it
+// is generated from the live configuration so it can be applied as is.
+type Onboarding struct {
+	Namespace    string
+	ConfigMap    string
+	ConfigMapErr string // why the ConfigMap couldn't be read; empty if it was
+	IngressErr   string // why ingresses couldn't be listed; empty if they were
+
+	ConfigMapYAML string
+	IngressYAML   string
+	RBACYAML      string
+}
+
+// needsOnboarding reports whether the page would be empty: no ingresses and
+// no bookmarks of the visitor's own. The built-in example bookmarks used
+// while the ConfigMap can't be read don't count.
+func needsOnboarding(apps, services []IngressInfo, config *Config) bool {
+	return len(apps) == 0 && len(services) == 0 && (len(config.Bookmarks) == 0 || config.Bookmarks[0].Sample)
+}
+
+// buildOnboarding fills in the setup guide for the ConfigMap bm reads and
+// the service account GoHome runs as.
+func (s *Server) buildOnboarding(ctx context.Context) *Onboarding {
+	namespace, name, _ := strings.Cut(s.bookmarkManager.ConfigMapRef(), "/")
+	o := &Onboarding{Namespace: namespace, ConfigMap: name}
+	if err := s.bookmarkManager.LoadStatus().Err; err != nil {
+		o.ConfigMapErr = err.Error()
+	}
+	if err := s.kube().SyncStatus().Err; err != nil {
+		o.IngressErr = err.Error()
+	}
+
+	saNamespace, saName := s.kube().serviceAccount(ctx)
+	if saName == "" {
+		saNamespace, saName = namespace, "gohome"
+	}
+
+	o.ConfigMapYAML = fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  namespace: %s
+data:
+  title: "Go Home"
+  # bookmark-<name>: "url|category|tags=a,b|icon=si:<slug>"
+  bookmark-kubernetes-docs: "https://kubernetes.io/docs/|Docs"
+  bookmark-hacker-news: "https://news.ycombinator.com|News|icon=si:ycombinator"
+`, name, namespace)
+
+	o.IngressYAML = `metadata:
+  annotations:
+    gohome.stringer.sh/app: "true"        # list under Apps instead of Services
+    gohome.stringer.sh/name: "Grafana"    # display name
+    gohome.stringer.sh/icon: "si:grafana" # tile icon
+    gohome.stringer.sh/hide: "true"       # leave this ingress off the homepage
+`
+
+	o.RBACYAML = fmt.Sprintf(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: gohome-reader
+rules:
+  - apiGroups: ["networking.k8s.io"]
+    resources: ["ingresses"]
+    verbs: ["get", "list", "watch"]
+  - apiGroups: [""]
+    resources: ["configmaps"]
+    verbs: ["get", "list", "watch"]
+  - apiGroups: ["discovery.k8s.io"]
+    resources: ["endpointslices"]
+    verbs: ["list"]
+  - apiGroups: [""]
+    resources: ["configmaps"]
+    resourceNames: ["%s"]
+    verbs: ["update"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: gohome-reader-binding
+subjects:
+  - kind: ServiceAccount
+    name: %s
+    namespace: %s
+roleRef:
+  kind: ClusterRole
+  name: gohome-reader
+  apiGroup: rbac.authorization.k8s.io
+`, name, saName, saNamespace)
+	return o
+}
+
+// serviceAccount asks the API server who GoHome is authenticated as and
+// returns the service account's namespace and name, or empty strings when
+// that isn't a service account or the cluster doesn't support the review.
+func (k *K8sClient) serviceAccount(ctx context.Context) (namespace, name string) {
+	if k == nil || k.clientset == nil {
+		return "", ""
+	}
+	review, err := k.clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return "", ""
+	}
+	rest, ok := strings.CutPrefix(review.Status.UserInfo.Username, "system:serviceaccount:")
+	if !ok {
+		return "", ""
+	}
+	namespace, name, _ = strings.Cut(rest, ":")
+	return namespace, name
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// orderKeyPrefix marks ConfigMap keys holding a custom tile order, one key per
+// group: "order-apps", "order-services" or "order-cat-<category>". The value
+// is a newline-separated list of tile IDs (see ingressTileID and
+// bookmarkTileID), written by the drag-and-drop API. Tiles not listed keep
+// their default position after the listed ones.
+const orderKeyPrefix = "order-"
+
+// groupIDPattern matches the group IDs accepted by the order API. It is also
+// a valid ConfigMap key suffix.
+var groupIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ingressTileID identifies an ingress tile across reloads.
+func ingressTileID(info IngressInfo) string {
+	return ingressKey(info)
+}
+
+// bookmarkTileID identifies a bookmark tile across reloads.
+func bookmarkTileID(b Bookmark) string {
+	return "bookmark/" + b.Name
+}
+
+// parseOrder reads the order-* keys from ConfigMap data.
+func parseOrder(data map[string]string) map[string][]string {
+	order := make(map[string][]string)
+	for key, value := range data {
+		group, ok := strings.CutPrefix(key, orderKeyPrefix)
+		if !ok || group == "" {
+			continue
+		}
+		order[group] = splitLines(value)
+	}
+	return order
+}
+
+// splitLines splits a newline-separated list, trimming whitespace and
+// dropping empty lines.
+func splitLines(value string) []string {
+	var items []string
+	for line := range strings.Lines(value) {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
+
+// applyOrder stably reorders items so those named in order come first, in
+// that order. Items not in order keep their relative position after them.
+func applyOrder[T any](items []T, order []string, id func(T) string) {
+	if len(order) == 0 {
+		return
+	}
+	rank := make(map[string]int, len(order))
+	for i, key := range order {
+		rank[key] = i
+	}
+	position := func(item T) int {
+		if r, ok := rank[id(item)]; ok {
+			return r
+		}
+		return len(order)
+	}
+	slices.SortStableFunc(items, func(a, b T) int {
+		return position(a) - position(b)
+	})
+}
+
+// SaveOrder persists the tile order for a group into the ConfigMap and
+// returns the order it replaced, and the change to the ConfigMap. In demo
+// mode there is no ConfigMap, so the order is kept in memory instead and
+// the change is empty.
+func (bm *BookmarkManager) SaveOrder(ctx context.Context, group string, ids []string) (previous []string, change ConfigChange, err error) {
+	if bm.client() == nil {
+		bm.loadMu.Lock()
+		defer bm.loadMu.Unlock()
+		if bm.demoOrder == nil {
+			bm.demoOrder = make(map[string][]string)
+		}
+		previous = bm.demoOrder[group]
+		bm.demoOrder[group] = ids
+		return previous, ConfigChange{}, nil
+	}
+
+	change, err = bm.updateData(ctx, func(data map[string]string) {
+		if len(ids) == 0 {
+			delete(data, orderKeyPrefix+group)
+		} else {
+			data[orderKeyPrefix+group] = strings.Join(ids, "\n") + "\n"
+		}
+	})
+	return splitLines(change.Before[orderKeyPrefix+group]), change, err
+}
+
+// orderRequest is the JSON body accepted by PUT /api/v1/order/{group}.
+type orderRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// handleSaveOrder stores a custom tile order for one group, as produced by
+// dragging tiles around on the homepage. An empty list restores the default.
+func (s *Server) handleSaveOrder(w http.ResponseWriter, r *http.Request) {
+	group := r.PathValue("group")
+	if !groupIDPattern.MatchString(group) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid group"})
+		return
+	}
+
+	var req orderRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+	for _, id := range req.IDs {
+		if strings.ContainsAny(id, "\r\n") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "tile IDs may not contain newlines"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	previous, change, err := s.bookmarkManager.SaveOrder(ctx, group, req.IDs)
+	if err != nil {
+		log.Printf("Warning: Could not save order for %s: %v", group, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	actor := s.auditActor(r)
+	s.audit.Record(ctx, actor, "order.save", group, previous, req.IDs)
+	s.revisions.Record(ctx, actor, "order.save", change)
+
+	log.Printf("Saved custom order for %s (%d tiles)", group, len(req.IDs))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyTileOrder reorders the page's tiles in place according to the
+// configured order.
+func applyTileOrder(config *Config, apps, services []IngressInfo, categories []BookmarkCategory) {
+	applyOrder(apps, config.Order["apps"], ingressTileID)
+	applyOrder(services, config.Order["services"], ingressTileID)
+	for _, category := range categories {
+		applyOrder(category.Bookmarks, config.Order[category.ID], bookmarkTileID)
+	}
+}
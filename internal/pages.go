@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const pageKeyPrefix = "page-"
+
+// pageSlug is what a page-<slug> key may use as its route.
+var pageSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// reservedPages are first path segments already served by other routes, so a
+// page with that slug could never be reached.
+var reservedPages = map[string]bool{
+	"api": true, "click": true, "favicons": true, "go": true, "health": true, "healthz": true,
+	"icons": true, "images": true, "kiosk": true, "metrics": true, "ns": true, "preferences": true, "qr": true,
+	"static": true, "status": true, "theme": true, "version": true,
+}
+
+// Page is an extra homepage at /<slug> showing a subset of the tiles.
+// Ingresses are narrowed by Namespaces and Selector, bookmarks by
+// Categories; a page that filters only one kind leaves the other out, and a
+// page without any filters shows everything.
+type Page struct {
+	Slug       string
+	Title      string
+	Namespaces []string
+	Categories []string // category IDs, see categoryID
+	Selector   labels.Selector
+	Namespace  string // set for /ns/<namespace>, whose bookmarks are the namespace's own and kept as they are
+}
+
+// parsePageEntry parses a page-<slug> ConfigMap value of optional
+// |key=value settings, e.g.
+// "title=Media|namespaces=jellyfin,arr|categories=Streaming|labels=tier=media".
+func parsePageEntry(key, value string) (Page, bool) {
+	page := Page{Slug: strings.TrimPrefix(key, pageKeyPrefix)}
+	if !pageSlug.MatchString(page.Slug) || reservedPages[page.Slug] {
+		log.Printf("Warning: %s is not a usable page route, skipping", key)
+		return page, false
+	}
+	for _, opt := range strings.Split(value, "|") {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		v = strings.TrimSpace(v)
+		switch k {
+		case "":
+		case "title":
+			page.Title = v
+		case "namespaces":
+			page.Namespaces = splitList(v)
+		case "categories":
+			for _, c := range splitList(v) {
+				page.Categories = append(page.Categories, categoryID(c))
+			}
+		case "labels":
+			selector, err := labels.Parse(v)
+			if err != nil {
+				log.Printf("Warning: %s has invalid labels %q: %v", key, v, err)
+				return page, false
+			}
+			page.Selector = selector
+		default:
+			log.Printf("Warning: %s has unknown option %q", key, opt)
+		}
+	}
+	if page.Title == "" {
+		page.Title = cases.Title(language.English).String(strings.ReplaceAll(page.Slug, "-", " "))
+	}
+	return page, true
+}
+
+// parsePages reads every page-* key from ConfigMap data, sorted by slug.
+func parsePages(data map[string]string) []Page {
+	var pages []Page
+	for key, value := range data {
+		if !strings.HasPrefix(key, pageKeyPrefix) {
+			continue
+		}
+		if page, ok := parsePageEntry(key, value); ok {
+			pages = append(pages, page)
+		}
+	}
+	slices.SortFunc(pages, func(a, b Page) int { return strings.Compare(a.Slug, b.Slug) })
+	return pages
+}
+
+// findPage returns the configured page with the given slug, or nil.
+func findPage(pages []Page, slug string) *Page {
+	for i := range pages {
+		if pages[i].Slug == slug {
+			return &pages[i]
+		}
+	}
+	return nil
+}
+
+// filter returns the tiles that belong on the page.
+func (p *Page) filter(apps, services []IngressInfo, bookmarks []Bookmark) ([]IngressInfo, []IngressInfo, []Bookmark) {
+	filtersIngresses := len(p.Namespaces) > 0 || p.Selector != nil
+	filtersBookmarks := len(p.Categories) > 0
+	if !filtersIngresses && !filtersBookmarks {
+		return apps, services, bookmarks
+	}
+
+	keep := func(info IngressInfo) bool {
+		if !filtersIngresses {
+			return false
+		}
+		if len(p.Namespaces) > 0 && !slices.Contains(p.Namespaces, info.Namespace) {
+			return false
+		}
+		return p.Selector == nil || p.Selector.Matches(labels.Set(info.Labels))
+	}
+	apps = slices.DeleteFunc(apps, func(info IngressInfo) bool { return !keep(info) })
+	services = slices.DeleteFunc(services, func(info IngressInfo) bool { return !keep(info) })
+	if p.Namespace != "" {
+		return apps, services, bookmarks
+	}
+	bookmarks = slices.DeleteFunc(bookmarks, func(b Bookmark) bool {
+		return !filtersBookmarks || !slices.Contains(p.Categories, categoryID(b.Category))
+	})
+	return apps, services, bookmarks
+}
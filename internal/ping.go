@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Protocol numbers of ICMP and ICMPv6, for parsing replies.
+const (
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
+
+// pingSeq numbers echo requests so concurrent pings can't take each other's
+// replies.
+var pingSeq atomic.Uint32
+
+// pingNetworks are the sockets a ping is tried over, in order: unprivileged
+// ICMP datagram sockets, which Linux allows for groups in
+// net.ipv4.ping_group_range, then raw sockets, which need CAP_NET_RAW.
+var pingNetworks = map[bool][][2]string{
+	false: {{"udp4", "0.0.0.0"}, {"ip4:icmp", "0.0.0.0"}},
+	true:  {{"udp6", "::"}, {"ip6:ipv6-icmp", "::"}},
+}
+
+// ping sends one ICMP echo request to the host of rawURL and waits for the
+// reply until ctx is done, returning the round trip time.
+func ping(ctx context.Context, rawURL string) (time.Duration, error) {
+	host, _ := targetHostPort(rawURL)
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return 0, err
+	}
+	if len(addrs) == 0 {
+		return 0, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	ip := addrs[0].IP
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			ip = addr.IP
+			break
+		}
+	}
+	v6 := ip.To4() == nil
+
+	var conn *icmp.PacketConn
+	var dst net.Addr
+	for _, network := range pingNetworks[v6] {
+		if conn, err = icmp.ListenPacket(network[0], network[1]); err == nil {
+			dst = &net.IPAddr{IP: ip}
+			if network[0][:3] == "udp" {
+				dst = &net.UDPAddr{IP: ip}
+			}
+			break
+		}
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return 0, errors.New("not permitted to send pings")
+		}
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	token := make([]byte, 16)
+	rand.Read(token)
+	seq := int(pingSeq.Add(1) & 0xffff)
+	var typ, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	proto := protocolICMP
+	if v6 {
+		typ, reply, proto = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, protocolICMPv6
+	}
+	request, err := (&icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{ID: os.Getpid() & 0xffff, Seq: seq, Data: token},
+	}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(request, dst); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		// Raw sockets see every reply to the host, and datagram sockets
+		// rewrite the ID, so match on the sequence number and payload.
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || msg.Type != reply {
+			continue
+		}
+		if echo, ok := msg.Body.(*icmp.Echo); ok && echo.Seq == seq && bytes.Equal(echo.Data, token) {
+			return time.Since(start), nil
+		}
+	}
+}
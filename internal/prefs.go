@@ -0,0 +1,404 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Preferences are per-visitor display settings, stored client-side in the
+// signed session cookie (see Sessions) so they survive reloads and are
+// available to the server for the initial render (avoiding a flash of the
+// wrong theme).
+type Preferences struct {
+	Theme   string // "auto", "light" or "dark"; empty means use the configured default
+	Palette string // one of PaletteNames(); empty means use the configured default
+	Layout  string // "grid" or "list"; empty means use the configured default
+	GroupBy string // one of Groupings; empty means use the configured default
+
+	// Collapsed holds the group IDs the visitor has collapsed. CollapsedSet
+	// distinguishes "never toggled anything" (use the configured defaults)
+	// from "expanded everything".
+	Collapsed    []string
+	CollapsedSet bool
+
+	// Favorites holds the tile IDs the visitor has pinned, in display order.
+	Favorites []string
+
+	// Hidden holds the tile IDs the visitor has hidden; ShowHidden renders
+	// them anyway (dimmed) so they can be restored.
+	Hidden     map[string]bool
+	ShowHidden bool
+
+	// Dismissed holds the IDs of announcements the visitor has closed.
+	Dismissed map[string]bool
+
+	// Recent holds the tile IDs the visitor opened through /click, newest first.
+	Recent []string
+}
+
+// The cookies below held preferences before the session cookie. They are
+// unsigned, so they are only read from browsers without a session, once,
+// to move them over to one, and then expired.
+const (
+	// themeCookie holds the visitor's theme choice, written by the toggle in static/app.js.
+	themeCookie = "gohome_theme"
+	// paletteCookie holds the visitor's palette choice, written by the picker in static/app.js.
+	paletteCookie = "gohome_palette"
+	// layoutCookie holds the visitor's layout choice, written by the toggle in static/app.js.
+	layoutCookie = "gohome_layout"
+	// groupByCookie holds the visitor's grouping choice, written by the selector in static/app.js.
+	groupByCookie = "gohome_group_by"
+	// collapsedCookie holds the group IDs the visitor has collapsed, as a list cookie.
+	collapsedCookie = "gohome_collapsed"
+	// favoritesCookie holds the tile IDs the visitor has pinned, as a list cookie.
+	favoritesCookie = "gohome_favorites"
+	// hiddenCookie holds the tile IDs the visitor has hidden, as a list cookie.
+	hiddenCookie = "gohome_hidden"
+	// showHiddenCookie is "1" while the "show hidden" toggle is on.
+	showHiddenCookie = "gohome_show_hidden"
+	// dismissedCookie holds the IDs of dismissed announcements, as a list cookie.
+	dismissedCookie = "gohome_dismissed"
+	// recentCookie holds the tile IDs the visitor opened most recently, newest
+	// first, as a list cookie written by handleClick.
+	recentCookie = "gohome_recent"
+)
+
+// preferenceCookies are all the cookies that made up Preferences.
+var preferenceCookies = []string{
+	themeCookie, paletteCookie, layoutCookie, groupByCookie, collapsedCookie, favoritesCookie,
+	hiddenCookie, showHiddenCookie, dismissedCookie, recentCookie,
+}
+
+// validLayouts lists the accepted values for the layout setting.
+var validLayouts = map[string]bool{"grid": true, "list": true}
+
+// validCompact lists the accepted values for the compact setting.
+var validCompact = map[string]bool{"auto": true, "always": true, "never": true}
+
+// validThemes lists the accepted values for the theme setting.
+var validThemes = map[string]bool{"auto": true, "light": true, "dark": true, "contrast": true}
+
+// loadPreferences reads the visitor's preferences from their session
+// cookie, or else, until they are moved over, the cookies that held them
+// before. ok reports whether there were any.
+func (s *Server) loadPreferences(r *http.Request) (prefs Preferences, ok bool) {
+	if prefs, ok := s.sessions.Load(r); ok {
+		return prefs, true
+	}
+	if !hasLegacyPreferences(r) {
+		return Preferences{}, false
+	}
+	return legacyPreferences(r), true
+}
+
+// hasLegacyPreferences reports whether r carries any of the cookies that
+// held preferences before the session cookie.
+func hasLegacyPreferences(r *http.Request) bool {
+	return slices.ContainsFunc(preferenceCookies, func(name string) bool {
+		_, err := r.Cookie(name)
+		return err == nil
+	})
+}
+
+// savePreferences writes prefs as the visitor's session cookie and expires
+// any preference cookies from before it.
+func (s *Server) savePreferences(w http.ResponseWriter, r *http.Request, prefs Preferences) error {
+	if err := s.sessions.Save(w, r, prefs); err != nil {
+		return err
+	}
+	for _, name := range preferenceCookies {
+		if _, err := r.Cookie(name); err == nil {
+			http.SetCookie(w, &http.Cookie{Name: name, Path: "/", MaxAge: -1})
+		}
+	}
+	return nil
+}
+
+// legacyPreferences reads the cookies that held preferences before the
+// session cookie, ignoring any values that aren't recognised.
+func legacyPreferences(r *http.Request) Preferences {
+	var prefs Preferences
+	if c, err := r.Cookie(themeCookie); err == nil {
+		prefs.Theme = c.Value
+	}
+	if c, err := r.Cookie(paletteCookie); err == nil {
+		prefs.Palette = c.Value
+	}
+	if c, err := r.Cookie(layoutCookie); err == nil {
+		prefs.Layout = c.Value
+	}
+	if c, err := r.Cookie(groupByCookie); err == nil {
+		prefs.GroupBy = c.Value
+	}
+	prefs.Collapsed, prefs.CollapsedSet = listCookie(r, collapsedCookie)
+	prefs.Favorites, _ = listCookie(r, favoritesCookie)
+	prefs.Recent, _ = listCookie(r, recentCookie)
+	if hidden, _ := listCookie(r, hiddenCookie); len(hidden) > 0 {
+		prefs.Hidden = setOf(hidden)
+	}
+	if c, err := r.Cookie(showHiddenCookie); err == nil && c.Value == "1" {
+		prefs.ShowHidden = true
+	}
+	if dismissed, _ := listCookie(r, dismissedCookie); len(dismissed) > 0 {
+		prefs.Dismissed = setOf(dismissed)
+	}
+	return sanitizePreferences(prefs)
+}
+
+// sanitizePreferences clears the settings that aren't recognised.
+func sanitizePreferences(prefs Preferences) Preferences {
+	if !validThemes[prefs.Theme] {
+		prefs.Theme = ""
+	}
+	if !validPalette(prefs.Palette) {
+		prefs.Palette = ""
+	}
+	if !validLayouts[prefs.Layout] {
+		prefs.Layout = ""
+	}
+	if !validGroupings[prefs.GroupBy] {
+		prefs.GroupBy = ""
+	}
+	return prefs
+}
+
+// setOf returns items as a set.
+func setOf(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// viewerPreferences reads the visitor's preferences from their session.
+// With a data store and a tailnet login they also follow the visitor from
+// one browser to the next: a browser without a session starts from the
+// preferences stored for that login, and any other has its preferences
+// stored unless READ_ONLY=true. Browsers still on the old preference
+// cookies are moved over to a session.
+func (s *Server) viewerPreferences(w http.ResponseWriter, r *http.Request, login string) Preferences {
+	prefs, found := s.sessions.Load(r)
+	if !found && hasLegacyPreferences(r) {
+		prefs, found = legacyPreferences(r), true
+		if err := s.savePreferences(w, r, prefs); err != nil {
+			log.Printf("Warning: Could not move preferences to a session: %v", err)
+		}
+	}
+	if s.store == nil || login == "" {
+		return prefs
+	}
+	stored, storedFound, err := s.store.Get(r.Context(), storeNamespacePrefs, login)
+	if err != nil {
+		log.Printf("Warning: Could not load preferences for %s: %v", login, err)
+		return prefs
+	}
+
+	if !found {
+		var restored Preferences
+		if !storedFound || json.Unmarshal(stored, &restored) != nil {
+			return prefs
+		}
+		restored = sanitizePreferences(restored)
+		if err := s.savePreferences(w, r, restored); err != nil {
+			log.Printf("Warning: Could not restore preferences for %s: %v", login, err)
+		}
+		return restored
+	}
+
+	if s.readOnly {
+		return prefs
+	}
+	if raw, err := json.Marshal(prefs); err == nil && !bytes.Equal(raw, stored) {
+		if err := s.store.Set(r.Context(), storeNamespacePrefs, login, raw); err != nil {
+			log.Printf("Warning: Could not save preferences for %s: %v", login, err)
+		}
+	}
+	return prefs
+}
+
+// preferenceUpdate is the body of POST /preferences: the settings to
+// change, named as in static/app.js. Lists replace the stored ones.
+type preferenceUpdate struct {
+	Theme      *string   `json:"theme"`
+	Palette    *string   `json:"palette"`
+	Layout     *string   `json:"layout"`
+	GroupBy    *string   `json:"group_by"`
+	ShowHidden *string   `json:"show_hidden"` // "1" or "0"
+	Collapsed  *[]string `json:"collapsed"`
+	Favorites  *[]string `json:"favorites"`
+	Hidden     *[]string `json:"hidden"`
+	Dismissed  *[]string `json:"dismissed"`
+}
+
+// apply changes prefs as u says, or returns an error for a value that isn't
+// recognised.
+func (u preferenceUpdate) apply(prefs *Preferences) error {
+	for _, setting := range []struct {
+		name  string
+		value *string
+		valid func(string) bool
+		dst   *string
+	}{
+		{"theme", u.Theme, func(v string) bool { return validThemes[v] }, &prefs.Theme},
+		{"palette", u.Palette, validPalette, &prefs.Palette},
+		{"layout", u.Layout, func(v string) bool { return validLayouts[v] }, &prefs.Layout},
+		{"group_by", u.GroupBy, func(v string) bool { return validGroupings[v] }, &prefs.GroupBy},
+	} {
+		if setting.value == nil {
+			continue
+		}
+		if !setting.valid(*setting.value) {
+			return fmt.Errorf("invalid %s %q", setting.name, *setting.value)
+		}
+		*setting.dst = *setting.value
+	}
+	if u.ShowHidden != nil {
+		prefs.ShowHidden = *u.ShowHidden == "1"
+	}
+	if u.Collapsed != nil {
+		prefs.Collapsed, prefs.CollapsedSet = *u.Collapsed, true
+	}
+	if u.Favorites != nil {
+		prefs.Favorites = *u.Favorites
+	}
+	if u.Hidden != nil {
+		prefs.Hidden = setOf(*u.Hidden)
+	}
+	if u.Dismissed != nil {
+		prefs.Dismissed = setOf(*u.Dismissed)
+	}
+	return nil
+}
+
+// handlePreferences serves POST /preferences, which static/app.js uses to
+// change the visitor's preferences, since it can't touch the session
+// cookie. Only JSON is accepted, which a cross-site form can't send, and
+// the cookie is SameSite, so other sites can't change them.
+func (s *Server) handlePreferences(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "expected application/json"})
+		return
+	}
+	var update preferenceUpdate
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSessionBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&update); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	prefs, _ := s.loadPreferences(r)
+	if err := update.apply(&prefs); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.savePreferences(w, r, prefs); err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientPreferences are the lists static/app.js edits in place, rendered
+// into the page since it can't read the session cookie.
+func clientPreferences(prefs Preferences) map[string][]string {
+	return map[string][]string{
+		"favorites": slices.Clone(prefs.Favorites),
+		"hidden":    slices.Sorted(maps.Keys(prefs.Hidden)),
+		"dismissed": slices.Sorted(maps.Keys(prefs.Dismissed)),
+	}
+}
+
+// listCookie reads a cookie written by setListPreference in static/app.js
+// before the session cookie: a comma-separated list of URL-escaped items. ok
+// reports whether the cookie was present at all, even if empty.
+func listCookie(r *http.Request, name string) (items []string, ok bool) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return nil, false
+	}
+	for raw := range strings.SplitSeq(c.Value, ",") {
+		if item, err := url.PathUnescape(raw); err == nil && item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, true
+}
+
+// resolveTheme picks the visitor's theme if set, otherwise the configured
+// default, falling back to "auto" (follow the OS preference).
+func resolveTheme(prefs Preferences, config *Config) string {
+	if prefs.Theme != "" {
+		return prefs.Theme
+	}
+	if validThemes[config.Theme] {
+		return config.Theme
+	}
+	return "auto"
+}
+
+// resolvePalette picks the visitor's palette if set, otherwise the configured
+// default, falling back to the built-in look.
+func resolvePalette(prefs Preferences, config *Config) string {
+	if prefs.Palette != "" {
+		return prefs.Palette
+	}
+	if validPalette(config.Palette) {
+		return config.Palette
+	}
+	return DefaultPalette
+}
+
+// resolveCompact returns the configured compact mode, falling back to "auto"
+// (compact on narrow viewports, decided by static/app.js).
+func resolveCompact(config *Config) string {
+	if validCompact[config.Compact] {
+		return config.Compact
+	}
+	return "auto"
+}
+
+// resolveLayout picks the visitor's layout if set, otherwise the configured
+// default, falling back to the tile grid.
+func resolveLayout(prefs Preferences, config *Config) string {
+	if prefs.Layout != "" {
+		return prefs.Layout
+	}
+	if validLayouts[config.Layout] {
+		return config.Layout
+	}
+	return "grid"
+}
+
+// sectionIDs are the collapse-state identifiers of the top-level sections.
+var sectionIDs = map[string]bool{"favorites": true, "recent": true, "most-used": true, "apps": true, "services": true, "bookmarks": true, "feeds": true, "notes": true, "calendar": true, "github": true, "promql": true, "grafana": true}
+
+// resolveCollapsed returns the set of group IDs that should render collapsed:
+// the visitor's own choice if they've made one, otherwise the configured
+// defaults, which may name sections or bookmark categories.
+func resolveCollapsed(prefs Preferences, config *Config) map[string]bool {
+	collapsed := make(map[string]bool)
+	if prefs.CollapsedSet {
+		for _, id := range prefs.Collapsed {
+			collapsed[id] = true
+		}
+		return collapsed
+	}
+	for _, name := range config.Collapsed {
+		if id := slugify(name); sectionIDs[id] {
+			collapsed[id] = true
+		} else {
+			collapsed[categoryID(name)] = true
+		}
+	}
+	return collapsed
+}
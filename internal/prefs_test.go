@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// roundTrip saves prefs through s and returns the request a browser would
+// send with the cookies it got back.
+func roundTrip(t *testing.T, s *Server, prefs Preferences) *http.Request {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if err := s.savePreferences(w, httptest.NewRequest(http.MethodGet, "/", nil), prefs); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge >= 0 {
+			r.AddCookie(c)
+		}
+	}
+	return r
+}
+
+func TestPreferencesNeedTheSessionKey(t *testing.T) {
+	prefs := Preferences{
+		Theme:        "dark",
+		Layout:       "list",
+		Collapsed:    []string{"services"},
+		CollapsedSet: true,
+		Favorites:    []string{"ingress:media/jellyfin", "bookmark:a,b"},
+		Hidden:       map[string]bool{"ingress:ai/open-webui": true},
+		ShowHidden:   true,
+	}
+
+	before := &Server{sessions: &Sessions{key: newSessionKey()}}
+	r := roundTrip(t, before, prefs)
+	if got, ok := before.loadPreferences(r); !ok || !reflect.DeepEqual(got, prefs) {
+		t.Fatalf("same key: got %+v, %v; want %+v", got, ok, prefs)
+	}
+	for _, c := range r.Cookies() {
+		if c.Name != sessionCookie {
+			t.Errorf("unsigned cookie %s written", c.Name)
+		}
+	}
+
+	// Nothing unsigned is left to fall back on with another key.
+	after := &Server{sessions: &Sessions{key: newSessionKey()}}
+	if got, ok := after.loadPreferences(r); ok {
+		t.Errorf("new key: got %+v, want no preferences", got)
+	}
+}
+
+func TestLegacyCookiesMovedToSession(t *testing.T) {
+	s := &Server{sessions: &Sessions{key: newSessionKey()}}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: themeCookie, Value: "light"})
+	r.AddCookie(&http.Cookie{Name: favoritesCookie, Value: "ingress:media%2Fjellyfin"})
+	w := httptest.NewRecorder()
+	want := Preferences{Theme: "light", Favorites: []string{"ingress:media/jellyfin"}}
+	if got := s.viewerPreferences(w, r, ""); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	next := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		switch {
+		case c.Name == sessionCookie:
+			next.AddCookie(c)
+		case c.MaxAge >= 0:
+			t.Errorf("%s not expired", c.Name)
+		}
+	}
+	if got, ok := s.sessions.Load(next); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("session has %+v, %v; want %+v", got, ok, want)
+	}
+}
+
+func TestSaveExpiresLegacyCookies(t *testing.T) {
+	s := &Server{sessions: &Sessions{key: newSessionKey()}}
+	save := httptest.NewRequest(http.MethodGet, "/", nil)
+	save.AddCookie(&http.Cookie{Name: themeCookie, Value: "light"})
+	w := httptest.NewRecorder()
+	if err := s.savePreferences(w, save, Preferences{Theme: "dark"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range w.Result().Cookies() {
+		switch c.Name {
+		case sessionCookie:
+		case themeCookie:
+			if c.MaxAge >= 0 {
+				t.Errorf("%s not expired", c.Name)
+			}
+		default:
+			t.Errorf("unexpected cookie %s", c.Name)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// promqlKeyPrefix marks ConfigMap keys that define a stat:
+	//   promql-<name>: "<query>|unit=%|decimals=1|trend=1h"
+	promqlKeyPrefix = "promql-"
+	// defaultPrometheusInterval is how often every query is re-run.
+	defaultPrometheusInterval = time.Minute
+	// promqlTrendPoints is how many samples a trend line is drawn from.
+	promqlTrendPoints = 30
+	// maxPrometheusBytes caps the size of a query response.
+	maxPrometheusBytes = 1 << 20
+)
+
+// PromQLConfig is one stat from the ConfigMap.
+type PromQLConfig struct {
+	Name     string
+	Query    string
+	Unit     string        // appended to the value, e.g. "%" or "ms"
+	Decimals int           // digits after the point; -1 picks by magnitude
+	Trend    time.Duration // range of the trend line under the value; zero for none
+}
+
+// key identifies the cached result of c; changing the query or the trend
+// starts over.
+func (c PromQLConfig) key() string {
+	return c.Query + "\x00" + c.Trend.String()
+}
+
+// PrometheusConfig configures the optional Prometheus widget, from the
+// prometheus-url and promql-<name> ConfigMap keys, and the request rates
+// on tiles, from ingress-traffic. Credentials come from
+// PROMETHEUS_TOKEN, or PROMETHEUS_USERNAME and PROMETHEUS_PASSWORD, so they
+// can live in a Secret.
+type PrometheusConfig struct {
+	URL            string
+	Queries        []PromQLConfig // sorted by name
+	IngressTraffic bool           // ingress-traffic: "true" shows ingress-nginx request rates on tiles
+}
+
+// PromStat is a query result as rendered on the homepage.
+type PromStat struct {
+	Name    string
+	Value   string // formatted with its unit; empty until a query succeeds
+	Trend   string // SVG polyline points in a 100×20 box; empty without a trend
+	Err     string // set when the most recent run failed
+	Fetched time.Time
+}
+
+// promResult is a query result as fetched.
+type promResult struct {
+	value float64
+	trend []float64
+}
+
+// PromQLFetcher runs the configured PromQL queries in the background and
+// caches their results, so the homepage never waits on Prometheus.
+type PromQLFetcher struct {
+	client             *http.Client
+	token              string
+	username, password string
+	interval           time.Duration
+	stats              widgetCache[promResult] // keyed by PromQLConfig.key
+	mu                 sync.Mutex
+	traffic            map[string]hostTraffic // by host; nil unless ingress-traffic is on
+	trafficAttempted   time.Time
+}
+
+// NewPromQLFetcherFromEnv creates a fetcher that re-runs queries every
+// PROMETHEUS_INTERVAL.
+func NewPromQLFetcherFromEnv() *PromQLFetcher {
+	return &PromQLFetcher{
+		client:   &http.Client{Timeout: 15 * time.Second},
+		token:    os.Getenv("PROMETHEUS_TOKEN"),
+		username: os.Getenv("PROMETHEUS_USERNAME"),
+		password: os.Getenv("PROMETHEUS_PASSWORD"),
+		interval: durationFromEnv("PROMETHEUS_INTERVAL", defaultPrometheusInterval),
+	}
+}
+
+// parsePrometheus reads prometheus-url and every promql-* key from ConfigMap
+// data.
+func parsePrometheus(data map[string]string) PrometheusConfig {
+	cfg := PrometheusConfig{
+		URL:            strings.TrimSuffix(strings.TrimSpace(data["prometheus-url"]), "/"),
+		IngressTraffic: data["ingress-traffic"] == "true",
+	}
+	for key, value := range data {
+		if !strings.HasPrefix(key, promqlKeyPrefix) {
+			continue
+		}
+		if query, ok := parsePromQLEntry(key, value); ok {
+			cfg.Queries = append(cfg.Queries, query)
+		}
+	}
+	if (len(cfg.Queries) > 0 || cfg.IngressTraffic) && cfg.URL == "" {
+		log.Printf("Warning: promql-* or ingress-traffic keys are set but prometheus-url isn't")
+	}
+	slices.SortFunc(cfg.Queries, func(a, b PromQLConfig) int { return strings.Compare(a.Name, b.Name) })
+	return cfg
+}
+
+// parsePromQLEntry parses a promql-<name> ConfigMap value: the query
+// followed by optional |key=value settings. Regex matchers such as
+// job=~"a|b" put "|" inside the query too, so only trailing parts that
+// start with a known setting are taken as settings.
+func parsePromQLEntry(key, value string) (PromQLConfig, bool) {
+	query := PromQLConfig{Name: strings.TrimPrefix(key, promqlKeyPrefix), Decimals: -1}
+	parts := strings.Split(value, "|")
+	n := len(parts)
+	for n > 1 {
+		k, _, _ := strings.Cut(strings.TrimSpace(parts[n-1]), "=")
+		if k != "unit" && k != "decimals" && k != "trend" && k != "label" {
+			break
+		}
+		n--
+	}
+	query.Query = strings.TrimSpace(strings.Join(parts[:n], "|"))
+	if query.Query == "" {
+		log.Printf("Warning: promql %s has no query, skipping", query.Name)
+		return query, false
+	}
+	for _, opt := range parts[n:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		v = strings.TrimSpace(v)
+		switch k {
+		case "unit":
+			query.Unit = v
+		case "label":
+			query.Name = v
+		case "decimals":
+			if d, err := strconv.Atoi(v); err == nil && d >= 0 && d <= 6 {
+				query.Decimals = d
+			} else {
+				log.Printf("Warning: promql %s has invalid decimals %q", query.Name, v)
+			}
+		case "trend":
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				query.Trend = d
+			} else {
+				log.Printf("Warning: promql %s has invalid trend %q", query.Name, v)
+			}
+		}
+	}
+	return query, true
+}
+
+// Name names the Prometheus widget.
+func (f *PromQLFetcher) Name() string { return "promql" }
+
+// Interval is a minute at most, so the configuration is re-read every
+// minute even with a longer PROMETHEUS_INTERVAL.
+func (f *PromQLFetcher) Interval() time.Duration { return min(f.interval, time.Minute) }
+
+// Refresh runs every query that is due, and the ingress-nginx queries with
+// ingress-traffic, and drops state for queries that are no longer
+// configured.
+func (f *PromQLFetcher) Refresh(ctx context.Context, config *Config) error {
+	cfg := config.Prometheus
+	if cfg.URL == "" {
+		cfg.Queries, cfg.IngressTraffic = nil, false
+	}
+	f.mu.Lock()
+	trafficDue := cfg.IngressTraffic && time.Since(f.trafficAttempted) >= f.interval
+	if !cfg.IngressTraffic {
+		f.traffic = nil
+	}
+	f.mu.Unlock()
+	var trafficErr error
+	var wg sync.WaitGroup
+	if trafficDue {
+		wg.Go(func() {
+			if err := f.fetchTraffic(ctx, cfg.URL); err != nil {
+				trafficErr = fmt.Errorf("ingress traffic: %w", err)
+			}
+		})
+	}
+
+	fetches := make([]widgetFetch[promResult], 0, len(cfg.Queries))
+	for _, query := range cfg.Queries {
+		fetches = append(fetches, widgetFetch[promResult]{
+			key:   query.key(),
+			name:  "PromQL query " + query.Name,
+			every: f.interval,
+			fetch: func(ctx context.Context) (promResult, error) {
+				value, err := f.instant(ctx, cfg.URL, query.Query)
+				if err != nil || query.Trend <= 0 {
+					return promResult{value: value}, err
+				}
+				trend, err := f.series(ctx, cfg.URL, query.Query, query.Trend)
+				return promResult{value: value, trend: trend}, err
+			},
+		})
+	}
+	err := f.stats.refresh(ctx, 30*time.Second, fetches)
+	wg.Wait()
+	return errors.Join(trafficErr, err)
+}
+
+// Render returns the Stats of the configured queries.
+func (f *PromQLFetcher) Render(config *Config, _ *Locale) any {
+	return f.Stats(config.Prometheus)
+}
+
+// Stats returns the cached results for cfg. Queries that haven't run yet
+// are left out.
+func (f *PromQLFetcher) Stats(cfg PrometheusConfig) []PromStat {
+	if f == nil || cfg.URL == "" {
+		return nil
+	}
+	var stats []PromStat
+	for _, query := range cfg.Queries {
+		state, ok := f.stats.get(query.key())
+		if !ok {
+			continue
+		}
+		stat := PromStat{Name: query.Name, Err: state.errString(), Fetched: state.fetched}
+		if !state.fetched.IsZero() {
+			stat.Value = formatStat(state.value.value, query.Decimals, query.Unit)
+			stat.Trend = trendPoints(state.value.trend)
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// Len returns the number of queries with cached state.
+func (f *PromQLFetcher) Len() int {
+	if f == nil {
+		return 0
+	}
+	return f.stats.Len()
+}
+
+// formatStat formats v with decimals digits, or by magnitude when decimals
+// is negative, followed by unit.
+func formatStat(v float64, decimals int, unit string) string {
+	if decimals < 0 {
+		switch abs := math.Abs(v); {
+		case abs >= 100 || v == math.Trunc(v):
+			decimals = 0
+		case abs >= 10:
+			decimals = 1
+		default:
+			decimals = 2
+		}
+	}
+	text := strconv.FormatFloat(v, 'f', decimals, 64)
+	if unit == "" || unit == "%" {
+		return text + unit
+	}
+	return text + " " + unit
+}
+
+// trendPoints scales values into a 100×20 box as SVG polyline points, with
+// the highest value at the top.
+func trendPoints(values []float64) string {
+	if len(values) < 2 {
+		return ""
+	}
+	lo, hi := slices.Min(values), slices.Max(values)
+	span := hi - lo
+	var b strings.Builder
+	for i, v := range values {
+		y := 10.0
+		if span > 0 {
+			y = 19 - 18*(v-lo)/span
+		}
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f", 100*float64(i)/float64(len(values)-1), y)
+	}
+	return b.String()
+}
+
+// promResponse is the envelope of the Prometheus HTTP API.
+type promResponse struct {
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+	ErrorType string `json:"errorType"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// instant runs query now and returns the value of its first series, or of
+// the scalar it evaluates to.
+func (f *PromQLFetcher) instant(ctx context.Context, baseURL, query string) (float64, error) {
+	data, err := f.get(ctx, baseURL+"/api/v1/query", url.Values{"query": {query}})
+	if err != nil {
+		return 0, err
+	}
+	var sample [2]any
+	switch data.Data.ResultType {
+	case "vector":
+		var result []struct {
+			Value [2]any `json:"value"`
+		}
+		if err := json.Unmarshal(data.Data.Result, &result); err != nil {
+			return 0, err
+		}
+		if len(result) == 0 {
+			return 0, errors.New("no data")
+		}
+		sample = result[0].Value
+	case "scalar":
+		if err := json.Unmarshal(data.Data.Result, &sample); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("query returns a %s, want a single number", data.Data.ResultType)
+	}
+	return sampleValue(sample)
+}
+
+// series runs query over the last span and returns promqlTrendPoints
+// values of its first series.
+func (f *PromQLFetcher) series(ctx context.Context, baseURL, query string, span time.Duration) ([]float64, error) {
+	end := time.Now()
+	step := max(span/promqlTrendPoints, time.Second)
+	data, err := f.get(ctx, baseURL+"/api/v1/query_range", url.Values{
+		"query": {query},
+		"start": {strconv.FormatInt(end.Add(-span).Unix(), 10)},
+		"end":   {strconv.FormatInt(end.Unix(), 10)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []struct {
+		Values [][2]any `json:"values"`
+	}
+	if err := json.Unmarshal(data.Data.Result, &result); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	values := make([]float64, 0, len(result[0].Values))
+	for _, sample := range result[0].Values {
+		v, err := sampleValue(sample)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// get calls a Prometheus API endpoint.
+func (f *PromQLFetcher) get(ctx context.Context, endpoint string, params url.Values) (*promResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gohome-promql")
+	req.Header.Set("Accept", "application/json")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	} else if f.username != "" {
+		req.SetBasicAuth(f.username, f.password)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var data promResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxPrometheusBytes)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if data.Status != "success" {
+		return nil, fmt.Errorf("%s: %s", cmp.Or(data.ErrorType, resp.Status), data.Error)
+	}
+	return &data, nil
+}
+
+// sampleValue returns the value of a [timestamp, "value"] sample.
+func sampleValue(sample [2]any) (float64, error) {
+	s, ok := sample[1].(string)
+	if !ok {
+		return 0, errors.New("malformed sample")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errors.New("no data")
+	}
+	return v, nil
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Provider is a discovery source: something GoHome lists tiles from, such
+// as the cluster's ingresses or the Consul catalog.
+type Provider interface {
+	// Name describes the source in logs, e.g. "Consul services".
+	Name() string
+	// Discover returns the tiles of the source. If it can't be read it
+	// returns the error together with the tiles from the last listing that
+	// worked, if any.
+	Discover(ctx context.Context) ([]IngressInfo, error)
+	// Watch calls changed whenever the source's tiles may have changed,
+	// until ctx is done. A source that can only be polled returns at once
+	// and is listed again when its cache expires.
+	Watch(ctx context.Context, changed func())
+}
+
+// explainer is a Provider that can also tell which of its objects it
+// leaves out and why, for DryRun.
+type explainer interface {
+	Explain(ctx context.Context) (tiles []IngressInfo, skipped []skippedIngress, err error)
+}
+
+// providerFactories are the discovery sources GoHome knows, in the order
+// their tiles are listed. Each returns nil when its source isn't
+// configured. A new source only needs an entry here.
+var providerFactories = []func(s *Server) Provider{
+	func(s *Server) Provider { return newIngressProviderFromEnv(s) },
+	func(*Server) Provider {
+		if c := NewConsulCatalogFromEnv(); c != nil {
+			return c
+		}
+		return nil
+	},
+	func(*Server) Provider {
+		if n := NewNomadServicesFromEnv(); n != nil {
+			return n
+		}
+		return nil
+	},
+	func(s *Server) Provider {
+		if t := NewTailnetDevicesFromEnv(); t != nil {
+			return tailnetProvider{s, t}
+		}
+		return nil
+	},
+}
+
+// newProviders returns the configured discovery sources of s.
+func newProviders(s *Server) []Provider {
+	var providers []Provider
+	for _, factory := range providerFactories {
+		if p := factory(s); p != nil {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// watchProviders runs the Watch of every provider until ctx is done,
+// sending on s.tilesChanged when one reports a change.
+func (s *Server) watchProviders(ctx context.Context) {
+	for _, p := range s.providers {
+		go p.Watch(ctx, func() {
+			select {
+			case s.tilesChanged <- struct{}{}:
+			default: // a change is already pending
+			}
+		})
+	}
+}
+
+// ingressProvider lists the ingresses of whichever client the server uses
+// at the time, so it follows a switch from demo mode to the cluster.
+type ingressProvider struct {
+	s *Server
+	// minRetry and maxRetry bound the wait before watching again after the
+	// watch fails, which doubles from one failure to the next.
+	minRetry, maxRetry time.Duration
+}
+
+// newIngressProviderFromEnv reads the watch backoff from WATCH_RETRY_MIN
+// and WATCH_RETRY_MAX.
+func newIngressProviderFromEnv(s *Server) ingressProvider {
+	minRetry := durationFromEnv("WATCH_RETRY_MIN", defaultWatchRetryMin)
+	maxRetry := durationFromEnv("WATCH_RETRY_MAX", defaultWatchRetryMax)
+	if maxRetry < minRetry {
+		log.Printf("Warning: WATCH_RETRY_MAX %s is below WATCH_RETRY_MIN, using %s", maxRetry, minRetry)
+		maxRetry = minRetry
+	}
+	return ingressProvider{s: s, minRetry: minRetry, maxRetry: maxRetry}
+}
+
+// Name is how logs refer to the source.
+func (p ingressProvider) Name() string { return "ingresses" }
+
+// Discover returns the visible ingresses, apps and services together.
+func (p ingressProvider) Discover(ctx context.Context) ([]IngressInfo, error) {
+	apps, services, err := p.s.kube().GetVisibleIngresses(ctx)
+	return append(apps, services...), err
+}
+
+// Explain lists the ingresses bypassing the cache, with those left out.
+// In demo mode it returns the samples.
+func (p ingressProvider) Explain(ctx context.Context) ([]IngressInfo, []skippedIngress, error) {
+	k := p.s.kube()
+	if k == nil {
+		tiles, err := p.Discover(ctx)
+		return tiles, nil, err
+	}
+	list, skipped, err := k.explainIngresses(ctx)
+	return append(list.Apps, list.Services...), skipped, err
+}
+
+// Default delays between attempts to watch the ingresses after the watch
+// fails.
+const (
+	defaultWatchRetryMin = 5 * time.Second
+	defaultWatchRetryMax = 5 * time.Minute
+)
+
+// Watch watches the ingresses through the API server, retrying with
+// backoff when the watch fails and polling for the cluster every minRetry
+// in demo mode.
+// Fixtures never change, so it returns at once for them.
+func (p ingressProvider) Watch(ctx context.Context, changed func()) {
+	backoff := p.minRetry
+	for {
+		k := p.s.kube()
+		if k != nil && k.clientset == nil {
+			return
+		}
+		wait := p.minRetry
+		if k != nil {
+			if err := k.watchIngresses(ctx, changed); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				wait = backoff
+				backoff = min(2*backoff, p.maxRetry)
+				log.Printf("Warning: Could not watch ingresses, retrying in %v: %v", wait, err)
+			} else {
+				backoff = p.minRetry
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// tailnetProvider lists the tailnet devices through the tsnet node's
+// local client, which the server only gets once the node is up.
+type tailnetProvider struct {
+	s       *Server
+	devices *TailnetDevices
+}
+
+// Name is how logs refer to the source.
+func (p tailnetProvider) Name() string { return "tailnet devices" }
+
+// Discover returns the devices of the network map, or none before the node
+// is up.
+func (p tailnetProvider) Discover(ctx context.Context) ([]IngressInfo, error) {
+	return p.devices.Devices(ctx, p.s.tsLocalClient)
+}
+
+// Watch returns at once: the network map is polled as its cache expires.
+func (p tailnetProvider) Watch(context.Context, func()) {}
@@ -0,0 +1,467 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// A minimal QR code encoder for /qr, following ISO/IEC 18004: byte mode,
+// error correction level M, versions 1 to 20 (up to 666 bytes, far more than
+// any tile URL).
+
+// qrBlocks describes the error correction layout of one version at level M:
+// EC codewords per block, and the count and data length of the short and
+// long block groups.
+type qrBlocks struct {
+	ec               int
+	short, shortData int
+	long, longData   int
+}
+
+// qrLevelM is indexed by version - 1.
+var qrLevelM = []qrBlocks{
+	{10, 1, 16, 0, 0}, {16, 1, 28, 0, 0}, {26, 1, 44, 0, 0}, {18, 2, 32, 0, 0},
+	{24, 2, 43, 0, 0}, {16, 4, 27, 0, 0}, {18, 4, 31, 0, 0}, {22, 2, 38, 2, 39},
+	{22, 3, 36, 2, 37}, {26, 4, 43, 1, 44}, {30, 1, 50, 4, 51}, {22, 6, 36, 2, 37},
+	{22, 8, 37, 1, 38}, {24, 4, 40, 5, 41}, {24, 5, 41, 5, 42}, {28, 7, 45, 3, 46},
+	{28, 10, 46, 1, 47}, {26, 9, 43, 4, 44}, {26, 3, 44, 11, 45}, {26, 3, 41, 13, 42},
+}
+
+// errQRTooLong is returned for data that doesn't fit the largest version.
+var errQRTooLong = errors.New("qr: data too long")
+
+// QRCode is an encoded symbol; Modules[y][x] is true for dark modules.
+type QRCode struct {
+	Size    int
+	Modules [][]bool
+
+	function [][]bool // modules belonging to function patterns, never masked
+	mask     int      // the mask pattern applied, chosen by applyBestMask
+}
+
+// encodeQR encodes data in the smallest version that fits.
+func encodeQR(data []byte) (*QRCode, error) {
+	for version := 1; version <= len(qrLevelM); version++ {
+		blocks := qrLevelM[version-1]
+		capacity := blocks.short*blocks.shortData + blocks.long*blocks.longData
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) > 8*capacity {
+			continue
+		}
+
+		var bits qrBitBuffer
+		bits.append(0b0100, 4) // byte mode
+		bits.append(len(data), countBits)
+		for _, b := range data {
+			bits.append(int(b), 8)
+		}
+		bits.append(0, min(4, 8*capacity-len(bits)))
+		bits.append(0, (8-len(bits)%8)%8)
+		for pad := 0xEC; len(bits) < 8*capacity; pad ^= 0xEC ^ 0x11 {
+			bits.append(pad, 8)
+		}
+
+		q := newQRCode(version)
+		q.drawCodewords(interleaveQR(bits.bytes(), blocks))
+		q.applyBestMask()
+		return q, nil
+	}
+	return nil, errQRTooLong
+}
+
+// qrBitBuffer accumulates the data bit stream, one bool per bit.
+type qrBitBuffer []bool
+
+func (b *qrBitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 == 1)
+	}
+}
+
+func (b qrBitBuffer) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return out
+}
+
+// interleaveQR splits data into blocks, appends each block's Reed-Solomon
+// codewords and interleaves the result as the symbol expects.
+func interleaveQR(data []byte, layout qrBlocks) []byte {
+	divisor := rsDivisor(layout.ec)
+	var blocks, ecc [][]byte
+	for i := range layout.short + layout.long {
+		n := layout.shortData
+		if i >= layout.short {
+			n = layout.longData
+		}
+		blocks = append(blocks, data[:n])
+		ecc = append(ecc, rsRemainder(data[:n], divisor))
+		data = data[n:]
+	}
+
+	var out []byte
+	for i := range max(layout.shortData, layout.longData) {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := range layout.ec {
+		for _, block := range ecc {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the given
+// degree, highest coefficient first, without the leading 1.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 2)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords for data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// newQRCode draws the function patterns of a version, leaving the data area
+// blank.
+func newQRCode(version int) *QRCode {
+	size := version*4 + 17
+	q := &QRCode{Size: size, Modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range size {
+		q.Modules[y] = make([]bool, size)
+		q.function[y] = make([]bool, size)
+	}
+
+	for i := range size {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x >= 0 && x < size && y >= 0 && y < size {
+					d := max(abs(dx), abs(dy))
+					q.setFunction(x, y, d != 2 && d != 4)
+				}
+			}
+		}
+	}
+	positions := qrAlignmentPositions(version)
+	for i, x := range positions {
+		for j, y := range positions {
+			last := len(positions) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // these overlap the finder patterns
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	q.drawFormat(0) // reserve the format areas; redrawn once the mask is chosen
+
+	if version >= 7 {
+		rem := version
+		for range 12 {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := range 18 {
+			dark := bits>>i&1 == 1
+			a, b := size-11+i%3, i/3
+			q.setFunction(a, b, dark)
+			q.setFunction(b, a, dark)
+		}
+	}
+	return q
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// qrAlignmentPositions returns the row and column centres of the alignment
+// patterns for a version.
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*4 + n*2 + 1) / (n*2 - 2) * 2
+	positions := make([]int, n)
+	positions[0] = 6
+	for i, pos := n-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+func (q *QRCode) setFunction(x, y int, dark bool) {
+	q.Modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+// drawFormat writes both copies of the format information for level M and
+// the given mask, plus the dark module.
+func (q *QRCode) drawFormat(mask int) {
+	data := 0b00<<3 | mask // 00 is level M
+	rem := data
+	for range 10 {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := range 6 {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+	for i := range 8 {
+		q.setFunction(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.Size-15+i, bit(i))
+	}
+	q.setFunction(8, q.Size-8, true)
+}
+
+// drawCodewords places the codewords in the zigzag pattern, skipping
+// function modules.
+func (q *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		for vert := range q.Size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert // upward column
+				}
+				if !q.function[y][x] && i < len(codewords)*8 {
+					q.Modules[y][x] = codewords[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// qrMask reports whether mask pattern m inverts the module at (x, y).
+func qrMask(m, x, y int) bool {
+	switch m {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	}
+	return ((x+y)%2+x*y%3)%2 == 0
+}
+
+// applyMask XORs mask m over the data modules; applying it twice undoes it.
+func (q *QRCode) applyMask(m int) {
+	for y := range q.Size {
+		for x := range q.Size {
+			if !q.function[y][x] && qrMask(m, x, y) {
+				q.Modules[y][x] = !q.Modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask tries all eight masks and keeps the one with the lowest
+// penalty score.
+func (q *QRCode) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for m := range 8 {
+		q.applyMask(m)
+		q.drawFormat(m)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = m, p
+		}
+		q.applyMask(m)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	q.mask = best
+}
+
+// penalty scores the symbol by the four rules of the standard: long runs of
+// one colour, 2x2 blocks, finder-like patterns and dark/light imbalance.
+func (q *QRCode) penalty() int {
+	n := q.Size
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return q.Modules[x][y]
+		}
+		return q.Modules[y][x]
+	}
+
+	score := 0
+	for _, transpose := range []bool{false, true} {
+		for y := range n {
+			run := 0
+			for x := range n {
+				if x > 0 && at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					if run == 5 {
+						score += 3
+					} else if run > 5 {
+						score++
+					}
+				} else {
+					run = 1
+				}
+
+				// 1:1:3:1:1 dark-light-dark pattern with four light modules
+				// on one side.
+				if x+7 <= n {
+					pattern := true
+					for k, dark := range []bool{true, false, true, true, true, false, true} {
+						if at(x+k, y, transpose) != dark {
+							pattern = false
+							break
+						}
+					}
+					if pattern && (qrLight(at, x-4, x, y, n, transpose) || qrLight(at, x+7, x+11, y, n, transpose)) {
+						score += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := range n {
+		for x := range n {
+			if q.Modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := q.Modules[y][x]
+				if c == q.Modules[y][x+1] && c == q.Modules[y+1][x] && c == q.Modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return score + max(k, 0)*10
+}
+
+// qrLight reports whether modules from..to-1 of a line are all light, with
+// modules outside the symbol counting as light.
+func qrLight(at func(x, y int, transpose bool) bool, from, to, y, n int, transpose bool) bool {
+	for x := from; x < to; x++ {
+		if x >= 0 && x < n && at(x, y, transpose) {
+			return false
+		}
+	}
+	return true
+}
+
+// SVG renders the symbol as a scalable image with a four-module quiet zone.
+func (q *QRCode) SVG() string {
+	const quiet = 4
+	var path strings.Builder
+	for y := range q.Size {
+		for x := range q.Size {
+			if q.Modules[y][x] {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+quiet, y+quiet)
+			}
+		}
+	}
+	size := q.Size + 2*quiet
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/></svg>`,
+		size, size, size, size, path.String())
+}
+
+// handleQR serves /qr?url=<link> as an SVG QR code, so a link on a wall
+// display can be opened on a phone by scanning it. Only http(s) URLs are
+// encoded.
+func (s *Server) handleQR(w http.ResponseWriter, r *http.Request) {
+	link := r.URL.Query().Get("url")
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+	q, err := encodeQR([]byte(link))
+	if err != nil {
+		http.Error(w, "url is too long for a QR code", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	fmt.Fprint(w, q.SVG())
+}
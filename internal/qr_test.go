@@ -0,0 +1,386 @@
+package internal
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// The tables of ISO/IEC 18004 the encoder is checked against.
+var (
+	// qrFormatM is the format information of level M, by mask (Table C.1).
+	qrFormatM = []int{
+		0b101010000010010, 0b101000100100101, 0b101111001111100, 0b101101101001011,
+		0b100010111111001, 0b100000011001110, 0b100111110010111, 0b100101010100000,
+	}
+	// qrVersionInfo is the version information of versions 7 and up (Table D.1).
+	qrVersionInfo = map[int]int{
+		7: 0x07C94, 8: 0x085BC, 9: 0x09A99, 10: 0x0A4D3, 11: 0x0BBF6, 12: 0x0C762, 13: 0x0D847,
+		14: 0x0E60D, 15: 0x0F928, 16: 0x10B78, 17: 0x1145D, 18: 0x12A17, 19: 0x13532, 20: 0x149A6,
+	}
+	// qrAlignment is the row and column centres of the alignment patterns,
+	// by version (Table E.1).
+	qrAlignment = [][]int{
+		nil, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34}, {6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+		{6, 30, 54}, {6, 32, 58}, {6, 34, 62}, {6, 26, 46, 66}, {6, 26, 48, 70}, {6, 26, 50, 74},
+		{6, 30, 54, 78}, {6, 30, 56, 82}, {6, 30, 58, 86}, {6, 34, 62, 90},
+	}
+	// qrCodewords is the total and level M data codewords, by version (Table 9).
+	qrCodewords = [][2]int{
+		{26, 16}, {44, 28}, {70, 44}, {100, 64}, {134, 86}, {172, 108}, {196, 124}, {242, 154}, {292, 182}, {346, 216},
+		{404, 254}, {466, 290}, {532, 334}, {581, 365}, {655, 415}, {733, 453}, {815, 507}, {901, 563}, {991, 627}, {1085, 669},
+	}
+)
+
+func TestQRBlocks(t *testing.T) {
+	for version := 1; version <= len(qrLevelM); version++ {
+		blocks := qrLevelM[version-1]
+		data := blocks.short*blocks.shortData + blocks.long*blocks.longData
+		total := data + (blocks.short+blocks.long)*blocks.ec
+		if want := qrCodewords[version-1]; total != want[0] || data != want[1] {
+			t.Errorf("version %d: %d codewords with %d data, want %d with %d", version, total, data, want[0], want[1])
+		}
+	}
+}
+
+func TestQRAlignmentPositions(t *testing.T) {
+	for version := 1; version <= len(qrLevelM); version++ {
+		if got, want := qrAlignmentPositions(version), qrAlignment[version-1]; !slices.Equal(got, want) {
+			t.Errorf("version %d: alignment patterns at %v, want %v", version, got, want)
+		}
+	}
+}
+
+// TestQRErrorCorrection checks the Reed-Solomon codewords of the 1-M
+// "HELLO WORLD" example that is worked through by hand in most QR guides.
+func TestQRErrorCorrection(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQRFormatAndVersion(t *testing.T) {
+	for mask, want := range qrFormatM {
+		q := newQRCode(1)
+		q.drawFormat(mask)
+		if first, second := qrReadFormat(q, false), qrReadFormat(q, true); first != want || second != want {
+			t.Errorf("mask %d: format %015b and %015b, want %015b", mask, first, second, want)
+		}
+	}
+	for version, want := range qrVersionInfo {
+		q := newQRCode(version)
+		for _, transpose := range []bool{false, true} {
+			got := 0
+			for i := range 18 {
+				x, y := q.Size-11+i%3, i/3
+				if transpose {
+					x, y = y, x
+				}
+				if q.Modules[y][x] {
+					got |= 1 << i
+				}
+			}
+			if got != want {
+				t.Errorf("version %d: version information %018b, want %018b", version, got, want)
+			}
+		}
+	}
+}
+
+// TestEncodeQRGolden encodes payloads in a single-block version with each
+// mask, and in versions with several blocks of two lengths, and compares
+// the symbols with those in testdata/qr. Each symbol is also read back:
+// its format information must be one of the standard's, every block's
+// error correction must check out and the payload must be what was
+// encoded. Run with -update to rewrite the files.
+func TestEncodeQRGolden(t *testing.T) {
+	type golden struct {
+		name    string
+		data    string
+		version int
+		mask    int // -1 for the mask encodeQR picks
+	}
+	var tests []golden
+	for mask := range 8 {
+		tests = append(tests, golden{fmt.Sprintf("v1-m%d", mask), "http://gohome/", 1, mask})
+	}
+	tests = append(tests,
+		golden{"v1", "http://gohome/", 1, -1},
+		// Two blocks of 38 data codewords and two of 39.
+		golden{"v8", "https://grafana.example.com/d/k8s/cluster?" + strings.Repeat("var-node=all&", 8) + "org=1", 8, -1},
+		// Six blocks of 36 and two of 37, with a 16-bit byte count.
+		golden{"v12", "https://media.example.com/web/index.html#/details?" + strings.Repeat("id=0123456789abcdef&", 11), 12, -1},
+	)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := encodeQR([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.version*4 + 17; q.Size != want {
+				t.Fatalf("size %d, want %d for version %d", q.Size, want, tt.version)
+			}
+			if tt.mask >= 0 {
+				q.applyMask(q.mask)
+				q.applyMask(tt.mask)
+				q.drawFormat(tt.mask)
+			}
+
+			if got, err := qrDecode(q); err != nil {
+				t.Errorf("reading the symbol back: %v", err)
+			} else if string(got) != tt.data {
+				t.Errorf("read back %q, want %q", got, tt.data)
+			}
+
+			path := filepath.Join("testdata", "qr", tt.name+".txt")
+			got := qrText(q)
+			if *updateGolden {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("symbol differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestEncodeQRTooLong(t *testing.T) {
+	if _, err := encodeQR(bytes.Repeat([]byte{'a'}, 667)); err != errQRTooLong {
+		t.Errorf("got %v, want errQRTooLong", err)
+	}
+	if q, err := encodeQR(bytes.Repeat([]byte{'a'}, 666)); err != nil || q.Size != 20*4+17 {
+		t.Errorf("666 bytes don't fit version 20: %v", err)
+	}
+}
+
+// qrText draws a symbol with one line per row, "#" for dark modules.
+func qrText(q *QRCode) string {
+	var b strings.Builder
+	for _, row := range q.Modules {
+		for _, dark := range row {
+			if dark {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// qrReadFormat reads the copy of the format information around the top
+// left finder, or the one split between the other two.
+func qrReadFormat(q *QRCode, second bool) int {
+	var at [15][2]int // x, y of each bit
+	for i := range 15 {
+		switch {
+		case second && i < 8:
+			at[i] = [2]int{q.Size - 1 - i, 8}
+		case second:
+			at[i] = [2]int{8, q.Size - 15 + i}
+		case i < 6:
+			at[i] = [2]int{8, i}
+		case i < 8:
+			at[i] = [2]int{8, i + 1}
+		case i == 8:
+			at[i] = [2]int{7, 8}
+		default:
+			at[i] = [2]int{14 - i, 8}
+		}
+	}
+	bits := 0
+	for i, xy := range at {
+		if q.Modules[xy[1]][xy[0]] {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
+// qrDecode reads the byte mode payload of a level M symbol, working out
+// which modules hold data from the standard's tables rather than from the
+// encoder.
+func qrDecode(q *QRCode) ([]byte, error) {
+	version := (q.Size - 17) / 4
+	mask := slices.Index(qrFormatM, qrReadFormat(q, false))
+	if mask < 0 || qrReadFormat(q, true) != qrFormatM[mask] {
+		return nil, fmt.Errorf("format information %015b is not level M", qrReadFormat(q, false))
+	}
+	masks := []func(i, j int) bool{ // i is the row, j the column
+		func(i, j int) bool { return (i+j)%2 == 0 },
+		func(i, j int) bool { return i%2 == 0 },
+		func(i, j int) bool { return j%3 == 0 },
+		func(i, j int) bool { return (i+j)%3 == 0 },
+		func(i, j int) bool { return (i/2+j/3)%2 == 0 },
+		func(i, j int) bool { return (i*j)%2+(i*j)%3 == 0 },
+		func(i, j int) bool { return ((i*j)%2+(i*j)%3)%2 == 0 },
+		func(i, j int) bool { return ((i+j)%2+(i*j)%3)%2 == 0 },
+	}
+
+	// Mark the function patterns: finders with their separators and
+	// format information, timing, alignment and version information.
+	reserved := make([][]bool, q.Size)
+	for y := range reserved {
+		reserved[y] = make([]bool, q.Size)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := max(y0, 0); y < min(y0+h, q.Size); y++ {
+			for x := max(x0, 0); x < min(x0+w, q.Size); x++ {
+				reserved[y][x] = true
+			}
+		}
+	}
+	fill(0, 0, 9, 9)
+	fill(q.Size-8, 0, 8, 9)
+	fill(0, q.Size-8, 9, 8)
+	fill(6, 0, 1, q.Size)
+	fill(0, 6, q.Size, 1)
+	centres := qrAlignment[version-1]
+	last := len(centres) - 1
+	for i, cy := range centres {
+		for j, cx := range centres {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue // in place of a finder
+			}
+			fill(cx-2, cy-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		fill(q.Size-11, 0, 3, 6)
+		fill(0, q.Size-11, 6, 3)
+	}
+
+	// Read the modules two columns at a time from the bottom right,
+	// alternately up and down, skipping the vertical timing pattern.
+	var bits []bool
+	up := true
+	for right := q.Size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for n := range q.Size {
+			y := n
+			if up {
+				y = q.Size - 1 - n
+			}
+			for _, x := range []int{right, right - 1} {
+				if !reserved[y][x] {
+					bits = append(bits, q.Modules[y][x] != masks[mask](y, x))
+				}
+			}
+		}
+		up = !up
+	}
+	total := qrCodewords[version-1][0]
+	if len(bits) < total*8 || len(bits)-total*8 >= 8 {
+		return nil, fmt.Errorf("%d data modules for %d codewords", len(bits), total)
+	}
+	codewords := make([]byte, total)
+	for i := range total * 8 {
+		if bits[i] {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	// Undo the interleaving and check each block's error correction.
+	layout := qrLevelM[version-1]
+	n := layout.short + layout.long
+	blocks := make([][]byte, n)
+	k := 0
+	for i := range max(layout.shortData, layout.longData) + layout.ec {
+		for b := range n {
+			size := layout.shortData
+			if b >= layout.short {
+				size = layout.longData
+			}
+			if i < layout.longData && i >= size {
+				continue // short blocks have no codeword here
+			}
+			blocks[b] = append(blocks[b], codewords[k])
+			k++
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		if s := qrSyndromes(block, layout.ec); s != nil {
+			return nil, fmt.Errorf("block %d has syndromes %v", b, s)
+		}
+		data = append(data, block[:len(block)-layout.ec]...)
+	}
+
+	// The data is a single byte mode segment.
+	bit := func(i int) int { return int(data[i/8]>>(7-i%8)) & 1 }
+	read := func(at, n int) int {
+		v := 0
+		for i := range n {
+			v = v<<1 | bit(at+i)
+		}
+		return v
+	}
+	if mode := read(0, 4); mode != 0b0100 {
+		return nil, fmt.Errorf("mode %04b, want byte mode", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	count := read(4, countBits)
+	if 4+countBits+8*count > 8*len(data) {
+		return nil, fmt.Errorf("count %d exceeds the data", count)
+	}
+	payload := make([]byte, count)
+	for i := range payload {
+		payload[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return payload, nil
+}
+
+// qrSyndromes evaluates a block at the first ec powers of 2 in GF(2^8),
+// returning nil when all are zero as they are for a valid block.
+func qrSyndromes(block []byte, ec int) []byte {
+	var exp [255]byte
+	log := make(map[byte]int)
+	for i, x := 0, 1; i < 255; i++ {
+		exp[i], log[byte(x)] = byte(x), i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	mul := func(a, b byte) byte {
+		if a == 0 || b == 0 {
+			return 0
+		}
+		return exp[(log[a]+log[b])%255]
+	}
+	var syndromes []byte
+	nonzero := false
+	for k := range ec {
+		var s byte
+		for _, c := range block {
+			s = mul(s, exp[k]) ^ c
+		}
+		syndromes = append(syndromes, s)
+		nonzero = nonzero || s != 0
+	}
+	if !nonzero {
+		return nil
+	}
+	return syndromes
+}
@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultAccessCheckInterval is how often the permissions are checked
+// again, so fixing the RBAC clears the warnings without a restart.
+const defaultAccessCheckInterval = 10 * time.Minute
+
+// AccessProblem is a permission GoHome lacks for a feature in use. Key is
+// the locale message saying what that means for the page, formatted with
+// Arg when it is set.
+type AccessProblem struct {
+	Permission string // e.g. "list ingresses.networking.k8s.io in all namespaces"
+	Key        string
+	Arg        string
+}
+
+// AccessChecker asks the API server, with SelfSubjectAccessReviews, for
+// the permissions each enabled feature needs, so missing ones show up as a
+// warning on the page saying what is affected rather than as errors in the
+// log. Without cluster-wide access to ingresses it narrows the listing to
+// GoHome's own namespace.
+type AccessChecker struct {
+	k8s      func() *K8sClient // nil in demo mode until connected
+	bm       *BookmarkManager
+	interval time.Duration
+	readOnly bool // READ_ONLY=true: the ConfigMap is never updated
+	secrets  bool // CERT_SECRETS=true: Secrets are listed for certificate expiry
+	capacity bool // CLUSTER_CAPACITY=true: nodes and pods are listed for the capacity widget
+
+	mu       sync.Mutex
+	problems []AccessProblem
+}
+
+// NewAccessCheckerFromEnv creates a checker running every
+// RBAC_CHECK_INTERVAL. k8s returns the current client; while it returns nil
+// there is nothing to check.
+func NewAccessCheckerFromEnv(k8s func() *K8sClient, bm *BookmarkManager) *AccessChecker {
+	return &AccessChecker{
+		k8s:      k8s,
+		bm:       bm,
+		interval: durationFromEnv("RBAC_CHECK_INTERVAL", defaultAccessCheckInterval),
+		readOnly: readOnlyEnabled(),
+		secrets:  os.Getenv("CERT_SECRETS") == "true",
+		capacity: os.Getenv("CLUSTER_CAPACITY") == "true",
+	}
+}
+
+// Job checks the permissions right away and then every interval.
+func (a *AccessChecker) Job() Job {
+	if a == nil {
+		return Job{}
+	}
+	return Job{Name: "rbac", Interval: a.interval, Run: a.check}
+}
+
+// Problems returns the permissions found missing by the last check.
+func (a *AccessChecker) Problems() []AccessProblem {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.problems
+}
+
+// check reviews every permission and logs the problems that appeared or
+// went away since the last check. If the reviews themselves fail the
+// previous result is kept.
+func (a *AccessChecker) check(ctx context.Context) error {
+	k := a.k8s()
+	if k == nil || k.clientset == nil {
+		// Demo mode and fixtures have no API server to ask.
+		return nil
+	}
+	namespace, name := a.bm.namespace, a.bm.configMapName
+	var problems []AccessProblem
+	var errs []error
+	review := func(attrs authorizationv1.ResourceAttributes) bool {
+		allowed, err := k.canI(ctx, attrs)
+		if err != nil {
+			errs = append(errs, err)
+			return true
+		}
+		return allowed
+	}
+	missing := func(verb, resource, scope, key, arg string) {
+		problems = append(problems, AccessProblem{Permission: verb + " " + resource + " " + scope, Key: key, Arg: arg})
+	}
+
+	ingresses := authorizationv1.ResourceAttributes{Verb: "list", Group: "networking.k8s.io", Resource: "ingresses"}
+	ingressNamespace := ""
+	if !review(ingresses) {
+		inNamespace := ingresses
+		inNamespace.Namespace = namespace
+		if review(inNamespace) {
+			ingressNamespace = namespace
+			missing("list", "ingresses.networking.k8s.io", "in all namespaces", "rbac.ingresses_namespace", namespace)
+		} else {
+			missing("list", "ingresses.networking.k8s.io", "in "+namespace, "rbac.ingresses", "")
+		}
+	}
+
+	configMap := authorizationv1.ResourceAttributes{Verb: "get", Resource: "configmaps", Namespace: namespace, Name: name}
+	if !review(configMap) {
+		missing("get", "configmaps/"+name, "in "+namespace, "rbac.configmap", namespace+"/"+name)
+	}
+	if !a.readOnly {
+		update := configMap
+		update.Verb = "update"
+		if !review(update) {
+			missing("update", "configmaps/"+name, "in "+namespace, "rbac.configmap_update", namespace+"/"+name)
+		}
+	}
+	if !review(authorizationv1.ResourceAttributes{Verb: "get", Resource: "configmaps"}) {
+		missing("get", "configmaps", "in all namespaces", "rbac.namespace_configmaps", "")
+	}
+	if !review(authorizationv1.ResourceAttributes{Verb: "list", Group: "discovery.k8s.io", Resource: "endpointslices"}) {
+		missing("list", "endpointslices.discovery.k8s.io", "in all namespaces", "rbac.endpointslices", "")
+	}
+	if a.secrets && !review(authorizationv1.ResourceAttributes{Verb: "list", Resource: "secrets"}) {
+		missing("list", "secrets", "in all namespaces", "rbac.secrets", "")
+	}
+	if a.capacity {
+		if !review(authorizationv1.ResourceAttributes{Verb: "list", Resource: "nodes"}) {
+			missing("list", "nodes", "cluster-wide", "rbac.capacity", "")
+		} else if !review(authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods"}) {
+			missing("list", "pods", "in all namespaces", "rbac.capacity", "")
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("Warning: Could not check RBAC permissions: %v", err)
+		return err
+	}
+	k.setIngressNamespace(ingressNamespace)
+
+	a.mu.Lock()
+	previous := a.problems
+	a.problems = problems
+	a.mu.Unlock()
+
+	english := locales[defaultLanguage]
+	for _, p := range problems {
+		if !slices.Contains(previous, p) {
+			log.Printf("Warning: Missing permission to %s: %s", p.Permission, p.Message(english))
+		}
+	}
+	for _, p := range previous {
+		if !slices.Contains(problems, p) {
+			log.Printf("Permission to %s granted", p.Permission)
+		}
+	}
+	return nil
+}
+
+// Message formats the problem in locale l.
+func (p AccessProblem) Message(l *Locale) string {
+	if p.Arg == "" {
+		return l.T(p.Key)
+	}
+	return l.T(p.Key, p.Arg)
+}
+
+// canI asks the API server whether GoHome's own identity may do what attrs
+// describe.
+func (k *K8sClient) canI(ctx context.Context, attrs authorizationv1.ResourceAttributes) (bool, error) {
+	review, err := k.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attrs},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
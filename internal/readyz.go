@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// defaultInitialSyncTimeout is how long /readyz waits for the first ingress
+// listing before reporting ready anyway, so a flaky API server degrades the
+// page instead of keeping every replica out of service.
+const defaultInitialSyncTimeout = 2 * time.Minute
+
+// readiness tracks the initial ingress sync that gates /readyz.
+type readiness struct {
+	timeout time.Duration
+	synced  atomic.Bool
+	gaveUp  atomic.Bool // the timeout passed before the sync; logged once
+}
+
+// handleReady serves /readyz: 503 until ingresses have been listed once,
+// then 200 for the rest of the process's life, like /health. In demo mode,
+// or once INITIAL_SYNC_TIMEOUT has passed since startup, it is ready
+// straight away.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.initialSyncDone(ctx) {
+		http.Error(w, "waiting for the initial ingress sync", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// initialSyncDone reports whether /readyz should pass, listing the
+// ingresses if that hasn't worked yet. The listing is cached, so probes
+// don't add load on the API server.
+func (s *Server) initialSyncDone(ctx context.Context) bool {
+	if s.ready.synced.Load() {
+		return true
+	}
+	k := s.kube()
+	if k == nil {
+		return true
+	}
+	// A snapshot restored at startup doesn't count: its cache entry is
+	// fetched again on first use, so only a listing that worked returns
+	// no error.
+	if _, _, err := k.GetVisibleIngresses(ctx); err == nil {
+		if !s.ready.synced.Swap(true) {
+			log.Printf("Initial ingress sync done after %s", time.Since(s.startTime).Round(time.Millisecond))
+		}
+		return true
+	}
+	if time.Since(s.startTime) < s.ready.timeout {
+		return false
+	}
+	if !s.ready.gaveUp.Swap(true) {
+		log.Printf("Warning: Initial ingress sync not done within INITIAL_SYNC_TIMEOUT (%s), reporting ready anyway", s.ready.timeout)
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// defaultRecentCount is how many tiles the "Recently used" row shows.
+const defaultRecentCount = 6
+
+// parseTileCount parses a setting holding a number of tiles to show in a
+// row, such as recent, with "0" or "false" turning the row off.
+func parseTileCount(setting, value string, fallback int) int {
+	if value == "false" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 50 {
+		log.Printf("Warning: invalid %s %q, want a number of tiles from 0 to 50", setting, value)
+		return fallback
+	}
+	return n
+}
+
+// trackClicks reports whether tiles should open through /click, which is
+// needed for the recently used row and for click stats.
+func trackClicks(config *Config) bool {
+	return config.Recent > 0 || config.ClickStats
+}
+
+// clickURL is the /click link a tile uses while click tracking is on.
+func clickURL(id string) string {
+	return "/click?id=" + url.QueryEscape(id)
+}
+
+// applyClickLinks points ingress tiles at /click so opening them is recorded.
+func applyClickLinks(items []IngressInfo) {
+	for i := range items {
+		items[i].Href = clickURL(ingressTileID(items[i]))
+	}
+}
+
+// applyBookmarkClickLinks is applyClickLinks for bookmarks.
+func applyBookmarkClickLinks(bookmarks []Bookmark) {
+	for i := range bookmarks {
+		bookmarks[i].Href = clickURL(bookmarkTileID(bookmarks[i]))
+	}
+}
+
+// handleClick serves /click?id=<tile ID>: it counts the click, moves the
+// tile to the front of the visitor's recently used list and redirects to
+// its URL. Only the URLs
+// of current tiles are redirected to, so it can't be used as an open
+// redirect.
+func (s *Server) handleClick(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: /click could not load ingresses: %v", err)
+	}
+
+	apps, services, bookmarks := s.restrictTiles(r, s.resolveViewer(ctx, r), config, apps, services, config.Bookmarks)
+
+	id := r.URL.Query().Get("id")
+	tiles := buildFavorites([]string{id}, apps, services, bookmarks)
+	if len(tiles) == 0 {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	s.recordClick(w, r, config, id)
+	http.Redirect(w, r, tiles[0].URL(), http.StatusFound)
+}
+
+// recordClick counts a click on the tile id and moves it to the front of
+// the visitor's recently used list, as far as config tracks either. With
+// READ_ONLY=true the list, a preference, is left as it is.
+func (s *Server) recordClick(w http.ResponseWriter, r *http.Request, config *Config, id string) {
+	if config.ClickStats {
+		s.clicks.Record(id, time.Now())
+	}
+	if config.Recent > 0 && !s.readOnly {
+		prefs, _ := s.loadPreferences(r)
+		recent := slices.DeleteFunc(prefs.Recent, func(item string) bool { return item == id })
+		recent = append([]string{id}, recent...)
+		prefs.Recent = recent[:min(len(recent), config.Recent)]
+		if err := s.savePreferences(w, r, prefs); err != nil {
+			log.Printf("Warning: Could not save recently used tiles: %v", err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisKeyPrefix namespaces every key GoHome writes, so a Redis shared with
+// other applications stays tidy.
+const redisKeyPrefix = "gohome:"
+
+// redisTimeout bounds one command when the caller's context has no deadline.
+const redisTimeout = 5 * time.Second
+
+// RedisClient is a minimal Redis client speaking RESP2 over one connection,
+// enough for the shared cache, click counters and health results that let
+// several replicas cooperate. Commands are serialised; the connection is
+// re-established on the next command after any error.
+type RedisClient struct {
+	addr     string
+	tls      bool
+	username string
+	password string
+	db       int
+	id       string // this replica, as the holder of a lock
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// redisError is an error reply from the server, as opposed to a network
+// failure.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// sharedRedis is the client for REDIS_URL, or nil when it isn't set. Every
+// component that can share state across replicas uses the same one.
+var sharedRedis = sync.OnceValue(func() *RedisClient {
+	raw := os.Getenv("REDIS_URL")
+	if raw == "" {
+		return nil
+	}
+	client, err := parseRedisURL(raw)
+	if err != nil {
+		log.Printf("Warning: ignoring REDIS_URL: %v", err)
+		return nil
+	}
+	log.Printf("Sharing cache, click counts and health results through Redis at %s", client.addr)
+	return client
+})
+
+// parseRedisURL parses redis://[[user]:password@]host[:port][/db], or
+// rediss:// for TLS.
+func parseRedisURL(raw string) (*RedisClient, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	client := &RedisClient{id: replicaID()}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		client.tls = true
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q, want redis or rediss", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("missing host")
+	}
+	client.addr = u.Host
+	if u.Port() == "" {
+		client.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		client.username = u.User.Username()
+		client.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if client.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid database %q", db)
+		}
+	}
+	return client, nil
+}
+
+// Do sends one command and returns its reply: a string for simple and bulk
+// strings, an int64 for integers, a []any for arrays and nil for a null
+// reply. Error replies are returned as errors.
+func (c *RedisClient) Do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	if c.conn == nil {
+		if err := c.connect(ctx, deadline); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(deadline, args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// connect dials the server and authenticates.
+func (c *RedisClient) connect(ctx context.Context, deadline time.Time) error {
+	dialer := &net.Dialer{Deadline: deadline}
+	var conn net.Conn
+	var err error
+	if c.tls {
+		host, _, _ := net.SplitHostPort(c.addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	c.conn, c.rd = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	switch {
+	case c.username != "":
+		setup = append(setup, []string{"AUTH", c.username, c.password})
+	case c.password != "":
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	for _, args := range setup {
+		if _, err := c.roundTrip(deadline, args); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RedisClient) roundTrip(deadline time.Time, args []string) (any, error) {
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readRESP(c.rd)
+}
+
+// readRESP reads one RESP2 reply.
+func readRESP(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	body := line[1:]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		// An error reply in the array, as EXEC sends for a failed command,
+		// is only returned once every element has been read, so the next
+		// reply on the connection is read from where it starts.
+		items := make([]any, n)
+		var replyErr error
+		for i := range items {
+			items[i], err = readRESP(rd)
+			var elemErr redisError
+			switch {
+			case errors.As(err, &elemErr):
+				if replyErr == nil {
+					replyErr = err
+				}
+			case err != nil:
+				return nil, err
+			}
+		}
+		if replyErr != nil {
+			return nil, replyErr
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// Get returns the string value of key, and false if it doesn't exist.
+func (c *RedisClient) Get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := c.Do(ctx, "GET", redisKeyPrefix+key)
+	value, ok := reply.(string)
+	return value, ok, err
+}
+
+// Set stores value under key, expiring after ttl.
+func (c *RedisClient) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := c.Do(ctx, "SET", redisKeyPrefix+key, value, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// HGetAll returns every field of the hash at key.
+func (c *RedisClient) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	reply, err := c.Do(ctx, "HGETALL", redisKeyPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]any)
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		field, _ := items[i].(string)
+		value, _ := items[i+1].(string)
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// redisLockScript takes the lock in KEYS[1] for ARGV[1], or extends it if
+// ARGV[1] already holds it, for ARGV[2] milliseconds.
+const redisLockScript = `local holder = redis.call('GET', KEYS[1])
+if holder == false or holder == ARGV[1] then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return 1
+end
+return 0`
+
+// Lead reports whether this replica holds the named lock, taking it if
+// it's free and extending it for ttl if this replica already has it. A
+// replica that stops renewing loses the lock once ttl passes, and another
+// one takes over.
+func (c *RedisClient) Lead(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	reply, err := c.Do(ctx, "EVAL", redisLockScript, "1", redisKeyPrefix+"lock:"+name, c.id, strconv.FormatInt(ttl.Milliseconds(), 10))
+	return reply == int64(1), err
+}
+
+// replicaID identifies this process to the other replicas; the pod name
+// is unique within a Deployment.
+var replicaID = sync.OnceValue(func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+})
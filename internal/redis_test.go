@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		name, reply string
+		want        any
+		err         string // substring of the error, if one is expected
+	}{
+		{"simple string", "+OK\r\n", "OK", ""},
+		{"error", "-ERR unknown command\r\n", nil, "redis: ERR unknown command"},
+		{"integer", ":42\r\n", int64(42), ""},
+		{"negative integer", ":-1\r\n", int64(-1), ""},
+		{"bulk string", "$5\r\nhello\r\n", "hello", ""},
+		{"bulk string with CRLF", "$7\r\nab\r\ncde\r\n", "ab\r\ncde", ""},
+		{"empty bulk string", "$0\r\n\r\n", "", ""},
+		{"null bulk string", "$-1\r\n", nil, ""},
+		{"array", "*2\r\n$1\r\na\r\n:1\r\n", []any{"a", int64(1)}, ""},
+		{"nested array", "*2\r\n*1\r\n+x\r\n$-1\r\n", []any{[]any{"x"}, nil}, ""},
+		{"empty array", "*0\r\n", []any{}, ""},
+		{"null array", "*-1\r\n", nil, ""},
+		{"error in array", "*3\r\n+OK\r\n-WRONGTYPE bad\r\n:1\r\n", nil, "WRONGTYPE bad"},
+		{"bad integer", ":x\r\n", nil, "invalid syntax"},
+		{"truncated bulk string", "$5\r\nhel", nil, "EOF"},
+		{"truncated array", "*2\r\n+OK\r\n", nil, "EOF"},
+		{"unknown type", "!3\r\n", nil, "unexpected reply"},
+		{"empty line", "\r\n", nil, "empty reply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRESP(bufio.NewReader(strings.NewReader(tt.reply)))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadRESPErrorInArray checks that an error reply inside an array
+// doesn't leave the rest of the array to be read as the next reply.
+func TestReadRESPErrorInArray(t *testing.T) {
+	rd := bufio.NewReader(strings.NewReader("*3\r\n-ERR first\r\n-ERR second\r\n$3\r\nabc\r\n:7\r\n"))
+	var replyErr redisError
+	if _, err := readRESP(rd); !errors.As(err, &replyErr) || string(replyErr) != "ERR first" {
+		t.Fatalf("error %v, want the first error reply", err)
+	}
+	if got, err := readRESP(rd); err != nil || got != int64(7) {
+		t.Errorf("next reply %#v, %v, want 7", got, err)
+	}
+}
+
+func TestParseRedisURL(t *testing.T) {
+	type settings struct {
+		addr               string
+		tls                bool
+		username, password string
+		db                 int
+	}
+	tests := []struct {
+		raw  string
+		want settings
+		err  bool
+	}{
+		{raw: "redis://redis", want: settings{addr: "redis:6379"}},
+		{raw: "redis://redis.db.svc:6380/2", want: settings{addr: "redis.db.svc:6380", db: 2}},
+		{raw: "rediss://:s3cret@redis", want: settings{addr: "redis:6379", tls: true, password: "s3cret"}},
+		{raw: "redis://gohome:s3cret@[::1]", want: settings{addr: "[::1]:6379", username: "gohome", password: "s3cret"}},
+		{raw: "http://redis", err: true},
+		{raw: "redis://", err: true},
+		{raw: "redis://redis/zero", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			c, err := parseRedisURL(tt.raw)
+			if tt.err {
+				if err == nil {
+					t.Errorf("got %s, want an error", c.addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := (settings{c.addr, c.tls, c.username, c.password, c.db}); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeRedis serves RESP on a local port, answering each command with what
+// reply returns for it, and records the commands it was sent.
+type fakeRedis struct {
+	addr  string
+	reply func(args []string) string
+
+	mu       sync.Mutex
+	commands [][]string
+	conns    int
+}
+
+func newFakeRedis(t *testing.T, reply func(args []string) string) *fakeRedis {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	f := &fakeRedis{addr: l.Addr().String(), reply: reply}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns++
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		command, err := readRESP(rd)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range command.([]any) {
+			args = append(args, arg.(string))
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		f.mu.Unlock()
+		if _, err := conn.Write([]byte(f.reply(args))); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedisClient(t *testing.T) {
+	f := newFakeRedis(t, func(args []string) string {
+		switch strings.Join(args, " ") {
+		case "AUTH gohome s3cret", "SELECT 3":
+			return "+OK\r\n"
+		case "EXEC":
+			return "*2\r\n-ERR failed\r\n:1\r\n"
+		case "HGETALL gohome:clicks":
+			return "*4\r\n$7\r\ngrafana\r\n$1\r\n3\r\n$4\r\ndocs\r\n$1\r\n1\r\n"
+		case "GET gohome:garbled":
+			return "?garbled\r\n"
+		case "GET gohome:missing":
+			return "$-1\r\n"
+		}
+		return "$5\r\nvalue\r\n"
+	})
+	client, err := parseRedisURL("redis://gohome:s3cret@" + f.addr + "/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.Do(ctx, "EXEC"); err == nil || !strings.Contains(err.Error(), "ERR failed") {
+		t.Errorf("EXEC: %v, want the error reply", err)
+	}
+	// The rest of EXEC's reply was read with it, so HGETALL gets its own.
+	fields, err := client.HGetAll(ctx, "clicks")
+	if err != nil || !reflect.DeepEqual(fields, map[string]string{"grafana": "3", "docs": "1"}) {
+		t.Errorf("HGetAll: %v, %v", fields, err)
+	}
+	if _, ok, err := client.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("Get of a missing key: %v, %v", ok, err)
+	}
+
+	// A reply that can't be parsed drops the connection, and the next
+	// command dials again.
+	if _, _, err := client.Get(ctx, "garbled"); err == nil {
+		t.Error("Get of a garbled reply succeeded")
+	}
+	if value, ok, err := client.Get(ctx, "key"); value != "value" || !ok || err != nil {
+		t.Errorf("Get after reconnecting: %q, %v, %v", value, ok, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conns != 2 {
+		t.Errorf("%d connections, want 2", f.conns)
+	}
+	if got := strings.Join(f.commands[0], " ") + "; " + strings.Join(f.commands[1], " "); got != "AUTH gohome s3cret; SELECT 3" {
+		t.Errorf("connection set up with %q", got)
+	}
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// linksKeyPrefix marks ConfigMap keys that define a remote links file:
+	//   links-<name>: "https://config.example.com/links.yaml|category=Shared"
+	linksKeyPrefix = "links-"
+	// defaultLinksInterval is how often every links file is refetched.
+	defaultLinksInterval = 15 * time.Minute
+	// maxLinksBytes caps the size of a fetched links file.
+	maxLinksBytes = 1 << 20
+)
+
+// LinkSource is one links-<name> entry from the ConfigMap.
+type LinkSource struct {
+	Name     string
+	URL      string
+	Category string // for links that don't name one; "General" if empty
+}
+
+// RemoteLink is one link in a links file. The file is JSON or YAML, either
+// a list of links or an object with a "links" list.
+type RemoteLink struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Category string   `json:"category,omitempty"`
+	Icon     string   `json:"icon,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// linksState is what RemoteLinks remembers about one links file URL.
+type linksState struct {
+	links     []RemoteLink
+	attempted time.Time // last attempt, successful or not
+}
+
+// RemoteLinks periodically fetches the links files named by links-* keys
+// in the background, so a fleet of GoHome instances can share a centrally
+// managed set of common links. A file that fails keeps its last good links.
+type RemoteLinks struct {
+	client   *http.Client
+	interval time.Duration
+	mu       sync.Mutex
+	files    map[string]linksState // keyed by URL
+}
+
+// NewRemoteLinksFromEnv creates a fetcher that refetches every
+// LINKS_INTERVAL.
+func NewRemoteLinksFromEnv() *RemoteLinks {
+	return &RemoteLinks{
+		client:   &http.Client{Timeout: 15 * time.Second},
+		interval: durationFromEnv("LINKS_INTERVAL", defaultLinksInterval),
+		files:    make(map[string]linksState),
+	}
+}
+
+// parseLinkSources reads every links-* key from ConfigMap data, sorted by
+// name. Values are the URL followed by optional |key=value settings.
+func parseLinkSources(data map[string]string) []LinkSource {
+	var sources []LinkSource
+	for key, value := range data {
+		if !strings.HasPrefix(key, linksKeyPrefix) {
+			continue
+		}
+		parts := strings.Split(value, "|")
+		source := LinkSource{Name: strings.TrimPrefix(key, linksKeyPrefix), URL: strings.TrimSpace(parts[0])}
+		if source.URL == "" {
+			log.Printf("Warning: links %s has no URL, skipping", source.Name)
+			continue
+		}
+		for _, opt := range parts[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch k {
+			case "category":
+				source.Category = strings.TrimSpace(v)
+			default:
+				log.Printf("Warning: links %s has unknown option %q", source.Name, opt)
+			}
+		}
+		sources = append(sources, source)
+	}
+	slices.SortFunc(sources, func(a, b LinkSource) int { return strings.Compare(a.Name, b.Name) })
+	return sources
+}
+
+// Job refetches the links files returned by sources every interval. The
+// list is re-read more often than that so a newly added file shows up
+// within a minute. Files that are no longer configured are dropped.
+func (l *RemoteLinks) Job(sources func(context.Context) []LinkSource) Job {
+	if l == nil {
+		return Job{}
+	}
+	return Job{
+		Name:     "links",
+		Interval: min(l.interval, time.Minute),
+		Run:      func(ctx context.Context) error { return l.fetchAll(ctx, sources(ctx)) },
+	}
+}
+
+// fetchAll fetches every links file that is due concurrently, returning
+// the errors of those that failed.
+func (l *RemoteLinks) fetchAll(ctx context.Context, sources []LinkSource) error {
+	var errs []error
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		l.mu.Lock()
+		due := time.Since(l.files[source.URL].attempted) >= l.interval
+		l.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		wg.Go(func() {
+			fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			links, err := l.fetch(fetchCtx, source.URL)
+			cancel()
+			if err != nil {
+				log.Printf("Warning: Could not fetch links %s: %v", source.Name, err)
+			}
+
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			state := l.files[source.URL]
+			state.attempted = time.Now()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", source.Name, err))
+			} else {
+				state.links = links
+			}
+			l.files[source.URL] = state
+		})
+	}
+	wg.Wait()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for u := range l.files {
+		if !slices.ContainsFunc(sources, func(s LinkSource) bool { return s.URL == u }) {
+			delete(l.files, u)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetch downloads and parses one links file.
+func (l *RemoteLinks) fetch(ctx context.Context, rawURL string) ([]RemoteLink, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, */*;q=0.5")
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxLinksBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxLinksBytes {
+		return nil, fmt.Errorf("larger than %d bytes", maxLinksBytes)
+	}
+	return parseLinksFile(body)
+}
+
+// parseLinksFile parses a links file, dropping links without a name or URL.
+func parseLinksFile(body []byte) ([]RemoteLink, error) {
+	var links []RemoteLink
+	if err := yaml.Unmarshal(body, &links); err != nil {
+		var file struct {
+			Links []RemoteLink `json:"links"`
+		}
+		if yaml.Unmarshal(body, &file) != nil {
+			return nil, fmt.Errorf("not a list of links or an object with a links list: %w", err)
+		}
+		links = file.Links
+	}
+	return slices.DeleteFunc(links, func(link RemoteLink) bool {
+		return strings.TrimSpace(link.Name) == "" || strings.TrimSpace(link.URL) == ""
+	}), nil
+}
+
+// Bookmarks returns the links of the files named by sources as bookmarks,
+// leaving out those named like one of local, which take precedence. Files
+// that haven't been fetched yet contribute nothing.
+func (l *RemoteLinks) Bookmarks(sources []LinkSource, local []Bookmark) []Bookmark {
+	if l == nil || len(sources) == 0 {
+		return nil
+	}
+	taken := make(map[string]bool, len(local))
+	for _, b := range local {
+		taken[strings.ToLower(b.Name)] = true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var bookmarks []Bookmark
+	for _, source := range sources {
+		for _, link := range l.files[source.URL].links {
+			name := strings.TrimSpace(link.Name)
+			if taken[strings.ToLower(name)] {
+				continue
+			}
+			taken[strings.ToLower(name)] = true
+			b := Bookmark{
+				Name:     name,
+				URL:      strings.TrimSpace(link.URL),
+				Category: cmp.Or(strings.TrimSpace(link.Category), source.Category, "General"),
+				Tags:     link.Tags,
+				Groups:   link.Groups,
+				Icon:     link.Icon,
+			}
+			b.IconURL = resolveIcon(b.Icon)
+			bookmarks = append(bookmarks, b)
+		}
+	}
+	return bookmarks
+}
+
+// linkSources returns the links files configured in the ConfigMap.
+func (s *Server) linkSources(ctx context.Context) []LinkSource {
+	loadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	config, err := s.bookmarkManager.GetConfig(loadCtx)
+	if err != nil {
+		return nil
+	}
+	return config.LinkSources
+}
@@ -0,0 +1,410 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// storeNamespaceRevisions holds Revisions keyed by their ID.
+	storeNamespaceRevisions = "revisions"
+	// defaultRevisionHistory is how many revisions are kept.
+	defaultRevisionHistory = 20
+)
+
+// errNoRevision is returned for a revision ID that isn't kept.
+var errNoRevision = errors.New("no such revision")
+
+// Revision is the ConfigMap's data as it was before one change made
+// through the API or the page, which a rollback writes back.
+type Revision struct {
+	ID      string            `json:"id"` // sorts by time
+	Time    time.Time         `json:"time"`
+	Actor   string            `json:"actor"`  // as in the audit log
+	Action  string            `json:"action"` // e.g. "order.save"
+	Changes RestoreResult     `json:"changes"`
+	Data    map[string]string `json:"data,omitempty"` // left out of listings
+}
+
+// KeyDiff is how one key of the ConfigMap differs between now and a
+// revision, line by line.
+type KeyDiff struct {
+	Key    string     `json:"key"`
+	Change string     `json:"change"` // what a rollback does: "added", "changed" or "removed"
+	Lines  []DiffLine `json:"lines"`
+}
+
+// DiffLine is one line of a KeyDiff.
+type DiffLine struct {
+	Op   string `json:"op"` // "+" added by a rollback, "-" removed by it, " " unchanged
+	Text string `json:"text"`
+}
+
+// Class is the CSS class of the line on /admin/revisions.
+func (l DiffLine) Class() string {
+	switch l.Op {
+	case "+":
+		return "diff-added"
+	case "-":
+		return "diff-removed"
+	default:
+		return "diff-same"
+	}
+}
+
+// Revisions keeps the REVISION_HISTORY most recent versions of the
+// ConfigMap replaced by writes, in the data store when there is one so they
+// survive restarts.
+type Revisions struct {
+	store Store // nil without STORE or DATA_DIR
+	keep  int
+
+	mu        sync.Mutex
+	revisions []Revision // oldest first
+}
+
+// NewRevisionsFromEnv creates the revision history, keeping
+// REVISION_HISTORY revisions and loading earlier ones from the store.
+func NewRevisionsFromEnv() *Revisions {
+	h := &Revisions{keep: defaultRevisionHistory}
+	if v := os.Getenv("REVISION_HISTORY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			h.keep = n
+		} else {
+			log.Printf("Warning: invalid REVISION_HISTORY %q, using %d", v, h.keep)
+		}
+	}
+	if h.store = sharedStore(); h.store == nil {
+		return h
+	}
+	revisions, err := listJSON[Revision](context.Background(), h.store, storeNamespaceRevisions)
+	if err != nil {
+		log.Printf("Warning: Could not load revisions: %v", err)
+	}
+	h.revisions = slices.Collect(maps.Values(revisions))
+	slices.SortFunc(h.revisions, func(x, y Revision) int { return strings.Compare(x.ID, y.ID) })
+	h.trim(context.Background())
+	return h
+}
+
+// Record keeps change.Before as a revision, unless the write changed
+// nothing or was made in demo mode.
+func (h *Revisions) Record(ctx context.Context, actor, action string, change ConfigChange) {
+	if h == nil || h.keep == 0 || change.Before == nil {
+		return
+	}
+	changes := diffData(change.Before, change.After)
+	if len(changes.Added)+len(changes.Changed)+len(changes.Removed) == 0 {
+		return
+	}
+	revision := Revision{Time: time.Now().UTC(), Actor: actor, Action: action, Changes: changes, Data: change.Before}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// IDs must be unique, so two changes in the same nanosecond are
+	// nudged apart, as in the audit log.
+	if n := len(h.revisions); n > 0 && !revision.Time.After(h.revisions[n-1].Time) {
+		revision.Time = h.revisions[n-1].Time.Add(time.Nanosecond)
+	}
+	revision.ID = fmt.Sprintf("%020d", revision.Time.UnixNano())
+	h.revisions = append(h.revisions, revision)
+	if h.store != nil {
+		if err := setJSON(ctx, h.store, storeNamespaceRevisions, revision.ID, revision); err != nil {
+			log.Printf("Warning: Could not store revision: %v", err)
+		}
+	}
+	h.trim(ctx)
+}
+
+// trim drops the oldest revisions beyond REVISION_HISTORY, from the store
+// too. The caller holds mu, or is the constructor.
+func (h *Revisions) trim(ctx context.Context) {
+	extra := len(h.revisions) - h.keep
+	if extra <= 0 {
+		return
+	}
+	if h.store != nil {
+		for _, revision := range h.revisions[:extra] {
+			if err := h.store.Delete(ctx, storeNamespaceRevisions, revision.ID); err != nil {
+				log.Printf("Warning: Could not delete old revision: %v", err)
+				break
+			}
+		}
+	}
+	h.revisions = slices.Clone(h.revisions[extra:])
+}
+
+// List returns the kept revisions without their data, newest first.
+func (h *Revisions) List() []Revision {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	revisions := make([]Revision, 0, len(h.revisions))
+	for _, revision := range slices.Backward(h.revisions) {
+		revision.Data = nil
+		revisions = append(revisions, revision)
+	}
+	return revisions
+}
+
+// Get returns the revision with id.
+func (h *Revisions) Get(id string) (Revision, bool) {
+	if h == nil {
+		return Revision{}, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, revision := range h.revisions {
+		if revision.ID == id {
+			return revision, true
+		}
+	}
+	return Revision{}, false
+}
+
+// diffRevision lists how current differs from the revision's data, as the
+// changes a rollback to it would make, sorted by key.
+func diffRevision(current map[string]string, revision Revision) []KeyDiff {
+	result := diffData(current, revision.Data)
+	diffs := make([]KeyDiff, 0, len(result.Added)+len(result.Changed)+len(result.Removed))
+	for change, keys := range map[string][]string{"added": result.Added, "changed": result.Changed, "removed": result.Removed} {
+		for _, key := range keys {
+			diffs = append(diffs, KeyDiff{Key: key, Change: change, Lines: diffLines(current[key], revision.Data[key])})
+		}
+	}
+	slices.SortFunc(diffs, func(x, y KeyDiff) int { return strings.Compare(x.Key, y.Key) })
+	return diffs
+}
+
+// diffLines compares two values line by line with a longest common
+// subsequence, which is plenty for the size of a ConfigMap.
+func diffLines(before, after string) []DiffLine {
+	a, b := splitDiffLines(before), splitDiffLines(after)
+	// common[i][j] is the length of the LCS of a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, DiffLine{Op: " ", Text: a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || common[i][j+1] >= common[i+1][j]):
+			lines = append(lines, DiffLine{Op: "+", Text: b[j]})
+			j++
+		default:
+			lines = append(lines, DiffLine{Op: "-", Text: a[i]})
+			i++
+		}
+	}
+	return lines
+}
+
+// splitDiffLines splits a value into lines, ignoring the final newline.
+func splitDiffLines(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(value, "\n"), "\n")
+}
+
+// recordConfigChange records a write that replaced several keys of the
+// ConfigMap, such as a restore or rollback: the changed keys in the audit
+// log, and the data it replaced in the revision history. It returns which
+// keys changed.
+func (s *Server) recordConfigChange(ctx context.Context, actor, action, target string, change ConfigChange) RestoreResult {
+	result := diffData(change.Before, change.After)
+	before, after := make(map[string]string), make(map[string]string)
+	for _, key := range slices.Concat(result.Changed, result.Removed) {
+		before[key] = change.Before[key]
+	}
+	for _, key := range slices.Concat(result.Added, result.Changed) {
+		after[key] = change.After[key]
+	}
+	s.audit.Record(ctx, actor, action, target, before, after)
+	s.revisions.Record(ctx, actor, action, change)
+	return result
+}
+
+// rollback writes revision id back to the ConfigMap. The data it replaces
+// becomes a revision itself, so a rollback can be undone.
+func (s *Server) rollback(ctx context.Context, r *http.Request, id string) (RestoreResult, error) {
+	revision, ok := s.revisions.Get(id)
+	if !ok {
+		return RestoreResult{}, errNoRevision
+	}
+	change, err := s.bookmarkManager.Restore(ctx, revision.Data)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	result := s.recordConfigChange(ctx, s.auditActor(r), "revision.rollback", id, change)
+	log.Printf("Rolled back ConfigMap %s to revision %s from %s: %d added, %d changed, %d removed",
+		s.bookmarkManager.ConfigMapRef(), id, revision.Time.Format(time.RFC3339),
+		len(result.Added), len(result.Changed), len(result.Removed))
+	return result, nil
+}
+
+// handleRevisions serves the revision history as JSON, newest first.
+func (s *Server) handleRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions := s.revisions.List()
+	if revisions == nil {
+		revisions = []Revision{}
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// handleRevision serves one revision, with its data.
+func (s *Server) handleRevision(w http.ResponseWriter, r *http.Request) {
+	revision, ok := s.revisions.Get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": errNoRevision.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, revision)
+}
+
+// handleRevisionDiff serves how the ConfigMap differs from a revision.
+func (s *Server) handleRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	revision, ok := s.revisions.Get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": errNoRevision.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	current, err := s.bookmarkManager.Backup(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, diffRevision(current.Data, revision))
+}
+
+// handleRollback serves POST /api/v1/revisions/{id}/rollback, reporting
+// which keys changed.
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.rollback(ctx, r, r.PathValue("id"))
+	switch {
+	case errors.Is(err, errNoRevision):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, errNoConfigMap):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		log.Printf("Warning: Could not roll back ConfigMap %s: %v", s.bookmarkManager.ConfigMapRef(), err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// handleRollbackForm serves the rollback buttons on /admin/revisions, and
+// redirects back to it.
+func (s *Server) handleRollbackForm(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	if _, err := s.rollback(ctx, r, id); err != nil {
+		log.Printf("Warning: Could not roll back ConfigMap %s to revision %s: %v", s.bookmarkManager.ConfigMapRef(), id, err)
+		http.Redirect(w, r, "/admin/revisions?"+url.Values{"error": {err.Error()}}.Encode(), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/admin/revisions?"+url.Values{"rolledback": {id}}.Encode(), http.StatusSeeOther)
+}
+
+// RevisionView is a revision on /admin/revisions, with how the ConfigMap
+// differs from it now.
+type RevisionView struct {
+	Revision
+	Diff []KeyDiff
+}
+
+// RevisionsPage is the content of /admin/revisions.
+type RevisionsPage struct {
+	Revisions   []RevisionView // newest first
+	CanRollback bool           // the viewer may roll back (see requireEditor)
+	RolledBack  string         // the revision just rolled back to
+	Error       string         // why the last rollback failed
+}
+
+// handleRevisionsPage renders the revision history at /admin/revisions.
+func (s *Server) handleRevisionsPage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	page := &RevisionsPage{
+		CanRollback: !s.readOnly && s.kube() != nil,
+		RolledBack:  r.URL.Query().Get("rolledback"),
+		Error:       r.URL.Query().Get("error"),
+	}
+	if revisions := s.revisions.List(); len(revisions) > 0 {
+		current, err := s.bookmarkManager.Backup(ctx)
+		if err != nil {
+			log.Printf("Warning: Could not read ConfigMap %s: %v", s.bookmarkManager.ConfigMapRef(), err)
+		}
+		for _, summary := range revisions {
+			view := RevisionView{Revision: summary}
+			if revision, ok := s.revisions.Get(summary.ID); ok && err == nil {
+				view.Diff = diffRevision(current.Data, revision)
+			}
+			page.Revisions = append(page.Revisions, view)
+		}
+	}
+
+	prefs, _ := s.loadPreferences(r)
+	palette := resolvePalette(prefs, config)
+	data := PageData{
+		Config:     config,
+		DemoMode:   s.kube() == nil,
+		Theme:      resolveTheme(prefs, config),
+		Palette:    palette,
+		ThemeColor: themeColor(config, palette),
+		Locale:     localeFor(r, config),
+		Revisions:  page,
+		Now:        time.Now(),
+	}
+
+	data.Nonce = s.setCSP(w)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.templates[data.Locale.Lang].ExecuteTemplate(w, "revisions.html", data); err != nil {
+		log.Printf("Error rendering revisions template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
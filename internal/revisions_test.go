@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name, before, after string
+		want                string // one "<op><text>" per line
+	}{
+		{"same", "a\nb\n", "a\nb", " a| b"},
+		{"added", "", "a\nb", "+a|+b"},
+		{"removed", "a\nb", "", "-a|-b"},
+		{"changed line", "a\nb\nc", "a\nB\nc", " a|+B|-b| c"},
+		{"inserted in the middle", "a\nc", "a\nb\nc", " a|+b| c"},
+		{"moved", "a\nb\nc", "c\na\nb", "+c| a| b|-c"},
+		{"both empty", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, line := range diffLines(tt.before, tt.after) {
+				got = append(got, line.Op+line.Text)
+			}
+			if strings.Join(got, "|") != tt.want {
+				t.Errorf("got %q, want %q", strings.Join(got, "|"), tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffRevision(t *testing.T) {
+	current := map[string]string{"title": "Home", "bookmark-wiki": "https://wiki.example.com", "layout": "grid"}
+	revision := Revision{Data: map[string]string{"title": "Lab", "layout": "grid", "theme": "dark"}}
+
+	var got []string
+	for _, diff := range diffRevision(current, revision) {
+		var ops []string
+		for _, line := range diff.Lines {
+			ops = append(ops, line.Op+line.Text)
+		}
+		got = append(got, diff.Key+" "+diff.Change+": "+strings.Join(ops, "|"))
+	}
+	want := []string{
+		"bookmark-wiki removed: -https://wiki.example.com",
+		"theme added: +dark",
+		"title changed: +Lab|-Home",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRevisionsRecord(t *testing.T) {
+	store := newMemoryStore()
+	h := &Revisions{store: store, keep: 2}
+	ctx := context.Background()
+	for _, change := range []ConfigChange{
+		{Before: map[string]string{"title": "One"}, After: map[string]string{"title": "Two"}},
+		{Before: map[string]string{"title": "Two"}, After: map[string]string{"title": "Two"}}, // no change
+		{After: map[string]string{"title": "Demo"}},                                           // demo mode
+		{Before: map[string]string{"title": "Two"}, After: map[string]string{"title": "Three"}},
+		{Before: map[string]string{"title": "Three"}, After: map[string]string{"title": "Four", "theme": "dark"}},
+	} {
+		h.Record(ctx, "alice@example.com", "config.restore", change)
+	}
+
+	list := h.List()
+	if len(list) != 2 {
+		t.Fatalf("kept %d revisions, want 2", len(list))
+	}
+	if list[0].ID <= list[1].ID {
+		t.Errorf("not newest first: %s, %s", list[0].ID, list[1].ID)
+	}
+	for _, revision := range list {
+		if revision.Data != nil {
+			t.Errorf("listing %s has its data", revision.ID)
+		}
+	}
+	if got := list[0].Changes; !reflect.DeepEqual(got, RestoreResult{Added: []string{"theme"}, Changed: []string{"title"}, Removed: []string{}}) {
+		t.Errorf("changes %+v", got)
+	}
+
+	newest, ok := h.Get(list[0].ID)
+	if !ok || newest.Data["title"] != "Three" || newest.Actor != "alice@example.com" {
+		t.Errorf("Get(%s) = %+v, %v; want the data the last change replaced", list[0].ID, newest, ok)
+	}
+	if _, ok := h.Get("00000000000000000000"); ok {
+		t.Error("found a revision that was never kept")
+	}
+
+	// The store drops the trimmed revisions too.
+	stored, err := listJSON[Revision](ctx, store, storeNamespaceRevisions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 || !maps.Equal(stored[list[1].ID].Data, map[string]string{"title": "Two"}) {
+		t.Errorf("store has %+v", stored)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	bm, clientset := newTestBookmarkManager(t, map[string]string{
+		"title":        "Three",
+		"theme":        "dark",
+		clickCountsKey: `{"a":1}`,
+	})
+	s := &Server{bookmarkManager: bm, revisions: &Revisions{keep: 10}}
+	ctx := context.Background()
+	s.revisions.Record(ctx, "alice@example.com", "config.restore", ConfigChange{
+		Before: map[string]string{"title": "Two", "bookmark-wiki": "https://wiki.example.com"},
+		After:  map[string]string{"title": "Three", "theme": "dark"},
+	})
+	id := s.revisions.List()[0].ID
+
+	tests := []struct {
+		name, id string
+		status   int
+	}{
+		{"unknown revision", "00000000000000000000", http.StatusNotFound},
+		{"rollback", id, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/revisions/"+tt.id+"/rollback", nil)
+			r.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+			s.handleRollback(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+
+	want := map[string]string{"title": "Two", "bookmark-wiki": "https://wiki.example.com", clickCountsKey: `{"a":1}`}
+	if got := configMapData(t, clientset); !maps.Equal(got, want) {
+		t.Errorf("ConfigMap has %v, want %v", got, want)
+	}
+
+	// The rollback is a revision itself, so it can be undone.
+	list := s.revisions.List()
+	if len(list) != 2 || list[0].Action != "revision.rollback" || list[0].Actor != "anonymous" {
+		t.Fatalf("revisions %+v", list)
+	}
+	undo, _ := s.revisions.Get(list[0].ID)
+	if undo.Data["title"] != "Three" || undo.Data["theme"] != "dark" {
+		t.Errorf("undo revision has %v", undo.Data)
+	}
+	var changes RestoreResult
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.SetPathValue("id", list[0].ID)
+	w := httptest.NewRecorder()
+	s.handleRollback(w, r)
+	if err := json.NewDecoder(w.Body).Decode(&changes); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, RestoreResult{Added: []string{"theme"}, Changed: []string{"title"}, Removed: []string{"bookmark-wiki"}}) {
+		t.Errorf("undo changed %+v", changes)
+	}
+}
+
+func TestRollbackInDemoMode(t *testing.T) {
+	s := &Server{bookmarkManager: NewBookmarkManager(nil, "gohome", "gohome-config"), revisions: &Revisions{keep: 10}}
+	s.revisions.Record(context.Background(), "api-token", "config.restore", ConfigChange{
+		Before: map[string]string{"title": "Two"},
+		After:  map[string]string{"title": "Three"},
+	})
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.SetPathValue("id", s.revisions.List()[0].ID)
+	w := httptest.NewRecorder()
+	s.handleRollback(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("status %d, want %d", w.Code, http.StatusConflict)
+	}
+}
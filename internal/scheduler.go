@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// jobFinalTimeout bounds the last run of a Final job after shutdown.
+const jobFinalTimeout = 10 * time.Second
+
+// Job is periodic background work: probing health, fetching favicons,
+// polling feeds and so on.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Jitter is how much later than Interval each run may start, picked at
+	// random every time so jobs don't stay in lockstep with each other, or
+	// with other replicas. Zero means a tenth of Interval.
+	Jitter time.Duration
+	// Final runs the job once more after shutdown, for jobs that flush
+	// state somewhere.
+	Final bool
+	// Run does one round of work. Failures of individual items are joined
+	// into the error; they are counted, the job logs them itself.
+	Run func(context.Context) error
+}
+
+// Scheduler runs Jobs until shutdown and reports how they fare in the
+// gohome_job_* metrics.
+type Scheduler struct {
+	jobs        []Job
+	runs        *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	lastSuccess *prometheus.GaugeVec
+}
+
+// NewScheduler creates a scheduler without any jobs and registers its
+// metrics.
+func NewScheduler() *Scheduler {
+	s := newScheduler()
+	prometheus.MustRegister(s.runs, s.duration, s.lastSuccess)
+	return s
+}
+
+// newScheduler creates a scheduler whose metrics aren't registered.
+func newScheduler() *Scheduler {
+	return &Scheduler{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gohome_job_runs_total",
+			Help: "Background job runs by job and result (success or failure).",
+		}, []string{"job", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gohome_job_duration_seconds",
+			Help:    "Background job run duration in seconds by job.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"job"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gohome_job_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run of each background job.",
+		}, []string{"job"}),
+	}
+}
+
+// Add schedules job. Jobs of disabled components have no Run and are
+// ignored.
+func (s *Scheduler) Add(job Job) {
+	if job.Run == nil {
+		return
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// Run runs every job right away and then every Interval, plus jitter, until
+// ctx is cancelled. It returns once all of them have stopped.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Go(func() { s.loop(ctx, job) })
+	}
+	wg.Wait()
+}
+
+// RunOnce runs jobs once, concurrently, for commands that show what the
+// page would without serving it. It returns once all of them have finished.
+func (s *Scheduler) RunOnce(ctx context.Context, jobs ...Job) {
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		if job.Run != nil {
+			wg.Go(func() { s.run(ctx, job) })
+		}
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	jitter := job.Jitter
+	if jitter == 0 {
+		jitter = job.Interval / 10
+	}
+	for {
+		s.run(ctx, job)
+
+		delay := job.Interval
+		if jitter > 0 {
+			delay += rand.N(jitter)
+		}
+		select {
+		case <-ctx.Done():
+			if job.Final {
+				finalCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobFinalTimeout)
+				s.run(finalCtx, job)
+				cancel()
+			}
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// run runs job once and records the outcome. A panic counts as a failure
+// rather than taking the process down.
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Error: job %s panicked: %v", job.Name, r)
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return job.Run(ctx)
+	}()
+
+	s.duration.WithLabelValues(job.Name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.runs.WithLabelValues(job.Name, "failure").Inc()
+		return
+	}
+	s.runs.WithLabelValues(job.Name, "success").Inc()
+	s.lastSuccess.WithLabelValues(job.Name).SetToCurrentTime()
+}
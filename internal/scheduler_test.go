@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricValue reads a counter or gauge.
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	t.Helper()
+	var out dto.Metric
+	if err := m.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Counter != nil {
+		return out.Counter.GetValue()
+	}
+	return out.Gauge.GetValue()
+}
+
+func TestSchedulerRecordsRuns(t *testing.T) {
+	tests := []struct {
+		name      string
+		run       func(context.Context) error
+		result    string
+		succeeded bool
+	}{
+		{"success", func(context.Context) error { return nil }, "success", true},
+		{"failure", func(context.Context) error { return errors.New("feed down") }, "failure", false},
+		{"panic", func(context.Context) error { panic("nil map") }, "failure", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScheduler()
+			s.RunOnce(context.Background(), Job{Name: "job", Run: tt.run}, Job{Name: "disabled"})
+			if n := metricValue(t, s.runs.WithLabelValues("job", tt.result)); n != 1 {
+				t.Errorf("%s runs = %v, want 1", tt.result, n)
+			}
+			if succeeded := metricValue(t, s.lastSuccess.WithLabelValues("job")) > 0; succeeded != tt.succeeded {
+				t.Errorf("last success set = %v, want %v", succeeded, tt.succeeded)
+			}
+		})
+	}
+}
+
+func TestSchedulerAddSkipsDisabledJobs(t *testing.T) {
+	s := newScheduler()
+	s.Add(Job{Name: "disabled", Interval: time.Minute})
+	s.Add(Job{Name: "enabled", Interval: time.Minute, Run: func(context.Context) error { return nil }})
+	if len(s.jobs) != 1 || s.jobs[0].Name != "enabled" {
+		t.Errorf("jobs %+v, want only the enabled one", s.jobs)
+	}
+}
+
+func TestSchedulerRunsUntilShutdown(t *testing.T) {
+	tests := []struct {
+		name  string
+		final bool
+	}{
+		{"not final", false},
+		{"final", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var runs atomic.Int32
+			var finalLive atomic.Bool
+			s := newScheduler()
+			s.Add(Job{
+				Name:     "job",
+				Interval: time.Millisecond,
+				Jitter:   time.Millisecond,
+				Final:    tt.final,
+				Run: func(ctx context.Context) error {
+					n := runs.Add(1)
+					if n == 3 {
+						cancel()
+					}
+					if n > 3 && ctx.Err() == nil {
+						finalLive.Store(true)
+					}
+					return nil
+				},
+			})
+
+			done := make(chan struct{})
+			go func() {
+				s.Run(ctx)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("scheduler didn't stop")
+			}
+
+			want := 3.0
+			if tt.final {
+				want = 4
+			}
+			if n := metricValue(t, s.runs.WithLabelValues("job", "success")); n != want {
+				t.Errorf("%v runs, want %v", n, want)
+			}
+			if tt.final && !finalLive.Load() {
+				t.Error("final run didn't get a live context")
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchResult is a single ranked hit returned by /api/v1/search.
+type SearchResult struct {
+	Kind      string   `json:"kind"` // "app", "service" or "bookmark"
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	Host      string   `json:"host,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	Category  string   `json:"category,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Score     int      `json:"score"`
+}
+
+// searchField is one piece of text a query term can match, with a weight
+// reflecting how strongly a match there suggests the user meant this item.
+type searchField struct {
+	text   string
+	weight int
+}
+
+const defaultSearchLimit = 20
+
+// handleSearch serves /api/v1/search?q=...&limit=N, fuzzy-matching the query
+// across ingresses and bookmarks and returning results best-first.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing q parameter"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: search could not load ingresses: %v", err)
+	}
+	var bookmarks []Bookmark
+	if config, err := s.bookmarkManager.GetConfig(ctx); err == nil {
+		apps, services, bookmarks = s.restrictTiles(r, s.resolveViewer(ctx, r), config, apps, services, config.Bookmarks)
+	}
+
+	results := searchItems(query, apps, services, bookmarks)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"query":   query,
+		"results": results,
+	})
+}
+
+// searchItems scores every ingress and bookmark against query and returns the
+// matches, highest score first. Every whitespace-separated term in the query
+// must match at least one field for an item to be included.
+func searchItems(query string, apps, services []IngressInfo, bookmarks []Bookmark) []SearchResult {
+	terms := strings.Fields(strings.ToLower(query))
+	var results []SearchResult
+
+	addIngress := func(kind string, info IngressInfo) {
+		fields := []searchField{{info.Name, 3}, {info.Host, 1}, {info.Namespace, 1}}
+		for _, tag := range info.Tags {
+			fields = append(fields, searchField{tag, 2})
+		}
+		if score := scoreTerms(terms, fields); score > 0 {
+			results = append(results, SearchResult{
+				Kind:      kind,
+				Name:      info.Name,
+				URL:       info.URL,
+				Host:      info.Host,
+				Namespace: info.Namespace,
+				Tags:      info.Tags,
+				Score:     score,
+			})
+		}
+	}
+	for _, info := range apps {
+		addIngress("app", info)
+	}
+	for _, info := range services {
+		addIngress("service", info)
+	}
+
+	for _, b := range bookmarks {
+		fields := []searchField{{b.Name, 3}, {b.Category, 1}, {hostOf(b.URL), 1}}
+		for _, tag := range b.Tags {
+			fields = append(fields, searchField{tag, 2})
+		}
+		if score := scoreTerms(terms, fields); score > 0 {
+			results = append(results, SearchResult{
+				Kind:     "bookmark",
+				Name:     b.Name,
+				URL:      b.URL,
+				Host:     hostOf(b.URL),
+				Category: b.Category,
+				Tags:     b.Tags,
+				Score:    score,
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// scoreTerms sums, for each term, the best weighted score across fields. It
+// returns 0 if any term matches nothing.
+func scoreTerms(terms []string, fields []searchField) int {
+	total := 0
+	for _, term := range terms {
+		best := 0
+		for _, f := range fields {
+			if score := fuzzyScore(term, strings.ToLower(f.text)) * f.weight; score > best {
+				best = score
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// fuzzyScore rates how well query matches text, both already lower-cased.
+// static/app.js carries a copy of this for instant client-side filtering, so
+// keep the two in step.
+// Exact, prefix and substring matches rank highest; otherwise the query's
+// characters must appear in order (so "grf" matches "grafana"), with bonuses
+// for consecutive runs and for matches at the start of a word. It returns 0
+// when there is no match at all.
+func fuzzyScore(query, text string) int {
+	if query == "" || text == "" {
+		return 0
+	}
+	switch {
+	case text == query:
+		return 1000
+	case strings.HasPrefix(text, query):
+		return 700 - min(len(text)-len(query), 100)
+	}
+	if idx := strings.Index(text, query); idx >= 0 {
+		return 300 - min(idx, 100)
+	}
+
+	runes := []rune(text)
+	score, ti, run := 100, 0, 0
+	for _, qc := range query {
+		found := false
+		for ti < len(runes) {
+			tc := runes[ti]
+			atBoundary := ti == 0 || strings.ContainsRune(" -_./", runes[ti-1])
+			ti++
+			if tc == qc {
+				found = true
+				run++
+				score += 10 * run
+				if atBoundary {
+					score += 15
+				}
+				break
+			}
+			run = 0
+			score--
+		}
+		if !found {
+			return 0
+		}
+	}
+	return max(score, 1)
+}
+
+// hostOf extracts the host from a URL string, or returns it unchanged if it
+// doesn't look like a URL.
+func hostOf(rawURL string) string {
+	rest := rawURL
+	if _, after, ok := strings.Cut(rawURL, "://"); ok {
+		rest = after
+	}
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
@@ -2,14 +2,22 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"html/template"
 	"log"
 	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
 	"time"
 
 	"sync"
+	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -18,13 +26,50 @@ import (
 
 // Server represents the HTTP server
 type Server struct {
-	k8sClient            *K8sClient
+	k8s                  atomic.Pointer[K8sClient] // nil in demo mode, see kube
 	bookmarkManager      *BookmarkManager
-	templates            *template.Template
+	templates            map[string]*template.Template // per language, see localizeTemplates
 	port                 string
+	apiToken             string // bearer token required by mutating /api/ endpoints; empty disables them
+	readOnly             bool   // READ_ONLY=true: mutating endpoints are disabled whatever the auth
+	csp                  string // CSP: "enforce", "report-only" or "off"
+	sessions             *Sessions
+	authUserHeader       string         // header an authenticating proxy puts the login in; empty to ignore
+	authGroupsHeader     string         // header an authenticating proxy puts the login's groups in; empty to ignore
+	trustedProxies       []netip.Prefix // peers whose identity headers are believed, see fromTrustedProxy
 	mux                  *http.ServeMux
 	handler              http.Handler // instrumented handler, built once, shared by all listeners
 	tsLocalClient        *local.Client
+	notifier             *Dispatcher
+	alerts               *AlertForwarder // nil unless ALERTMANAGER_URL or ALERT_WEBHOOK_URL is set
+	heartbeat            *Heartbeat      // nil unless HEARTBEAT_URL is set
+	audit                *AuditLog
+	revisions            *Revisions
+	images               *ImageProxy
+	favicons             *FaviconScraper // nil when FAVICON_SCRAPING=false
+	health               *HealthChecker  // nil when HEALTH_CHECKS=false
+	dns                  *DNSChecker     // nil unless DNS_CHECKS=true
+	certs                *CertMonitor
+	weather              *WeatherFetcher
+	feeds                *FeedAggregator
+	calendars            *CalendarAggregator
+	github               *GitHubFetcher
+	promql               *PromQLFetcher
+	hass                 *HomeAssistantFetcher
+	media                *MediaFetcher
+	probes               *NetProbes
+	capacity             *CapacityMonitor
+	launcher             *Launcher
+	grafana              *GrafanaRenderer
+	widgets              []Widget // the enabled widgets above, in job order
+	clicks               *ClickCounter
+	access               *AccessChecker
+	providers            []Provider    // the configured discovery sources, see providerFactories
+	tilesChanged         chan struct{} // a provider saw a change; see watchProviders
+	ready                readiness
+	timeouts             RenderTimeouts
+	store                Store // nil unless STORE or DATA_DIR is set
+	scheduler            *Scheduler
 	appsDisplayed        prometheus.Gauge
 	servicesDisplayed    prometheus.Gauge
 	uniqueVisitors       *prometheus.GaugeVec
@@ -33,6 +78,8 @@ type Server struct {
 	httpRequestsInFlight prometheus.Gauge
 	httpRequestsTotal    *prometheus.CounterVec
 	httpRequestDuration  *prometheus.HistogramVec
+	version              string
+	startTime            time.Time
 }
 
 // PageData represents the data passed to templates
@@ -43,12 +90,70 @@ type PageData struct {
 	Error         string
 	DemoMode      bool
 	TailscaleUser string // email of the viewing tailnet peer, empty for local requests
+	Theme         string // resolved colour scheme for this visitor: "auto", "light" or "dark"
+	Palette       string // resolved colour palette for this visitor
+	ThemeColor    string // theme-color meta value, see themeColor
+	Palettes      []string
+	Layout        string // resolved tile layout for this visitor: "grid" or "list"
+	GroupBy       string // resolved tile grouping for this visitor, one of Groupings
+	Groupings     []string
+	Compact       string // "auto", "always" or "never", see resolveCompact
+	Locale        *Locale
+	Nonce         string              // this response's CSP nonce, for inline <script> and <style> elements
+	Preferences   map[string][]string // the visitor's lists for static/app.js, see clientPreferences
+
+	Announcements      []Announcement     // unexpired announcements the visitor hasn't dismissed
+	Favorites          []Favorite         // tiles the visitor has pinned, shown first
+	Pinned             map[string]bool    // tile IDs in Favorites
+	Recent             []Favorite         // tiles the visitor opened most recently, newest first
+	MostUsed           []Favorite         // tiles everyone opens most, see mostUsed
+	Hidden             map[string]bool    // tile IDs the visitor has hidden
+	HiddenCount        int                // number of tiles the visitor has hidden
+	ShowHidden         bool               // hidden tiles are rendered (dimmed) instead of left out
+	BookmarkCategories []BookmarkCategory // Config.Bookmarks grouped for display
+	Groups             []TileGroup        // all tiles regrouped when GroupBy isn't "category"
+	Collapsed          map[string]bool    // group IDs that render collapsed
+	CanEdit            bool               // viewer may rearrange tiles (see requireEditor)
+	CanRefresh         bool               // viewer may make the degraded banner's retry refresh from the cluster (see requireEditor)
+	Clock              *ClockWidget       // nil unless the clock widget is enabled
+	Widgets            map[string]any     // what each enabled widget renders, by Widget.Name
+
+	Kiosk          bool // full-screen wall display: no controls, clock, auto-refresh
+	Export         bool // static snapshot from gohome export-html: no scripts or controls
+	RefreshSeconds int  // kiosk refresh interval
+	HealthSummary  HealthSummary
+
+	StatusRows []StatusRow // every monitored target, for /status
+	Now        time.Time   // render time, for relative times on /status
+
+	Audit     []AuditRecord  // newest first, for /admin/audit
+	Revisions *RevisionsPage // for /admin/revisions
+
+	Launch *LaunchPage // the tile /launch found down
+
+	Onboarding     *Onboarding     // setup guide shown instead of an empty homepage
+	AccessProblems []AccessProblem // permissions GoHome lacks, see AccessChecker
+	Degraded       *Degraded       // set when ingresses couldn't be listed
+	Page           *Page           // the configured page being shown; nil on the homepage
+}
+
+// BookmarkCount returns the number of bookmarks shown across all categories.
+func (d PageData) BookmarkCount() int {
+	n := 0
+	for _, c := range d.BookmarkCategories {
+		n += len(c.Bookmarks)
+	}
+	return n
 }
 
 // NewServer creates a new HTTP server
 func NewServer(k8sClient *K8sClient, bookmarkManager *BookmarkManager, Version string) (*Server, error) {
 	// Parse templates
-	templates, err := template.ParseGlob("templates/*.html")
+	base, err := template.New("").Funcs(templateFuncs()).ParseGlob("templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	templates, err := localizeTemplates(base)
 	if err != nil {
 		return nil, err
 	}
@@ -95,13 +200,56 @@ func NewServer(k8sClient *K8sClient, bookmarkManager *BookmarkManager, Version s
 	}, []string{"email"})
 	prometheus.MustRegister(uniqueVisitors)
 
+	// API_TOKEN guards the mutating API endpoints. When unset they are
+	// disabled entirely rather than left open.
+	apiToken := os.Getenv("API_TOKEN")
+	readOnly := readOnlyEnabled()
+	if readOnly {
+		log.Printf("Read-only mode: the API and the page will not change the ConfigMap or preferences")
+	}
+
+	// Favicon scraping is on by default; it can be turned off for clusters
+	// where outbound requests to every service are unwelcome.
+	var favicons *FaviconScraper
+	if os.Getenv("FAVICON_SCRAPING") != "false" {
+		favicons = NewFaviconScraper()
+	}
+
 	mux := http.NewServeMux()
 
 	s := &Server{
-		k8sClient:            k8sClient,
 		bookmarkManager:      bookmarkManager,
 		templates:            templates,
 		port:                 port,
+		apiToken:             apiToken,
+		readOnly:             readOnly,
+		csp:                  cspModeFromEnv(),
+		sessions:             NewSessionsFromEnv(),
+		timeouts:             NewRenderTimeoutsFromEnv(),
+		authUserHeader:       os.Getenv("AUTH_USER_HEADER"),
+		authGroupsHeader:     os.Getenv("AUTH_GROUPS_HEADER"),
+		trustedProxies:       trustedProxiesFromEnv(),
+		notifier:             NewDispatcherFromEnv(),
+		alerts:               NewAlertForwarderFromEnv(),
+		heartbeat:            NewHeartbeatFromEnv(),
+		audit:                NewAuditLogFromEnv(),
+		revisions:            NewRevisionsFromEnv(),
+		images:               sharedImageProxy(),
+		favicons:             favicons,
+		health:               NewHealthCheckerFromEnv(),
+		dns:                  NewDNSCheckerFromEnv(),
+		weather:              NewWeatherFetcher(),
+		feeds:                NewFeedAggregatorFromEnv(),
+		calendars:            NewCalendarAggregatorFromEnv(),
+		github:               NewGitHubFetcherFromEnv(),
+		promql:               NewPromQLFetcherFromEnv(),
+		hass:                 NewHomeAssistantFetcherFromEnv(),
+		media:                NewMediaFetcherFromEnv(),
+		grafana:              NewGrafanaRendererFromEnv(),
+		clicks:               NewClickCounter(),
+		tilesChanged:         make(chan struct{}, 1),
+		store:                sharedStore(),
+		scheduler:            NewScheduler(),
 		mux:                  mux,
 		appsDisplayed:        appsDisplayed,
 		servicesDisplayed:    servicesDisplayed,
@@ -110,15 +258,83 @@ func NewServer(k8sClient *K8sClient, bookmarkManager *BookmarkManager, Version s
 		httpRequestsInFlight: httpRequestsInFlight,
 		httpRequestsTotal:    httpRequestsTotal,
 		httpRequestDuration:  httpRequestDuration,
+		version:              Version,
+		startTime:            time.Now(),
 	}
-
-	s.mux.HandleFunc("/", s.handleHome)
+	s.k8s.Store(k8sClient)
+	s.providers = newProviders(s)
+	s.ready.timeout = durationFromEnv("INITIAL_SYNC_TIMEOUT", defaultInitialSyncTimeout)
+	s.access = NewAccessCheckerFromEnv(s.kube, bookmarkManager)
+	if s.health != nil {
+		s.health.notifier = s.notifier
+	}
+	s.certs = NewCertMonitorFromEnv(s.health, s.kube)
+	s.probes = NewNetProbesFromEnv(s.kube)
+	s.capacity = NewCapacityMonitorFromEnv(s.kube)
+	s.widgets = slices.DeleteFunc([]Widget{
+		widget(s.weather),
+		widget(s.feeds),
+		widget(s.calendars),
+		widget(s.github),
+		widget(s.promql),
+		widget(s.hass),
+		widget(s.media),
+		widget(s.probes),
+		widget(s.capacity),
+		widget(s.grafana),
+	}, func(w Widget) bool { return w == nil })
+	s.launcher = NewLauncherFromEnv(s.health)
+
+	// "/{$}" matches only the root path. Everything else that isn't
+	// explicitly registered falls through to the "/" catch-all, which renders
+	// a 404 instead of the homepage so that stray probes (favicon, robots,
+	// scanners) don't trigger a full round of cluster queries.
+	s.mux.HandleFunc("/{$}", s.handleHome)
+	s.mux.HandleFunc("GET /kiosk", s.handleKiosk)
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+	s.mux.HandleFunc("GET /ns/{namespace}", s.handleNamespace)
+	s.mux.HandleFunc("/", s.handleNotFound)
 	s.mux.HandleFunc("/health", s.handleHealth)
+	s.mux.HandleFunc("GET /readyz", s.handleReady)
+	s.mux.HandleFunc("GET /healthz/details", s.handleHealthDetails)
+	s.mux.HandleFunc("GET /robots.txt", s.handleRobots)
+	s.mux.HandleFunc("GET /favicon.ico", s.handleFavicon)
+	s.mux.HandleFunc("GET /manifest.webmanifest", s.handleManifest)
+	s.mux.HandleFunc("GET /sw.js", s.handleServiceWorker)
 	s.mux.Handle("/metrics", promhttp.Handler())
+	s.mux.HandleFunc("POST /api/v1/refresh", s.requireWritable(s.requireEditor(s.handleRefresh)))
+	s.mux.HandleFunc("PUT /api/v1/order/{group}", s.requireWritable(s.requireEditor(s.handleSaveOrder)))
+	s.mux.HandleFunc("GET /go", s.handleGo)
+	s.mux.HandleFunc("GET /go/{link...}", s.handleGoLink)
+	s.mux.HandleFunc("GET /click", s.handleClick)
+	s.mux.HandleFunc("POST /preferences", s.requireWritable(s.handlePreferences))
+	s.mux.HandleFunc("GET /launch", s.handleLaunch)
+	s.mux.HandleFunc("GET /qr", s.handleQR)
+	s.mux.HandleFunc("GET /api/v1/search", s.handleSearch)
+	s.mux.HandleFunc("GET /api/v1/health", s.handleHealthStatus)
+	s.mux.HandleFunc("GET /api/v1/stats", s.handleStats)
+	s.mux.HandleFunc("GET /api/v1/golinks", s.handleGoLinks)
+	s.mux.HandleFunc("GET /api/v1/tiles", s.handleTiles)
+	s.mux.HandleFunc("GET /api/v1/homepage/services.yaml", s.handleHomepageServices)
+	s.mux.HandleFunc("GET /api/v1/audit", s.requireToken(s.handleAuditRecords))
+	s.mux.HandleFunc("GET /api/v1/backup", s.requireToken(s.handleBackup))
+	s.mux.HandleFunc("POST /api/v1/restore", s.requireWritable(s.requireToken(s.handleRestore)))
+	s.mux.HandleFunc("GET /api/v1/revisions", s.requireToken(s.handleRevisions))
+	s.mux.HandleFunc("GET /api/v1/revisions/{id}", s.requireToken(s.handleRevision))
+	s.mux.HandleFunc("GET /api/v1/revisions/{id}/diff", s.requireToken(s.handleRevisionDiff))
+	s.mux.HandleFunc("POST /api/v1/revisions/{id}/rollback", s.requireWritable(s.requireToken(s.handleRollback)))
+	s.mux.HandleFunc("GET /admin/audit", s.requireViewer(s.handleAudit))
+	s.mux.HandleFunc("GET /admin/revisions", s.requireViewer(s.handleRevisionsPage))
+	s.mux.HandleFunc("POST /admin/revisions/{id}/rollback", s.requireWritable(s.requireEditor(s.handleRollbackForm)))
 	s.mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
 		s.handleVersion(w, r, Version)
 	})
-	s.mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static/"))))
+	s.mux.HandleFunc("GET /theme/{file}", s.handlePaletteCSS)
+	s.mux.HandleFunc("GET /icons/{pack}/{slug}", s.handleIcon)
+	s.mux.HandleFunc("GET /images", s.handleImage)
+	s.mux.HandleFunc("GET /favicons/{scheme}/{host}", s.handleFaviconProxy)
+	s.mux.HandleFunc("GET /grafana/{name}", s.handleGrafanaImage)
+	s.mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
 
 	// Build the instrumented handler once so that both the local TCP listener
 	// and the tsnet listener share a single middleware chain and a single
@@ -132,10 +348,101 @@ func NewServer(k8sClient *K8sClient, bookmarkManager *BookmarkManager, Version s
 			),
 		),
 	)
+	if tracingEnabled() {
+		s.handler = withTraceContext(s.handler)
+	}
 
 	return s, nil
 }
 
+// templateFuncs returns the helper functions available to every template.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"join":   strings.Join,
+		"hostOf": hostOf,
+		"add":    func(a, b int) int { return a + b },
+		"dict":   dict,
+		"tileID": tileID,
+		// {{markdown .Text}} renders ConfigMap Markdown as safe HTML.
+		"markdown": renderMarkdown,
+		"host":     hostname,
+		"default":  fallback,
+		"coalesce": coalesce,
+		// Replaced per language by localizeTemplates.
+		"t":        locales[defaultLanguage].T,
+		"date":     locales[defaultLanguage].Date,
+		"ago":      locales[defaultLanguage].Ago,
+		"duration": locales[defaultLanguage].Duration,
+	}
+}
+
+// dict builds a map from alternating key/value arguments so templates can pass
+// more than one value to a sub-template, e.g. {{template "x" (dict "Item" . "Index" $i)}}.
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict: odd number of arguments")
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// fallback returns value, or def if value is empty, so templates can say
+// {{.Location | default "–"}}.
+func fallback(def, value any) any {
+	if isEmpty(value) {
+		return def
+	}
+	return value
+}
+
+// coalesce returns the first of values that isn't empty, or nil, e.g.
+// {{coalesce .Label .Name}}.
+func coalesce(values ...any) any {
+	for _, v := range values {
+		if !isEmpty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+// isEmpty reports whether v is nil, the zero value of its type, or an
+// empty string, slice or map, like the truth of {{if}} the other way round.
+func isEmpty(v any) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+// hostname returns the host name of rawURL without credentials or port,
+// e.g. "grafana.example.com" for "https://admin@grafana.example.com:3000/d/x".
+func hostname(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	host := hostOf(rawURL)
+	if _, after, ok := strings.Cut(host, "@"); ok {
+		host = after
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
 // Handler returns the shared instrumented handler for the server, so it can
 // be served over any listener (local TCP, tsnet, etc.) with all listeners
 // contributing to the same set of metrics.
@@ -149,6 +456,39 @@ func (s *Server) SetTailscaleClient(lc *local.Client) {
 	s.tsLocalClient = lc
 }
 
+// RunBackground runs the server's background workers until ctx is cancelled.
+func (s *Server) RunBackground(ctx context.Context) {
+	s.watchProviders(ctx)
+	s.scheduler.Add(s.access.Job())
+	for _, job := range slices.Concat(s.linkJobs(), s.widgetJobs()) {
+		s.scheduler.Add(job)
+	}
+	s.scheduler.Add(s.alerts.Job(s.currentAlerts))
+	s.scheduler.Add(s.heartbeat.Job(s.healthDetails))
+	s.scheduler.Add(s.clicks.Job(s.bookmarkManager))
+	s.scheduler.Run(ctx)
+}
+
+// linkJobs fetch the bookmarks that come from outside the ConfigMap.
+func (s *Server) linkJobs() []Job {
+	return []Job{s.bookmarkManager.remote.Job(s.linkSources), s.bookmarkManager.mdns.Job()}
+}
+
+// widgetJobs gather what the tiles and widgets show. Unlike the other
+// background jobs they change nothing outside GoHome, so ExportHTML can
+// run them once.
+func (s *Server) widgetJobs() []Job {
+	jobs := []Job{
+		s.favicons.Job(),
+		s.health.Job(s.healthTargets),
+		s.certs.Job(s.certTiles),
+	}
+	for _, w := range s.widgets {
+		jobs = append(jobs, s.widgetJob(w))
+	}
+	return jobs
+}
+
 // Start starts the HTTP server on the configured local port.
 func (s *Server) Start() error {
 	log.Printf("Server starting on port %s", s.port)
@@ -165,11 +505,30 @@ func (s *Server) ServeListener(l net.Listener) error {
 
 // handleHome handles the main homepage
 func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	s.renderHome(w, r, requestedView(r), "")
+}
+
+// homeView is how renderHome shows the homepage.
+type homeView int
+
+const (
+	viewNormal homeView = iota
+	viewKiosk           // full-screen wall display, see handleKiosk
+	viewExport          // static snapshot without scripts, see ExportHTML
+)
+
+// renderHome renders the homepage in the given view. A non-empty slug
+// renders that configured page instead, or the namespace page for
+// "ns/<namespace>", or a 404 if there is none.
+func (s *Server) renderHome(w http.ResponseWriter, r *http.Request, view homeView, slug string) {
+	kiosk, export := view == viewKiosk, view == viewExport
+	ctx, cancel := s.timeouts.render(r.Context())
 	defer cancel()
 
 	// Load configuration and bookmarks
-	config, err := s.bookmarkManager.GetConfig(ctx)
+	sourceCtx, cancelSource := s.timeouts.source(ctx, sourceConfigMap)
+	config, err := s.bookmarkManager.GetConfig(sourceCtx)
+	cancelSource()
 	if err != nil {
 		log.Printf("Warning: Error loading config: %v", err)
 		// Use default config if ConfigMap is not available
@@ -178,9 +537,16 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 			Bookmarks: []Bookmark{},
 		}
 	}
+	page := s.pageFor(ctx, config, slug)
+	if slug != "" && page == nil {
+		s.renderNotFound(w, r)
+		return
+	}
 
 	// Resolve the Tailscale identity of the requesting peer, if available.
-	tailscaleUser := s.resolveViewer(ctx, r)
+	sourceCtx, cancelSource = s.timeouts.source(ctx, sourceIdentity)
+	tailscaleUser := s.resolveViewer(sourceCtx, r)
+	cancelSource()
 
 	// Record unique visitors by email. Only set the gauge label the first time
 	// we see each address so the series persists across scrapes rather than
@@ -194,30 +560,134 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 		s.seenVisitorsMu.Unlock()
 	}
 
-	// Load ingresses
-	apps, services, err := s.k8sClient.GetVisibleIngresses(ctx)
+	// Load ingresses, falling back to the last successful listing
+	sourceCtx, cancelSource = s.timeouts.source(ctx, sourceIngresses)
+	apps, services, err := s.visibleTiles(sourceCtx)
+	cancelSource()
+	var degraded *Degraded
 	if err != nil {
 		log.Printf("Warning: Error loading ingresses: %v", err)
-		// Continue with empty slices instead of failing
-		apps = []IngressInfo{}
-		services = []IngressInfo{}
+		status := s.kube().SyncStatus()
+		degraded = &Degraded{Since: status.LastSuccess, Restored: status.Restored, Err: err.Error()}
+	}
+
+	// Fall back to scraped favicons for anything without a configured icon.
+	s.applyFavicons(apps)
+	s.applyFavicons(services)
+	s.applyBookmarkFavicons(config.Bookmarks)
+	s.applyHealth(apps)
+	s.applyHealth(services)
+	s.applyBookmarkHealth(config.Bookmarks)
+	s.applyCerts(apps, services, config.Bookmarks)
+	s.applyTraffic(apps, services, config.Prometheus)
+	sourceCtx, cancelSource = s.timeouts.source(ctx, sourceDNS)
+	s.applyDNS(sourceCtx, apps, services, config.Bookmarks)
+	cancelSource()
+	applyLinkTargets(apps, config.NewTab)
+	applyLinkTargets(services, config.NewTab)
+	applyBookmarkLinkTargets(config.Bookmarks, config.NewTab)
+	switch {
+	case export:
+		// A snapshot links straight to the tiles: there is no server
+		// behind it to count clicks or check them first.
+	case config.Launch:
+		applyLaunchLinks(apps)
+		applyLaunchLinks(services)
+		applyBookmarkLaunchLinks(config.Bookmarks)
+	case trackClicks(config):
+		applyClickLinks(apps)
+		applyClickLinks(services)
+		applyBookmarkClickLinks(config.Bookmarks)
 	}
 
 	// Update the displayed gauges.
 	s.appsDisplayed.Set(float64(len(apps)))
 	s.servicesDisplayed.Set(float64(len(services)))
 
+	// Decide before hiding tiles: a page the visitor emptied themselves
+	// doesn't need a setup guide.
+	var onboarding *Onboarding
+	if s.kube() != nil && !kiosk && !export && needsOnboarding(apps, services, config) {
+		onboarding = s.buildOnboarding(ctx)
+	}
+	apps, services, config.Bookmarks = s.restrictTiles(r, tailscaleUser, config, apps, services, config.Bookmarks)
+	if page != nil {
+		apps, services, config.Bookmarks = page.filter(apps, services, config.Bookmarks)
+	}
+
+	prefs := s.viewerPreferences(w, r, tailscaleUser)
+	apps, services, bookmarks, hiddenCount := hideTiles(prefs.Hidden, prefs.ShowHidden, apps, services, config.Bookmarks)
+
+	categories := groupBookmarks(bookmarks)
+	applyCategoryStyles(categories, config.Categories)
+	applyTileOrder(config, apps, services, categories)
+
 	// Prepare page data
+	favorites := buildFavorites(prefs.Favorites, apps, services, bookmarks)
+	var recent []Favorite
+	if config.Recent > 0 && !kiosk && !export {
+		recent = buildFavorites(prefs.Recent, apps, services, bookmarks)
+		recent = recent[:min(len(recent), config.Recent)]
+	}
+	var popular []Favorite
+	if config.ClickStats && config.MostUsed > 0 && !kiosk {
+		popular = mostUsed(s.clicks.Counts(), config.MostUsed, apps, services, bookmarks)
+	}
+	locale := localeFor(r, config)
+	palette := resolvePalette(prefs, config)
+	groupBy := resolveGrouping(prefs, config)
 	data := PageData{
 		Config:        config,
 		Apps:          apps,
 		Services:      services,
-		DemoMode:      s.k8sClient == nil,
+		DemoMode:      s.kube() == nil,
 		TailscaleUser: tailscaleUser,
+		Theme:         resolveTheme(prefs, config),
+		Palette:       palette,
+		ThemeColor:    themeColor(config, palette),
+		Palettes:      PaletteNames(),
+		Layout:        resolveLayout(prefs, config),
+		Compact:       resolveCompact(config),
+		GroupBy:       groupBy,
+		Groupings:     Groupings,
+		Locale:        locale,
+
+		Announcements:      activeAnnouncements(config.Announcements, prefs.Dismissed, time.Now()),
+		Favorites:          favorites,
+		Recent:             recent,
+		MostUsed:           popular,
+		Preferences:        clientPreferences(prefs),
+		Hidden:             prefs.Hidden,
+		HiddenCount:        hiddenCount,
+		ShowHidden:         prefs.ShowHidden,
+		Pinned:             pinnedSet(favorites),
+		BookmarkCategories: categories,
+		Groups:             groupTiles(groupBy, apps, services, categories, locale),
+		Collapsed:          resolveCollapsed(prefs, config),
+		CanEdit:            !s.readOnly && !kiosk && !export && page == nil && groupBy == "category" && (s.inMemory() || tailscaleUser != ""),
+		CanRefresh:         !s.readOnly && (s.inMemory() || tailscaleUser != ""),
+		Clock:              buildClock(config.Clock, tailscaleUser, time.Now(), locale),
+		Widgets:            s.renderWidgets(config, locale),
+		Onboarding:         onboarding,
+		AccessProblems:     s.access.Problems(),
+		Degraded:           degraded,
+		Page:               page,
+	}
+	if export {
+		data.Export = true
+		data.Clock = nil // it would be stuck at the time of the snapshot
+		data.Now = time.Now()
+	}
+	if kiosk {
+		data.Kiosk = true
+		data.RefreshSeconds = int(kioskRefresh(r).Seconds())
+		data.HealthSummary = summarizeHealth(apps, services, bookmarks)
 	}
 
 	// Render template
-	err = s.templates.ExecuteTemplate(w, "index.html", data)
+	data.Nonce = s.setCSP(w)
+	w.Header().Add("Vary", "Accept-Language")
+	err = s.templates[locale.Lang].ExecuteTemplate(w, "index.html", data)
 	if err != nil {
 		log.Printf("Error rendering template: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -237,13 +707,27 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 //     arrives over a raw net.Listener. No headers are injected, so we fall
 //     back to a WhoIs lookup using the request's remote address.
 //
-// Header spoofing on path 1 is not a concern because Tailscale Serve strips
-// any client-supplied Tailscale-* headers before re-adding them itself, and
-// the server should only be reachable via localhost in that setup.
+// With AUTH_USER_HEADER set, a login an authenticating proxy sends in that
+// header is taken before either (path 0).
+//
+// Anyone who can reach the HTTP port can send those headers, so they are
+// only believed on requests from TRUSTED_PROXIES, loopback by default,
+// which is where Tailscale Serve and a proxy in the same pod connect from.
+// Other requests are identified by WhoIs alone.
 func (s *Server) resolveViewer(ctx context.Context, r *http.Request) string {
-	// Path 1: Tailscale Serve injects this header.
-	if login := r.Header.Get("Tailscale-User-Login"); login != "" {
-		return login
+	if s.fromTrustedProxy(r) {
+		// Path 0: an authenticating proxy in front of GoHome, such as
+		// oauth2-proxy or Authelia, sends the login in a header of its own.
+		if s.authUserHeader != "" {
+			if login := r.Header.Get(s.authUserHeader); login != "" {
+				return login
+			}
+		}
+
+		// Path 1: Tailscale Serve injects this header.
+		if login := r.Header.Get("Tailscale-User-Login"); login != "" {
+			return login
+		}
 	}
 
 	// Path 2: tsnet — resolve by remote address via the local API.
@@ -258,6 +742,119 @@ func (s *Server) resolveViewer(ctx context.Context, r *http.Request) string {
 	return ""
 }
 
+// defaultTrustedProxies are the loopback ranges, where Tailscale Serve and a
+// proxy in the same pod connect from.
+var defaultTrustedProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+// trustedProxiesFromEnv parses TRUSTED_PROXIES, a comma-separated list of
+// addresses and CIDR ranges such as "10.42.0.0/16", defaulting to loopback.
+func trustedProxiesFromEnv() []netip.Prefix {
+	value := os.Getenv("TRUSTED_PROXIES")
+	if value == "" {
+		return defaultTrustedProxies
+	}
+	var prefixes []netip.Prefix
+	for _, item := range splitList(value) {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				log.Printf("Warning: Ignoring TRUSTED_PROXIES entry %q: not an address or CIDR range", item)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// fromTrustedProxy reports whether r came straight from one of
+// TRUSTED_PROXIES, so that its identity headers were set by the proxy rather
+// than by whoever sent the request.
+func (s *Server) fromTrustedProxy(r *http.Request) bool {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	return slices.ContainsFunc(s.trustedProxies, func(prefix netip.Prefix) bool { return prefix.Contains(addr) })
+}
+
+// handleNotFound serves configured pages and renders a 404 for any other
+// route that isn't explicitly registered.
+func (s *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	// Configured pages live at /<slug>; they come from the ConfigMap so they
+	// can't be registered as routes up front. Slugs the last configuration
+	// doesn't have, such as probes for /wp-login, are turned away without
+	// loading it again.
+	if slug := strings.TrimPrefix(r.URL.Path, "/"); r.Method == http.MethodGet && pageSlug.MatchString(slug) && s.bookmarkManager.MayHavePage(slug) {
+		s.renderHome(w, r, requestedView(r), slug)
+		return
+	}
+	s.renderNotFound(w, r)
+}
+
+// renderNotFound writes a 404. Requests under /api/ get a JSON body so API
+// clients don't have to parse HTML.
+func (s *Server) renderNotFound(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"error": "not found",
+			"path":  r.URL.Path,
+		})
+		return
+	}
+
+	data := PageData{
+		Config: &Config{
+			Title:     "Go Home",
+			Bookmarks: []Bookmark{},
+		},
+		Error:    r.URL.Path,
+		DemoMode: s.kube() == nil,
+	}
+	data.Locale = localeFor(r, data.Config)
+	data.Nonce = s.setCSP(w)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(http.StatusNotFound)
+	if err := s.templates[data.Locale.Lang].ExecuteTemplate(w, "404.html", data); err != nil {
+		log.Printf("Error rendering 404 template: %v", err)
+	}
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// handleRobots serves robots.txt, disallowing everything unless overridden
+// via the ConfigMap or ROBOTS_TXT. Crawlers fetch it often, so it is served
+// from the last configuration loaded rather than from the cluster.
+func (s *Server) handleRobots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(s.bookmarkManager.SiteFiles().RobotsTxt))
+}
+
+// handleFavicon serves the embedded favicon, or redirects to a configured
+// favicon URL when one is set, as of the last configuration loaded.
+func (s *Server) handleFavicon(w http.ResponseWriter, r *http.Request) {
+	if favicon := s.bookmarkManager.SiteFiles().FaviconURL; favicon != "" {
+		http.Redirect(w, r, favicon, http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/x-icon")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	_, _ = w.Write(faviconICO)
+}
+
 // handleHealth handles health checks
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -280,10 +877,12 @@ func (s *Server) renderError(w http.ResponseWriter, message string) {
 		},
 		Apps:     []IngressInfo{},
 		Services: []IngressInfo{},
-		DemoMode: s.k8sClient == nil,
+		DemoMode: s.kube() == nil,
+		Locale:   locales[defaultLanguage],
+		Nonce:    s.setCSP(w),
 	}
 
-	err := s.templates.ExecuteTemplate(w, "index.html", data)
+	err := s.templates[defaultLanguage].ExecuteTemplate(w, "index.html", data)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
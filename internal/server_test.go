@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestServer serves the fixtures in testdata the way FIXTURES would and
+// checks that every page and API only shows a visitor the tiles they may
+// see. NewServer registers its metrics globally, so it can only be called
+// once per test binary; further end-to-end checks belong here as subtests.
+func TestServer(t *testing.T) {
+	t.Setenv("AUTH_USER_HEADER", "X-Forwarded-User")
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.1") // where httptest requests come from
+	t.Setenv("FAVICON_SCRAPING", "false")
+	t.Setenv("CACHE_TTL", "0")
+
+	up := httptest.NewServer(http.NotFoundHandler())
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	fixtures, err := LoadFixtures("testdata/fixtures.yaml", "gohome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fixtures.ConfigMaps["gohome/gohome-config"].Data
+	data["bookmark-up"] = up.URL + "|Reference"
+	data["bookmark-down"] = down.URL + "|Reference"
+	data["bookmark-admin-up"] = up.URL + "/admin|Reference|groups=admins"
+	bm := NewBookmarkManager(nil, "gohome", "gohome-config")
+	bm.UseFixtures(fixtures)
+
+	// Templates and static files are found relative to the repository root.
+	t.Chdir("..")
+	s, err := NewServer(NewFixtureClient(fixtures), bm, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := s.Handler()
+
+	// get serves a GET of target, as alice when admin is set and as an
+	// anonymous visitor otherwise.
+	get := func(t *testing.T, target string, admin bool) *http.Response {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if admin {
+			r.Header.Set("X-Forwarded-User", "alice@example.com")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+	body := func(t *testing.T, resp *http.Response) string {
+		t.Helper()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	decode := func(t *testing.T, resp *http.Response, v any) {
+		t.Helper()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d: %s", resp.StatusCode, body(t, resp))
+		}
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("home", func(t *testing.T) {
+		for _, tt := range []struct {
+			admin         bool
+			shown, hidden []string
+		}{
+			{false, []string{"Test Lab", "grafana.example.com", "docs.example.com"}, []string{"portainer.example.com", "router.example.com"}},
+			{true, []string{"grafana.example.com", "portainer.example.com", "router.example.com"}, nil},
+		} {
+			resp := get(t, "/", tt.admin)
+			page := body(t, resp)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d", resp.StatusCode)
+			}
+			for _, s := range tt.shown {
+				if !strings.Contains(page, s) {
+					t.Errorf("admin=%v: %q not shown", tt.admin, s)
+				}
+			}
+			for _, s := range tt.hidden {
+				if strings.Contains(page, s) {
+					t.Errorf("admin=%v: %q shown", tt.admin, s)
+				}
+			}
+		}
+	})
+
+	t.Run("tiles", func(t *testing.T) {
+		names := func(admin bool) []string {
+			var feed TileFeed
+			decode(t, get(t, "/api/v1/tiles", admin), &feed)
+			var names []string
+			for _, tile := range feed.Tiles {
+				names = append(names, tile.Name)
+			}
+			slices.Sort(names)
+			return names
+		}
+		if got, want := names(false), []string{"Docs", "Down", "Up", "grafana"}; !slices.Equal(got, want) {
+			t.Errorf("anonymous tiles %q, want %q", got, want)
+		}
+		if got, want := names(true), []string{"Admin Up", "Docs", "Down", "Router", "Up", "grafana", "portainer"}; !slices.Equal(got, want) {
+			t.Errorf("admin tiles %q, want %q", got, want)
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		for _, admin := range []bool{false, true} {
+			var search struct{ Results []SearchResult }
+			decode(t, get(t, "/api/v1/search?q=portainer", admin), &search)
+			if found := slices.ContainsFunc(search.Results, func(r SearchResult) bool { return r.Name == "portainer" }); found != admin {
+				t.Errorf("admin=%v: portainer found %v", admin, found)
+			}
+		}
+	})
+
+	t.Run("golinks", func(t *testing.T) {
+		links := func(admin bool) []string {
+			var links []GoLink
+			decode(t, get(t, "/api/v1/golinks", admin), &links)
+			var names []string
+			for _, link := range links {
+				names = append(names, link.Name)
+			}
+			slices.Sort(names)
+			return names
+		}
+		if got, want := links(false), []string{"docs", "grafana", "wiki"}; !slices.Equal(got, want) {
+			t.Errorf("anonymous go links %q, want %q", got, want)
+		}
+		if got, want := links(true), []string{"docs", "grafana", "portainer", "router", "wiki"}; !slices.Equal(got, want) {
+			t.Errorf("admin go links %q, want %q", got, want)
+		}
+	})
+
+	t.Run("go", func(t *testing.T) {
+		for _, tt := range []struct {
+			target   string
+			admin    bool
+			status   int
+			location string
+		}{
+			{"/go/wiki", false, http.StatusFound, "https://wiki.example.com"},
+			{"/go/grafana/d/home?orgId=1", false, http.StatusFound, "https://grafana.example.com/d/home?orgId=1"},
+			{"/go/Docs", false, http.StatusFound, "https://docs.example.com"},
+			{"/go/portainer", true, http.StatusFound, "https://portainer.example.com/"},
+			{"/go/portainer", false, http.StatusSeeOther, "/go?q=portainer"},
+			{"/go/router", false, http.StatusSeeOther, "/go?q=router"},
+		} {
+			resp := get(t, tt.target, tt.admin)
+			if resp.StatusCode != tt.status || resp.Header.Get("Location") != tt.location {
+				t.Errorf("%s (admin=%v): %d to %q, want %d to %q", tt.target, tt.admin, resp.StatusCode, resp.Header.Get("Location"), tt.status, tt.location)
+			}
+		}
+	})
+
+	t.Run("launch", func(t *testing.T) {
+		resp := get(t, launchURL(up.URL), false)
+		if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != up.URL {
+			t.Errorf("up: %d to %q, want a redirect to %s", resp.StatusCode, resp.Header.Get("Location"), up.URL)
+		}
+
+		resp = get(t, launchURL(down.URL), false)
+		if page := body(t, resp); resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(page, "Down") {
+			t.Errorf("down: %d, want the interstitial naming the tile", resp.StatusCode)
+		}
+
+		// Only tiles the visitor may see are launched.
+		for _, tt := range []struct {
+			admin    bool
+			location string
+		}{
+			{false, "/"},
+			{true, up.URL + "/admin"},
+		} {
+			resp := get(t, launchURL(up.URL+"/admin"), tt.admin)
+			if location := resp.Header.Get("Location"); location != tt.location {
+				t.Errorf("admin=%v: launched to %q, want %q", tt.admin, location, tt.location)
+			}
+		}
+		if location := get(t, launchURL("https://portainer.example.com/"), false).Header.Get("Location"); location != "/" {
+			t.Errorf("hidden ingress launched to %q", location)
+		}
+	})
+}
+
+func TestResolveViewer(t *testing.T) {
+	tests := []struct {
+		name, trusted, remote string
+		headers               map[string]string
+		want                  string
+	}{
+		{"Tailscale Serve", "", "127.0.0.1:40000", map[string]string{"Tailscale-User-Login": "alice@example.com"}, "alice@example.com"},
+		{"IPv6 loopback", "", "[::1]:40000", map[string]string{"Tailscale-User-Login": "alice@example.com"}, "alice@example.com"},
+		{"proxy header", "", "127.0.0.1:40000", map[string]string{"X-Forwarded-User": "bob@example.com", "Tailscale-User-Login": "alice@example.com"}, "bob@example.com"},
+		{"spoofed Tailscale header", "", "192.0.2.7:40000", map[string]string{"Tailscale-User-Login": "alice@example.com"}, ""},
+		{"spoofed proxy header", "", "192.0.2.7:40000", map[string]string{"X-Forwarded-User": "bob@example.com"}, ""},
+		{"proxy in range", "10.42.0.0/16", "10.42.3.4:40000", map[string]string{"X-Forwarded-User": "bob@example.com"}, "bob@example.com"},
+		{"proxy address", "192.0.2.9, 10.42.0.0/16", "192.0.2.9:40000", map[string]string{"X-Forwarded-User": "bob@example.com"}, "bob@example.com"},
+		{"loopback not trusted", "10.42.0.0/16", "127.0.0.1:40000", map[string]string{"X-Forwarded-User": "bob@example.com"}, ""},
+		{"IPv4-mapped IPv6", "10.42.0.0/16", "[::ffff:10.42.3.4]:40000", map[string]string{"X-Forwarded-User": "bob@example.com"}, "bob@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+			s := &Server{authUserHeader: "X-Forwarded-User", trustedProxies: trustedProxiesFromEnv()}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := s.resolveViewer(r.Context(), r); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// serviceWorkerJS is the body of /sw.js. handleServiceWorker prepends the
+// cache name and the list of files to precache, which depend on the files in
+// static/.
+//
+//go:embed serviceworker.js
+var serviceWorkerJS string
+
+// staticDir is where the /static/ file server reads from.
+const staticDir = "static"
+
+// serviceWorkerPrelude is computed once: static/ only changes on deploy.
+var serviceWorkerPrelude = sync.OnceValue(func() string {
+	precache := []string{"/", "/manifest.webmanifest"}
+	hash := sha256.New()
+	err := filepath.WalkDir(staticDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		precache = append(precache, "/"+filepath.ToSlash(path))
+		hash.Write([]byte(path))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: could not list %s/ for the service worker: %v", staticDir, err)
+	}
+	list, _ := json.Marshal(precache)
+	return fmt.Sprintf("const CACHE = 'gohome-%s';\nconst PRECACHE = %s;\n\n", hex.EncodeToString(hash.Sum(nil))[:12], list)
+})
+
+// handleServiceWorker serves /sw.js. It must live at the root so its scope
+// covers the homepage. A new deploy changes the cache name, which makes
+// browsers install the new worker and drop the old caches.
+func (s *Server) handleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write([]byte(serviceWorkerPrelude() + serviceWorkerJS))
+}
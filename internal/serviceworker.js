@@ -0,0 +1,78 @@
+// GoHome service worker, served at /sw.js with CACHE and PRECACHE defined
+// above by internal/serviceworker.go.
+//
+// Static assets are cached on install and refreshed in the background.
+// Pages and API responses always go to the network first; the last good copy
+// is kept so that when the cluster or network is down the homepage still
+// opens with its links, marked with data-offline on <body> so app.js can say
+// they may be stale.
+
+const PAGES = ['/', '/kiosk'];
+
+self.addEventListener('install', (event) => {
+    event.waitUntil(
+        caches.open(CACHE)
+            .then((cache) => cache.addAll(PRECACHE))
+            .then(() => self.skipWaiting())
+    );
+});
+
+self.addEventListener('activate', (event) => {
+    event.waitUntil(
+        caches.keys()
+            .then((keys) => Promise.all(keys.filter((key) => key.startsWith('gohome-') && key !== CACHE).map((key) => caches.delete(key))))
+            .then(() => self.clients.claim())
+    );
+});
+
+self.addEventListener('fetch', (event) => {
+    const request = event.request;
+    const url = new URL(request.url);
+    if (request.method !== 'GET' || url.origin !== location.origin) return;
+
+    if (request.mode === 'navigate' && PAGES.includes(url.pathname)) {
+        event.respondWith(networkFirst(request, markOffline));
+    } else if (url.pathname.startsWith('/api/v1/')) {
+        event.respondWith(networkFirst(request, (response) => response));
+    } else if (/^\/(static|theme|icons|favicons)\//.test(url.pathname) || url.pathname === '/images' || url.pathname === '/manifest.webmanifest') {
+        event.respondWith(staleWhileRevalidate(event, request));
+    }
+});
+
+// networkFirst fetches request, caching good responses, and falls back to
+// the cached copy passed through fallback when the fetch fails.
+async function networkFirst(request, fallback) {
+    const cache = await caches.open(CACHE);
+    try {
+        const response = await fetch(request);
+        if (response.ok) await cache.put(request, response.clone());
+        return response;
+    } catch (err) {
+        // Pages are cached without their query string (e.g. ?show-hidden).
+        const cached = await cache.match(request) || await cache.match(request, { ignoreSearch: true });
+        if (!cached) throw err;
+        return fallback(cached);
+    }
+}
+
+// staleWhileRevalidate answers from the cache when it can and refreshes the
+// cached copy in the background.
+async function staleWhileRevalidate(event, request) {
+    const cache = await caches.open(CACHE);
+    const cached = await cache.match(request);
+    const refresh = fetch(request).then((response) => {
+        if (response.ok) return cache.put(request, response.clone()).then(() => response);
+        return response;
+    });
+    if (!cached) return refresh;
+    event.waitUntil(refresh.catch(() => {}));
+    return cached;
+}
+
+// markOffline adds data-offline="<when the page was rendered>" to a cached
+// page's <body>.
+async function markOffline(cached) {
+    const rendered = new Date(cached.headers.get('Date') || Date.now()).toISOString();
+    const html = (await cached.text()).replace('<body ', `<body data-offline="${rendered}" `);
+    return new Response(html, { status: cached.status, headers: cached.headers });
+}
@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionCookie holds the visitor's Preferences, signed by Sessions.
+	sessionCookie = "gohome_session"
+	// sessionMaxAge is how long the session cookie lives after its last
+	// change, the same as the preference cookies it replaced.
+	sessionMaxAge = 365 * 24 * time.Hour
+	// maxSessionBytes keeps the cookie within what every browser stores.
+	maxSessionBytes = 4000
+	// sessionKeyBytes is the length of a generated signing key.
+	sessionKeyBytes = 32
+	// sessionKeyFileName is the file in TS_STATE_DIR that keeps a generated
+	// signing key when there is no data store.
+	sessionKeyFileName = "gohome-session.key"
+)
+
+// errSessionTooLarge is returned when preferences don't fit in a cookie.
+var errSessionTooLarge = errors.New("preferences too large for the session cookie")
+
+// Sessions signs the session cookie that holds each visitor's preferences,
+// so they can't be edited or forged in the browser. The cookie is HttpOnly:
+// static/app.js changes preferences through POST /preferences.
+type Sessions struct {
+	key []byte
+}
+
+// NewSessionsFromEnv signs with SESSION_SECRET. Without one the key is
+// generated and kept so sessions survive restarts: in the data store when
+// there is one (see sharedStore), or else in TS_STATE_DIR. With neither,
+// sessions only last until the next restart.
+func NewSessionsFromEnv() *Sessions {
+	if secret := os.Getenv("SESSION_SECRET"); secret != "" {
+		if len(secret) < 16 {
+			log.Printf("Warning: SESSION_SECRET is shorter than 16 characters")
+		}
+		return &Sessions{key: []byte(secret)}
+	}
+	if store := sharedStore(); store != nil {
+		key, err := storedSessionKey(store)
+		if err == nil {
+			return &Sessions{key: key}
+		}
+		log.Printf("Warning: Could not load the session key from the data store: %v", err)
+	}
+	if dir := os.Getenv("TS_STATE_DIR"); dir != "" {
+		key, err := fileSessionKey(filepath.Join(dir, sessionKeyFileName))
+		if err == nil {
+			return &Sessions{key: key}
+		}
+		log.Printf("Warning: Could not keep the session key in TS_STATE_DIR: %v", err)
+	}
+	log.Printf("Warning: SESSION_SECRET is not set and there is nowhere to keep a generated key; visitors' preferences will be reset on every restart")
+	return &Sessions{key: newSessionKey()}
+}
+
+// storedSessionKey returns the signing key kept in store, creating it on
+// first start.
+func storedSessionKey(store Store) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	raw, found, err := store.Get(ctx, storeNamespaceSessions, "key")
+	if err != nil {
+		return nil, err
+	}
+	var key []byte
+	if found && json.Unmarshal(raw, &key) == nil && len(key) >= sessionKeyBytes {
+		return key, nil
+	}
+	key = newSessionKey()
+	if err := setJSON(ctx, store, storeNamespaceSessions, "key", key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// fileSessionKey returns the signing key kept in the file at path, creating
+// it, and its directory if tsnet hasn't yet, on first start. Only the owner
+// may read it.
+func fileSessionKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) >= sessionKeyBytes {
+		return key, nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	key = newSessionKey()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newSessionKey() []byte {
+	key := make([]byte, sessionKeyBytes)
+	_, _ = rand.Read(key)
+	return key
+}
+
+// sign returns the MAC of payload.
+func (s *Sessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encode returns prefs as a cookie value: base64 JSON, a dot and its MAC.
+func (s *Sessions) encode(prefs Preferences) (string, error) {
+	raw, err := json.Marshal(prefs)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	value := payload + "." + s.sign(payload)
+	if len(sessionCookie)+len(value) > maxSessionBytes {
+		return "", errSessionTooLarge
+	}
+	return value, nil
+}
+
+// decode verifies a cookie value written by encode and returns the
+// preferences in it, ignoring any values that aren't recognised.
+func (s *Sessions) decode(value string) (Preferences, bool) {
+	var prefs Preferences
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return prefs, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(raw, &prefs) != nil {
+		return Preferences{}, false
+	}
+	return sanitizePreferences(prefs), true
+}
+
+// Load returns the preferences in r's session cookie, and whether it had a
+// valid one.
+func (s *Sessions) Load(r *http.Request) (Preferences, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return Preferences{}, false
+	}
+	return s.decode(c.Value)
+}
+
+// Save writes prefs as the session cookie.
+func (s *Sessions) Save(w http.ResponseWriter, r *http.Request, prefs Preferences) error {
+	value, err := s.encode(prefs)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(sessionMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSessionsDecode(t *testing.T) {
+	s := &Sessions{key: []byte("0123456789abcdef0123456789abcdef")}
+	value, err := s.encode(Preferences{Theme: "dark", Favorites: []string{"bookmark:wiki"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, _ := strings.Cut(value, ".")
+	other, err := (&Sessions{key: newSessionKey()}).encode(Preferences{Theme: "dark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, _, _ := strings.Cut(other, ".")
+
+	tests := []struct {
+		name, value string
+		ok          bool
+	}{
+		{"valid", value, true},
+		{"other key", other, false},
+		{"payload swapped", forged + "." + sig, false},
+		{"signature dropped", payload, false},
+		{"empty signature", payload + ".", false},
+		{"signature altered", payload + "." + strings.ToUpper(sig), false},
+		{"unsigned JSON", `{"Theme":"dark"}`, false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs, ok := s.decode(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && (prefs.Theme != "dark" || len(prefs.Favorites) != 1) {
+				t.Errorf("got %+v", prefs)
+			}
+		})
+	}
+}
+
+func TestSessionsDecodeSanitizes(t *testing.T) {
+	s := &Sessions{key: newSessionKey()}
+	value, err := s.encode(Preferences{Theme: "neon", Layout: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefs, ok := s.decode(value)
+	if !ok || prefs.Theme != "" || prefs.Layout != "list" {
+		t.Errorf("got %+v, %v; want the unknown theme dropped", prefs, ok)
+	}
+}
+
+func TestSessionsEncodeTooLarge(t *testing.T) {
+	s := &Sessions{key: newSessionKey()}
+	favorites := make([]string, 500)
+	for i := range favorites {
+		favorites[i] = "ingress:namespace/tile"
+	}
+	if _, err := s.encode(Preferences{Favorites: favorites}); err != errSessionTooLarge {
+		t.Errorf("got %v, want %v", err, errSessionTooLarge)
+	}
+}
+
+func TestNewSessionsFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, secret, stateDir string
+		same                   bool // the key is the same on the next start
+	}{
+		{"secret", "a shared secret for every replica", "", true},
+		{"kept in TS_STATE_DIR", "", filepath.Join(dir, "tsnet"), true},
+		{"nowhere to keep it", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SESSION_SECRET", tt.secret)
+			t.Setenv("TS_STATE_DIR", tt.stateDir)
+			first, second := NewSessionsFromEnv(), NewSessionsFromEnv()
+			if len(first.key) < 16 {
+				t.Fatalf("key of %d bytes", len(first.key))
+			}
+			if same := bytes.Equal(first.key, second.key); same != tt.same {
+				t.Errorf("same key on restart = %v, want %v", same, tt.same)
+			}
+		})
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "tsnet", sessionKeyFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("key file mode %v, want 0600", perm)
+	}
+}
+
+func TestFileSessionKeyReplacesShortKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), sessionKeyFileName)
+	if err := os.WriteFile(path, []byte("short"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := fileSessionKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != sessionKeyBytes {
+		t.Errorf("key of %d bytes, want %d", len(key), sessionKeyBytes)
+	}
+	if kept, _ := os.ReadFile(path); !bytes.Equal(kept, key) {
+		t.Error("new key not written")
+	}
+}
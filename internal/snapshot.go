@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// storeNamespaceSnapshots holds a sharedCacheEntry per Snapshot key.
+	storeNamespaceSnapshots = "snapshots"
+	// snapshotInterval is how often an unchanged snapshot is saved again,
+	// so its time stays close to the last successful listing without
+	// writing on every one.
+	snapshotInterval = time.Minute
+)
+
+// snapshotStore is where discovery snapshots are kept: SNAPSHOT_FILE if it
+// is set, otherwise the data store. It is nil without either.
+var snapshotStore = sync.OnceValue(func() Store {
+	path := os.Getenv("SNAPSHOT_FILE")
+	if path == "" {
+		return sharedStore()
+	}
+	store, err := OpenFileStore(path)
+	if err != nil {
+		log.Printf("Warning: Not keeping discovery snapshots in %s: %v", path, err)
+		return nil
+	}
+	log.Printf("Keeping discovery snapshots in %s", path)
+	return store
+})
+
+// Snapshot persists the last successful result of one discovery step, such
+// as the ingress listing, so a GoHome restarted while the API server is
+// unreachable still has something to show. A nil Snapshot does nothing.
+type Snapshot[V any] struct {
+	store Store
+	key   string
+
+	mu    sync.Mutex
+	saved time.Time
+	last  []byte // value as last saved
+}
+
+// newSnapshot returns the snapshot stored under key, or nil when there is
+// nowhere to keep it.
+func newSnapshot[V any](key string) *Snapshot[V] {
+	store := snapshotStore()
+	if store == nil {
+		return nil
+	}
+	return &Snapshot[V]{store: store, key: key}
+}
+
+// Load returns the saved value and when it was saved, if there is one.
+func (s *Snapshot[V]) Load(ctx context.Context) (V, time.Time, bool) {
+	var entry sharedCacheEntry[V]
+	if s == nil {
+		return entry.Value, time.Time{}, false
+	}
+	raw, ok, err := s.store.Get(ctx, storeNamespaceSnapshots, s.key)
+	if err != nil {
+		log.Printf("Warning: Could not load the %s snapshot: %v", s.key, err)
+		return entry.Value, time.Time{}, false
+	}
+	if !ok {
+		return entry.Value, time.Time{}, false
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		log.Printf("Warning: Ignoring invalid %s snapshot: %v", s.key, err)
+		return entry.Value, time.Time{}, false
+	}
+	s.mu.Lock()
+	s.saved, s.last = entry.Fetched, nil
+	s.mu.Unlock()
+	return entry.Value, entry.Fetched, true
+}
+
+// Save stores value as the latest snapshot, unless it is the same as the
+// one saved less than snapshotInterval ago.
+func (s *Snapshot[V]) Save(ctx context.Context, value V) {
+	if s == nil {
+		return
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Warning: Could not encode the %s snapshot: %v", s.key, err)
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if bytes.Equal(raw, s.last) && now.Sub(s.saved) < snapshotInterval {
+		return
+	}
+	entry := sharedCacheEntry[json.RawMessage]{Fetched: now, Value: raw}
+	if err := setJSON(ctx, s.store, storeNamespaceSnapshots, s.key, entry); err != nil {
+		log.Printf("Warning: Could not save the %s snapshot: %v", s.key, err)
+		return
+	}
+	s.saved, s.last = now, raw
+}
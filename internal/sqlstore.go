@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// sqliteDrivers are the database/sql driver names SQLite drivers register
+// under: modernc.org/sqlite (pure Go) and github.com/mattn/go-sqlite3 (cgo).
+var sqliteDrivers = []string{"sqlite", "sqlite3"}
+
+// sqliteMigrations create and upgrade the schema, one entry per version;
+// the version applied last is kept in gohome_schema. Only ever append.
+var sqliteMigrations = []string{
+	// 1: the key/value table.
+	`CREATE TABLE store (
+		namespace TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (namespace, key)
+	)`,
+}
+
+// SQLiteStore is a Store in an SQLite database, for those who would rather
+// query their data with the sqlite3 tool than read a JSON file. The driver
+// is registered by cmd/main.go, which imports modernc.org/sqlite.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens the database at path, creating it if it doesn't
+// exist, and applies any pending migrations.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	drivers := sql.Drivers()
+	i := slices.IndexFunc(sqliteDrivers, func(name string) bool { return slices.Contains(drivers, name) })
+	if i < 0 {
+		return nil, errors.New("this build of GoHome has no SQLite driver")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(sqliteDrivers[i], path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time; one connection avoids "database is
+	// locked" errors between GoHome's own goroutines.
+	db.SetMaxOpenConns(1)
+	if err := migrateSQLite(context.Background(), db, path); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// migrateSQLite applies the migrations the database hasn't had yet, each in
+// its own transaction.
+func migrateSQLite(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS gohome_schema (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT version FROM gohome_schema`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = db.ExecContext(ctx, `INSERT INTO gohome_schema (version) VALUES (0)`)
+	}
+	if err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("%s has schema version %d, newer than this GoHome supports (%d)", path, version, len(sqliteMigrations))
+	}
+
+	from := version
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migrating %s to schema version %d: %w", path, version+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE gohome_schema SET version = ?`, version+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	if from > 0 && from < version {
+		log.Printf("Migrated %s from schema version %d to %d", path, from, version)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, namespace, key string) (json.RawMessage, bool, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM store WHERE namespace = ? AND key = ?`, namespace, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	return json.RawMessage(value), err == nil, err
+}
+
+func (s *SQLiteStore) Set(ctx context.Context, namespace, key string, value json.RawMessage) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO store (namespace, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (namespace, key) DO UPDATE SET value = excluded.value`, namespace, key, string(value))
+	return err
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, namespace, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM store WHERE namespace = ? AND key = ?`, namespace, key)
+	return err
+}
+
+func (s *SQLiteStore) List(ctx context.Context, namespace string) (map[string]json.RawMessage, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, value FROM store WHERE namespace = ?`, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make(map[string]json.RawMessage)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = json.RawMessage(value)
+	}
+	return values, rows.Err()
+}
+
+func (s *SQLiteStore) Replace(ctx context.Context, namespace string, values map[string]json.RawMessage) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM store WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	for key, value := range values {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO store (namespace, key, value) VALUES (?, ?, ?)`, namespace, key, string(value)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
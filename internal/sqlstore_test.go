@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "gohome.sqlite")
+	s, err := OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	s.db.Close()
+
+	reopened, err := OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.db.Close()
+	if value, ok, _ := reopened.Get(context.Background(), storeNamespacePrefs, "a"); !ok || string(value) != `"prefs"` {
+		t.Errorf("after reopening: %s, %v", value, ok)
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int // the schema version the database starts at; -1 for none
+		err     string
+	}{
+		{"new database", -1, ""},
+		{"current database", len(sqliteMigrations), ""},
+		{"newer database", len(sqliteMigrations) + 1, "newer than this GoHome supports"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gohome.sqlite")
+			db, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			ctx := context.Background()
+			if tt.version >= 0 {
+				if _, err := db.ExecContext(ctx, `CREATE TABLE gohome_schema (version INTEGER NOT NULL)`); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := db.ExecContext(ctx, `INSERT INTO gohome_schema (version) VALUES (?)`, tt.version); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = migrateSQLite(ctx, db, path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var version int
+			if err := db.QueryRowContext(ctx, `SELECT version FROM gohome_schema`).Scan(&version); err != nil || version != len(sqliteMigrations) {
+				t.Errorf("schema version %d (%v), want %d", version, err, len(sqliteMigrations))
+			}
+			// Migrating again changes nothing.
+			if err := migrateSQLite(ctx, db, path); err != nil {
+				t.Errorf("second migration: %v", err)
+			}
+		})
+	}
+}
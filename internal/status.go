@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Replicas counts the endpoints behind an ingress's backend Service.
+type Replicas struct {
+	Ready, Total int
+}
+
+// StatusRow is one monitored target on the /status page.
+type StatusRow struct {
+	Name      string
+	Kind      string // "app", "service" or "bookmark"
+	Namespace string // empty for bookmarks
+	URL       string
+	Health    TargetHealth
+	Cert      CertStatus
+	Replicas  *Replicas // nil for bookmarks, demo ingresses and Services with no EndpointSlices
+}
+
+// statusRank orders rows with problems first: down, slow, unchecked, up.
+func statusRank(h TargetHealth) int {
+	switch {
+	case h.State == HealthDown:
+		return 0
+	case h.State == HealthUp && h.Slow:
+		return 1
+	case h.State == HealthUp:
+		return 3
+	}
+	return 2
+}
+
+// buildStatusRows lists every tile that is health checked, problems first.
+func buildStatusRows(apps, services []IngressInfo, bookmarks []Bookmark, replicas map[string]Replicas) []StatusRow {
+	var rows []StatusRow
+	for _, list := range [][]IngressInfo{apps, services} {
+		for _, info := range list {
+			row := StatusRow{Name: info.Name, Kind: "service", Namespace: info.Namespace, URL: info.URL, Health: info.Health, Cert: info.Cert}
+			if info.IsApp {
+				row.Kind = "app"
+			}
+			if r, ok := replicas[info.Namespace+"/"+info.Service]; ok && info.Service != "" {
+				row.Replicas = &r
+			}
+			rows = append(rows, row)
+		}
+	}
+	for _, b := range bookmarks {
+		rows = append(rows, StatusRow{Name: b.Name, Kind: "bookmark", URL: b.URL, Health: b.Health, Cert: b.Cert})
+	}
+	slices.SortStableFunc(rows, func(a, b StatusRow) int {
+		return cmp.Or(cmp.Compare(statusRank(a.Health), statusRank(b.Health)), strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)))
+	})
+	return rows
+}
+
+// GetReplicas counts ready and total endpoints per Service across all
+// namespaces, keyed by "namespace/service". It returns nil in demo mode.
+// The result is cached and must not be modified.
+func (k *K8sClient) GetReplicas(ctx context.Context) (map[string]Replicas, error) {
+	if k == nil || k.clientset == nil {
+		return nil, nil
+	}
+	return k.replicas.Get(ctx, "", k.countReplicas)
+}
+
+// countReplicas lists the EndpointSlices behind GetReplicas.
+func (k *K8sClient) countReplicas(ctx context.Context) (map[string]Replicas, error) {
+	list, err := k.clientset.DiscoveryV1().EndpointSlices("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	replicas := make(map[string]Replicas)
+	for _, slice := range list.Items {
+		service := slice.Labels["kubernetes.io/service-name"]
+		if service == "" {
+			continue
+		}
+		key := slice.Namespace + "/" + service
+		r := replicas[key]
+		for _, endpoint := range slice.Endpoints {
+			r.Total++
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				r.Ready++
+			}
+		}
+		replicas[key] = r
+	}
+	return replicas, nil
+}
+
+// handleStatus renders /status: every monitored target with its health,
+// latency, last check, certificate expiry and replicas in one dense table.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: /status could not load ingresses: %v", err)
+	}
+	apps, services, config.Bookmarks = s.restrictTiles(r, s.resolveViewer(ctx, r), config, apps, services, config.Bookmarks)
+	replicas, err := s.kube().GetReplicas(ctx)
+	if err != nil {
+		log.Printf("Warning: /status could not list endpoint slices: %v", err)
+	}
+	s.applyHealth(apps)
+	s.applyHealth(services)
+	s.applyBookmarkHealth(config.Bookmarks)
+	s.applyCerts(apps, services, config.Bookmarks)
+
+	prefs, _ := s.loadPreferences(r)
+	palette := resolvePalette(prefs, config)
+	data := PageData{
+		Config:        config,
+		DemoMode:      s.kube() == nil,
+		Theme:         resolveTheme(prefs, config),
+		Palette:       palette,
+		ThemeColor:    themeColor(config, palette),
+		Locale:        localeFor(r, config),
+		HealthSummary: summarizeHealth(apps, services, config.Bookmarks),
+		StatusRows:    buildStatusRows(apps, services, config.Bookmarks, replicas),
+		Now:           time.Now(),
+	}
+
+	data.Nonce = s.setCSP(w)
+	w.Header().Add("Vary", "Accept-Language")
+	if err := s.templates[data.Locale.Lang].ExecuteTemplate(w, "status.html", data); err != nil {
+		log.Printf("Error rendering status template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Namespaces of the data store.
+const (
+	storeNamespaceClicks   = "clicks"   // ClickCount by tile ID
+	storeNamespaceHealth   = "health"   // []HealthSample by URL
+	storeNamespacePrefs    = "prefs"    // Preferences by tailnet login
+	storeNamespaceFavicons = "favicons" // faviconMeta by scheme://host
+	storeNamespaceSessions = "sessions" // the session cookie signing key, under "key"
+)
+
+// Store is a key/value store for data that changes too often or grows too
+// large to live in the ConfigMap: click counts, uptime history, per-user
+// preferences and favicon metadata. Keys are grouped into namespaces, one
+// per feature, and values are JSON documents, encoded by setJSON, listJSON
+// and replaceJSON. Features take whichever Store STORE selects, so none of
+// them is tied to one way of persisting.
+type Store interface {
+	// Get returns the value under key and whether there is one.
+	Get(ctx context.Context, namespace, key string) (json.RawMessage, bool, error)
+	// Set stores value under key.
+	Set(ctx context.Context, namespace, key string, value json.RawMessage) error
+	// Delete removes key; removing a missing key is not an error.
+	Delete(ctx context.Context, namespace, key string) error
+	// List returns every key and value in namespace.
+	List(ctx context.Context, namespace string) (map[string]json.RawMessage, error)
+	// Replace makes values the whole contents of namespace in one step.
+	Replace(ctx context.Context, namespace string, values map[string]json.RawMessage) error
+}
+
+// storeFileNames are the files the file and SQLite stores keep in DATA_DIR.
+var storeFileNames = map[string]string{"file": "gohome.json", "sqlite": "gohome.sqlite"}
+
+// legacyStoreFileName is what the file store was called before it was
+// named after what it holds, JSON rather than a database.
+const legacyStoreFileName = "gohome.db"
+
+// sharedStore is the store STORE selects: "memory", "file", "sqlite" or
+// "redis", defaulting to "file" when DATA_DIR is set. It is nil when neither
+// is set or the store can't be opened, in which case everything is kept in
+// memory and the ConfigMap as before.
+var sharedStore = sync.OnceValue(func() Store {
+	kind := os.Getenv("STORE")
+	dir := os.Getenv("DATA_DIR")
+	if kind == "" && dir != "" {
+		kind = "file"
+	}
+	if kind == "" {
+		return nil
+	}
+	store, where, err := openStore(kind, dir)
+	if err != nil {
+		log.Printf("Warning: Not using the %s store: %v", kind, err)
+		return nil
+	}
+	log.Printf("Keeping click counts, uptime history, preferences and favicon metadata in %s", where)
+	return store
+})
+
+// openStore opens the named kind of store, returning a description of where
+// it keeps its data for the log.
+func openStore(kind, dir string) (Store, string, error) {
+	switch kind {
+	case "memory":
+		return newMemoryStore(), "memory", nil
+	case "file", "sqlite":
+		if dir == "" {
+			return nil, "", fmt.Errorf("STORE=%s needs DATA_DIR", kind)
+		}
+		path := filepath.Join(dir, storeFileNames[kind])
+		if kind == "file" {
+			if err := renameLegacyStoreFile(dir, path); err != nil {
+				return nil, "", err
+			}
+			store, err := OpenFileStore(path)
+			return store, path, err
+		}
+		store, err := OpenSQLiteStore(path)
+		return store, path, err
+	case "redis":
+		client := sharedRedis()
+		if client == nil {
+			return nil, "", fmt.Errorf("STORE=redis needs REDIS_URL")
+		}
+		return &redisStore{client: client}, "Redis at " + client.addr, nil
+	}
+	return nil, "", fmt.Errorf("unknown STORE %q, want memory, file, sqlite or redis", kind)
+}
+
+// renameLegacyStoreFile moves a file store left under legacyStoreFileName
+// to path, unless there already is one there.
+func renameLegacyStoreFile(dir, path string) error {
+	legacy := filepath.Join(dir, legacyStoreFileName)
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return nil
+	}
+	log.Printf("Renaming %s to %s", legacy, path)
+	return os.Rename(legacy, path)
+}
+
+// setJSON stores v under key.
+func setJSON[V any](ctx context.Context, s Store, namespace, key string, v V) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Set(ctx, namespace, key, raw)
+}
+
+// listJSON decodes every value in namespace. Values that don't decode are
+// logged and left out.
+func listJSON[V any](ctx context.Context, s Store, namespace string) (map[string]V, error) {
+	raws, err := s.List(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]V, len(raws))
+	for key, raw := range raws {
+		var v V
+		if err := json.Unmarshal(raw, &v); err != nil {
+			log.Printf("Warning: Ignoring invalid %s/%s in the store: %v", namespace, key, err)
+			continue
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// replaceJSON makes values the whole contents of namespace.
+func replaceJSON[V any](ctx context.Context, s Store, namespace string, values map[string]V) error {
+	raws := make(map[string]json.RawMessage, len(values))
+	for key, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		raws[key] = raw
+	}
+	return s.Replace(ctx, namespace, raws)
+}
+
+// memoryStore keeps everything in memory, for trying things out and for
+// running without a volume when losing the data on restart is fine.
+type memoryStore struct {
+	mu   sync.Mutex
+	data map[string]map[string]json.RawMessage
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: make(map[string]map[string]json.RawMessage)}
+}
+
+func (m *memoryStore) Get(_ context.Context, namespace, key string) (json.RawMessage, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.data[namespace][key]
+	return value, ok, nil
+}
+
+func (m *memoryStore) Set(_ context.Context, namespace, key string, value json.RawMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data[namespace] == nil {
+		m.data[namespace] = make(map[string]json.RawMessage)
+	}
+	m.data[namespace][key] = value
+	return nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, namespace, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data[namespace], key)
+	return nil
+}
+
+func (m *memoryStore) List(_ context.Context, namespace string) (map[string]json.RawMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.data[namespace]), nil
+}
+
+func (m *memoryStore) Replace(_ context.Context, namespace string, values map[string]json.RawMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[namespace] = maps.Clone(values)
+	return nil
+}
+
+// redisStore keeps each namespace in a Redis hash, gohome:store:<namespace>,
+// so every replica sees the same data.
+type redisStore struct {
+	client *RedisClient
+}
+
+// redisReplaceScript replaces the hash in KEYS[1] with the field/value pairs
+// in ARGV.
+const redisReplaceScript = `redis.call('DEL', KEYS[1])
+for i = 1, #ARGV, 2 do
+  redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return 1`
+
+func (r *redisStore) key(namespace string) string {
+	return redisKeyPrefix + "store:" + namespace
+}
+
+func (r *redisStore) Get(ctx context.Context, namespace, key string) (json.RawMessage, bool, error) {
+	reply, err := r.client.Do(ctx, "HGET", r.key(namespace), key)
+	value, ok := reply.(string)
+	return json.RawMessage(value), ok, err
+}
+
+func (r *redisStore) Set(ctx context.Context, namespace, key string, value json.RawMessage) error {
+	_, err := r.client.Do(ctx, "HSET", r.key(namespace), key, string(value))
+	return err
+}
+
+func (r *redisStore) Delete(ctx context.Context, namespace, key string) error {
+	_, err := r.client.Do(ctx, "HDEL", r.key(namespace), key)
+	return err
+}
+
+func (r *redisStore) List(ctx context.Context, namespace string) (map[string]json.RawMessage, error) {
+	fields, err := r.client.HGetAll(ctx, "store:"+namespace)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]json.RawMessage, len(fields))
+	for key, value := range fields {
+		values[key] = json.RawMessage(value)
+	}
+	return values, nil
+}
+
+func (r *redisStore) Replace(ctx context.Context, namespace string, values map[string]json.RawMessage) error {
+	args := []string{"EVAL", redisReplaceScript, "1", r.key(namespace)}
+	for key, value := range values {
+		args = append(args, key, string(value))
+	}
+	_, err := r.client.Do(ctx, args...)
+	return err
+}
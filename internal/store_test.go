@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testStore runs the same writes against any Store and checks that each
+// one reads back as the interface promises.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	raw := func(v string) json.RawMessage { return json.RawMessage(v) }
+	list := func(namespace string) map[string]string {
+		t.Helper()
+		values, err := s.List(ctx, namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string, len(values))
+		for k, v := range values {
+			got[k] = string(v)
+		}
+		return got
+	}
+
+	if _, ok, err := s.Get(ctx, storeNamespaceClicks, "grafana"); ok || err != nil {
+		t.Fatalf("Get of a missing key: %v, %v", ok, err)
+	}
+	for _, step := range []struct {
+		name  string
+		write func() error
+		want  map[string]string
+	}{
+		{"set", func() error { return s.Set(ctx, storeNamespaceClicks, "grafana", raw(`{"count":1}`)) }, map[string]string{"grafana": `{"count":1}`}},
+		{"set another", func() error { return s.Set(ctx, storeNamespaceClicks, "docs", raw(`{"count":2}`)) }, map[string]string{"grafana": `{"count":1}`, "docs": `{"count":2}`}},
+		{"overwrite", func() error { return s.Set(ctx, storeNamespaceClicks, "grafana", raw(`{"count":3}`)) }, map[string]string{"grafana": `{"count":3}`, "docs": `{"count":2}`}},
+		{"delete", func() error { return s.Delete(ctx, storeNamespaceClicks, "docs") }, map[string]string{"grafana": `{"count":3}`}},
+		{"delete missing", func() error { return s.Delete(ctx, storeNamespaceClicks, "docs") }, map[string]string{"grafana": `{"count":3}`}},
+		{"replace", func() error {
+			return s.Replace(ctx, storeNamespaceClicks, map[string]json.RawMessage{"a": raw(`1`), "b": raw(`2`)})
+		}, map[string]string{"a": `1`, "b": `2`}},
+		{"replace with nothing", func() error { return s.Replace(ctx, storeNamespaceClicks, nil) }, map[string]string{}},
+	} {
+		if err := step.write(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := list(storeNamespaceClicks); !maps.Equal(got, step.want) {
+			t.Errorf("after %s: %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	// Namespaces don't see each other's keys.
+	if err := s.Set(ctx, storeNamespacePrefs, "a", raw(`"prefs"`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(storeNamespaceClicks); len(got) != 0 {
+		t.Errorf("clicks namespace holds %v", got)
+	}
+	if value, ok, err := s.Get(ctx, storeNamespacePrefs, "a"); !ok || err != nil || string(value) != `"prefs"` {
+		t.Errorf("Get: %s, %v, %v", value, ok, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, newMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "gohome.json")
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+
+	// Everything is read back from the file by the next process.
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, _ := reopened.Get(context.Background(), storeNamespacePrefs, "a"); !ok || string(value) != `"prefs"` {
+		t.Errorf("after reopening: %s, %v", value, ok)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%d files in the data directory, want only the store", len(entries))
+	}
+}
+
+func TestOpenFileStore(t *testing.T) {
+	tests := []struct {
+		name, contents string
+		err            string // substring of the error, if one is expected
+		migrated       bool   // the file is rewritten with the initial buckets
+	}{
+		{name: "new file", migrated: true},
+		{name: "unversioned file", contents: `{"buckets":{"clicks":{"a":1}}}`, migrated: true},
+		{name: "current file", contents: `{"version":1,"buckets":{}}`},
+		{name: "newer file", contents: `{"version":99,"buckets":{}}`, err: "newer than this GoHome supports"},
+		{name: "corrupt file", contents: `{"version":`, err: "is corrupt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gohome.json")
+			if tt.contents != "" {
+				if err := os.WriteFile(path, []byte(tt.contents), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s, err := OpenFileStore(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one containing %q", err, tt.err)
+				}
+				// A file GoHome can't read is left alone.
+				if raw, _ := os.ReadFile(path); string(raw) != tt.contents {
+					t.Errorf("file rewritten to %s", raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var onDisk fileStoreData
+			raw, _ := os.ReadFile(path)
+			if err := json.Unmarshal(raw, &onDisk); err != nil || onDisk.Version != len(fileStoreMigrations) {
+				t.Errorf("file at version %d (%v), want %d", onDisk.Version, err, len(fileStoreMigrations))
+			}
+			if _, ok := onDisk.Buckets[storeNamespaceHealth]; ok != tt.migrated {
+				t.Errorf("file has a %s bucket: %v, want %v", storeNamespaceHealth, ok, tt.migrated)
+			}
+			if tt.name == "unversioned file" {
+				if value, ok, _ := s.Get(context.Background(), storeNamespaceClicks, "a"); !ok || string(value) != "1" {
+					t.Errorf("migration lost a value: %s, %v", value, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenStoreRenamesLegacyFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, legacyStoreFileName), []byte(`{"version":1,"buckets":{"clicks":{"a":1}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, where, err := openStore("file", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "gohome.json"); where != want {
+		t.Errorf("store at %s, want %s", where, want)
+	}
+	if value, ok, _ := s.Get(context.Background(), storeNamespaceClicks, "a"); !ok || string(value) != "1" {
+		t.Errorf("legacy value %s, %v", value, ok)
+	}
+	if _, err := os.Stat(filepath.Join(dir, legacyStoreFileName)); !os.IsNotExist(err) {
+		t.Errorf("%s is still there: %v", legacyStoreFileName, err)
+	}
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"tailscale.com/client/local"
+	"tailscale.com/ipn"
+	"tailscale.com/tailcfg"
+)
+
+const (
+	// tailnetNamespace is the namespace tailnet devices are shown in.
+	tailnetNamespace = "tailscale"
+	// defaultTailnetURLTemplate links to a device by its MagicDNS name.
+	defaultTailnetURLTemplate = "{{if eq .Port 443}}https://{{.Address}}{{else if eq .Port 80}}http://{{.Address}}{{else}}http://{{.Address}}:{{.Port}}{{end}}"
+)
+
+// defaultTailnetPorts are the web ports a device is linked on, in order of
+// preference.
+var defaultTailnetPorts = []int{443, 80}
+
+// TailnetDevices lists the devices GoHome's own tailnet node can see as
+// tiles, so machines reachable only over Tailscale show up with their
+// MagicDNS names. A device is linked on the first of TAILSCALE_PORTS it
+// advertises in its Tailscale services; one that advertises no services
+// (the tailnet doesn't collect them) is linked on the first port anyway.
+// Listings are cached like ingress listings.
+type TailnetDevices struct {
+	tags     []string // a device is listed if it has one of these ACL tags; empty for every device
+	ports    []int
+	template *template.Template
+	devices  *Cache[[]IngressInfo]
+}
+
+// NewTailnetDevicesFromEnv returns a lister for the devices tagged with one
+// of TAILSCALE_TAGS when TAILSCALE_DEVICES=true, or nil.
+func NewTailnetDevicesFromEnv() *TailnetDevices {
+	if os.Getenv("TAILSCALE_DEVICES") != "true" {
+		return nil
+	}
+	t := &TailnetDevices{
+		ports:    defaultTailnetPorts,
+		template: urlTemplateFromEnv("TAILSCALE_URL_TEMPLATE", defaultTailnetURLTemplate),
+		devices:  NewCacheFromEnv[[]IngressInfo]("tailscale"),
+	}
+	for _, tag := range splitList(os.Getenv("TAILSCALE_TAGS")) {
+		if !strings.HasPrefix(tag, "tag:") {
+			tag = "tag:" + tag
+		}
+		t.tags = append(t.tags, tag)
+	}
+	if v := splitList(os.Getenv("TAILSCALE_PORTS")); len(v) > 0 {
+		t.ports = nil
+		for _, p := range v {
+			port, err := strconv.Atoi(p)
+			if err != nil || port < 1 || port > 65535 {
+				log.Printf("Warning: Ignoring invalid TAILSCALE_PORTS entry %q", p)
+				continue
+			}
+			t.ports = append(t.ports, port)
+		}
+		if len(t.ports) == 0 {
+			t.ports = defaultTailnetPorts
+		}
+	}
+	log.Printf("Listing tailnet devices")
+	return t
+}
+
+// Devices returns a tile for each listed device, sorted by name, from the
+// network map of lc. If it can't be read it returns the error together with
+// the tiles from the last listing that worked, if any. A nil lister, or one
+// without a client yet, has no devices.
+func (t *TailnetDevices) Devices(ctx context.Context, lc *local.Client) ([]IngressInfo, error) {
+	if t == nil || lc == nil {
+		return nil, nil
+	}
+	devices, err := t.devices.Get(ctx, "", func(ctx context.Context) ([]IngressInfo, error) {
+		return t.list(ctx, lc)
+	})
+	return slices.Clone(devices), err
+}
+
+// list reads the current network map and builds a tile for every peer
+// that passes the tag filter and serves one of the ports.
+func (t *TailnetDevices) list(ctx context.Context, lc *local.Client) ([]IngressInfo, error) {
+	watcher, err := lc.WatchIPNBus(ctx, ipn.NotifyInitialNetMap)
+	if err != nil {
+		return nil, err
+	}
+	defer watcher.Close()
+	var n ipn.Notify
+	for n.NetMap == nil {
+		if n, err = watcher.Next(); err != nil {
+			return nil, err
+		}
+		if n.ErrMessage != nil {
+			return nil, errors.New(*n.ErrMessage)
+		}
+	}
+
+	var tiles []IngressInfo
+	for _, peer := range n.NetMap.Peers {
+		if peer.IsWireGuardOnly() || peer.Name() == "" {
+			continue
+		}
+		tags := peer.Tags().AsSlice()
+		if !t.wanted(tags) {
+			continue
+		}
+		port, ok := t.port(peer.Hostinfo().Services().AsSlice())
+		if !ok {
+			continue
+		}
+		name := peer.ComputedName()
+		if name == "" {
+			name = peer.Hostinfo().Hostname()
+		}
+		data := serviceURLData{
+			Name:       name,
+			Address:    strings.TrimSuffix(peer.Name(), "."),
+			Port:       port,
+			Node:       peer.Hostinfo().Hostname(),
+			Datacenter: n.NetMap.Domain,
+		}
+		for _, tag := range tags {
+			data.Tags = append(data.Tags, strings.TrimPrefix(tag, "tag:"))
+		}
+		if info, ok := serviceTile(fmt.Sprintf("tailnet device %s", name), tailnetNamespace, t.template, data); ok {
+			tiles = append(tiles, info)
+		}
+	}
+	sort.Slice(tiles, func(i, j int) bool { return tiles[i].Name < tiles[j].Name })
+	return tiles, nil
+}
+
+// wanted reports whether a device with ACL tags passes TAILSCALE_TAGS.
+func (t *TailnetDevices) wanted(tags []string) bool {
+	if len(t.tags) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(tags, func(tag string) bool { return slices.Contains(t.tags, tag) })
+}
+
+// port returns the first of the ports that services include, the first
+// port when services lists no TCP ports at all, or false when it lists
+// only others.
+func (t *TailnetDevices) port(services []tailcfg.Service) (int, bool) {
+	var listening []int
+	for _, svc := range services {
+		if svc.Proto == tailcfg.TCP {
+			listening = append(listening, int(svc.Port))
+		}
+	}
+	if len(listening) == 0 {
+		return t.ports[0], true
+	}
+	for _, port := range t.ports {
+		if slices.Contains(listening, port) {
+			return port, true
+		}
+	}
+	return 0, false
+}
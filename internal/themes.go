@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Palette is a named set of CSS colour variables with a dark and a light
+// variant. The active variant is chosen by the data-theme attribute (see
+// resolveTheme), so every palette works with the light/dark toggle.
+type Palette struct {
+	Dark  map[string]string
+	Light map[string]string
+}
+
+// DefaultPalette is the built-in look defined directly in static/style.css.
+const DefaultPalette = "default"
+
+// palettes are the built-in colour schemes selectable via the "palette"
+// ConfigMap key, the PALETTE env var, or the picker in the header.
+var palettes = map[string]Palette{
+	"nord": {
+		Dark: map[string]string{
+			"bg-primary": "#2e3440", "bg-secondary": "#3b4252", "bg-tertiary": "#434c5e",
+			"text-primary": "#eceff4", "text-secondary": "#d8dee9", "text-muted": "#7b88a1",
+			"accent-primary": "#88c0d0", "accent-secondary": "#b48ead",
+			"success": "#a3be8c", "warning": "#ebcb8b", "error": "#bf616a",
+			"border": "#4c566a", "border-light": "#5e6b82",
+		},
+		Light: map[string]string{
+			"bg-primary": "#eceff4", "bg-secondary": "#ffffff", "bg-tertiary": "#e5e9f0",
+			"text-primary": "#2e3440", "text-secondary": "#3b4252", "text-muted": "#6c7a96",
+			"accent-primary": "#5e81ac", "accent-secondary": "#b48ead",
+			"success": "#5f8a47", "warning": "#b58a2a", "error": "#bf616a",
+			"border": "#d8dee9", "border-light": "#c2cad8",
+		},
+	},
+	"dracula": {
+		Dark: map[string]string{
+			"bg-primary": "#282a36", "bg-secondary": "#303341", "bg-tertiary": "#44475a",
+			"text-primary": "#f8f8f2", "text-secondary": "#d6d6d0", "text-muted": "#6272a4",
+			"accent-primary": "#8be9fd", "accent-secondary": "#bd93f9",
+			"success": "#50fa7b", "warning": "#f1fa8c", "error": "#ff5555",
+			"border": "#44475a", "border-light": "#6272a4",
+		},
+		// Dracula's official light counterpart, "Alucard".
+		Light: map[string]string{
+			"bg-primary": "#fffbeb", "bg-secondary": "#ffffff", "bg-tertiary": "#efeddc",
+			"text-primary": "#1f1f1f", "text-secondary": "#3a3a3a", "text-muted": "#635d97",
+			"accent-primary": "#036a96", "accent-secondary": "#644ac9",
+			"success": "#14710a", "warning": "#846e15", "error": "#cb3a2a",
+			"border": "#cfcfde", "border-light": "#bcbad6",
+		},
+	},
+	"gruvbox": {
+		Dark: map[string]string{
+			"bg-primary": "#282828", "bg-secondary": "#32302f", "bg-tertiary": "#3c3836",
+			"text-primary": "#ebdbb2", "text-secondary": "#d5c4a1", "text-muted": "#928374",
+			"accent-primary": "#83a598", "accent-secondary": "#d3869b",
+			"success": "#b8bb26", "warning": "#fabd2f", "error": "#fb4934",
+			"border": "#504945", "border-light": "#665c54",
+		},
+		Light: map[string]string{
+			"bg-primary": "#fbf1c7", "bg-secondary": "#f9f5d7", "bg-tertiary": "#ebdbb2",
+			"text-primary": "#3c3836", "text-secondary": "#504945", "text-muted": "#928374",
+			"accent-primary": "#076678", "accent-secondary": "#8f3f71",
+			"success": "#79740e", "warning": "#b57614", "error": "#9d0006",
+			"border": "#d5c4a1", "border-light": "#bdae93",
+		},
+	},
+	"solarized": {
+		Dark: map[string]string{
+			"bg-primary": "#002b36", "bg-secondary": "#073642", "bg-tertiary": "#0b4452",
+			"text-primary": "#eee8d5", "text-secondary": "#93a1a1", "text-muted": "#657b83",
+			"accent-primary": "#2aa198", "accent-secondary": "#6c71c4",
+			"success": "#859900", "warning": "#b58900", "error": "#dc322f",
+			"border": "#0e4b5a", "border-light": "#586e75",
+		},
+		Light: map[string]string{
+			"bg-primary": "#fdf6e3", "bg-secondary": "#fffbf0", "bg-tertiary": "#eee8d5",
+			"text-primary": "#073642", "text-secondary": "#586e75", "text-muted": "#93a1a1",
+			"accent-primary": "#268bd2", "accent-secondary": "#6c71c4",
+			"success": "#859900", "warning": "#b58900", "error": "#dc322f",
+			"border": "#e4dcc6", "border-light": "#d3cbb7",
+		},
+	},
+}
+
+// PaletteNames returns the selectable palette names, default first.
+func PaletteNames() []string {
+	names := make([]string, 0, len(palettes))
+	for name := range palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultPalette}, names...)
+}
+
+// validPalette reports whether name is a known palette.
+func validPalette(name string) bool {
+	_, ok := palettes[name]
+	return ok || name == DefaultPalette
+}
+
+// paletteCSS renders a palette as CSS custom properties, mirroring the
+// dark/light/auto selectors used in static/style.css.
+func paletteCSS(p Palette) string {
+	var b strings.Builder
+	writeVars := func(selector string, vars map[string]string, indent string) {
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&b, "%s%s {\n", indent, selector)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s    --%s: %s;\n", indent, k, vars[k])
+		}
+		fmt.Fprintf(&b, "%s}\n", indent)
+	}
+
+	writeVars(":root", p.Dark, "")
+	writeVars(`:root[data-theme="light"]`, p.Light, "")
+	b.WriteString("@media (prefers-color-scheme: light) {\n")
+	writeVars(`:root[data-theme="auto"]`, p.Light, "    ")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// handlePaletteCSS serves /theme/{name}.css. The default palette lives in
+// style.css already, so it's served as an empty stylesheet.
+func (s *Server) handlePaletteCSS(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("file"), ".css")
+	if !ok || !validPalette(name) {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if name == DefaultPalette {
+		return
+	}
+	_, _ = w.Write([]byte(paletteCSS(palettes[name])))
+}
@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// tileFeedVersion is the TileFeed format served by GET /api/v1/tiles. Fields
+// may be added within a version but are never renamed or removed.
+const tileFeedVersion = 1
+
+// TileFeed is the JSON body returned by GET /api/v1/tiles, for dashboards
+// and scripts that use GoHome as their discovery source.
+type TileFeed struct {
+	Version   int        `json:"version"`
+	Generated time.Time  `json:"generated"`
+	Title     string     `json:"title"`
+	Tiles     []FeedTile `json:"tiles"`
+}
+
+// FeedTile is one app, service or bookmark in a TileFeed.
+type FeedTile struct {
+	ID        string      `json:"id"`   // "namespace/ingress" or "bookmark/Name", as in /api/v1/stats
+	Kind      string      `json:"kind"` // "app", "service" or "bookmark"
+	Name      string      `json:"name"`
+	URL       string      `json:"url"`
+	Group     string      `json:"group"` // "Apps", "Services" or the bookmark category
+	Host      string      `json:"host,omitempty"`
+	Namespace string      `json:"namespace,omitempty"`
+	Cluster   string      `json:"cluster,omitempty"`
+	Tags      []string    `json:"tags,omitempty"`
+	Icon      string      `json:"icon,omitempty"`   // as configured, e.g. "si:grafana"
+	Health    HealthState `json:"health,omitempty"` // only with health checks on
+}
+
+// HomepageService is one entry of a gethomepage.dev services.yaml.
+type HomepageService struct {
+	Href        string `json:"href"`
+	Description string `json:"description,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+}
+
+// feedTiles lists the tiles in config that restrict keeps, in page order,
+// each group of apps, services and bookmark categories in turn. With a
+// page slug, configured or "ns/<namespace>", only that page's tiles are
+// listed.
+func (s *Server) feedTiles(ctx context.Context, config *Config, slug string, restrict func(apps, services []IngressInfo, bookmarks []Bookmark) ([]IngressInfo, []IngressInfo, []Bookmark)) ([]FeedTile, error) {
+	page := s.pageFor(ctx, config, slug)
+	if slug != "" && page == nil {
+		return nil, fmt.Errorf("no page %q", slug)
+	}
+	apps, services, err := s.visibleTiles(ctx)
+	if err != nil {
+		log.Printf("Warning: Could not load ingresses: %v", err)
+	}
+	apps, services, bookmarks := restrict(apps, services, config.Bookmarks)
+	if page != nil {
+		apps, services, bookmarks = page.filter(apps, services, bookmarks)
+	}
+	categories := groupBookmarks(bookmarks)
+	applyTileOrder(config, apps, services, categories)
+
+	tiles := make([]FeedTile, 0, len(apps)+len(services)+len(bookmarks))
+	for _, section := range []struct {
+		kind, group string
+		tiles       []IngressInfo
+	}{{"app", "Apps", apps}, {"service", "Services", services}} {
+		for _, info := range section.tiles {
+			tiles = append(tiles, FeedTile{
+				ID:        ingressTileID(info),
+				Kind:      section.kind,
+				Name:      info.Name,
+				URL:       info.URL,
+				Group:     section.group,
+				Host:      info.Host,
+				Namespace: info.Namespace,
+				Cluster:   info.Cluster,
+				Tags:      info.Tags,
+				Icon:      info.Icon,
+			})
+		}
+	}
+	for _, category := range categories {
+		for _, b := range category.Bookmarks {
+			tiles = append(tiles, FeedTile{
+				ID:    bookmarkTileID(b),
+				Kind:  "bookmark",
+				Name:  b.Name,
+				URL:   b.URL,
+				Group: category.Name,
+				Host:  hostOf(b.URL),
+				Tags:  b.Tags,
+				Icon:  b.Icon,
+			})
+		}
+	}
+	return tiles, nil
+}
+
+// requestTiles is feedTiles for a request to the feed: the tiles its
+// visitor may see on ?page=, with their health.
+func (s *Server) requestTiles(w http.ResponseWriter, r *http.Request) (*Config, []FeedTile, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return nil, nil, false
+	}
+	viewer := s.resolveViewer(ctx, r)
+	tiles, err := s.feedTiles(ctx, config, r.URL.Query().Get("page"), func(apps, services []IngressInfo, bookmarks []Bookmark) ([]IngressInfo, []IngressInfo, []Bookmark) {
+		return s.restrictTiles(r, viewer, config, apps, services, bookmarks)
+	})
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return nil, nil, false
+	}
+	if s.health != nil {
+		for i := range tiles {
+			tiles[i].Health = s.health.Status(tiles[i].URL).State
+		}
+	}
+	return config, tiles, true
+}
+
+// handleTiles serves GET /api/v1/tiles: every tile the visitor may see in
+// the documented TileFeed format.
+func (s *Server) handleTiles(w http.ResponseWriter, r *http.Request) {
+	config, tiles, ok := s.requestTiles(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newTileFeed(config, tiles))
+}
+
+// newTileFeed wraps tiles from config in a TileFeed.
+func newTileFeed(config *Config, tiles []FeedTile) TileFeed {
+	return TileFeed{
+		Version:   tileFeedVersion,
+		Generated: time.Now().UTC(),
+		Title:     config.Title,
+		Tiles:     tiles,
+	}
+}
+
+// TileListOptions selects what ListTiles prints.
+type TileListOptions struct {
+	Format string // "table" or "json"
+	Page   string // page slug, configured or "ns/<namespace>"; empty for the homepage
+	As     string // tailnet login to list the tiles of, "-" for a visitor without one; empty for every tile
+}
+
+// ListTiles writes the tiles the homepage would show to w, as a table or
+// in the TileFeed format of GET /api/v1/tiles. It is what gohome links
+// prints.
+func (s *Server) ListTiles(ctx context.Context, w io.Writer, opts TileListOptions) error {
+	if opts.Format != "table" && opts.Format != "json" {
+		return fmt.Errorf("unknown format %q, want table or json", opts.Format)
+	}
+	// Links files and mDNS are otherwise fetched by RunBackground.
+	s.scheduler.RunOnce(ctx, s.linkJobs()...)
+	s.bookmarkManager.InvalidateCache()
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	tiles, err := s.feedTiles(ctx, config, opts.Page, func(apps, services []IngressInfo, bookmarks []Bookmark) ([]IngressInfo, []IngressInfo, []Bookmark) {
+		if opts.As == "" {
+			return apps, services, bookmarks
+		}
+		login := opts.As
+		if login == "-" {
+			login = ""
+		}
+		return s.restrictTiles(httptest.NewRequest(http.MethodGet, "/", nil), login, config, apps, services, bookmarks)
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.Format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(newTileFeed(config, tiles))
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tURL\tGROUP\tNAMESPACE")
+	for _, tile := range tiles {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", tile.Kind, tile.Name, tile.URL, tile.Group, cmp.Or(tile.Namespace, "-"))
+	}
+	return tw.Flush()
+}
+
+// handleHomepageServices serves GET /api/v1/homepage/services.yaml: the
+// same tiles as a gethomepage.dev services.yaml, one group per section
+// of the page, so Homepage can show what GoHome discovered.
+func (s *Server) handleHomepageServices(w http.ResponseWriter, r *http.Request) {
+	_, tiles, ok := s.requestTiles(w, r)
+	if !ok {
+		return
+	}
+
+	// services.yaml is a list of groups, each a list of services, and every
+	// group and service a single-key map naming it.
+	groups := make([]map[string][]map[string]HomepageService, 0)
+	for i, tile := range tiles {
+		if i == 0 || tiles[i-1].Group != tile.Group {
+			groups = append(groups, map[string][]map[string]HomepageService{tile.Group: nil})
+		}
+		group := groups[len(groups)-1]
+		group[tile.Group] = append(group[tile.Group], map[string]HomepageService{tile.Name: {
+			Href:        tile.URL,
+			Description: tile.Host,
+			Icon:        homepageIcon(tile.Icon),
+		}})
+	}
+
+	body, err := yaml.Marshal(groups)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing %s: %v", r.URL.Path, err)
+	}
+}
+
+// homepageIcon converts an icon setting to Homepage's notation: "si-<slug>"
+// for Simple Icons, "<slug>.png" for Dashboard Icons and URLs as they are.
+// Anything else gives no icon.
+func homepageIcon(icon string) string {
+	icon = strings.TrimSpace(icon)
+	if strings.HasPrefix(icon, "https://") || strings.HasPrefix(icon, "http://") {
+		return icon
+	}
+	pack, slug, _ := strings.Cut(icon, ":")
+	slug = strings.ToLower(slug)
+	if !iconSlugPattern.MatchString(slug) {
+		return ""
+	}
+	switch pack {
+	case "si", "simple-icons":
+		return "si-" + slug
+	case "di", "dashboard-icons":
+		return slug + ".png"
+	}
+	return ""
+}
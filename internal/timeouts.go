@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultRenderTimeout bounds a whole page render.
+	defaultRenderTimeout = 10 * time.Second
+	// defaultSourceTimeout bounds each data source a render waits on.
+	defaultSourceTimeout = 5 * time.Second
+)
+
+// Data sources a page render waits on, as named in SOURCE_TIMEOUTS.
+const (
+	sourceConfigMap = "configmap"
+	sourceIngresses = "ingresses"
+	sourceIdentity  = "identity" // Tailscale WhoIs
+	sourceDNS       = "dns"
+)
+
+var renderSources = []string{sourceConfigMap, sourceIngresses, sourceIdentity, sourceDNS}
+
+// RenderTimeouts bound how long a page view waits, in total and on each
+// data source, so an API server that hangs makes the page degraded rather
+// than slow. A source that runs out of time is served from its last good
+// result where there is one, and its fetch carries on in the background
+// for the next view.
+type RenderTimeouts struct {
+	Render  time.Duration
+	Sources map[string]time.Duration
+}
+
+// NewRenderTimeoutsFromEnv reads RENDER_TIMEOUT and SOURCE_TIMEOUTS, a
+// comma-separated list of source=duration, e.g. "ingresses=3s,dns=1s".
+func NewRenderTimeoutsFromEnv() RenderTimeouts {
+	t := RenderTimeouts{
+		Render:  durationFromEnv("RENDER_TIMEOUT", defaultRenderTimeout),
+		Sources: make(map[string]time.Duration),
+	}
+	for _, pair := range splitList(os.Getenv("SOURCE_TIMEOUTS")) {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		switch {
+		case !ok || err != nil || d <= 0:
+			log.Printf("Warning: Ignoring invalid SOURCE_TIMEOUTS entry %q, want source=duration", pair)
+		case !slices.Contains(renderSources, name):
+			log.Printf("Warning: Ignoring SOURCE_TIMEOUTS entry for unknown source %q, want one of %s", name, strings.Join(renderSources, ", "))
+		default:
+			t.Sources[name] = d
+		}
+	}
+	return t
+}
+
+// render returns the context for a whole page render.
+func (t RenderTimeouts) render(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, t.Render, fmt.Errorf("page render took longer than RENDER_TIMEOUT (%s)", t.Render))
+}
+
+// source returns the context for loading one data source within a render.
+// Its cause names the source, so the error says which one was slow.
+func (t RenderTimeouts) source(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+	d, ok := t.Sources[name]
+	if !ok {
+		d = defaultSourceTimeout
+	}
+	return context.WithTimeoutCause(ctx, d, fmt.Errorf("%s timeout of %s exceeded", name, d))
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// GoHome doesn't record spans of its own. With TRACING=true it passes the
+// W3C trace context of a page request, as set by a tracing ingress
+// controller or proxy, on to the Kubernetes API requests made while
+// serving it. An API server with tracing enabled then records its spans
+// for those calls as children of the page request, so a slow render points
+// at the call responsible.
+
+// traceparentPattern matches a version 00 traceparent header with non-zero
+// trace and parent IDs.
+var traceparentPattern = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// traceContext is the trace a request is part of.
+type traceContext struct {
+	parent string // traceparent header
+	state  string // tracestate header; may be empty
+}
+
+type traceContextKey struct{}
+
+// tracingEnabled reports whether TRACING=true.
+func tracingEnabled() bool {
+	return os.Getenv("TRACING") == "true"
+}
+
+// withTraceContext remembers the trace context of each request in its
+// context, for traceKubernetes.
+func withTraceContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent := r.Header.Get("traceparent")
+		if traceparentPattern.MatchString(parent) && parent[3:35] != "00000000000000000000000000000000" && parent[36:52] != "0000000000000000" {
+			tc := traceContext{parent: parent, state: r.Header.Get("tracestate")}
+			r = r.WithContext(context.WithValue(r.Context(), traceContextKey{}, tc))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// kubernetesTracing adds the trace context of the page request, if any, to
+// each request the Kubernetes client sends through rt.
+type kubernetesTracing struct {
+	rt http.RoundTripper
+}
+
+// traceKubernetes wraps the Kubernetes client's transport; see
+// rest.Config.Wrap.
+func traceKubernetes(rt http.RoundTripper) http.RoundTripper {
+	return kubernetesTracing{rt: rt}
+}
+
+func (t kubernetesTracing) RoundTrip(req *http.Request) (*http.Response, error) {
+	tc, ok := req.Context().Value(traceContextKey{}).(traceContext)
+	if !ok || req.Header.Get("traceparent") != "" {
+		return t.rt.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(req.Context())
+	req.Header.Set("traceparent", tc.parent)
+	if tc.state != "" {
+		req.Header.Set("tracestate", tc.state)
+	}
+	return t.rt.RoundTrip(req)
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// trafficRateQuery and trafficErrorQuery are per-host request and 5xx
+	// rates from ingress-nginx's controller metrics.
+	trafficRateQuery  = `sum by (host) (rate(nginx_ingress_controller_requests[5m]))`
+	trafficErrorQuery = `sum by (host) (rate(nginx_ingress_controller_requests{status=~"5.."}[5m]))`
+	// trafficErrorPercent is the share of 5xx responses from which a tile's
+	// traffic indicator turns red.
+	trafficErrorPercent = 5
+	// trafficMinRate is the request rate, per second, below which a host
+	// counts as idle and gets no indicator.
+	trafficMinRate = 0.01
+)
+
+// hostTraffic is the request rate of one host, per second.
+type hostTraffic struct {
+	rate, errors float64
+}
+
+// TrafficStatus is a tile's request rate as rendered on it. The zero value
+// means no traffic is known.
+type TrafficStatus struct {
+	Rate   string // requests per second, e.g. "12" or "0.35"
+	Errors string // share of 5xx responses, e.g. "3%"
+	Level  string // "errors" from trafficErrorPercent, else ""
+}
+
+// fetchTraffic runs the ingress-nginx queries and caches their results by
+// host. A failure keeps the previous results.
+func (f *PromQLFetcher) fetchTraffic(ctx context.Context, baseURL string) error {
+	f.mu.Lock()
+	f.trafficAttempted = time.Now()
+	f.mu.Unlock()
+
+	fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	rates, err := f.byHost(fetchCtx, baseURL, trafficRateQuery)
+	var errorRates map[string]float64
+	if err == nil {
+		errorRates, err = f.byHost(fetchCtx, baseURL, trafficErrorQuery)
+	}
+	if err != nil {
+		log.Printf("Warning: Could not read ingress-nginx request rates: %v", err)
+		return err
+	}
+
+	traffic := make(map[string]hostTraffic, len(rates))
+	for host, rate := range rates {
+		traffic[host] = hostTraffic{rate: rate, errors: errorRates[host]}
+	}
+	f.mu.Lock()
+	f.traffic = traffic
+	f.mu.Unlock()
+	return nil
+}
+
+// byHost runs query and returns the value of each series by its lowercased
+// host label.
+func (f *PromQLFetcher) byHost(ctx context.Context, baseURL, query string) (map[string]float64, error) {
+	data, err := f.get(ctx, baseURL+"/api/v1/query", url.Values{"query": {query}})
+	if err != nil {
+		return nil, err
+	}
+	var result []struct {
+		Metric map[string]string `json:"metric"`
+		Value  [2]any            `json:"value"`
+	}
+	if err := json.Unmarshal(data.Data.Result, &result); err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64, len(result))
+	for _, series := range result {
+		host := strings.ToLower(series.Metric["host"])
+		v, err := sampleValue(series.Value)
+		if host == "" || err != nil {
+			continue
+		}
+		values[host] = v
+	}
+	return values, nil
+}
+
+// Traffic returns the cached request rate of host.
+func (f *PromQLFetcher) Traffic(host string) TrafficStatus {
+	if f == nil {
+		return TrafficStatus{}
+	}
+	f.mu.Lock()
+	t, ok := f.traffic[strings.ToLower(host)]
+	f.mu.Unlock()
+	if !ok || t.rate < trafficMinRate {
+		return TrafficStatus{}
+	}
+	percent := 100 * t.errors / t.rate
+	status := TrafficStatus{
+		Rate:   formatStat(t.rate, -1, ""),
+		Errors: formatStat(percent, 0, "%"),
+	}
+	if percent >= trafficErrorPercent {
+		status.Level = "errors"
+	}
+	return status
+}
+
+// applyTraffic sets the request rate of every tile once ingress-traffic is
+// on.
+func (s *Server) applyTraffic(apps, services []IngressInfo, cfg PrometheusConfig) {
+	if !cfg.IngressTraffic || cfg.URL == "" {
+		return
+	}
+	for _, list := range [][]IngressInfo{apps, services} {
+		for i := range list {
+			list[i].Traffic = s.promql.Traffic(list[i].Host)
+		}
+	}
+}
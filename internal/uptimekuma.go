@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// HealthCheckUptimeKuma marks results taken from Uptime Kuma rather than
+// probed by GoHome.
+const HealthCheckUptimeKuma = "uptime-kuma"
+
+// Monitor statuses in Uptime Kuma's monitor_status metric.
+const (
+	kumaDown        = 0
+	kumaUp          = 1
+	kumaPending     = 2 // failing, but still within the monitor's retries
+	kumaMaintenance = 3
+)
+
+// UptimeKuma reads monitor statuses from an Uptime Kuma instance, so tiles
+// it already monitors take its result instead of being probed twice.
+type UptimeKuma struct {
+	metricsURL string
+	apiKey     string
+	client     *http.Client
+}
+
+// NewUptimeKumaFromEnv returns a client for UPTIME_KUMA_URL authenticating
+// with UPTIME_KUMA_API_KEY, or nil when no URL is set. The key is best set
+// from a Secret.
+func NewUptimeKumaFromEnv() *UptimeKuma {
+	base := os.Getenv("UPTIME_KUMA_URL")
+	if base == "" {
+		return nil
+	}
+	k := &UptimeKuma{
+		metricsURL: strings.TrimSuffix(base, "/") + "/metrics",
+		apiKey:     os.Getenv("UPTIME_KUMA_API_KEY"),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	if k.apiKey == "" {
+		log.Printf("Warning: UPTIME_KUMA_URL is set without UPTIME_KUMA_API_KEY; Uptime Kuma only allows that with authentication disabled")
+	}
+	log.Printf("Taking health from Uptime Kuma at %s", redactURL(base))
+	return k
+}
+
+// Monitors returns the status of every HTTP monitor, keyed by kumaURLKey of
+// its URL, as of now. Monitors without a URL, such as ping or DNS ones,
+// are left out. slow is HEALTH_CHECK_SLOW.
+func (k *UptimeKuma) Monitors(ctx context.Context, slow time.Duration) (map[string]TargetHealth, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.metricsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gohome-uptime-kuma")
+	if k.apiKey != "" {
+		// API keys go in the password; the user name is ignored.
+		req.SetBasicAuth("", k.apiKey)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	// The other metrics are matched to a monitor by its name.
+	latency := make(map[string]float64)
+	for _, m := range families["monitor_response_time"].GetMetric() {
+		latency[kumaLabel(m.GetLabel(), "monitor_name")] = m.GetGauge().GetValue()
+	}
+	certDays := make(map[string]float64)
+	for _, m := range families["monitor_cert_days_remaining"].GetMetric() {
+		certDays[kumaLabel(m.GetLabel(), "monitor_name")] = m.GetGauge().GetValue()
+	}
+
+	now := time.Now()
+	monitors := make(map[string]TargetHealth)
+	for _, m := range families["monitor_status"].GetMetric() {
+		labels := m.GetLabel()
+		key := kumaURLKey(kumaLabel(labels, "monitor_url"))
+		if key == "" {
+			continue
+		}
+		name := kumaLabel(labels, "monitor_name")
+		result := TargetHealth{State: HealthDown, Check: HealthCheckUptimeKuma, LastChecked: now}
+		switch int(m.GetGauge().GetValue()) {
+		case kumaUp:
+			result.State = HealthUp
+			result.Latency = time.Duration(latency[name] * float64(time.Millisecond))
+			result.Slow = result.Latency > slow
+		case kumaDown:
+			result.Err = "down in Uptime Kuma"
+		case kumaPending:
+			result.Err = "pending in Uptime Kuma"
+		case kumaMaintenance:
+			result.State, result.Err = HealthUnknown, "under maintenance in Uptime Kuma"
+		}
+		if days, ok := certDays[name]; ok {
+			result.CertExpiry = now.Add(time.Duration(days * float64(24*time.Hour)))
+		}
+		monitors[key] = result
+	}
+	return monitors, nil
+}
+
+// kumaLabel returns the value of the named label.
+func kumaLabel(labels []*dto.LabelPair, name string) string {
+	for _, l := range labels {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// kumaURLKey normalises a URL so a tile and a monitor match despite a
+// different case in the host, a default port or a trailing slash. It
+// returns "" for anything that isn't an HTTP URL, including the "null"
+// Uptime Kuma reports for monitors without one.
+func kumaURLKey(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != map[string]string{"http": "80", "https": "443"}[u.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	key := u.Scheme + "://" + host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+// kumaMonitors fetches the Uptime Kuma statuses for one round of checks.
+// On failure every target is probed as usual.
+func (h *HealthChecker) kumaMonitors(ctx context.Context) map[string]TargetHealth {
+	if h.kuma == nil {
+		return nil
+	}
+	monitors, err := h.kuma.Monitors(ctx, h.slow)
+	if err != nil {
+		log.Printf("Warning: Could not read Uptime Kuma monitors, probing every target: %v", err)
+		return nil
+	}
+	return monitors
+}
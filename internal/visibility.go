@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	visibilityKeyPrefix = "visibility-"
+	groupKeyPrefix      = "group-"
+)
+
+// VisibilityRule limits the tiles it matches to members of Groups. Like a
+// Page, Namespaces and Selector pick ingresses and Categories picks
+// bookmarks; a rule without ingress filters matches no ingresses, and one
+// without categories matches no bookmarks.
+type VisibilityRule struct {
+	Name       string
+	Namespaces []string
+	Categories []string // category IDs, see categoryID
+	Selector   labels.Selector
+	Groups     []string
+}
+
+// Visibility is who may see which tiles, from visibility-<name> and
+// group-<name> keys.
+type Visibility struct {
+	Rules   []VisibilityRule
+	Members map[string][]string // group name to the logins in it
+}
+
+// parseVisibilityRule parses a visibility-<name> ConfigMap value of
+// |key=value settings, e.g. "namespaces=argocd,portainer|groups=admins".
+func parseVisibilityRule(key, value string) (VisibilityRule, bool) {
+	rule := VisibilityRule{Name: strings.TrimPrefix(key, visibilityKeyPrefix)}
+	for _, opt := range strings.Split(value, "|") {
+		k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		v = strings.TrimSpace(v)
+		switch k {
+		case "":
+		case "namespaces":
+			rule.Namespaces = splitList(v)
+		case "categories":
+			for _, c := range splitList(v) {
+				rule.Categories = append(rule.Categories, categoryID(c))
+			}
+		case "labels":
+			selector, err := labels.Parse(v)
+			if err != nil {
+				log.Printf("Warning: %s has invalid labels %q: %v", key, v, err)
+				return rule, false
+			}
+			rule.Selector = selector
+		case "groups":
+			rule.Groups = splitList(v)
+		default:
+			log.Printf("Warning: %s has unknown option %q", key, opt)
+		}
+	}
+	if len(rule.Namespaces) == 0 && rule.Selector == nil && len(rule.Categories) == 0 {
+		log.Printf("Warning: %s matches no tiles, skipping", key)
+		return rule, false
+	}
+	if len(rule.Groups) == 0 {
+		log.Printf("Warning: %s has no groups, so its tiles are hidden from everyone", key)
+	}
+	return rule, true
+}
+
+// parseVisibility reads every visibility-* and group-* key from ConfigMap
+// data, except the group-by setting. Group members are logins separated by
+// commas or newlines.
+func parseVisibility(data map[string]string) Visibility {
+	var v Visibility
+	for key, value := range data {
+		switch {
+		case strings.HasPrefix(key, visibilityKeyPrefix):
+			if rule, ok := parseVisibilityRule(key, value); ok {
+				v.Rules = append(v.Rules, rule)
+			}
+		case strings.HasPrefix(key, groupKeyPrefix) && key != "group-by":
+			if v.Members == nil {
+				v.Members = make(map[string][]string)
+			}
+			name := strings.TrimPrefix(key, groupKeyPrefix)
+			v.Members[name] = splitList(strings.ReplaceAll(value, "\n", ","))
+		}
+	}
+	slices.SortFunc(v.Rules, func(a, b VisibilityRule) int { return strings.Compare(a.Name, b.Name) })
+	return v
+}
+
+// matchesIngress reports whether the rule applies to an ingress.
+func (rule VisibilityRule) matchesIngress(info IngressInfo) bool {
+	if len(rule.Namespaces) == 0 && rule.Selector == nil {
+		return false
+	}
+	if len(rule.Namespaces) > 0 && !slices.Contains(rule.Namespaces, info.Namespace) {
+		return false
+	}
+	return rule.Selector == nil || rule.Selector.Matches(labels.Set(info.Labels))
+}
+
+// matchesBookmark reports whether the rule applies to a bookmark.
+func (rule VisibilityRule) matchesBookmark(b Bookmark) bool {
+	return slices.Contains(rule.Categories, categoryID(b.Category))
+}
+
+// viewerGroups returns the groups of the viewer with the given login: those
+// the authenticating proxy sent in AUTH_GROUPS_HEADER, plus every
+// group-<name> key listing the login. Like the login, the header is only
+// believed from TRUSTED_PROXIES (see resolveViewer).
+func (s *Server) viewerGroups(r *http.Request, login string, v Visibility) []string {
+	var groups []string
+	if s.authGroupsHeader != "" && s.fromTrustedProxy(r) {
+		groups = splitList(r.Header.Get(s.authGroupsHeader))
+	}
+	if login == "" {
+		return groups
+	}
+	for name, members := range v.Members {
+		if slices.ContainsFunc(members, func(m string) bool { return strings.EqualFold(m, login) }) {
+			groups = append(groups, name)
+		}
+	}
+	return groups
+}
+
+// inAnyGroup reports whether groups shares a group with allowed; group
+// names are compared without regard to case.
+func inAnyGroup(groups, allowed []string) bool {
+	return slices.ContainsFunc(allowed, func(a string) bool {
+		return slices.ContainsFunc(groups, func(g string) bool { return strings.EqualFold(g, a) })
+	})
+}
+
+// restriction is one source of the groups that alone may see a tile.
+type restriction struct {
+	Source string // e.g. "visibility-admin" or the groups annotation
+	Groups []string
+}
+
+// ingressRestrictions returns what restricts who may see an ingress.
+func ingressRestrictions(v Visibility, info IngressInfo) []restriction {
+	var restrictions []restriction
+	if len(info.Groups) > 0 {
+		restrictions = append(restrictions, restriction{Source: GroupsAnnotation, Groups: info.Groups})
+	}
+	for _, rule := range v.Rules {
+		if rule.matchesIngress(info) {
+			restrictions = append(restrictions, restriction{Source: visibilityKeyPrefix + rule.Name, Groups: rule.Groups})
+		}
+	}
+	return restrictions
+}
+
+// bookmarkRestrictions returns what restricts who may see a bookmark.
+func bookmarkRestrictions(v Visibility, b Bookmark) []restriction {
+	var restrictions []restriction
+	if len(b.Groups) > 0 {
+		restrictions = append(restrictions, restriction{Source: "groups option", Groups: b.Groups})
+	}
+	for _, rule := range v.Rules {
+		if rule.matchesBookmark(b) {
+			restrictions = append(restrictions, restriction{Source: visibilityKeyPrefix + rule.Name, Groups: rule.Groups})
+		}
+	}
+	return restrictions
+}
+
+// restrictTiles drops the tiles the viewer with the given login may not
+// see. A tile is visible when the viewer is in one of the groups of its
+// gohome.stringer.sh/groups annotation or groups option, and of every
+// visibility rule matching it; anyone may see a tile nothing restricts.
+// Requests with the API token see everything.
+func (s *Server) restrictTiles(r *http.Request, login string, config *Config, apps, services []IngressInfo, bookmarks []Bookmark) ([]IngressInfo, []IngressInfo, []Bookmark) {
+	v := config.Visibility
+	if s.validToken(r) {
+		return apps, services, bookmarks
+	}
+	groups := s.viewerGroups(r, login, v)
+	excluded := func(rs []restriction) bool {
+		return slices.ContainsFunc(rs, func(r restriction) bool { return !inAnyGroup(groups, r.Groups) })
+	}
+	hideIngress := func(info IngressInfo) bool { return excluded(ingressRestrictions(v, info)) }
+	apps = slices.DeleteFunc(apps, hideIngress)
+	services = slices.DeleteFunc(services, hideIngress)
+	bookmarks = slices.DeleteFunc(bookmarks, func(b Bookmark) bool { return excluded(bookmarkRestrictions(v, b)) })
+	return apps, services, bookmarks
+}
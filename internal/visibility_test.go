@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParseVisibilityRule(t *testing.T) {
+	tests := []struct {
+		name, value string
+		ok          bool
+		namespaces  []string
+		categories  []string
+		groups      []string
+		selector    string
+	}{
+		{"namespaces", "namespaces=argocd, portainer|groups=admins", true, []string{"argocd", "portainer"}, nil, []string{"admins"}, ""},
+		{"categories", "categories=Home Lab|groups=admins,family", true, nil, []string{categoryID("Home Lab")}, []string{"admins", "family"}, ""},
+		{"labels", "labels=tier=internal|groups=ops", true, nil, nil, []string{"ops"}, "tier=internal"},
+		{"no groups", "namespaces=argocd", true, []string{"argocd"}, nil, nil, ""},
+		{"unknown option", "namespaces=argocd|colour=red|groups=admins", true, []string{"argocd"}, nil, []string{"admins"}, ""},
+		{"no tiles", "groups=admins", false, nil, nil, []string{"admins"}, ""},
+		{"bad labels", "labels=tier in (|groups=admins", false, nil, nil, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := parseVisibilityRule("visibility-admin", tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if rule.Name != "admin" {
+				t.Errorf("name %q, want admin", rule.Name)
+			}
+			if !slices.Equal(rule.Namespaces, tt.namespaces) || !slices.Equal(rule.Categories, tt.categories) || !slices.Equal(rule.Groups, tt.groups) {
+				t.Errorf("got namespaces %q, categories %q, groups %q", rule.Namespaces, rule.Categories, rule.Groups)
+			}
+			var selector string
+			if rule.Selector != nil {
+				selector = rule.Selector.String()
+			}
+			if selector != tt.selector {
+				t.Errorf("selector %q, want %q", selector, tt.selector)
+			}
+		})
+	}
+}
+
+func TestParseVisibility(t *testing.T) {
+	v := parseVisibility(map[string]string{
+		"visibility-ops":   "namespaces=monitoring|groups=ops",
+		"visibility-admin": "namespaces=argocd|groups=admins",
+		"visibility-empty": "groups=admins",
+		"group-admins":     "alice@example.com, bob@example.com",
+		"group-ops":        "carol@example.com\ndave@example.com\n",
+		"group-by":         "category",
+		"bookmark-wiki":    "https://wiki.example.com",
+	})
+	var names []string
+	for _, rule := range v.Rules {
+		names = append(names, rule.Name)
+	}
+	if want := []string{"admin", "ops"}; !slices.Equal(names, want) {
+		t.Errorf("rules %q, want %q", names, want)
+	}
+	for group, want := range map[string][]string{
+		"admins": {"alice@example.com", "bob@example.com"},
+		"ops":    {"carol@example.com", "dave@example.com"},
+	} {
+		if got := v.Members[group]; !slices.Equal(got, want) {
+			t.Errorf("group %s has %q, want %q", group, got, want)
+		}
+	}
+	if _, ok := v.Members["by"]; ok {
+		t.Error("the group-by setting was read as a group")
+	}
+}
+
+func TestVisibilityRuleMatches(t *testing.T) {
+	rule, _ := parseVisibilityRule("visibility-admin", "namespaces=argocd|labels=tier=internal|categories=Admin|groups=admins")
+	ingresses := []struct {
+		name string
+		info IngressInfo
+		want bool
+	}{
+		{"namespace and labels", IngressInfo{Namespace: "argocd", Labels: map[string]string{"tier": "internal"}}, true},
+		{"other namespace", IngressInfo{Namespace: "default", Labels: map[string]string{"tier": "internal"}}, false},
+		{"other labels", IngressInfo{Namespace: "argocd", Labels: map[string]string{"tier": "public"}}, false},
+	}
+	for _, tt := range ingresses {
+		if got := rule.matchesIngress(tt.info); got != tt.want {
+			t.Errorf("%s: matchesIngress = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	bookmarks := []struct {
+		category string
+		want     bool
+	}{
+		{"Admin", true},
+		{"admin", true},
+		{"Media", false},
+		{"", false},
+	}
+	for _, tt := range bookmarks {
+		if got := rule.matchesBookmark(Bookmark{Category: tt.category}); got != tt.want {
+			t.Errorf("category %q: matchesBookmark = %v, want %v", tt.category, got, tt.want)
+		}
+	}
+
+	// A rule for bookmarks alone matches no ingresses.
+	bookmarksOnly, _ := parseVisibilityRule("visibility-admin", "categories=Admin|groups=admins")
+	if bookmarksOnly.matchesIngress(IngressInfo{Namespace: "argocd"}) {
+		t.Error("a rule without ingress filters matched an ingress")
+	}
+}
+
+func TestRestrictTiles(t *testing.T) {
+	config := &Config{Visibility: parseVisibility(map[string]string{
+		"visibility-admin": "namespaces=argocd|categories=Admin|groups=admins",
+		"group-admins":     "alice@example.com",
+	})}
+	apps := []IngressInfo{
+		{Name: "grafana", Namespace: "monitoring"},
+		{Name: "argocd", Namespace: "argocd"},
+		{Name: "jellyfin", Namespace: "media", Groups: []string{"family"}},
+	}
+	bookmarks := []Bookmark{
+		{Name: "Wiki", Category: "Docs"},
+		{Name: "Router", Category: "Admin"},
+		{Name: "Photos", Category: "Media", Groups: []string{"family"}},
+	}
+
+	tests := []struct {
+		name, login, remote string
+		headers             map[string]string
+		apps, bookmarks     []string
+	}{
+		{"anonymous", "", "192.0.2.7:40000", nil, []string{"grafana"}, []string{"Wiki"}},
+		{"group member", "Alice@example.com", "192.0.2.7:40000", nil, []string{"grafana", "argocd"}, []string{"Wiki", "Router"}},
+		{"not a member", "bob@example.com", "192.0.2.7:40000", nil, []string{"grafana"}, []string{"Wiki"}},
+		{"groups from the proxy", "bob@example.com", "127.0.0.1:40000", map[string]string{"X-Forwarded-Groups": "Family, admins"}, []string{"grafana", "argocd", "jellyfin"}, []string{"Wiki", "Router", "Photos"}},
+		{"spoofed groups", "bob@example.com", "192.0.2.7:40000", map[string]string{"X-Forwarded-Groups": "family,admins"}, []string{"grafana"}, []string{"Wiki"}},
+		{"API token", "", "192.0.2.7:40000", map[string]string{"Authorization": "Bearer secret"}, []string{"grafana", "argocd", "jellyfin"}, []string{"Wiki", "Router", "Photos"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{apiToken: "secret", authGroupsHeader: "X-Forwarded-Groups", trustedProxies: defaultTrustedProxies}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			gotApps, _, gotBookmarks := s.restrictTiles(r, tt.login, config, slices.Clone(apps), nil, slices.Clone(bookmarks))
+			var appNames, bookmarkNames []string
+			for _, app := range gotApps {
+				appNames = append(appNames, app.Name)
+			}
+			for _, b := range gotBookmarks {
+				bookmarkNames = append(bookmarkNames, b.Name)
+			}
+			if !slices.Equal(appNames, tt.apps) {
+				t.Errorf("apps %q, want %q", appNames, tt.apps)
+			}
+			if !slices.Equal(bookmarkNames, tt.bookmarks) {
+				t.Errorf("bookmarks %q, want %q", bookmarkNames, tt.bookmarks)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultWeatherTTL is how long a weather report is shown before it is
+	// fetched again.
+	defaultWeatherTTL = 15 * time.Minute
+	// weatherRetry is how long to wait after a failed fetch.
+	weatherRetry = 5 * time.Minute
+)
+
+// WeatherConfig configures the optional weather widget, from the ConfigMap
+// keys weather-city or weather-latitude/weather-longitude, weather-provider,
+// weather-units and weather-ttl. The OpenWeatherMap API key is read from
+// WEATHER_API_KEY so it can come from a Secret and never reaches the browser.
+type WeatherConfig struct {
+	City      string
+	Latitude  string
+	Longitude string
+	Provider  string // "open-meteo" (default, no key needed) or "openweathermap"
+	Units     string // "metric" (default) or "imperial"
+	TTL       time.Duration
+}
+
+// Enabled reports whether a location has been configured.
+func (c WeatherConfig) Enabled() bool {
+	return c.City != "" || (c.Latitude != "" && c.Longitude != "")
+}
+
+// cacheKey identifies a configuration so a ConfigMap change triggers a refetch.
+func (c WeatherConfig) cacheKey() string {
+	return c.Provider + "|" + c.City + "|" + c.Latitude + "|" + c.Longitude + "|" + c.Units
+}
+
+// Weather is the current conditions shown by the widget.
+type Weather struct {
+	Location    string
+	Temperature int
+	Unit        string // "°C" or "°F"
+	Description string
+	Icon        string // emoji
+	Fetched     time.Time
+}
+
+// WeatherFetcher fetches and caches the current weather server-side, so
+// a render never waits on the weather provider.
+type WeatherFetcher struct {
+	client  *http.Client
+	apiKey  string
+	reports widgetCache[*Weather] // keyed by WeatherConfig.cacheKey
+}
+
+// NewWeatherFetcher creates a fetcher using WEATHER_API_KEY for providers
+// that need one.
+func NewWeatherFetcher() *WeatherFetcher {
+	return &WeatherFetcher{
+		client: &http.Client{Timeout: 10 * time.Second},
+		apiKey: os.Getenv("WEATHER_API_KEY"),
+	}
+}
+
+// Name names the weather widget.
+func (f *WeatherFetcher) Name() string { return "weather" }
+
+// Interval is a minute, so a changed location shows up soon; the report
+// itself is refetched every weather-ttl.
+func (f *WeatherFetcher) Interval() time.Duration { return time.Minute }
+
+// Refresh fetches a new report once the last one is older than the TTL, or
+// weatherRetry after a failure. A changed configuration drops the old
+// report and fetches right away.
+func (f *WeatherFetcher) Refresh(ctx context.Context, config *Config) error {
+	cfg := config.Weather
+	var fetches []widgetFetch[*Weather]
+	if cfg.Enabled() {
+		ttl := cfg.TTL
+		if ttl <= 0 {
+			ttl = defaultWeatherTTL
+		}
+		fetches = append(fetches, widgetFetch[*Weather]{
+			key:   cfg.cacheKey(),
+			name:  "weather",
+			every: ttl,
+			retry: weatherRetry,
+			fetch: func(ctx context.Context) (*Weather, error) { return f.fetch(ctx, cfg) },
+		})
+	}
+	return f.reports.refresh(ctx, 20*time.Second, fetches)
+}
+
+// Render returns the Current report.
+func (f *WeatherFetcher) Render(config *Config, _ *Locale) any {
+	return f.Current(config.Weather)
+}
+
+// Current returns the cached report for cfg, or nil if there isn't one yet.
+func (f *WeatherFetcher) Current(cfg WeatherConfig) *Weather {
+	if f == nil || !cfg.Enabled() {
+		return nil
+	}
+	state, _ := f.reports.get(cfg.cacheKey())
+	return state.value
+}
+
+func (f *WeatherFetcher) fetch(ctx context.Context, cfg WeatherConfig) (*Weather, error) {
+	if cfg.Provider == "openweathermap" {
+		if f.apiKey == "" {
+			return nil, fmt.Errorf("weather-provider is openweathermap but WEATHER_API_KEY is not set")
+		}
+		return f.fetchOpenWeatherMap(ctx, cfg)
+	}
+	return f.fetchOpenMeteo(ctx, cfg)
+}
+
+// getJSON fetches rawURL and decodes the JSON response into v.
+func (f *WeatherFetcher) getJSON(ctx context.Context, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// fetchOpenMeteo uses the free Open-Meteo API, geocoding weather-city first
+// if no coordinates are configured.
+func (f *WeatherFetcher) fetchOpenMeteo(ctx context.Context, cfg WeatherConfig) (*Weather, error) {
+	lat, lon, name := cfg.Latitude, cfg.Longitude, cfg.City
+	if lat == "" || lon == "" {
+		var geo struct {
+			Results []struct {
+				Name      string  `json:"name"`
+				Latitude  float64 `json:"latitude"`
+				Longitude float64 `json:"longitude"`
+			} `json:"results"`
+		}
+		q := url.Values{"name": {cfg.City}, "count": {"1"}}
+		if err := f.getJSON(ctx, "https://geocoding-api.open-meteo.com/v1/search?"+q.Encode(), &geo); err != nil {
+			return nil, fmt.Errorf("geocoding %q: %w", cfg.City, err)
+		}
+		if len(geo.Results) == 0 {
+			return nil, fmt.Errorf("geocoding %q: no match", cfg.City)
+		}
+		lat = strconv.FormatFloat(geo.Results[0].Latitude, 'f', -1, 64)
+		lon = strconv.FormatFloat(geo.Results[0].Longitude, 'f', -1, 64)
+		name = geo.Results[0].Name
+	}
+
+	q := url.Values{
+		"latitude":  {lat},
+		"longitude": {lon},
+		"current":   {"temperature_2m,weather_code"},
+	}
+	unit := "°C"
+	if cfg.Units == "imperial" {
+		q.Set("temperature_unit", "fahrenheit")
+		unit = "°F"
+	}
+	var forecast struct {
+		Current struct {
+			Temperature float64 `json:"temperature_2m"`
+			WeatherCode int     `json:"weather_code"`
+		} `json:"current"`
+	}
+	if err := f.getJSON(ctx, "https://api.open-meteo.com/v1/forecast?"+q.Encode(), &forecast); err != nil {
+		return nil, err
+	}
+
+	description, icon := wmoWeather(forecast.Current.WeatherCode)
+	return &Weather{
+		Location:    name,
+		Temperature: int(math.Round(forecast.Current.Temperature)),
+		Unit:        unit,
+		Description: description,
+		Icon:        icon,
+		Fetched:     time.Now(),
+	}, nil
+}
+
+// fetchOpenWeatherMap uses the OpenWeatherMap current weather API.
+func (f *WeatherFetcher) fetchOpenWeatherMap(ctx context.Context, cfg WeatherConfig) (*Weather, error) {
+	q := url.Values{"appid": {f.apiKey}, "units": {"metric"}}
+	unit := "°C"
+	if cfg.Units == "imperial" {
+		q.Set("units", "imperial")
+		unit = "°F"
+	}
+	if cfg.Latitude != "" && cfg.Longitude != "" {
+		q.Set("lat", cfg.Latitude)
+		q.Set("lon", cfg.Longitude)
+	} else {
+		q.Set("q", cfg.City)
+	}
+
+	var current struct {
+		Name string `json:"name"`
+		Main struct {
+			Temp float64 `json:"temp"`
+		} `json:"main"`
+		Weather []struct {
+			ID          int    `json:"id"`
+			Description string `json:"description"`
+		} `json:"weather"`
+	}
+	if err := f.getJSON(ctx, "https://api.openweathermap.org/data/2.5/weather?"+q.Encode(), &current); err != nil {
+		// The URL carries the API key; don't let it reach the logs.
+		return nil, fmt.Errorf("openweathermap: %w", redactURLError(err))
+	}
+
+	w := &Weather{
+		Location:    current.Name,
+		Temperature: int(math.Round(current.Main.Temp)),
+		Unit:        unit,
+		Fetched:     time.Now(),
+	}
+	if len(current.Weather) > 0 {
+		w.Description = current.Weather[0].Description
+		w.Icon = owmIcon(current.Weather[0].ID)
+	}
+	return w, nil
+}
+
+// redactURLError strips the request URL from a client error.
+func redactURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
+
+// wmoWeather describes a WMO weather interpretation code as used by Open-Meteo.
+func wmoWeather(code int) (description, icon string) {
+	switch {
+	case code == 0:
+		return "clear", "☀️"
+	case code <= 2:
+		return "partly cloudy", "⛅"
+	case code == 3:
+		return "overcast", "☁️"
+	case code == 45 || code == 48:
+		return "fog", "🌫️"
+	case code >= 51 && code <= 57:
+		return "drizzle", "🌦️"
+	case code >= 61 && code <= 67, code >= 80 && code <= 82:
+		return "rain", "🌧️"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "snow", "🌨️"
+	case code >= 95:
+		return "thunderstorm", "⛈️"
+	}
+	return "unknown", "🌡️"
+}
+
+// owmIcon picks an emoji for an OpenWeatherMap condition ID.
+func owmIcon(id int) string {
+	switch {
+	case id >= 200 && id < 300:
+		return "⛈️"
+	case id >= 300 && id < 400:
+		return "🌦️"
+	case id >= 500 && id < 600:
+		return "🌧️"
+	case id >= 600 && id < 700:
+		return "🌨️"
+	case id >= 700 && id < 800:
+		return "🌫️"
+	case id == 800:
+		return "☀️"
+	case id == 801 || id == 802:
+		return "⛅"
+	case id > 802:
+		return "☁️"
+	}
+	return "🌡️"
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// bangKeyPrefix marks ConfigMap keys that define a bang shortcut:
+	//   bang-mdn: "https://developer.mozilla.org/search?q={query}"
+	bangKeyPrefix = "bang-"
+	// queryPlaceholder is replaced by the URL-escaped query in search-engine
+	// and bang templates.
+	queryPlaceholder = "{query}"
+)
+
+// searchEngines are the names accepted by search-engine in place of a URL
+// template.
+var searchEngines = map[string]string{
+	"duckduckgo": "https://duckduckgo.com/?q={query}",
+	"google":     "https://www.google.com/search?q={query}",
+	"bing":       "https://www.bing.com/search?q={query}",
+	"kagi":       "https://kagi.com/search?q={query}",
+	"startpage":  "https://www.startpage.com/do/search?q={query}",
+	"brave":      "https://search.brave.com/search?q={query}",
+}
+
+// defaultBangs are available without any configuration; bang-<name> keys add
+// to or override them.
+var defaultBangs = map[string]string{
+	"g":  "https://www.google.com/search?q={query}",
+	"yt": "https://www.youtube.com/results?search_query={query}",
+	"gh": "https://github.com/search?q={query}",
+	"w":  "https://en.wikipedia.org/w/index.php?search={query}",
+}
+
+// parseSearchEngine resolves a search-engine value, either one of
+// searchEngines or a URL template containing {query}.
+func parseSearchEngine(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if tmpl, ok := searchEngines[strings.ToLower(value)]; ok {
+		return tmpl
+	}
+	if !strings.Contains(value, queryPlaceholder) {
+		log.Printf("Warning: search-engine %q is neither a known engine nor a URL containing %s", value, queryPlaceholder)
+		return ""
+	}
+	return value
+}
+
+// parseBangs adds every bang-* key from ConfigMap data to bangs.
+func parseBangs(bangs map[string]string, data map[string]string) {
+	for key, value := range data {
+		name, ok := strings.CutPrefix(key, bangKeyPrefix)
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if name == "" || !strings.Contains(value, queryPlaceholder) {
+			log.Printf("Warning: %s must be a URL containing %s, skipping", key, queryPlaceholder)
+			continue
+		}
+		bangs[strings.ToLower(name)] = value
+	}
+}
+
+// expandQuery fills in a URL template, escaping the query for the path or
+// the query string depending on where the placeholder sits.
+func expandQuery(tmpl, query string) string {
+	escaped := url.QueryEscape(query)
+	if q := strings.Index(tmpl, "?"); q < 0 || strings.Index(tmpl, queryPlaceholder) < q {
+		escaped = url.PathEscape(query)
+	}
+	return strings.ReplaceAll(tmpl, queryPlaceholder, escaped)
+}
+
+// parseCommands parses the commands ConfigMap value, one quick action per
+// line as "name: URL template", e.g. "jira: https://jira.example.com/browse/{query}".
+// Blank lines and lines starting with # are ignored.
+func parseCommands(value string) map[string]string {
+	commands := make(map[string]string)
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, tmpl, _ := strings.Cut(line, ":")
+		name, tmpl = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(tmpl)
+		if name == "" || strings.ContainsAny(name, " \t!") || !strings.Contains(tmpl, queryPlaceholder) {
+			log.Printf("Warning: invalid command %q, want \"name: URL containing %s\"", line, queryPlaceholder)
+			continue
+		}
+		commands[name] = tmpl
+	}
+	return commands
+}
+
+// resolveCommand expands "name args" when name is a configured command.
+func resolveCommand(config *Config, query string) (string, bool) {
+	name, args, _ := strings.Cut(strings.TrimSpace(query), " ")
+	tmpl, ok := config.Commands[strings.ToLower(name)]
+	if !ok {
+		return "", false
+	}
+	return expandQuery(tmpl, strings.TrimSpace(args)), true
+}
+
+// resolveSearch returns where a search box query should go: "!name rest" uses
+// that bang, anything else the default engine. It returns false when there
+// is nowhere to send it.
+func resolveSearch(config *Config, query string) (string, bool) {
+	query = strings.TrimSpace(query)
+	if rest, ok := strings.CutPrefix(query, "!"); ok {
+		name, terms, _ := strings.Cut(rest, " ")
+		if tmpl, ok := config.Bangs[strings.ToLower(name)]; ok {
+			return expandQuery(tmpl, strings.TrimSpace(terms)), true
+		}
+	}
+	if config.SearchEngine == "" || query == "" {
+		return "", false
+	}
+	return expandQuery(config.SearchEngine, query), true
+}
+
+// goTileMinScore is the per-term score a tile needs before /go opens it
+// instead of searching the web: roughly a substring match on its name.
+const goTileMinScore = 300
+
+// handleGo serves /go?q=..., the search box's form target and a handy
+// browser keyword search. The query is resolved, in order, as a command
+// ("jira ABC-123"), a bang ("!gh gohome"), a tile that clearly matches, and
+// finally the default search engine. Without any destination the visitor
+// is sent back to the homepage.
+func (s *Server) handleGo(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	config, err := s.bookmarkManager.GetConfig(ctx)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	target, ok := resolveCommand(config, query)
+	if !ok && !strings.HasPrefix(query, "!") && query != "" {
+		apps, services, err := s.visibleTiles(ctx)
+		if err != nil {
+			log.Printf("Warning: /go could not load ingresses: %v", err)
+		}
+		apps, services, bookmarks := s.restrictTiles(r, s.resolveViewer(ctx, r), config, apps, services, config.Bookmarks)
+		results := searchItems(query, apps, services, bookmarks)
+		if len(results) > 0 && results[0].Score >= goTileMinScore*len(strings.Fields(query)) {
+			target, ok = results[0].URL, true
+		}
+	}
+	if !ok {
+		target, ok = resolveSearch(config, query)
+	}
+	if !ok {
+		target = "/"
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"sync"
+	"time"
+)
+
+// widgetConfigTimeout bounds reading the ConfigMap before a widget refresh.
+const widgetConfigTimeout = 30 * time.Second
+
+// Widget is a homepage panel showing data fetched in the background, such
+// as the weather, feed headlines or Prometheus stats, so that a page view
+// never waits on the service behind it. Its job calls Refresh every
+// Interval with the configuration of the moment; Render turns what was
+// fetched into what index.html shows as .Widgets.<Name>, where nil or an
+// empty slice leaves the panel out.
+type Widget interface {
+	// Name names the widget's job and its entry in PageData.Widgets.
+	Name() string
+	// Interval is how often Refresh runs. Widgets that refetch less often
+	// still re-read their configuration at this pace, so a change shows up
+	// soon.
+	Interval() time.Duration
+	// Refresh fetches what config asks for that is due. Failures of
+	// individual items are joined into the error.
+	Refresh(ctx context.Context, config *Config) error
+	// Render returns the template data for config from what was fetched.
+	Render(config *Config, locale *Locale) any
+}
+
+// widget returns w as a Widget, or nil for a widget its environment turns
+// off, rather than an interface holding a nil pointer.
+func widget[T any, P interface {
+	*T
+	Widget
+}](w P) Widget {
+	if w == nil {
+		return nil
+	}
+	return w
+}
+
+// widgetJob refreshes w every Interval with the current ConfigMap. A round
+// is skipped while the ConfigMap can't be read, so panels keep what they
+// have rather than being dropped as unconfigured.
+func (s *Server) widgetJob(w Widget) Job {
+	return Job{
+		Name:     w.Name(),
+		Interval: w.Interval(),
+		Run: func(ctx context.Context) error {
+			loadCtx, cancel := context.WithTimeout(ctx, widgetConfigTimeout)
+			config, err := s.bookmarkManager.GetConfig(loadCtx)
+			cancel()
+			if err != nil {
+				return nil
+			}
+			return w.Refresh(ctx, config)
+		},
+	}
+}
+
+// renderWidgets returns the template data of every widget, by name.
+func (s *Server) renderWidgets(config *Config, locale *Locale) map[string]any {
+	data := make(map[string]any, len(s.widgets))
+	for _, w := range s.widgets {
+		data[w.Name()] = w.Render(config, locale)
+	}
+	return data
+}
+
+// widgetCache keeps what a widget last fetched for each of its items, such
+// as each feed, and how the most recent attempt went. Unlike Cache it is
+// filled by the widget's job rather than on demand, and a failed fetch
+// keeps the last good value for the panel to show as stale. The zero value
+// is ready to use.
+type widgetCache[T any] struct {
+	mu    sync.Mutex
+	items map[string]widgetItem[T]
+}
+
+// widgetItem is what a widgetCache holds for one item.
+type widgetItem[T any] struct {
+	value     T         // from the last fetch that worked
+	err       error     // of the most recent fetch, nil if it worked
+	fetched   time.Time // last success, zero before the first
+	attempted time.Time // last attempt, successful or not
+}
+
+// errString returns the error of the most recent fetch for a panel, or ""
+// if it worked.
+func (i widgetItem[T]) errString() string {
+	if i.err == nil {
+		return ""
+	}
+	return i.err.Error()
+}
+
+// widgetFetch is an item for widgetCache.refresh to fetch.
+type widgetFetch[T any] struct {
+	key   string        // identifies the item, e.g. the feed URL
+	name  string        // names it in warnings, e.g. "feed News"
+	every time.Duration // how long after an attempt the item is due again
+	retry time.Duration // the same after a failed attempt; zero means every
+	fetch func(context.Context) (T, error)
+}
+
+// refresh concurrently fetches the items that are due, each within
+// timeout, keeps the values of those that worked and the errors of those
+// that didn't, and drops the items that aren't in fetches any more.
+// Failures are logged and joined into the error.
+func (c *widgetCache[T]) refresh(ctx context.Context, timeout time.Duration, fetches []widgetFetch[T]) error {
+	var (
+		errs []error
+		wg   sync.WaitGroup
+	)
+	keys := make(map[string]bool, len(fetches))
+	for _, f := range fetches {
+		keys[f.key] = true
+		c.mu.Lock()
+		item := c.items[f.key]
+		c.mu.Unlock()
+		wait := f.every
+		if item.err != nil && f.retry > 0 {
+			wait = f.retry
+		}
+		if time.Since(item.attempted) < wait {
+			continue
+		}
+
+		wg.Go(func() {
+			fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+			value, err := f.fetch(fetchCtx)
+			cancel()
+			if err != nil {
+				log.Printf("Warning: Could not fetch %s: %v", f.name, err)
+			}
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.items == nil {
+				c.items = make(map[string]widgetItem[T])
+			}
+			item := c.items[f.key]
+			item.err, item.attempted = err, time.Now()
+			if err == nil {
+				item.value, item.fetched = value, item.attempted
+			} else {
+				errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+			}
+			c.items[f.key] = item
+		})
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	maps.DeleteFunc(c.items, func(key string, _ widgetItem[T]) bool { return !keys[key] })
+	return errors.Join(errs...)
+}
+
+// get returns the item under key, or false if it hasn't been attempted.
+func (c *widgetCache[T]) get(key string) (widgetItem[T], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	return item, ok
+}
+
+// Len returns the number of items held.
+func (c *widgetCache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
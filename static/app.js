@@ -0,0 +1,718 @@
+// Update timestamp
+function updateTimestamp() {
+    const now = new Date();
+    const timestamp = now.toLocaleTimeString('en-US', {
+        hour12: false,
+        timeZoneName: 'short'
+    });
+    document.getElementById('timestamp').textContent = timestamp;
+}
+
+updateTimestamp();
+setInterval(updateTimestamp, 1000);
+
+// Translations
+//
+// The server renders every string in the visitor's language (see
+// internal/i18n.go) and passes the few this script needs as JSON. t() formats
+// them like fmt.Sprintf does for %s, %d and %[n]s.
+const messages = JSON.parse(document.getElementById('i18n')?.textContent || '{}');
+const lang = document.documentElement.lang || undefined;
+
+function t(key, ...args) {
+    let next = 0;
+    return (messages[key] || key).replace(/%(?:\[(\d+)\])?[sd]/g, (_, n) => String(args[n ? Number(n) - 1 : next++]));
+}
+
+// Clock widget
+//
+// The server renders the initial time in the configured timezone and
+// format; this keeps it ticking. The greeting is only re-worded as the day
+// moves between morning/afternoon/evening, keeping the name the server used.
+const clockWidget = document.getElementById('clock-widget');
+if (clockWidget) {
+    const timeZone = clockWidget.dataset.timezone || undefined;
+    const hour12 = clockWidget.hasAttribute('data-hour12');
+    const timeEl = document.getElementById('clock-time');
+    const dateEl = document.getElementById('clock-date');
+    const greetingEl = document.getElementById('clock-greeting');
+
+    const tickClock = () => {
+        const now = new Date();
+        timeEl.textContent = now.toLocaleTimeString(lang, { timeZone, hour12, hour: hour12 ? 'numeric' : '2-digit', minute: '2-digit' });
+        dateEl.textContent = now.toLocaleDateString(lang, { timeZone, weekday: 'long', day: 'numeric', month: 'long' });
+        if (greetingEl) {
+            // Mirrors greeting in internal/clock.go.
+            const hour = Number(now.toLocaleString('en-GB', { timeZone, hour: 'numeric', hourCycle: 'h23' }));
+            const greeting = t(hour < 5 ? 'greeting.night' : hour < 12 ? 'greeting.morning' : hour < 18 ? 'greeting.afternoon' : 'greeting.evening');
+            const name = clockWidget.dataset.name;
+            greetingEl.textContent = name ? t('greeting.named', greeting, name) : greeting;
+        }
+    };
+    setInterval(tickClock, 1000);
+}
+
+// setPreference stores a per-visitor preference in the signed session
+// cookie that the server reads on the next render (see internal/prefs.go).
+// Scripts can't touch that cookie, so changes are posted to /preferences,
+// one at a time so none is lost to another. Wait for the returned promise
+// before reloading.
+let preferenceQueue = Promise.resolve();
+
+function setPreference(name, value) {
+    preferenceQueue = preferenceQueue.then(() => fetch('/preferences', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ [name]: value }),
+    })).then(res => {
+        if (!res.ok) console.warn('gohome: saving preference failed:', res.status);
+    }).catch(err => console.warn('gohome: saving preference failed:', err));
+    return preferenceQueue;
+}
+
+// setListPreference and getListPreference store a list of strings. The
+// server renders the current lists into the page (see clientPreferences
+// in internal/prefs.go), and they are kept up to date here.
+const preferenceLists = JSON.parse(document.getElementById('preferences')?.textContent || 'null') || {};
+
+function setListPreference(name, items) {
+    preferenceLists[name] = items;
+    return setPreference(name, items);
+}
+
+function getListPreference(name) {
+    return [...(preferenceLists[name] || [])];
+}
+
+// Announcements
+//
+// Dismissing a banner remembers its ID so the server leaves it out next
+// time. Only IDs still on the page are kept, so the session doesn't grow
+// with every old announcement.
+document.querySelectorAll('[data-announcement]').forEach(banner => {
+    banner.querySelector('[data-dismiss]')?.addEventListener('click', () => {
+        const shown = Array.from(document.querySelectorAll('[data-announcement]'), b => b.dataset.announcement);
+        const dismissed = getListPreference('dismissed').filter(id => shown.includes(id));
+        setListPreference('dismissed', [...dismissed, banner.dataset.announcement]);
+        banner.remove();
+    });
+});
+
+// Theme toggle
+//
+// Cycles auto → light → dark → contrast and stores the choice in the session, which the
+// server reads to render the right data-theme on the next page load.
+const themeToggle = document.getElementById('theme-toggle');
+const themes = ['auto', 'light', 'dark', 'contrast'];
+
+if (themeToggle) {
+    themeToggle.addEventListener('click', () => {
+        const root = document.documentElement;
+        const next = themes[(themes.indexOf(root.dataset.theme) + 1) % themes.length];
+        root.dataset.theme = next;
+        setPreference('theme', next);
+        themeToggle.querySelector('.theme-toggle-label').textContent = t(`theme.${next}`);
+        themeToggle.title = t('controls.theme_title', t(`theme.${next}`));
+        themeToggle.setAttribute('aria-label', t('controls.theme', t(`theme.${next}`)));
+    });
+}
+
+// Palette picker
+//
+// Swaps the server-generated palette stylesheet in place and remembers the
+// choice in the session for the next server render.
+const palettePicker = document.getElementById('palette-picker');
+
+if (palettePicker) {
+    palettePicker.addEventListener('change', () => {
+        const palette = palettePicker.value;
+        document.getElementById('palette-css').href = `/theme/${palette}.css`;
+        setPreference('palette', palette);
+    });
+}
+
+// Group-by selector
+//
+// The server does the grouping (see internal/grouping.go), so a change just
+// stores the choice and reloads.
+const groupPicker = document.getElementById('group-picker');
+
+if (groupPicker) {
+    groupPicker.addEventListener('change', () => {
+        setPreference('group_by', groupPicker.value).then(() => location.reload());
+    });
+}
+
+// Layout toggle
+//
+// Flips between the tile grid and a compact list by swapping the layout-*
+// class on <body>, which is also what the server renders.
+const layoutToggle = document.getElementById('layout-toggle');
+
+if (layoutToggle) {
+    layoutToggle.addEventListener('click', () => {
+        const next = layoutToggle.dataset.layout === 'list' ? 'grid' : 'list';
+        document.body.classList.replace(`layout-${layoutToggle.dataset.layout}`, `layout-${next}`);
+        layoutToggle.dataset.layout = next;
+        layoutToggle.querySelector('.theme-toggle-icon').textContent = next === 'list' ? '☰' : '▦';
+        layoutToggle.querySelector('.theme-toggle-label').textContent = t(`layout.${next}`);
+        layoutToggle.setAttribute('aria-label', t('controls.layout', t(`layout.${next}`)));
+        setPreference('layout', next);
+    });
+}
+
+// Compact mode
+//
+// A touch-friendly single column with the search box at the bottom. The
+// server renders the compact class when it's forced with "compact: always";
+// with "auto" it follows the viewport width here.
+const compactMode = document.body.dataset.compact;
+const compactQuery = window.matchMedia('(max-width: 768px)');
+
+function applyCompact() {
+    document.body.classList.toggle('compact', compactMode === 'always' || (compactMode === 'auto' && compactQuery.matches));
+}
+
+if (compactMode) {
+    applyCompact();
+    compactQuery.addEventListener('change', applyCompact);
+}
+
+// Add loading animation for links
+document.querySelectorAll('a[target="_blank"]').forEach(link => {
+    link.addEventListener('click', function() {
+        this.style.opacity = '0.7';
+    });
+});
+
+// Collapsible groups
+//
+// Sections and bookmark categories are <details data-group="id"> elements.
+// The server renders their initial open state; toggling one saves the full
+// set of collapsed IDs to the session. While a search is active every group is
+// forced open so matches aren't hidden, without touching the saved state.
+const groups = Array.from(document.querySelectorAll('details[data-group]'));
+let searchExpanded = false;
+
+function saveCollapsed() {
+    const collapsed = groups.filter(g => g.dataset.collapsed === 'true').map(g => g.dataset.group);
+    setListPreference('collapsed', collapsed);
+}
+
+groups.forEach(group => {
+    group.dataset.collapsed = String(!group.open);
+    group.addEventListener('toggle', () => {
+        if (searchExpanded) return;
+        group.dataset.collapsed = String(!group.open);
+        saveCollapsed();
+    });
+});
+
+function setSearchExpanded(expanded) {
+    if (expanded === searchExpanded) return;
+    searchExpanded = expanded;
+    groups.forEach(group => {
+        group.open = expanded || group.dataset.collapsed !== 'true';
+    });
+    // toggle events fire asynchronously; keep ignoring them until they've run.
+    if (!expanded) {
+        searchExpanded = true;
+        setTimeout(() => { searchExpanded = false; }, 0);
+    }
+}
+
+// Search / filter
+//
+// Every card carries data-* attributes rendered by the server (name, host,
+// namespace, category, tags). Typing in the search box fuzzy-matches the query
+// against them using the same scoring as /api/v1/search, hides cards that
+// don't match, and highlights the best hit so Enter can launch it.
+const searchInput = document.getElementById('search');
+const searchForm = document.getElementById('search-form');
+const bangs = new Set(((searchForm && searchForm.dataset.bangs) || '').split(' ').filter(Boolean));
+const commands = new Set(((searchForm && searchForm.dataset.commands) || '').split(' ').filter(Boolean));
+let topHit = null;
+
+// launcherQuery reports whether the query starts with a configured bang such
+// as "!gh", or is a command with arguments such as "jira ABC-123", in which
+// case /go resolves it rather than it filtering tiles. A bare command name
+// still filters, so "jira" alone finds a Jira tile.
+function launcherQuery(query) {
+    const m = query.trim().match(/^(!?)(\S+)(\s+\S)?/);
+    if (m === null) return false;
+    const name = m[2].toLowerCase();
+    return m[1] ? bangs.has(name) : commands.has(name) && m[3] !== undefined;
+}
+
+// fuzzyScore mirrors fuzzyScore in internal/search.go: exact, prefix and
+// substring matches rank highest, otherwise the query's characters must
+// appear in order, with bonuses for runs and word starts. 0 means no match.
+function fuzzyScore(query, text) {
+    if (!query || !text) return 0;
+    if (text === query) return 1000;
+    if (text.startsWith(query)) return 700 - Math.min(text.length - query.length, 100);
+    const idx = text.indexOf(query);
+    if (idx >= 0) return 300 - Math.min(idx, 100);
+
+    let score = 100, ti = 0, run = 0;
+    for (const qc of query) {
+        let found = false;
+        while (ti < text.length) {
+            const atBoundary = ti === 0 || ' -_./'.includes(text[ti - 1]);
+            const tc = text[ti++];
+            if (tc === qc) {
+                found = true;
+                run++;
+                score += 10 * run;
+                if (atBoundary) score += 15;
+                break;
+            }
+            run = 0;
+            score--;
+        }
+        if (!found) return 0;
+    }
+    return Math.max(score, 1);
+}
+
+function cardFields(card) {
+    const d = card.dataset;
+    const fields = [[d.name, 3], [d.host, 1], [d.namespace, 1], [d.category, 1]];
+    (d.tags || '').split(' ').filter(Boolean).forEach(tag => fields.push([tag, 2]));
+    return fields.filter(([text]) => text).map(([text, weight]) => [text.toLowerCase(), weight]);
+}
+
+// scoreCard sums the best weighted score per term; every term must match.
+function scoreCard(card, terms) {
+    const fields = cardFields(card);
+    let total = 0;
+    for (const term of terms) {
+        let best = 0;
+        for (const [text, weight] of fields) {
+            best = Math.max(best, fuzzyScore(term, text) * weight);
+        }
+        if (best === 0) return 0;
+        total += best;
+    }
+    return total;
+}
+
+function applyFilter() {
+    const terms = launcherQuery(searchInput.value) ? [] : searchInput.value.toLowerCase().split(/\s+/).filter(Boolean);
+    setSearchExpanded(terms.length > 0);
+    let visible = 0;
+    let bestScore = 0;
+    topHit = null;
+
+    document.querySelectorAll('.main .card').forEach(card => {
+        card.classList.remove('card--top');
+        const score = terms.length ? scoreCard(card, terms) : 1;
+        card.hidden = score === 0;
+        if (score === 0) return;
+        visible++;
+        if (terms.length && score > bestScore) {
+            bestScore = score;
+            topHit = card;
+        }
+    });
+
+    if (topHit) topHit.classList.add('card--top');
+
+    document.querySelectorAll('.main .category, .main .section').forEach(group => {
+        group.hidden = !group.querySelector('.card:not([hidden])');
+    });
+
+    document.getElementById('search-empty').hidden = visible > 0;
+}
+
+if (searchInput) {
+    searchInput.addEventListener('input', applyFilter);
+
+    searchInput.addEventListener('keydown', e => {
+        if (e.key === 'Escape') {
+            searchInput.value = '';
+            applyFilter();
+            searchInput.blur();
+        } else if (e.key === 'Enter' && topHit) {
+            // Enter launches the best match, like an app launcher. Holding a
+            // modifier opens it in a new tab instead of replacing this one.
+            e.preventDefault();
+            if (e.metaKey || e.ctrlKey) {
+                window.open(topHit.href, '_blank', 'noopener');
+            } else {
+                window.location.href = topHit.href;
+            }
+        } else if (e.key === 'Enter') {
+            // No tile matched: commands, bangs and, if configured, the
+            // default engine are resolved by the form's /go target.
+            if (!searchInput.value.trim() || (!launcherQuery(searchInput.value) && !('engine' in searchForm.dataset))) {
+                e.preventDefault();
+                return;
+            }
+            searchForm.target = e.metaKey || e.ctrlKey ? '_blank' : '';
+        }
+    });
+
+    // "/" focuses the search box from anywhere on the page, unless the user
+    // is already typing into a field.
+    document.addEventListener('keydown', e => {
+        const tag = document.activeElement && document.activeElement.tagName;
+        if (e.key === '/' && tag !== 'INPUT' && tag !== 'TEXTAREA') {
+            e.preventDefault();
+            searchInput.focus();
+            searchInput.select();
+        }
+    });
+}
+
+// Pinned favorites
+//
+// The ★ on each tile pins it to the Favorites row at the top of the page.
+// Pins live in the session the server reads when rendering, so toggling one
+// just updates it and reloads.
+function togglePin(tile) {
+    const id = tile.dataset.id;
+    const favorites = getListPreference('favorites');
+    const i = favorites.indexOf(id);
+    if (i >= 0) {
+        favorites.splice(i, 1);
+    } else {
+        favorites.push(id);
+    }
+    setListPreference('favorites', favorites).then(() => location.reload());
+}
+
+document.addEventListener('click', e => {
+    const pin = e.target.closest('[data-pin]');
+    if (!pin) return;
+    // The pin sits inside the tile's link; don't follow it.
+    e.preventDefault();
+    e.stopPropagation();
+    togglePin(pin.closest('[data-id]'));
+});
+
+// Per-visitor hiding
+//
+// The ✕ on each tile hides it for this browser only, without touching any
+// cluster annotations. The server leaves hidden tiles out of the page unless
+// the "N hidden" toggle in the header is on, in which case they are shown
+// dimmed and the same control restores them.
+function toggleHidden(tile) {
+    const id = tile.dataset.id;
+    const hidden = getListPreference('hidden');
+    const i = hidden.indexOf(id);
+    if (i >= 0) {
+        hidden.splice(i, 1);
+    } else {
+        hidden.push(id);
+    }
+    setListPreference('hidden', hidden).then(() => location.reload());
+}
+
+document.addEventListener('click', e => {
+    const hide = e.target.closest('[data-hide]');
+    if (!hide) return;
+    e.preventDefault();
+    e.stopPropagation();
+    toggleHidden(hide.closest('[data-id]'));
+});
+
+// QR codes
+//
+// The QR button on each tile opens a dialog with a scannable code for the
+// tile's URL, rendered by /qr, so a link on a wall display or someone else's
+// screen can be opened on a phone.
+const qrDialog = document.getElementById('qr-dialog');
+
+function showQR(tile) {
+    if (!qrDialog) return;
+    const url = tile.dataset.url;
+    document.getElementById('qr-dialog-title').textContent = tile.dataset.name;
+    document.getElementById('qr-dialog-url').textContent = url;
+    const image = document.getElementById('qr-dialog-image');
+    image.src = '/qr?url=' + encodeURIComponent(url);
+    image.alt = url;
+    qrDialog.showModal();
+}
+
+document.addEventListener('click', e => {
+    const qr = e.target.closest('[data-qr]');
+    if (!qr) return;
+    e.preventDefault();
+    e.stopPropagation();
+    showQR(qr.closest('[data-id]'));
+});
+
+qrDialog?.addEventListener('click', e => {
+    // A click on the backdrop lands on the dialog element itself.
+    if (e.target === qrDialog) qrDialog.close();
+});
+
+const hiddenToggle = document.getElementById('hidden-toggle');
+if (hiddenToggle) {
+    hiddenToggle.addEventListener('click', () => {
+        setPreference('show_hidden', hiddenToggle.getAttribute('aria-pressed') === 'true' ? '0' : '1').then(() => location.reload());
+    });
+}
+
+// Keyboard navigation
+//
+// Tiles rendered with data-nav can be moved between with the arrow keys or
+// j/k, opened with Enter (native link behaviour), and the first nine are
+// reachable directly via the number keys from data-shortcut. p pins or
+// unpins the focused tile, h hides or unhides it and q shows its QR code.
+function navTiles() {
+    return Array.from(document.querySelectorAll('[data-nav]:not([hidden])'))
+        .filter(tile => !tile.closest('[hidden]'));
+}
+
+// nearestTile picks the tile closest to `from` in the given direction, using
+// on-screen geometry so up/down work across grids of varying widths.
+function nearestTile(from, direction) {
+    const a = from.getBoundingClientRect();
+    let best = null, bestDistance = Infinity;
+    for (const tile of navTiles()) {
+        if (tile === from) continue;
+        const b = tile.getBoundingClientRect();
+        const dx = (b.left + b.width / 2) - (a.left + a.width / 2);
+        const dy = (b.top + b.height / 2) - (a.top + a.height / 2);
+        const inDirection =
+            (direction === 'up' && dy < -a.height / 2) ||
+            (direction === 'down' && dy > a.height / 2) ||
+            (direction === 'left' && dx < -a.width / 2 && Math.abs(dy) < a.height / 2) ||
+            (direction === 'right' && dx > a.width / 2 && Math.abs(dy) < a.height / 2);
+        if (!inDirection) continue;
+        // Weight the cross-axis so we prefer staying in the same column/row.
+        const distance = direction === 'up' || direction === 'down'
+            ? Math.abs(dy) + Math.abs(dx) * 2
+            : Math.abs(dx) + Math.abs(dy) * 2;
+        if (distance < bestDistance) {
+            best = tile;
+            bestDistance = distance;
+        }
+    }
+    return best;
+}
+
+function focusTile(tile) {
+    if (!tile) return;
+    document.body.classList.add('keyboard-nav');
+    tile.focus();
+    tile.scrollIntoView({ block: 'nearest' });
+}
+
+const navKeys = {
+    ArrowUp: 'up', ArrowDown: 'down', ArrowLeft: 'left', ArrowRight: 'right',
+    k: 'prev', j: 'next',
+};
+
+document.addEventListener('keydown', e => {
+    if (e.metaKey || e.ctrlKey || e.altKey) return;
+    const active = document.activeElement;
+    const typing = active && (active.tagName === 'INPUT' || active.tagName === 'TEXTAREA');
+    const direction = navKeys[e.key];
+
+    // From the search box, ArrowDown drops into the results.
+    if (typing) {
+        if (e.key === 'ArrowDown' && active === searchInput) {
+            e.preventDefault();
+            focusTile(topHit || navTiles()[0]);
+        }
+        return;
+    }
+
+    if (/^[1-9]$/.test(e.key)) {
+        const tile = document.querySelector(`[data-shortcut="${e.key}"]`);
+        if (tile && navTiles().includes(tile)) {
+            e.preventDefault();
+            tile.click();
+        }
+        return;
+    }
+
+    if (e.key === 'p' && active && active.matches('[data-nav][data-id]')) {
+        e.preventDefault();
+        togglePin(active);
+        return;
+    }
+
+    if (e.key === 'h' && active && active.matches('[data-nav][data-id]')) {
+        e.preventDefault();
+        toggleHidden(active);
+        return;
+    }
+
+    if (e.key === 'q' && active && active.matches('[data-nav][data-id]')) {
+        e.preventDefault();
+        showQR(active);
+        return;
+    }
+
+    if (!direction) return;
+    e.preventDefault();
+
+    const tiles = navTiles();
+    const current = tiles.includes(active) ? active : null;
+    if (!current) {
+        focusTile(tiles[0]);
+        return;
+    }
+
+    if (direction === 'next' || direction === 'prev') {
+        const i = tiles.indexOf(current) + (direction === 'next' ? 1 : -1);
+        focusTile(tiles[Math.max(0, Math.min(tiles.length - 1, i))]);
+    } else {
+        focusTile(nearestTile(current, direction));
+    }
+});
+
+// Drag-and-drop ordering
+//
+// Tiles can be dragged within their own grid, except in Recently used and
+// Most used, whose order comes from clicks. Reordering Favorites is a
+// per-visitor change saved with their preferences. Elsewhere, when the
+// server marks the page editable, dropping saves the new order for that group
+// (a section or bookmark category) via the API, which stores it server-side
+// so it follows the visitor to other devices. Dragging is disabled while a
+// search is filtering tiles.
+const editable = document.getElementById('main')?.hasAttribute('data-editable');
+let dragged = null;
+
+document.querySelectorAll('.grid > [data-id]').forEach(card => {
+    const group = card.closest('details[data-group]')?.dataset.group;
+    if (!group || group === 'recent' || group === 'most-used' || (group !== 'favorites' && !editable)) return;
+    card.draggable = true;
+
+    card.addEventListener('dragstart', e => {
+        if (searchInput && searchInput.value.trim()) {
+            e.preventDefault();
+            return;
+        }
+        dragged = card;
+        card.classList.add('card--dragging');
+        e.dataTransfer.effectAllowed = 'move';
+        e.dataTransfer.setData('text/uri-list', card.dataset.url);
+    });
+
+    card.addEventListener('dragend', () => {
+        card.classList.remove('card--dragging');
+        dragged = null;
+    });
+
+    card.addEventListener('dragover', e => {
+        if (!dragged || dragged === card || dragged.parentElement !== card.parentElement) return;
+        e.preventDefault();
+        const rect = card.getBoundingClientRect();
+        const after = document.body.classList.contains('layout-list') || document.body.classList.contains('compact')
+            ? e.clientY > rect.top + rect.height / 2
+            : e.clientX > rect.left + rect.width / 2;
+        card.parentElement.insertBefore(dragged, after ? card.nextSibling : card);
+    });
+
+    card.addEventListener('drop', e => {
+        if (!dragged) return;
+        e.preventDefault();
+        saveOrder(group, card.parentElement);
+    });
+});
+
+function saveOrder(group, grid) {
+    const ids = Array.from(grid.querySelectorAll(':scope > [data-id]')).map(c => c.dataset.id);
+    if (group === 'favorites') {
+        setListPreference('favorites', ids);
+        return;
+    }
+    fetch('/api/v1/order/' + encodeURIComponent(group), {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ ids }),
+    }).then(res => {
+        if (!res.ok) console.warn('gohome: saving order failed:', res.status);
+    });
+}
+
+// Kiosk mode
+//
+// /kiosk renders without controls and sets data-refresh on <body>. Every
+// interval the page is fetched again and the tiles and status swapped in
+// place, so a wall display never flashes blank or gets stuck on a browser
+// error page if the server is briefly unreachable; it just keeps showing the
+// last good data, marked stale.
+const kioskRefresh = Number(document.body.dataset.refresh);
+if (kioskRefresh > 0) {
+    const clock = document.getElementById('kiosk-clock');
+    const tick = () => {
+        const now = new Date();
+        clock.textContent = now.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit', hour12: false }) +
+            ' · ' + now.toLocaleDateString([], { weekday: 'short', day: 'numeric', month: 'short' });
+    };
+    tick();
+    setInterval(tick, 1000);
+
+    setInterval(async () => {
+        try {
+            const res = await fetch(location.href, { cache: 'no-store' });
+            if (!res.ok) throw new Error(res.status);
+            const next = new DOMParser().parseFromString(await res.text(), 'text/html');
+            for (const id of ['main', 'kiosk-health']) {
+                const current = document.getElementById(id);
+                const fresh = next.getElementById(id);
+                if (current && fresh) current.replaceWith(fresh);
+            }
+            document.body.classList.remove('kiosk--stale');
+        } catch (err) {
+            console.warn('gohome: kiosk refresh failed:', err);
+            document.body.classList.add('kiosk--stale');
+        }
+    }, kioskRefresh * 1000);
+}
+
+// Offline support
+//
+// The service worker (internal/serviceworker.js) keeps the last rendered
+// page and answers with it when the server can't be reached, adding
+// data-offline with the time it was rendered.
+if ('serviceWorker' in navigator) {
+    navigator.serviceWorker.register('/sw.js').catch((err) => console.warn('gohome: service worker registration failed:', err));
+}
+
+const offlineSince = document.body.dataset.offline;
+const offlineBanner = document.getElementById('offline-banner');
+if (offlineSince && offlineBanner) {
+    const rendered = new Date(offlineSince).toLocaleString(lang, { weekday: 'short', hour: '2-digit', minute: '2-digit' });
+    offlineBanner.textContent = t('offline.banner', rendered);
+    offlineBanner.hidden = false;
+    document.body.classList.add('offline');
+}
+
+// Cluster-unreachable banner
+//
+// Retry asks the server to re-list ingresses and reloads the page once the
+// cluster answers again; otherwise the banner says why it still can't.
+// Visitors who may not force a refresh just reload the page, which shows
+// the latest listing the server has.
+const degradedRetry = document.getElementById('degraded-retry');
+if (degradedRetry) {
+    const label = degradedRetry.textContent;
+    degradedRetry.addEventListener('click', async () => {
+        if (!degradedRetry.dataset.refresh) {
+            location.reload();
+            return;
+        }
+        degradedRetry.disabled = true;
+        degradedRetry.textContent = t('degraded.retrying');
+        try {
+            const resp = await fetch('/api/v1/refresh', { method: 'POST' });
+            const result = await resp.json();
+            if (resp.ok) {
+                location.reload();
+                return;
+            }
+            degradedRetry.previousElementSibling.textContent = t('degraded.failed', (result.errors || [result.error]).join('; '));
+        } catch (err) {
+            degradedRetry.previousElementSibling.textContent = t('degraded.failed', err.message);
+        }
+        degradedRetry.disabled = false;
+        degradedRetry.textContent = label;
+    });
+}